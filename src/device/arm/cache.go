@@ -0,0 +1,190 @@
+// Hand created file. DO NOT DELETE.
+// Cortex-M7 cache maintenance and memory barriers.
+
+//go:build cortexm
+// +build cortexm
+
+package arm
+
+import (
+	"runtime/volatile"
+	"unsafe"
+)
+
+// CACHE_MAINT_BASE is the base address of the cache maintenance operations of
+// the Cortex-M7. These registers do not exist on cores without a cache, so
+// they are only accessed when the data cache is enabled.
+const CACHE_MAINT_BASE = SCS_BASE + 0x0F50
+
+// DCACHE_LINE_SIZE is the size in bytes of a data cache line of the
+// Cortex-M7. Buffers that are shared with a DMA controller should be aligned
+// to and padded to a multiple of this size, as cache maintenance always
+// operates on full lines.
+const DCACHE_LINE_SIZE = 32
+
+// CacheMaint_Type provides the definitions for the cache maintenance
+// operations, see the ARMv7-M Architecture Reference Manual, B2.2.7.
+type CacheMaint_Type struct {
+	ICIALLU  volatile.Register32 // 0xF50: I-cache invalidate all to PoU
+	_        uint32              // reserved
+	ICIMVAU  volatile.Register32 // 0xF58: I-cache invalidate by address to PoU
+	DCIMVAC  volatile.Register32 // 0xF5C: D-cache invalidate by address to PoC
+	DCISW    volatile.Register32 // 0xF60: D-cache invalidate by set/way
+	DCCMVAU  volatile.Register32 // 0xF64: D-cache clean by address to PoU
+	DCCMVAC  volatile.Register32 // 0xF68: D-cache clean by address to PoC
+	DCCSW    volatile.Register32 // 0xF6C: D-cache clean by set/way
+	DCCIMVAC volatile.Register32 // 0xF70: D-cache clean and invalidate by address to PoC
+	DCCISW   volatile.Register32 // 0xF74: D-cache clean and invalidate by set/way
+}
+
+var CacheMaint = (*CacheMaint_Type)(unsafe.Pointer(uintptr(CACHE_MAINT_BASE)))
+
+// Cache size identification: CSSELR selects the cache that CCSIDR describes.
+var (
+	CCSIDR = (*volatile.Register32)(unsafe.Pointer(uintptr(SCS_BASE + 0x0D80)))
+	CSSELR = (*volatile.Register32)(unsafe.Pointer(uintptr(SCS_BASE + 0x0D84)))
+)
+
+const (
+	CCSIDR_NUMSETS_Pos       = 13
+	CCSIDR_NUMSETS_Msk       = 0x7FFF << CCSIDR_NUMSETS_Pos
+	CCSIDR_ASSOCIATIVITY_Pos = 3
+	CCSIDR_ASSOCIATIVITY_Msk = 0x3FF << CCSIDR_ASSOCIATIVITY_Pos
+
+	// Set and way fields of DCISW, DCCSW and DCCISW for the 4-way data cache
+	// with 32 byte lines of the Cortex-M7.
+	DCSW_WAY_Pos = 30
+	DCSW_WAY_Msk = 3 << DCSW_WAY_Pos
+	DCSW_SET_Pos = 5
+	DCSW_SET_Msk = 0x1FF << DCSW_SET_Pos
+)
+
+// DSB is a data synchronization barrier: it waits until all outstanding memory
+// accesses have completed.
+func DSB() {
+	Asm("dsb 0xF")
+}
+
+// DMB is a data memory barrier: memory accesses before it are observed before
+// memory accesses after it.
+func DMB() {
+	Asm("dmb 0xF")
+}
+
+// ISB is an instruction synchronization barrier: it flushes the pipeline so
+// that following instructions see the effect of earlier ones, such as a
+// change of the cache or MPU configuration.
+func ISB() {
+	Asm("isb 0xF")
+}
+
+// DCacheEnabled returns whether the data cache is enabled. It always returns
+// false on cores without a data cache.
+func DCacheEnabled() bool {
+	return SCB.CCR.HasBits(SCB_CCR_DC_Msk)
+}
+
+// EnableICache enables or disables the instruction cache, which is
+// invalidated first.
+func EnableICache(enable bool) {
+	if enable == SCB.CCR.HasBits(SCB_CCR_IC_Msk) {
+		return
+	}
+	DSB()
+	ISB()
+	if enable {
+		CacheMaint.ICIALLU.Set(0)
+		DSB()
+		ISB()
+		SCB.CCR.SetBits(SCB_CCR_IC_Msk)
+	} else {
+		SCB.CCR.ClearBits(SCB_CCR_IC_Msk)
+		CacheMaint.ICIALLU.Set(0)
+	}
+	DSB()
+	ISB()
+}
+
+// The loop counters of EnableDCache, kept out of the stack, which may be in the
+// cache lines that are being cleaned.
+var (
+	dcacheCcsidr volatile.Register32
+	dcacheSets   volatile.Register32
+	dcacheWays   volatile.Register32
+)
+
+// EnableDCache enables or disables the data cache. The cache is invalidated
+// before it is enabled, and cleaned and invalidated after it was disabled, so
+// that memory holds what the CPU wrote.
+func EnableDCache(enable bool) {
+	if enable == DCacheEnabled() {
+		return
+	}
+	CSSELR.Set(0) // level 1 data cache
+	DSB()
+	if enable {
+		ccsidr := CCSIDR.Get()
+		for sets := (ccsidr & CCSIDR_NUMSETS_Msk) >> CCSIDR_NUMSETS_Pos; sets != 0; sets-- {
+			for ways := (ccsidr & CCSIDR_ASSOCIATIVITY_Msk) >> CCSIDR_ASSOCIATIVITY_Pos; ways != 0; ways-- {
+				CacheMaint.DCISW.Set(((sets << DCSW_SET_Pos) & DCSW_SET_Msk) |
+					((ways << DCSW_WAY_Pos) & DCSW_WAY_Msk))
+			}
+		}
+		DSB()
+		SCB.CCR.SetBits(SCB_CCR_DC_Msk)
+	} else {
+		SCB.CCR.ClearBits(SCB_CCR_DC_Msk)
+		DSB()
+		dcacheCcsidr.Set(CCSIDR.Get())
+		dcacheSets.Set((dcacheCcsidr.Get() & CCSIDR_NUMSETS_Msk) >> CCSIDR_NUMSETS_Pos)
+		for dcacheSets.Get() != 0 {
+			dcacheWays.Set((dcacheCcsidr.Get() & CCSIDR_ASSOCIATIVITY_Msk) >> CCSIDR_ASSOCIATIVITY_Pos)
+			for dcacheWays.Get() != 0 {
+				CacheMaint.DCCISW.Set(((dcacheSets.Get() << DCSW_SET_Pos) & DCSW_SET_Msk) |
+					((dcacheWays.Get() << DCSW_WAY_Pos) & DCSW_WAY_Msk))
+				dcacheWays.Set(dcacheWays.Get() - 1)
+			}
+			dcacheSets.Set(dcacheSets.Get() - 1)
+		}
+	}
+	DSB()
+	ISB()
+}
+
+// CleanDCache writes the cache lines that hold the given memory range back to
+// memory, so that a DMA controller reading the memory sees what the CPU wrote.
+// Call it before starting a DMA transfer from memory.
+func CleanDCache(addr unsafe.Pointer, size uintptr) {
+	dcacheRange(&CacheMaint.DCCMVAC, addr, size)
+}
+
+// InvalidateDCache discards the cache lines that hold the given memory range,
+// so that the CPU reads what a DMA controller wrote. Call it after a DMA
+// transfer to memory has completed. Data written by the CPU to these lines
+// that was not cleaned yet is lost.
+func InvalidateDCache(addr unsafe.Pointer, size uintptr) {
+	dcacheRange(&CacheMaint.DCIMVAC, addr, size)
+}
+
+// CleanInvalidateDCache writes the cache lines that hold the given memory range
+// back to memory and discards them. Call it before starting a DMA transfer to
+// memory, so that no dirty line overwrites the transferred data later.
+func CleanInvalidateDCache(addr unsafe.Pointer, size uintptr) {
+	dcacheRange(&CacheMaint.DCCIMVAC, addr, size)
+}
+
+// dcacheRange applies a by-address cache maintenance operation to all cache
+// lines that overlap the given memory range.
+func dcacheRange(op *volatile.Register32, addr unsafe.Pointer, size uintptr) {
+	if size == 0 || !DCacheEnabled() {
+		return
+	}
+	start := uintptr(addr) &^ (DCACHE_LINE_SIZE - 1)
+	end := uintptr(addr) + size
+	DSB()
+	for line := start; line < end; line += DCACHE_LINE_SIZE {
+		op.Set(uint32(line))
+	}
+	DSB()
+	ISB()
+}
@@ -142,14 +142,14 @@ func (mpu *MPU_Type) Enable(enable bool) {
 	if enable {
 		mpu.CTRL.Set(MPU_CTRL_PRIVDEFENA_Msk | MPU_CTRL_ENABLE_Msk)
 		SystemControl.SHCSR.SetBits(SCB_SHCSR_MEMFAULTENA_Msk)
-		arm.Asm("dsb 0xF")
-		arm.Asm("isb 0xF")
-		enableDcache(true)
-		enableIcache(true)
+		arm.DSB()
+		arm.ISB()
+		arm.EnableDCache(true)
+		arm.EnableICache(true)
 	} else {
-		enableIcache(false)
-		enableDcache(false)
-		arm.Asm("dmb 0xF")
+		arm.EnableICache(false)
+		arm.EnableDCache(false)
+		arm.DMB()
 		SystemControl.SHCSR.ClearBits(SCB_SHCSR_MEMFAULTENA_Msk)
 		mpu.CTRL.ClearBits(MPU_CTRL_ENABLE_Msk)
 	}
@@ -180,75 +180,3 @@ func (mpu *MPU_Type) SetRASR(size RegionSize, access AccessPerms, ext Extension,
 		((uint32(size) << MPU_RASR_SIZE_Pos) & MPU_RASR_SIZE_Msk) |
 		MPU_RASR_ENABLE_Msk)
 }
-
-func enableIcache(enable bool) {
-	if enable != SystemControl.CCR.HasBits(SCB_CCR_IC_Msk) {
-		if enable {
-			arm.Asm("dsb 0xF")
-			arm.Asm("isb 0xF")
-			SystemControl.ICIALLU.Set(0)
-			arm.Asm("dsb 0xF")
-			arm.Asm("isb 0xF")
-			SystemControl.CCR.SetBits(SCB_CCR_IC_Msk)
-			arm.Asm("dsb 0xF")
-			arm.Asm("isb 0xF")
-		} else {
-			arm.Asm("dsb 0xF")
-			arm.Asm("isb 0xF")
-			SystemControl.CCR.ClearBits(SCB_CCR_IC_Msk)
-			SystemControl.ICIALLU.Set(0)
-			arm.Asm("dsb 0xF")
-			arm.Asm("isb 0xF")
-		}
-	}
-}
-
-var (
-	dcacheCcsidr volatile.Register32
-	dcacheSets   volatile.Register32
-	dcacheWays   volatile.Register32
-)
-
-func enableDcache(enable bool) {
-	if enable != SystemControl.CCR.HasBits(SCB_CCR_DC_Msk) {
-		if enable {
-			SystemControl.CSSELR.Set(0)
-			arm.Asm("dsb 0xF")
-			ccsidr := SystemControl.CCSIDR.Get()
-			sets := (ccsidr & SCB_CCSIDR_NUMSETS_Msk) >> SCB_CCSIDR_NUMSETS_Pos
-			for sets != 0 {
-				ways := (ccsidr & SCB_CCSIDR_ASSOCIATIVITY_Msk) >> SCB_CCSIDR_ASSOCIATIVITY_Pos
-				for ways != 0 {
-					SystemControl.DCISW.Set(
-						((sets << SCB_DCISW_SET_Pos) & SCB_DCISW_SET_Msk) |
-							((ways << SCB_DCISW_WAY_Pos) & SCB_DCISW_WAY_Msk))
-					ways--
-				}
-				sets--
-			}
-			arm.Asm("dsb 0xF")
-			SystemControl.CCR.SetBits(SCB_CCR_DC_Msk)
-			arm.Asm("dsb 0xF")
-			arm.Asm("isb 0xF")
-		} else {
-			SystemControl.CSSELR.Set(0)
-			arm.Asm("dsb 0xF")
-			SystemControl.CCR.ClearBits(SCB_CCR_DC_Msk)
-			arm.Asm("dsb 0xF")
-			dcacheCcsidr.Set(SystemControl.CCSIDR.Get())
-			dcacheSets.Set((dcacheCcsidr.Get() & SCB_CCSIDR_NUMSETS_Msk) >> SCB_CCSIDR_NUMSETS_Pos)
-			for dcacheSets.Get() != 0 {
-				dcacheWays.Set((dcacheCcsidr.Get() & SCB_CCSIDR_ASSOCIATIVITY_Msk) >> SCB_CCSIDR_ASSOCIATIVITY_Pos)
-				for dcacheWays.Get() != 0 {
-					SystemControl.DCCISW.Set(
-						((dcacheSets.Get() << SCB_DCCISW_SET_Pos) & SCB_DCCISW_SET_Msk) |
-							((dcacheWays.Get() << SCB_DCCISW_WAY_Pos) & SCB_DCCISW_WAY_Msk))
-					dcacheWays.Set(dcacheWays.Get() - 1)
-				}
-				dcacheSets.Set(dcacheSets.Get() - 1)
-			}
-			arm.Asm("dsb 0xF")
-			arm.Asm("isb 0xF")
-		}
-	}
-}
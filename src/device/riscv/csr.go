@@ -171,8 +171,8 @@ const (
 	PMPADDR15 CSR = 0x3BF // Physical memory protection address register 15.
 
 	// Machine Counter/Timers
-	mcycle         CSR = 0xB00 // Machine cycle counter.
-	minstret       CSR = 0xB02 // Machine instructions-retired counter.
+	MCYCLE         CSR = 0xB00 // Machine cycle counter.
+	MINSTRET       CSR = 0xB02 // Machine instructions-retired counter.
 	MHPMCOUNTER3   CSR = 0xB03 // Machine performance-monitoring counter 3.
 	MHPMCOUNTER4   CSR = 0xB04 // Machine performance-monitoring counter 4.
 	MHPMCOUNTER5   CSR = 0xB05 // Machine performance-monitoring counter 5.
@@ -235,6 +235,7 @@ const (
 	MHPMCOUNTER31H CSR = 0xB9F // Upper 32 bits of MHPMCOUNTER31, RV32I only.
 
 	// Machine Counter Setup
+	MHPMEVENT3  CSR = 0x323 // Machine performance-monitoring event selector 3.
 	MHPMEVENT4  CSR = 0x324 // Machine performance-monitoring event selector 4.
 	MHPMEVENT5  CSR = 0x325 // Machine performance-monitoring event selector 5.
 	MHPMEVENT6  CSR = 0x326 // Machine performance-monitoring event selector 6.
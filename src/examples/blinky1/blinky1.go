@@ -11,10 +11,10 @@ func main() {
 	led := machine.LED
 	led.Configure(machine.PinConfig{Mode: machine.PinOutput})
 	for {
-		led.Low()
+		led.Off()
 		time.Sleep(time.Millisecond * 500)
 
-		led.High()
+		led.On()
 		time.Sleep(time.Millisecond * 500)
 	}
 }
@@ -0,0 +1,10 @@
+//go:build fe310
+// +build fe310
+
+package main
+
+import "machine"
+
+func blockDevice() (machine.BlockDevice, error) {
+	return machine.Flash, nil
+}
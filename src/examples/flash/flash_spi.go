@@ -0,0 +1,22 @@
+//go:build !fe310
+// +build !fe310
+
+package main
+
+import "machine"
+
+// cs is the chip select pin of the flash chip. Change to whatever is in use on
+// your board.
+const cs = machine.Pin(3)
+
+func blockDevice() (machine.BlockDevice, error) {
+	machine.SPI0.Configure(machine.SPIConfig{
+		Frequency: 8000000,
+		Mode:      0,
+	})
+	flash := &machine.SPIFlash{Bus: machine.SPI0, CS: cs}
+	if err := flash.Configure(); err != nil {
+		return nil, err
+	}
+	return flash, nil
+}
@@ -0,0 +1,59 @@
+// Stores a boot counter in flash, the way a filesystem like LittleFS uses a
+// block device: erase a block, program it, read it back.
+//
+// On the HiFive1 this uses the free flash after the program (machine.Flash).
+// On other boards it uses an external SPI flash chip, see flash_spi.go.
+//
+// To use LittleFS (tinygo.org/x/tinyfs/littlefs) instead, pass the block
+// device to littlefs.New and configure it from machine.BlockDeviceInfo, and
+// call Lock/Unlock and machine.SyncBlockDevice from its hooks.
+package main
+
+import (
+	"encoding/binary"
+	"machine"
+	"time"
+)
+
+func main() {
+	time.Sleep(2 * time.Second) // wait for the serial console
+
+	dev, err := blockDevice()
+	if err != nil {
+		println("could not open flash:", err.Error())
+		return
+	}
+	geometry := machine.BlockDeviceInfo(dev)
+	println("read size:   ", geometry.ReadSize)
+	println("program size:", geometry.ProgramSize)
+	println("block size:  ", geometry.BlockSize)
+	println("block count: ", geometry.BlockCount)
+
+	if l, ok := dev.(machine.BlockDeviceLocker); ok {
+		l.Lock()
+		defer l.Unlock()
+	}
+
+	// Read the counter from the last block. Erased flash reads as 0xffffffff.
+	last := geometry.BlockCount - 1
+	var buf [4]byte
+	if _, err := dev.ReadAt(buf[:], last*geometry.BlockSize); err != nil {
+		println("read failed:", err.Error())
+		return
+	}
+	count := binary.LittleEndian.Uint32(buf[:]) + 1
+	println("boot count:  ", count)
+
+	binary.LittleEndian.PutUint32(buf[:], count)
+	if err := dev.EraseBlocks(last, 1); err != nil {
+		println("erase failed:", err.Error())
+		return
+	}
+	if _, err := dev.WriteAt(buf[:], last*geometry.BlockSize); err != nil {
+		println("write failed:", err.Error())
+		return
+	}
+	if err := machine.SyncBlockDevice(dev); err != nil {
+		println("sync failed:", err.Error())
+	}
+}
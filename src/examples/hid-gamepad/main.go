@@ -0,0 +1,36 @@
+package main
+
+import (
+	"machine"
+	"machine/usb/hid/gamepad"
+	"time"
+)
+
+func init() {
+	gamepad.Configure(gamepad.Config{Buttons: 4, Axes: 2, Hats: 1})
+}
+
+func main() {
+	button := machine.BUTTON
+	button.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+
+	gp := gamepad.New()
+
+	x := int16(0)
+	for {
+		pressed := !button.Get()
+		gp.SetButton(0, pressed)
+		if pressed {
+			gp.SetHat(0, gamepad.Up)
+		} else {
+			gp.SetHat(0, gamepad.Centered)
+		}
+
+		// Sweep the X axis from left to right.
+		x += 1024
+		gp.SetAxis(gamepad.X, x)
+		gp.SendReport()
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
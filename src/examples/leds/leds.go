@@ -0,0 +1,31 @@
+package main
+
+// Runs a light along the LEDs of the board, and lights all of them while a
+// button is pressed. It works unchanged on every board that lists its LEDs
+// and buttons in machine.LEDs and machine.Buttons.
+
+import (
+	"machine"
+	"time"
+)
+
+func main() {
+	for _, led := range machine.LEDs {
+		led.Configure()
+	}
+	for _, button := range machine.Buttons {
+		button.Configure()
+	}
+
+	for i := 0; ; i++ {
+		pressed := false
+		for _, button := range machine.Buttons {
+			pressed = pressed || button.Pressed()
+		}
+		for n, led := range machine.LEDs {
+			led.Set(pressed || n == i%len(machine.LEDs))
+		}
+
+		time.Sleep(time.Millisecond * 200)
+	}
+}
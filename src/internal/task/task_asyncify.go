@@ -127,3 +127,9 @@ func OnSystemStack() bool {
 	// If there is not an active goroutine, then this must be running on the system stack.
 	return Current() == nil
 }
+
+// StackUsage returns the most stack space the goroutine used so far, and the
+// size of its stack. The stack usage isn't tracked with asyncify.
+func (t *Task) StackUsage() (used, size uintptr) {
+	return 0, 0
+}
@@ -37,3 +37,9 @@ func OnSystemStack() bool {
 	// This scheduler does not do any stack switching.
 	return true
 }
+
+// StackUsage returns the most stack space the goroutine used so far, and the
+// size of its stack. There are no goroutine stacks: the program runs on the system stack.
+func (t *Task) StackUsage() (used, size uintptr) {
+	return 0, 0
+}
@@ -25,6 +25,9 @@ type state struct {
 	// When initializing the goroutine, the stackCanary constant is stored there.
 	// If the stack overflowed, the word will likely no longer equal stackCanary.
 	canaryPtr *uintptr
+
+	// stackSize is the size of the stack in bytes, starting at canaryPtr.
+	stackSize uintptr
 }
 
 // currentTask is the current running task, or nil if currently in the scheduler.
@@ -71,7 +74,8 @@ func (s *state) initialize(fn uintptr, args unsafe.Pointer, stackSize uintptr) {
 	// points to the first word of the stack. If it has changed between now and
 	// the next stack switch, there was a stack overflow.
 	s.canaryPtr = &stack[0]
-	*s.canaryPtr = stackCanary
+	s.stackSize = stackSize
+	fillStack(stack)
 
 	// Get a pointer to the top of the stack, where the initial register values
 	// are stored. They will be popped off the stack on the first stack switch
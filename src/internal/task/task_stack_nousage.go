@@ -0,0 +1,16 @@
+//go:build scheduler.tasks && !task.stackusage
+// +build scheduler.tasks,!task.stackusage
+
+package task
+
+// fillStack only sets the canary at the end of the stack, which detects a
+// stack overflow.
+func fillStack(stack []uintptr) {
+	stack[0] = stackCanary
+}
+
+// StackUsage returns 0, 0: the stack usage is only tracked when building with
+// the task.stackusage tag.
+func (t *Task) StackUsage() (used, size uintptr) {
+	return 0, 0
+}
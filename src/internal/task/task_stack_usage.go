@@ -0,0 +1,27 @@
+//go:build scheduler.tasks && task.stackusage
+// +build scheduler.tasks,task.stackusage
+
+package task
+
+import "unsafe"
+
+// fillStack fills the whole stack with the canary, so that StackUsage can find
+// the deepest word that was written. This makes starting a goroutine take time
+// proportional to its stack size, which is why it needs the task.stackusage
+// build tag.
+func fillStack(stack []uintptr) {
+	for i := range stack {
+		stack[i] = stackCanary
+	}
+}
+
+// StackUsage returns the most stack space the goroutine used so far, its high
+// water mark, and the size of its stack, in bytes.
+func (t *Task) StackUsage() (used, size uintptr) {
+	stack := unsafe.Slice(t.state.canaryPtr, t.state.stackSize/unsafe.Sizeof(uintptr(0)))
+	free := 0
+	for free < len(stack) && stack[free] == stackCanary {
+		free++
+	}
+	return uintptr(len(stack)-free) * unsafe.Sizeof(uintptr(0)), t.state.stackSize
+}
@@ -0,0 +1,82 @@
+//go:build !baremetal || sam || avr || nrf52 || nrf52840 || nrf52833 || mimxrt1062 || rp2040 || stm32f103 || stm32f4
+// +build !baremetal sam avr nrf52 nrf52840 nrf52833 mimxrt1062 rp2040 stm32f103 stm32f4
+
+package machine
+
+// AnalogMux reads the inputs of an analog multiplexer, such as the 8 channel
+// 74HC4051 or the 16 channel 74HC4067, through a single ADC input:
+//
+//	machine.InitADC()
+//	mux := machine.AnalogMux{
+//		ADC:    machine.ADC{Pin: machine.A0},
+//		Select: []machine.Pin{machine.D2, machine.D3, machine.D4}, // S0, S1, S2
+//	}
+//	mux.Configure(machine.ADCConfig{})
+//	pot := mux.Channel(5)
+//	value := pot.Get()
+//
+// The enable input of the multiplexer, if it has one, must be tied low.
+type AnalogMux struct {
+	ADC ADC
+
+	// Select are the pins wired to the select inputs, S0 first. The
+	// multiplexer has 1<<len(Select) channels.
+	Select []Pin
+
+	// SettlingTime is how long to wait after switching to another channel
+	// before the ADC samples it, in microseconds. The output of the
+	// multiplexer needs a few microseconds to follow an input with a high
+	// impedance, as it charges the sample capacitor of the ADC through the
+	// switch. Zero means 10µs.
+	SettlingTime uint32
+
+	channel  uint8
+	selected bool // whether the select pins are set to channel
+}
+
+// AnalogMuxChannel is an input of an AnalogMux. It reads like an ADC.
+type AnalogMuxChannel struct {
+	mux     *AnalogMux
+	channel uint8
+}
+
+// Configure configures the ADC input and the select pins.
+func (m *AnalogMux) Configure(config ADCConfig) {
+	m.ADC.Configure(config)
+	for _, pin := range m.Select {
+		pin.Configure(PinConfig{Mode: PinOutput})
+	}
+	m.selected = false
+}
+
+// Channels returns the number of channels of the multiplexer.
+func (m *AnalogMux) Channels() int {
+	return 1 << len(m.Select)
+}
+
+// Channel returns the given input of the multiplexer.
+func (m *AnalogMux) Channel(channel uint8) AnalogMuxChannel {
+	return AnalogMuxChannel{mux: m, channel: channel}
+}
+
+// Get selects the given channel, waits for the output of the multiplexer to
+// settle if it switched, and returns the value read by the ADC, like ADC.Get.
+func (m *AnalogMux) Get(channel uint8) uint16 {
+	if channel != m.channel || !m.selected {
+		for i, pin := range m.Select {
+			pin.Set(channel&(1<<i) != 0)
+		}
+		m.channel, m.selected = channel, true
+		settlingTime := m.SettlingTime
+		if settlingTime == 0 {
+			settlingTime = 10
+		}
+		sleepMicroseconds(int64(settlingTime))
+	}
+	return m.ADC.Get()
+}
+
+// Get returns the value of the channel read by the ADC of the multiplexer.
+func (c AnalogMuxChannel) Get() uint16 {
+	return c.mux.Get(c.channel)
+}
@@ -0,0 +1,360 @@
+package machine
+
+import "errors"
+
+// ATServer answers AT commands (ITU-T V.250, as used by modems and radio
+// modules) received on a serial port, so that a device can be controlled by a
+// host the same way:
+//
+//	at := machine.ATServer{Port: machine.Serial, Echo: true}
+//	at.Register("+LED", func(at *machine.ATServer, mode machine.ATMode, params []string) error {
+//		switch mode {
+//		case machine.ATRead:
+//			at.Respond("+LED: 1")
+//		case machine.ATTest:
+//			at.Respond("+LED: (0,1)")
+//		case machine.ATSet:
+//			if len(params) != 1 {
+//				return machine.ErrATParams
+//			}
+//			led.Set(params[0] == "1")
+//		}
+//		return nil
+//	})
+//	for {
+//		at.Update()
+//		time.Sleep(time.Millisecond)
+//	}
+//
+// A line holds one or more commands after the AT prefix: "AT+LED=1;+LED?".
+// Extended commands, which start with a character other than a letter or &,
+// end at a semicolon. Basic commands, a letter or & and a letter followed by a
+// number, such as E0 or &F, may also follow each other directly: "ATE0V1".
+// The line ends with a carriage return, and is answered with the responses of
+// its commands followed by OK, or by ERROR as soon as a command fails. AT,
+// ATE0 and ATE1 (echo off and on) are built in.
+type ATServer struct {
+	Port SerialPort
+
+	// Echo sends the characters received back, as terminals expect.
+	Echo bool
+
+	commands []atCommand
+	urcs     []string
+	line     [128]byte
+	n        int
+	overflow bool
+	params   [8]string
+	echo     [1]byte
+	out      [32]byte
+}
+
+// ATMode is the form in which a command is given.
+type ATMode uint8
+
+const (
+	ATExec ATMode = iota // AT+CMD
+	ATRead               // AT+CMD?
+	ATTest               // AT+CMD=?
+	ATSet                // AT+CMD=<params>
+)
+
+// ATHandler handles a command. Its responses are sent with Respond, before the
+// final OK that is sent when it returns nil. The params slice is only valid
+// during the call.
+type ATHandler func(at *ATServer, mode ATMode, params []string) error
+
+// ATCMEError is an error returned by an ATHandler to answer with an extended
+// error code, as "+CME ERROR: <code>", instead of ERROR.
+type ATCMEError int
+
+func (e ATCMEError) Error() string {
+	return string(appendDecimal([]byte("+CME ERROR: "), int(e)))
+}
+
+type atCommand struct {
+	name    string
+	handler ATHandler
+}
+
+var (
+	ErrATParams     = errors.New("machine: invalid AT command parameters")
+	errATNoCommand  = errors.New("machine: unknown AT command")
+	errATLineLength = errors.New("machine: AT command line too long")
+)
+
+const atMaxURCs = 8
+
+var atCRLF = []byte("\r\n")
+
+// Register adds a command such as "+CSQ" or "&F". Names are case insensitive.
+func (at *ATServer) Register(name string, handler ATHandler) {
+	at.commands = append(at.commands, atCommand{name: name, handler: handler})
+}
+
+// Respond sends an information response of a command. It must only be called
+// by an ATHandler.
+func (at *ATServer) Respond(response string) {
+	at.writeLine(response)
+}
+
+// URC queues an unsolicited result code, such as "+RING", which Update sends
+// between commands. It must not be called from an interrupt. When too many
+// codes are queued, the oldest ones are dropped.
+func (at *ATServer) URC(code string) {
+	if len(at.urcs) == atMaxURCs {
+		copy(at.urcs, at.urcs[1:])
+		at.urcs = at.urcs[:atMaxURCs-1]
+	}
+	at.urcs = append(at.urcs, code)
+}
+
+// Update reads the characters received since the last call, runs the commands
+// of every complete line, and sends the queued unsolicited result codes.
+func (at *ATServer) Update() {
+	for at.Port.Buffered() > 0 {
+		c, err := at.Port.ReadByte()
+		if err != nil {
+			break
+		}
+		if at.Echo {
+			at.echo[0] = c
+			at.Port.Write(at.echo[:])
+		}
+		switch {
+		case c == '\r':
+			n, overflow := at.n, at.overflow
+			at.n, at.overflow = 0, false
+			if overflow {
+				at.final(errATLineLength)
+			} else {
+				at.run(at.line[:n])
+			}
+		case c == '\n':
+		case c == 0x08 || c == 0x7f:
+			if at.n > 0 {
+				at.n--
+			}
+		case at.n == len(at.line):
+			at.overflow = true
+		default:
+			at.line[at.n] = c
+			at.n++
+		}
+	}
+	if at.n == 0 {
+		for _, code := range at.urcs {
+			at.writeLine(code)
+		}
+		at.urcs = at.urcs[:0]
+	}
+}
+
+// run runs the commands of a line.
+func (at *ATServer) run(line []byte) {
+	if len(line) < 2 || atUpper(line[0]) != 'A' || atUpper(line[1]) != 'T' {
+		// Not a command line: ignore it, like the line noise it probably is.
+		return
+	}
+	line = line[2:]
+	for {
+		segment, rest, more := cutCommand(line)
+		if err := at.runSegment(segment); err != nil {
+			at.final(err)
+			return
+		}
+		if !more {
+			break
+		}
+		line = rest
+	}
+	at.final(nil)
+}
+
+// runSegment runs the commands of a line between two semicolons: any basic
+// commands, and then at most one extended command.
+func (at *ATServer) runSegment(segment []byte) error {
+	for len(segment) > 0 {
+		n := atBasicLength(segment)
+		if n == 0 {
+			break
+		}
+		if err := at.runCommand(segment[:n]); err != nil {
+			return err
+		}
+		segment = segment[n:]
+	}
+	// The extended command, if any. An empty command does nothing.
+	return at.runCommand(segment)
+}
+
+// atBasicLength returns the length of the basic command at the start of cmd,
+// such as E0, &F or S0=1, or 0 if cmd starts with an extended command.
+func atBasicLength(cmd []byte) int {
+	i := 0
+	if cmd[0] == '&' {
+		i++
+	}
+	if i == len(cmd) || atUpper(cmd[i]) < 'A' || atUpper(cmd[i]) > 'Z' {
+		return 0
+	}
+	i++
+	i += atDigits(cmd[i:])
+	if i < len(cmd) && cmd[i] == '?' {
+		i++
+	} else if i < len(cmd) && cmd[i] == '=' {
+		i++
+		if i < len(cmd) && cmd[i] == '?' {
+			i++
+		} else {
+			i += atDigits(cmd[i:])
+		}
+	}
+	return i
+}
+
+func atDigits(b []byte) int {
+	n := 0
+	for n < len(b) && b[n] >= '0' && b[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// runCommand runs a single command, such as +CSQ=1,2.
+func (at *ATServer) runCommand(cmd []byte) error {
+	end := 0
+	for end < len(cmd) && cmd[end] != '=' && cmd[end] != '?' {
+		end++
+	}
+	name, args := cmd[:end], cmd[end:]
+	switch {
+	case len(name) == 0 && len(args) == 0:
+		return nil
+	case len(args) == 0 && atEqualFold(name, "E0"):
+		at.Echo = false
+		return nil
+	case len(args) == 0 && atEqualFold(name, "E1"):
+		at.Echo = true
+		return nil
+	}
+	for _, c := range at.commands {
+		if !atEqualFold(name, c.name) {
+			continue
+		}
+		switch {
+		case len(args) == 0:
+			return c.handler(at, ATExec, nil)
+		case string(args) == "?":
+			return c.handler(at, ATRead, nil)
+		case string(args) == "=?":
+			return c.handler(at, ATTest, nil)
+		case args[0] == '=':
+			params, err := at.parseParams(args[1:])
+			if err != nil {
+				return err
+			}
+			return c.handler(at, ATSet, params)
+		}
+		return ErrATParams
+	}
+	return errATNoCommand
+}
+
+// parseParams splits comma separated parameters, removing the quotes of
+// string parameters.
+func (at *ATServer) parseParams(args []byte) ([]string, error) {
+	params := at.params[:0]
+	for {
+		var param []byte
+		if len(args) > 0 && args[0] == '"' {
+			end := 1
+			for end < len(args) && args[end] != '"' {
+				end++
+			}
+			if end == len(args) {
+				return nil, ErrATParams
+			}
+			param, args = args[1:end], args[end+1:]
+		} else {
+			end := 0
+			for end < len(args) && args[end] != ',' {
+				end++
+			}
+			param, args = args[:end], args[end:]
+		}
+		if len(params) == cap(params) {
+			return nil, ErrATParams
+		}
+		params = append(params, string(param))
+		if len(args) == 0 {
+			return params, nil
+		}
+		if args[0] != ',' {
+			return nil, ErrATParams
+		}
+		args = args[1:]
+	}
+}
+
+// cutCommand splits the first command off a line, at a semicolon that isn't
+// inside a string.
+func cutCommand(line []byte) (cmd, rest []byte, more bool) {
+	quoted := false
+	for i, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			return line[:i], line[i+1:], true
+		}
+	}
+	return line, nil, false
+}
+
+// final sends the final result code of a line.
+func (at *ATServer) final(err error) {
+	switch err := err.(type) {
+	case nil:
+		at.writeLine("OK")
+	case ATCMEError:
+		at.writeLine(err.Error())
+	default:
+		at.writeLine("ERROR")
+	}
+}
+
+// writeLine sends s between line breaks. It is copied through a buffer, as a
+// conversion to a slice would allocate for every response.
+func (at *ATServer) writeLine(s string) {
+	at.Port.Write(atCRLF)
+	for len(s) > 0 {
+		n := copy(at.out[:], s)
+		at.Port.Write(at.out[:n])
+		s = s[n:]
+	}
+	at.Port.Write(atCRLF)
+}
+
+// atUpper returns the upper case of an ASCII letter, as commands are case
+// insensitive.
+func atUpper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// atEqualFold returns whether b and s are the same command name, ignoring
+// case.
+func atEqualFold(b []byte, s string) bool {
+	if len(b) != len(s) {
+		return false
+	}
+	for i := range b {
+		if atUpper(b[i]) != atUpper(s[i]) {
+			return false
+		}
+	}
+	return true
+}
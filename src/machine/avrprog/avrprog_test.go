@@ -0,0 +1,187 @@
+package avrprog
+
+import (
+	"bytes"
+	"testing"
+)
+
+// ispTarget answers ISP commands like an ATmega328P with a flash of 32kB.
+type ispTarget struct {
+	sync    int // commands to ignore before answering the enable command
+	reset   bool
+	flash   [32 << 10]byte
+	page    [128]byte
+	history [][4]byte
+}
+
+func (t *ispTarget) High() { t.reset = false }
+func (t *ispTarget) Low()  { t.reset = true }
+
+func (t *ispTarget) Tx(w, r []byte) error {
+	var cmd [4]byte
+	copy(cmd[:], w)
+	t.history = append(t.history, cmd)
+	r[0], r[1], r[2], r[3] = 0, cmd[0], cmd[1], cmd[2]
+	addr := (uint32(cmd[1])<<8 | uint32(cmd[2])) << 1
+	switch {
+	case cmd[0] == 0xac && cmd[1] == 0x53:
+		if t.sync > 0 {
+			t.sync--
+			r[2] = 0
+		}
+	case cmd[0] == 0x30:
+		r[3] = [3]byte{0x1e, 0x95, 0x0f}[cmd[2]]
+	case cmd[0] == 0x40 || cmd[0] == 0x48:
+		t.page[(addr+uint32(cmd[0]>>3&1))%uint32(len(t.page))] = cmd[3]
+	case cmd[0] == 0x4c:
+		copy(t.flash[addr:], t.page[:])
+	case cmd[0] == 0x20 || cmd[0] == 0x28:
+		r[3] = t.flash[addr+uint32(cmd[0]>>3&1)]
+	case cmd[0] == 0xf0:
+		r[3] = 0
+	}
+	return nil
+}
+
+func TestISP(t *testing.T) {
+	target := &ispTarget{sync: 2}
+	isp := ISP{Bus: target, Reset: target, PageSize: len(target.page)}
+	if err := isp.Enter(); err != nil {
+		t.Fatal(err)
+	}
+	if !target.reset {
+		t.Error("target isn't held in reset")
+	}
+	sig, err := isp.Signature()
+	if err != nil || sig != [3]byte{0x1e, 0x95, 0x0f} {
+		t.Errorf("Signature: got %x, %v", sig, err)
+	}
+	page := make([]byte, len(target.page))
+	for i := range page {
+		page[i] = byte(i * 3)
+	}
+	if err := isp.WriteFlashPage(0x100, page); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(target.flash[0x100:0x100+len(page)], page) {
+		t.Error("page wasn't written")
+	}
+	read := make([]byte, len(page))
+	if err := isp.ReadFlash(0x100, read); err != nil || !bytes.Equal(read, page) {
+		t.Errorf("ReadFlash: got %x, %v", read, err)
+	}
+	if err := isp.WriteFlashPage(0x101, page[:2]); err != errUnaligned {
+		t.Errorf("unaligned page: got %v", err)
+	}
+	for _, cmd := range target.history {
+		if cmd[0] == 0x4d {
+			t.Error("extended address sent to a small chip")
+		}
+	}
+	isp.Leave()
+	if target.reset {
+		t.Error("target still in reset")
+	}
+}
+
+func TestISPNoTarget(t *testing.T) {
+	target := &ispTarget{sync: 100}
+	isp := ISP{Bus: target, Reset: target}
+	if err := isp.Enter(); err != ErrNoTarget {
+		t.Errorf("got %v, want ErrNoTarget", err)
+	}
+}
+
+// updiTarget is a UPDI line with an echo, and a target behind it that
+// answers the instructions to read memory.
+type updiTarget struct {
+	memory [0x2000]byte
+	rx     []byte // bytes for the programmer to read
+	in     []byte // bytes received by the target
+	ptr    uint16
+	repeat int
+}
+
+func (t *updiTarget) Buffered() int { return len(t.rx) }
+
+func (t *updiTarget) ReadByte() (byte, error) {
+	b := t.rx[0]
+	t.rx = t.rx[1:]
+	return b, nil
+}
+
+func (t *updiTarget) Write(p []byte) (int, error) {
+	t.rx = append(t.rx, p...)
+	t.in = append(t.in, p...)
+	for len(t.in) > 0 && t.decode() {
+	}
+	return len(p), nil
+}
+
+// decode runs a complete instruction from t.in, if there is one.
+func (t *updiTarget) decode() bool {
+	if t.in[0] != updiSync {
+		t.in = t.in[1:]
+		return true
+	}
+	if len(t.in) < 2 {
+		return false
+	}
+	switch t.in[1] {
+	case updiSTPtr:
+		if len(t.in) < 4 {
+			return false
+		}
+		t.ptr = uint16(t.in[2]) | uint16(t.in[3])<<8
+		t.rx = append(t.rx, updiAck)
+		t.in = t.in[4:]
+	case updiRep:
+		if len(t.in) < 3 {
+			return false
+		}
+		t.repeat = int(t.in[2])
+		t.in = t.in[3:]
+	case updiLDInc:
+		for i := 0; i <= t.repeat; i++ {
+			t.rx = append(t.rx, t.memory[t.ptr])
+			t.ptr++
+		}
+		t.repeat = 0
+		t.in = t.in[2:]
+	default:
+		t.in = t.in[2:]
+	}
+	return true
+}
+
+func TestUPDIReadMemory(t *testing.T) {
+	target := &updiTarget{}
+	copy(target.memory[updiSignature:], []byte{0x1e, 0x92, 0x21})
+	for i := 0; i < 300; i++ {
+		target.memory[0x1400+i] = byte(i)
+	}
+	u := UPDI{Port: target}
+	sig, err := u.Signature()
+	if err != nil || sig != [3]byte{0x1e, 0x92, 0x21} {
+		t.Errorf("Signature: got %x, %v", sig, err)
+	}
+	data := make([]byte, 300)
+	if err := u.ReadMemory(0x1400, data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, target.memory[0x1400:0x1400+300]) {
+		t.Error("ReadMemory returned wrong data")
+	}
+}
+
+func TestUPDIKey(t *testing.T) {
+	target := &updiTarget{}
+	u := UPDI{Port: target}
+	if err := u.key(updiKeyNVMProgram); err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{updiSync, updiKey}, " gorPMVN"...)
+	if !bytes.Equal(u.buf[:10], want) {
+		t.Errorf("key sent as %q, want %q", u.buf[:10], want)
+	}
+}
@@ -0,0 +1,260 @@
+// Package avrprog programs companion AVR chips from a TinyGo board, over the
+// SPI based in-system programming interface (ISP) of the classic ATtiny and
+// ATmega chips, or over the single wire UPDI interface of the newer tinyAVR
+// and megaAVR 0-series chips.
+//
+// The package doesn't depend on machine: an ISP takes a machine.SPI and a
+// machine.Pin wired to the reset of the target, and a UPDI takes a UART.
+package avrprog
+
+import (
+	"errors"
+	"time"
+)
+
+// SPI is the bus the ISP commands are sent over, such as a machine.SPI
+// configured in mode 0 at less than a quarter of the clock of the target.
+type SPI interface {
+	Tx(w, r []byte) error
+}
+
+// ResetPin drives the reset of the target, such as a machine.Pin configured
+// as an output.
+type ResetPin interface {
+	High()
+	Low()
+}
+
+// ISP programs a classic AVR chip through its SPI programming interface:
+//
+//	spi := machine.SPI0
+//	spi.Configure(machine.SPIConfig{Frequency: 100e3})
+//	reset := machine.GPIO17
+//	reset.Configure(machine.PinConfig{Mode: machine.PinOutput})
+//	isp := avrprog.ISP{Bus: spi, Reset: reset, PageSize: 64}
+//	if err := isp.Enter(); err != nil {
+//		// no target
+//	}
+//	isp.ChipErase()
+//	for addr := 0; addr < len(firmware); addr += 64 {
+//		isp.WriteFlashPage(uint32(addr), firmware[addr:addr+64])
+//	}
+//	isp.Leave()
+//
+// Addresses are byte addresses, as in the hex files of the compilers.
+type ISP struct {
+	Bus   SPI
+	Reset ResetPin
+
+	// PageSize is the size of a flash page of the target in bytes, from its
+	// datasheet.
+	PageSize int
+
+	extended byte // the last extended address sent
+	cmd      [4]byte
+	resp     [4]byte
+}
+
+// Fuse selects a fuse byte, or the lock bits.
+type Fuse uint8
+
+const (
+	FuseLow Fuse = iota
+	FuseHigh
+	FuseExtended
+	Lock
+)
+
+// Read and write commands of the fuses, in the order of the Fuse constants.
+var (
+	ispReadFuse  = [...][2]byte{{0x50, 0x00}, {0x58, 0x08}, {0x50, 0x08}, {0x58, 0x00}}
+	ispWriteFuse = [...]byte{0xa0, 0xa8, 0xa4, 0xe0}
+)
+
+const (
+	ispEnterRetries = 4
+	ispBusyTimeout  = 50 * time.Millisecond
+)
+
+var (
+	ErrNoTarget  = errors.New("avrprog: target doesn't respond")
+	ErrTimeout   = errors.New("avrprog: timeout waiting for the target")
+	ErrPageSize  = errors.New("avrprog: data larger than a page")
+	errUnaligned = errors.New("avrprog: address isn't aligned")
+)
+
+// Enter holds the target in reset and enables its programming mode.
+func (p *ISP) Enter() error {
+	p.extended = 0xff
+	for i := 0; i < ispEnterRetries; i++ {
+		// The target must see a positive pulse on reset if SCK wasn't low
+		// when reset went low.
+		if i != 0 {
+			p.Reset.High()
+			time.Sleep(time.Millisecond)
+		}
+		p.Reset.Low()
+		time.Sleep(20 * time.Millisecond)
+		if err := p.command(0xac, 0x53, 0x00, 0x00); err != nil {
+			return err
+		}
+		// The target echoes the second byte when it is in sync.
+		if p.resp[2] == 0x53 {
+			return nil
+		}
+	}
+	return ErrNoTarget
+}
+
+// Leave releases the reset of the target, which starts its program.
+func (p *ISP) Leave() {
+	p.Reset.High()
+}
+
+// Signature returns the three signature bytes of the target, such as 1e 95 0f
+// for an ATmega328P.
+func (p *ISP) Signature() (sig [3]byte, err error) {
+	for i := range sig {
+		if err := p.command(0x30, 0x00, byte(i), 0x00); err != nil {
+			return sig, err
+		}
+		sig[i] = p.resp[3]
+	}
+	return sig, nil
+}
+
+// ChipErase erases the flash and EEPROM of the target, and clears its lock
+// bits.
+func (p *ISP) ChipErase() error {
+	if err := p.command(0xac, 0x80, 0x00, 0x00); err != nil {
+		return err
+	}
+	return p.wait()
+}
+
+// ReadFlash reads the flash of the target from the byte address addr.
+func (p *ISP) ReadFlash(addr uint32, data []byte) error {
+	for i := range data {
+		a := addr + uint32(i)
+		if err := p.setExtended(a); err != nil {
+			return err
+		}
+		// The low and high bytes of a word are read with separate commands.
+		word := a >> 1
+		if err := p.command(0x20|byte(a&1)<<3, byte(word>>8), byte(word), 0x00); err != nil {
+			return err
+		}
+		data[i] = p.resp[3]
+	}
+	return nil
+}
+
+// WriteFlashPage writes a page of flash at the byte address addr, which must
+// be aligned to a page. The page must be erased first, such as by ChipErase.
+// If data is shorter than a page, the rest of the page is left erased.
+func (p *ISP) WriteFlashPage(addr uint32, data []byte) error {
+	if len(data) > p.PageSize {
+		return ErrPageSize
+	}
+	if p.PageSize == 0 || addr%uint32(p.PageSize) != 0 {
+		return errUnaligned
+	}
+	if err := p.setExtended(addr); err != nil {
+		return err
+	}
+	for i, b := range data {
+		word := (addr + uint32(i)) >> 1
+		if err := p.command(0x40|byte(i&1)<<3, byte(word>>8), byte(word), b); err != nil {
+			return err
+		}
+	}
+	word := addr >> 1
+	if err := p.command(0x4c, byte(word>>8), byte(word), 0x00); err != nil {
+		return err
+	}
+	return p.wait()
+}
+
+// ReadEEPROM reads the EEPROM of the target from addr.
+func (p *ISP) ReadEEPROM(addr uint16, data []byte) error {
+	for i := range data {
+		a := addr + uint16(i)
+		if err := p.command(0xa0, byte(a>>8), byte(a), 0x00); err != nil {
+			return err
+		}
+		data[i] = p.resp[3]
+	}
+	return nil
+}
+
+// WriteEEPROM writes the EEPROM of the target from addr, a byte at a time.
+func (p *ISP) WriteEEPROM(addr uint16, data []byte) error {
+	for i, b := range data {
+		a := addr + uint16(i)
+		if err := p.command(0xc0, byte(a>>8), byte(a), b); err != nil {
+			return err
+		}
+		if err := p.wait(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadFuse reads a fuse byte or the lock bits.
+func (p *ISP) ReadFuse(fuse Fuse) (byte, error) {
+	c := ispReadFuse[fuse]
+	if err := p.command(c[0], c[1], 0x00, 0x00); err != nil {
+		return 0, err
+	}
+	return p.resp[3], nil
+}
+
+// WriteFuse writes a fuse byte or the lock bits. Wrong fuses can leave the
+// target without a working clock or without ISP, so check them twice.
+func (p *ISP) WriteFuse(fuse Fuse, value byte) error {
+	if err := p.command(0xac, ispWriteFuse[fuse], 0x00, value); err != nil {
+		return err
+	}
+	return p.wait()
+}
+
+// setExtended sends the extended address byte of chips with more than 128kB
+// of flash, when it changes.
+func (p *ISP) setExtended(addr uint32) error {
+	ext := byte(addr >> 17)
+	if ext == p.extended {
+		return nil
+	}
+	if p.extended == 0xff && ext == 0 {
+		// Small chips don't know the command; they start at zero anyway.
+		p.extended = 0
+		return nil
+	}
+	if err := p.command(0x4d, 0x00, ext, 0x00); err != nil {
+		return err
+	}
+	p.extended = ext
+	return nil
+}
+
+// wait polls the target until it has finished writing or erasing.
+func (p *ISP) wait() error {
+	deadline := time.Now().Add(ispBusyTimeout)
+	for {
+		if err := p.command(0xf0, 0x00, 0x00, 0x00); err != nil {
+			return err
+		}
+		if p.resp[3]&1 == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+	}
+}
+
+func (p *ISP) command(a, b, c, d byte) error {
+	p.cmd = [4]byte{a, b, c, d}
+	return p.Bus.Tx(p.cmd[:], p.resp[:])
+}
@@ -0,0 +1,436 @@
+package avrprog
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// UPDIPort is the UART that UPDI runs over, configured for 8 data bits, even
+// parity and 2 stop bits at up to 225kbaud. UPDI is a single wire: TX drives
+// the UPDI pin through a resistor of about 1kΩ and RX is connected to the pin
+// directly, so whatever is sent is received back as well.
+type UPDIPort interface {
+	io.Writer
+	ReadByte() (byte, error)
+	Buffered() int
+}
+
+// UPDI programs a tinyAVR 0/1/2-series or megaAVR 0-series chip through its
+// UPDI pin:
+//
+//	updi := avrprog.UPDI{Port: machine.UART1, Break: sendBreak}
+//	if err := updi.Enter(); err != nil {
+//		// no target
+//	}
+//	updi.ChipErase()
+//	for addr := 0; addr < len(firmware); addr += 64 {
+//		updi.WriteFlashPage(0x8000+uint16(addr), firmware[addr:addr+64])
+//	}
+//	updi.Leave()
+//
+// Addresses are in the data space of the target, where its flash starts at
+// 0x8000 on the tinyAVR chips and at 0x4000 on the megaAVR chips. Only the NVM
+// controller of these chips (version 0) is supported, not the one of the AVR
+// DA/DB chips.
+type UPDI struct {
+	Port UPDIPort
+
+	// Break, if set, holds the line low for longer than a frame to reset the
+	// UPDI interface of the target, for example by sending a zero byte at a
+	// quarter of the baud rate. Without it, a target with a confused UPDI
+	// interface can't be recovered until it is power cycled.
+	Break func() error
+
+	buf [12]byte
+}
+
+// Instructions and registers of UPDI.
+const (
+	updiSync  = 0x55
+	updiAck   = 0x40
+	updiLDS   = 0x04 // 16-bit address, 8-bit data
+	updiSTS   = 0x44 // 16-bit address, 8-bit data
+	updiLDInc = 0x24 // *ptr++, 8-bit data
+	updiSTInc = 0x64 // *ptr++, 8-bit data
+	updiSTPtr = 0x69 // ptr = 16-bit address
+	updiLDCS  = 0x80
+	updiSTCS  = 0xc0
+	updiRep   = 0xa0
+	updiKey   = 0xe0 // 64-bit key
+
+	updiCSCtrlA     = 0x02
+	updiCSCtrlB     = 0x03
+	updiASIKeyStat  = 0x07
+	updiASIResetReq = 0x08
+	updiASISysStat  = 0x0b
+
+	updiCtrlAIBDLY   = 0x80 // inter-byte delay, for reliable responses
+	updiCtrlBCCDETDS = 0x08 // no collision detection
+	updiCtrlBUPDIDIS = 0x04 // disable UPDI
+	updiResetSignat  = 0x59
+	updiKeyChipErase = 1 << 3
+	updiKeyNVMProg   = 1 << 4
+	updiSysLocked    = 1 << 0
+	updiSysNVMProg   = 1 << 3
+	updiSysRstSys    = 1 << 5
+
+	nvmCtrl       = 0x1000
+	nvmCtrlA      = nvmCtrl + 0x00
+	nvmStatus     = nvmCtrl + 0x02
+	nvmData       = nvmCtrl + 0x06
+	nvmAddr       = nvmCtrl + 0x08
+	nvmCmdERWP    = 0x03 // erase and write page
+	nvmCmdPBC     = 0x04 // page buffer clear
+	nvmCmdWFU     = 0x07 // write fuse
+	nvmStatBusy   = 0x03 // flash or EEPROM busy
+	nvmStatError  = 0x04
+	updiFuseBase  = 0x1280
+	updiSignature = 0x1100
+
+	updiTimeout = 100 * time.Millisecond
+)
+
+var (
+	updiKeyNVMProgram = []byte("NVMProg ")
+	updiKeyErase      = []byte("NVMErase")
+)
+
+var (
+	ErrLocked      = errors.New("avrprog: target is locked, erase it first")
+	ErrWrite       = errors.New("avrprog: target reported a write error")
+	errUPDIEcho    = errors.New("avrprog: UPDI line doesn't echo what was sent")
+	errUPDINoACK   = errors.New("avrprog: UPDI target didn't acknowledge")
+	errUPDIAddress = errors.New("avrprog: UPDI page crosses a 64kB boundary")
+)
+
+// Enter resets the UPDI interface of the target, and puts the target in
+// programming mode. It returns ErrLocked for a locked chip, which only
+// ChipErase can unlock.
+func (u *UPDI) Enter() error {
+	if u.Break != nil {
+		if err := u.Break(); err != nil {
+			return err
+		}
+	}
+	if err := u.stcs(updiCSCtrlB, updiCtrlBCCDETDS); err != nil {
+		return err
+	}
+	if err := u.stcs(updiCSCtrlA, updiCtrlAIBDLY); err != nil {
+		return err
+	}
+	if _, err := u.ldcs(updiCSCtrlA); err != nil {
+		return ErrNoTarget
+	}
+	status, err := u.ldcs(updiASISysStat)
+	if err != nil {
+		return err
+	}
+	if status&updiSysNVMProg != 0 {
+		return nil
+	}
+	if err := u.key(updiKeyNVMProgram); err != nil {
+		return err
+	}
+	if status, err := u.ldcs(updiASIKeyStat); err != nil {
+		return err
+	} else if status&updiKeyNVMProg == 0 {
+		return ErrNoTarget
+	}
+	if err := u.reset(); err != nil {
+		return err
+	}
+	status, err = u.waitSys(updiSysNVMProg)
+	if err != nil {
+		if status&updiSysLocked != 0 {
+			return ErrLocked
+		}
+		return err
+	}
+	return nil
+}
+
+// Leave resets the target, which starts its program, and disables UPDI until
+// the next break.
+func (u *UPDI) Leave() error {
+	if err := u.reset(); err != nil {
+		return err
+	}
+	return u.stcs(updiCSCtrlB, updiCtrlBUPDIDIS|updiCtrlBCCDETDS)
+}
+
+// ChipErase erases the flash and EEPROM of the target and unlocks it, and
+// leaves it in programming mode. It also works on a locked target, for which
+// Enter failed.
+func (u *UPDI) ChipErase() error {
+	if err := u.key(updiKeyErase); err != nil {
+		return err
+	}
+	if status, err := u.ldcs(updiASIKeyStat); err != nil {
+		return err
+	} else if status&updiKeyChipErase == 0 {
+		return ErrNoTarget
+	}
+	if err := u.reset(); err != nil {
+		return err
+	}
+	// The erase happens while the target comes out of reset, and unlocks it.
+	deadline := time.Now().Add(updiTimeout)
+	for {
+		status, err := u.ldcs(updiASISysStat)
+		if err != nil {
+			return err
+		}
+		if status&updiSysLocked == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+	}
+	return u.Enter()
+}
+
+// Signature returns the three signature bytes of the target, such as 1e 92 21
+// for an ATtiny402.
+func (u *UPDI) Signature() (sig [3]byte, err error) {
+	err = u.ReadMemory(updiSignature, sig[:])
+	return sig, err
+}
+
+// ReadMemory reads the data space of the target from addr, which covers its
+// flash, EEPROM, fuses and registers.
+func (u *UPDI) ReadMemory(addr uint16, data []byte) error {
+	for len(data) > 0 {
+		// A repeat counts to 256 bytes at most.
+		n := len(data)
+		if n > 256 {
+			n = 256
+		}
+		if err := u.setPointer(addr); err != nil {
+			return err
+		}
+		if n > 1 {
+			u.buf[0], u.buf[1], u.buf[2] = updiSync, updiRep, byte(n-1)
+			if err := u.send(u.buf[:3]); err != nil {
+				return err
+			}
+		}
+		u.buf[0], u.buf[1] = updiSync, updiLDInc
+		if err := u.send(u.buf[:2]); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			b, err := u.receive()
+			if err != nil {
+				return err
+			}
+			data[i] = b
+		}
+		data = data[n:]
+		addr += uint16(n)
+	}
+	return nil
+}
+
+// WriteFlashPage writes a page of flash at addr in the data space, which must
+// be aligned to a page of the target. The page is erased first.
+func (u *UPDI) WriteFlashPage(addr uint16, data []byte) error {
+	if int(addr)+len(data) > 0x10000 {
+		return errUPDIAddress
+	}
+	if err := u.nvmCommand(nvmCmdPBC); err != nil {
+		return err
+	}
+	if err := u.setPointer(addr); err != nil {
+		return err
+	}
+	for _, b := range data {
+		u.buf[0], u.buf[1], u.buf[2] = updiSync, updiSTInc, b
+		if err := u.send(u.buf[:2]); err != nil {
+			return err
+		}
+		if err := u.sendAcked(u.buf[2:3]); err != nil {
+			return err
+		}
+	}
+	return u.nvmCommand(nvmCmdERWP)
+}
+
+// WriteFuse writes fuse n, such as 2 for OSCCFG or 5 for SYSCFG0 on tinyAVR.
+func (u *UPDI) WriteFuse(n uint8, value byte) error {
+	addr := uint16(updiFuseBase) + uint16(n)
+	for _, w := range [...]struct {
+		addr  uint16
+		value byte
+	}{
+		{nvmAddr, byte(addr)},
+		{nvmAddr + 1, byte(addr >> 8)},
+		{nvmData, value},
+	} {
+		if err := u.sts(w.addr, w.value); err != nil {
+			return err
+		}
+	}
+	return u.nvmCommand(nvmCmdWFU)
+}
+
+// nvmCommand runs a command of the NVM controller, and waits for it.
+func (u *UPDI) nvmCommand(cmd byte) error {
+	if err := u.waitNVM(); err != nil {
+		return err
+	}
+	if err := u.sts(nvmCtrlA, cmd); err != nil {
+		return err
+	}
+	return u.waitNVM()
+}
+
+func (u *UPDI) waitNVM() error {
+	deadline := time.Now().Add(updiTimeout)
+	for {
+		status, err := u.lds(nvmStatus)
+		if err != nil {
+			return err
+		}
+		if status&nvmStatError != 0 {
+			return ErrWrite
+		}
+		if status&nvmStatBusy == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+	}
+}
+
+// reset pulses the system reset of the target.
+func (u *UPDI) reset() error {
+	if err := u.stcs(updiASIResetReq, updiResetSignat); err != nil {
+		return err
+	}
+	if err := u.stcs(updiASIResetReq, 0); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(updiTimeout)
+	for {
+		status, err := u.ldcs(updiASISysStat)
+		if err != nil {
+			return err
+		}
+		if status&updiSysRstSys == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+	}
+}
+
+// waitSys waits for a bit of ASI_SYS_STATUS to be set, and returns the last
+// status read.
+func (u *UPDI) waitSys(bit byte) (byte, error) {
+	deadline := time.Now().Add(updiTimeout)
+	for {
+		status, err := u.ldcs(updiASISysStat)
+		if err != nil || status&bit != 0 {
+			return status, err
+		}
+		if time.Now().After(deadline) {
+			return status, ErrTimeout
+		}
+	}
+}
+
+// key sends a 64-bit key, which goes out last byte first.
+func (u *UPDI) key(key []byte) error {
+	u.buf[0], u.buf[1] = updiSync, updiKey
+	for i := range key {
+		u.buf[2+i] = key[len(key)-1-i]
+	}
+	return u.send(u.buf[:2+len(key)])
+}
+
+func (u *UPDI) ldcs(reg byte) (byte, error) {
+	u.buf[0], u.buf[1] = updiSync, updiLDCS|reg
+	if err := u.send(u.buf[:2]); err != nil {
+		return 0, err
+	}
+	return u.receive()
+}
+
+func (u *UPDI) stcs(reg, value byte) error {
+	u.buf[0], u.buf[1], u.buf[2] = updiSync, updiSTCS|reg, value
+	return u.send(u.buf[:3])
+}
+
+func (u *UPDI) lds(addr uint16) (byte, error) {
+	u.buf[0], u.buf[1], u.buf[2], u.buf[3] = updiSync, updiLDS, byte(addr), byte(addr>>8)
+	if err := u.send(u.buf[:4]); err != nil {
+		return 0, err
+	}
+	return u.receive()
+}
+
+func (u *UPDI) sts(addr uint16, value byte) error {
+	u.buf[0], u.buf[1], u.buf[2], u.buf[3] = updiSync, updiSTS, byte(addr), byte(addr>>8)
+	if err := u.sendAcked(u.buf[:4]); err != nil {
+		return err
+	}
+	u.buf[0] = value
+	return u.sendAcked(u.buf[:1])
+}
+
+func (u *UPDI) setPointer(addr uint16) error {
+	u.buf[0], u.buf[1], u.buf[2], u.buf[3] = updiSync, updiSTPtr, byte(addr), byte(addr>>8)
+	return u.sendAcked(u.buf[:4])
+}
+
+// sendAcked sends b and waits for the ACK of the target.
+func (u *UPDI) sendAcked(b []byte) error {
+	if err := u.send(b); err != nil {
+		return err
+	}
+	ack, err := u.receive()
+	if err != nil {
+		return err
+	}
+	if ack != updiAck {
+		return errUPDINoACK
+	}
+	return nil
+}
+
+// send sends b, and reads back its echo.
+func (u *UPDI) send(b []byte) error {
+	// Drop anything left from an earlier failed command.
+	for u.Port.Buffered() > 0 {
+		u.Port.ReadByte()
+	}
+	if _, err := u.Port.Write(b); err != nil {
+		return err
+	}
+	for _, c := range b {
+		echo, err := u.receive()
+		if err != nil {
+			return err
+		}
+		if echo != c {
+			return errUPDIEcho
+		}
+	}
+	return nil
+}
+
+// receive waits for a byte from the target.
+func (u *UPDI) receive() (byte, error) {
+	deadline := time.Now().Add(updiTimeout)
+	for u.Port.Buffered() == 0 {
+		if time.Now().After(deadline) {
+			return 0, ErrTimeout
+		}
+		time.Sleep(10 * time.Microsecond)
+	}
+	return u.Port.ReadByte()
+}
@@ -0,0 +1,11 @@
+//go:build !scheduler.none
+// +build !scheduler.none
+
+package machine
+
+// goBackground runs f in a new goroutine, for drivers that keep doing
+// something in the background, and returns true.
+func goBackground(f func()) bool {
+	go f()
+	return true
+}
@@ -0,0 +1,10 @@
+//go:build scheduler.none
+// +build scheduler.none
+
+package machine
+
+// goBackground returns false without running f, as there are no goroutines
+// without a scheduler: the driver must be updated from the main loop instead.
+func goBackground(f func()) bool {
+	return false
+}
@@ -0,0 +1,82 @@
+// Package bits provides bit and byte manipulation helpers for the hot loops of
+// drivers, such as byte swapping RGB565 pixels for SPI displays or expanding
+// the bits of WS2812 pixel data into SPI waveforms.
+//
+// Where the CPU has instructions for these operations (REV, REV16 and RBIT on
+// Cortex-M) they are used, other targets use portable software versions.
+package bits
+
+// Reverse8 returns b with its bits in reverse order.
+func Reverse8(b uint8) uint8 {
+	return uint8(reverse32(uint32(b)) >> 24)
+}
+
+// Reverse16 returns v with its bits in reverse order.
+func Reverse16(v uint16) uint16 {
+	return uint16(reverse32(uint32(v)) >> 16)
+}
+
+// Reverse32 returns v with its bits in reverse order.
+func Reverse32(v uint32) uint32 {
+	return reverse32(v)
+}
+
+// ReverseBytes16 returns v with its bytes in reverse order.
+func ReverseBytes16(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// ReverseBytes32 returns v with its bytes in reverse order.
+func ReverseBytes32(v uint32) uint32 {
+	return reverseBytes32(v)
+}
+
+// SwapBytes16 swaps the bytes of each 16-bit word in buf, in place. This
+// converts little endian RGB565 pixels to the big endian order most SPI
+// displays expect, and back. A trailing odd byte is left unchanged.
+func SwapBytes16(buf []byte) {
+	i := 0
+	// Swap two pixels at a time.
+	for ; i+4 <= len(buf); i += 4 {
+		v := uint32(buf[i]) | uint32(buf[i+1])<<8 | uint32(buf[i+2])<<16 | uint32(buf[i+3])<<24
+		v = reverseBytes16x2(v)
+		buf[i], buf[i+1], buf[i+2], buf[i+3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+	}
+	if i+2 <= len(buf) {
+		buf[i], buf[i+1] = buf[i+1], buf[i]
+	}
+}
+
+// RGB565 packs an 8-bit per channel color into a 16-bit RGB565 pixel.
+func RGB565(r, g, b uint8) uint16 {
+	return uint16(r&0xf8)<<8 | uint16(g&0xfc)<<3 | uint16(b>>3)
+}
+
+// RGB565BigEndian packs an 8-bit per channel color into the two bytes of a
+// big endian RGB565 pixel, as sent to most SPI displays.
+func RGB565BigEndian(r, g, b uint8) (hi, lo byte) {
+	c := RGB565(r, g, b)
+	return byte(c >> 8), byte(c)
+}
+
+// ExpandBits3 expands every bit of src, most significant bit first, into three
+// bits of dst: 110 for a one and 100 for a zero. Sent over SPI at 2.4MHz this
+// produces the waveform of WS2812 LEDs. dst must be at least 3*len(src) bytes
+// long. It returns the number of bytes written to dst.
+func ExpandBits3(dst, src []byte) int {
+	n := 0
+	for _, b := range src {
+		v := expand3[b>>4]<<12 | expand3[b&0xf]
+		dst[n] = byte(v >> 16)
+		dst[n+1] = byte(v >> 8)
+		dst[n+2] = byte(v)
+		n += 3
+	}
+	return n
+}
+
+// expand3 holds the 12-bit expansion of every 4-bit value for ExpandBits3.
+var expand3 = [16]uint32{
+	0x924, 0x926, 0x934, 0x936, 0x9a4, 0x9a6, 0x9b4, 0x9b6,
+	0xd24, 0xd26, 0xd34, 0xd36, 0xda4, 0xda6, 0xdb4, 0xdb6,
+}
@@ -0,0 +1,18 @@
+//go:build cortexm
+// +build cortexm
+
+package bits
+
+import "device/arm"
+
+func reverseBytes32(v uint32) uint32 {
+	return uint32(arm.AsmFull("rev {}, {v}", map[string]interface{}{
+		"v": v,
+	}))
+}
+
+func reverseBytes16x2(v uint32) uint32 {
+	return uint32(arm.AsmFull("rev16 {}, {v}", map[string]interface{}{
+		"v": v,
+	}))
+}
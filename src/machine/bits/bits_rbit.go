@@ -0,0 +1,12 @@
+//go:build cortexm && !armv6m
+// +build cortexm,!armv6m
+
+package bits
+
+import "device/arm"
+
+func reverse32(v uint32) uint32 {
+	return uint32(arm.AsmFull("rbit {}, {v}", map[string]interface{}{
+		"v": v,
+	}))
+}
@@ -0,0 +1,13 @@
+//go:build !cortexm || armv6m
+// +build !cortexm armv6m
+
+package bits
+
+// Cortex-M0 and M0+ have no RBIT instruction.
+
+func reverse32(v uint32) uint32 {
+	v = v>>1&0x55555555 | v&0x55555555<<1
+	v = v>>2&0x33333333 | v&0x33333333<<2
+	v = v>>4&0x0f0f0f0f | v&0x0f0f0f0f<<4
+	return reverseBytes32(v)
+}
@@ -0,0 +1,12 @@
+//go:build !cortexm
+// +build !cortexm
+
+package bits
+
+func reverseBytes32(v uint32) uint32 {
+	return v<<24 | v&0xff00<<8 | v>>8&0xff00 | v>>24
+}
+
+func reverseBytes16x2(v uint32) uint32 {
+	return v&0x00ff00ff<<8 | v>>8&0x00ff00ff
+}
@@ -0,0 +1,82 @@
+package bits
+
+import (
+	"bytes"
+	mathbits "math/bits"
+	"testing"
+)
+
+func TestReverse(t *testing.T) {
+	for _, v := range []uint32{0, 1, 0x80000000, 0x12345678, 0xdeadbeef, 0xffffffff, 0x0f0f00ff} {
+		if got, want := Reverse32(v), mathbits.Reverse32(v); got != want {
+			t.Errorf("Reverse32(%#x) = %#x, want %#x", v, got, want)
+		}
+		if got, want := Reverse16(uint16(v)), mathbits.Reverse16(uint16(v)); got != want {
+			t.Errorf("Reverse16(%#x) = %#x, want %#x", uint16(v), got, want)
+		}
+		if got, want := ReverseBytes32(v), mathbits.ReverseBytes32(v); got != want {
+			t.Errorf("ReverseBytes32(%#x) = %#x, want %#x", v, got, want)
+		}
+		if got, want := ReverseBytes16(uint16(v)), mathbits.ReverseBytes16(uint16(v)); got != want {
+			t.Errorf("ReverseBytes16(%#x) = %#x, want %#x", uint16(v), got, want)
+		}
+	}
+	for i := 0; i < 256; i++ {
+		if got, want := Reverse8(uint8(i)), mathbits.Reverse8(uint8(i)); got != want {
+			t.Errorf("Reverse8(%#x) = %#x, want %#x", i, got, want)
+		}
+	}
+}
+
+func TestSwapBytes16(t *testing.T) {
+	buf := []byte{1, 2, 3, 4, 5, 6, 7}
+	SwapBytes16(buf)
+	if want := []byte{2, 1, 4, 3, 6, 5, 7}; !bytes.Equal(buf, want) {
+		t.Errorf("SwapBytes16: got %v, want %v", buf, want)
+	}
+}
+
+func TestRGB565(t *testing.T) {
+	if c := RGB565(0xff, 0xff, 0xff); c != 0xffff {
+		t.Errorf("RGB565(white) = %#x", c)
+	}
+	if c := RGB565(0xff, 0, 0); c != 0xf800 {
+		t.Errorf("RGB565(red) = %#x", c)
+	}
+	if c := RGB565(0, 0xff, 0); c != 0x07e0 {
+		t.Errorf("RGB565(green) = %#x", c)
+	}
+	if hi, lo := RGB565BigEndian(0, 0, 0xff); hi != 0x00 || lo != 0x1f {
+		t.Errorf("RGB565BigEndian(blue) = %#x %#x", hi, lo)
+	}
+}
+
+func TestExpandBits3(t *testing.T) {
+	src := []byte{0x00, 0xff, 0xa5}
+	dst := make([]byte, 3*len(src))
+	if n := ExpandBits3(dst, src); n != len(dst) {
+		t.Fatalf("ExpandBits3 returned %d, want %d", n, len(dst))
+	}
+	// Expand bit by bit for comparison.
+	var want []byte
+	var acc uint32
+	var nbits int
+	for _, b := range src {
+		for i := 7; i >= 0; i-- {
+			acc <<= 3
+			if b&(1<<i) != 0 {
+				acc |= 0b110
+			} else {
+				acc |= 0b100
+			}
+			nbits += 3
+			for nbits >= 8 {
+				want = append(want, byte(acc>>(nbits-8)))
+				nbits -= 8
+			}
+		}
+	}
+	if !bytes.Equal(dst, want) {
+		t.Errorf("ExpandBits3: got %x, want %x", dst, want)
+	}
+}
@@ -0,0 +1,95 @@
+package machine
+
+import stdio "io"
+
+// BlockDevice is the raw storage interface of flash memory: it can be read at
+// any offset, but must be erased before it can be written again. It is
+// implemented by the external SPI flash driver (SPIFlash) and is what
+// filesystems and key-value stores build upon.
+type BlockDevice interface {
+	// ReadAt reads the given number of bytes from the block device.
+	stdio.ReaderAt
+
+	// WriteAt writes the given number of bytes to the block device. The
+	// region must have been erased before.
+	stdio.WriterAt
+
+	// Size returns the number of bytes in this block device.
+	Size() int64
+
+	// WriteBlockSize returns the smallest unit of data that can be written
+	// at once. Writes must be aligned to, and a multiple of, this size.
+	WriteBlockSize() int64
+
+	// EraseBlockSize returns the smallest region that can be erased at once.
+	EraseBlockSize() int64
+
+	// EraseBlocks erases the given number of blocks, starting at block start.
+	// Erased memory reads as 0xff.
+	EraseBlocks(start, len int64) error
+}
+
+// Filesystems like LittleFS need a few more properties of the device than
+// BlockDevice provides, and hooks to flush caches and to serialize access.
+// Block devices that have them implement the following optional interfaces;
+// use BlockDeviceInfo to get the geometry with defaults filled in.
+
+// BlockDeviceReadSize is implemented by block devices that can only be read in
+// units larger than a byte.
+type BlockDeviceReadSize interface {
+	ReadBlockSize() int64
+}
+
+// BlockDeviceSyncer is implemented by block devices that buffer writes. Sync
+// returns once all data written before is stored.
+type BlockDeviceSyncer interface {
+	Sync() error
+}
+
+// BlockDeviceLocker is implemented by block devices that can be shared between
+// goroutines, for example because the bus they are on is shared. Operations on
+// the device must be done with the lock held.
+type BlockDeviceLocker interface {
+	Lock()
+	Unlock()
+}
+
+// BlockDeviceGeometry describes the layout of a block device in the terms
+// filesystems use. It maps directly to the LittleFS configuration:
+//
+//	geometry := machine.BlockDeviceInfo(dev)
+//	config := littlefs.Config{
+//		ReadSize:   uint32(geometry.ReadSize),
+//		ProgSize:   uint32(geometry.ProgramSize),
+//		BlockSize:  uint32(geometry.BlockSize),
+//		BlockCount: uint32(geometry.BlockCount),
+//	}
+type BlockDeviceGeometry struct {
+	ReadSize    int64 // smallest unit of a read
+	ProgramSize int64 // smallest unit of a write
+	BlockSize   int64 // smallest unit of an erase
+	BlockCount  int64 // number of erase blocks
+}
+
+// BlockDeviceInfo returns the geometry of the given block device.
+func BlockDeviceInfo(dev BlockDevice) BlockDeviceGeometry {
+	g := BlockDeviceGeometry{
+		ReadSize:    1,
+		ProgramSize: dev.WriteBlockSize(),
+		BlockSize:   dev.EraseBlockSize(),
+	}
+	if r, ok := dev.(BlockDeviceReadSize); ok {
+		g.ReadSize = r.ReadBlockSize()
+	}
+	g.BlockCount = dev.Size() / g.BlockSize
+	return g
+}
+
+// SyncBlockDevice flushes the writes buffered by the block device, if it
+// buffers them.
+func SyncBlockDevice(dev BlockDevice) error {
+	if s, ok := dev.(BlockDeviceSyncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
@@ -103,3 +103,8 @@ const (
 	AREF Pin = NoPin
 	LED  Pin = PB7
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+)
@@ -164,3 +164,8 @@ func init() {
 	interrupt.New(irq_USART2_RX, _UART2.handleInterrupt)
 	interrupt.New(irq_USART3_RX, _UART3.handleInterrupt)
 }
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+)
@@ -121,3 +121,10 @@ var (
 	usb_VID uint16 = 0x2341
 	usb_PID uint16 = 0x8057
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
@@ -100,3 +100,10 @@ const (
 	XIN32  Pin = PA00
 	XOUT32 Pin = PA01
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
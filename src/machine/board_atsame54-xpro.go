@@ -292,3 +292,10 @@ var (
 		Bus: sam.CAN1,
 	}
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
@@ -106,4 +106,19 @@ var DefaultUART = UART0
 
 func init() {
 	UART0.Interrupt = interrupt.New(rp.IRQ_UART0_IRQ, _UART0.handleInterrupt)
+
+	PowerRails = []*PowerRail{Power3V3}
+	Power3V3.Enable()
 }
+
+// Power3V3 keeps the 3.3V supply on while the board runs on battery, after a
+// button woke it up. Disabling it turns the board off until the next button
+// press, while it is not on USB power.
+var Power3V3 = &PowerRail{Pin: ENABLE_3V3}
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = I2C0_SDA_PIN
+	SCL         = I2C0_SCL_PIN
+)
@@ -109,3 +109,10 @@ const (
 	I2C0_SDA_PIN = PB7
 	I2C0_SCL_PIN = PB6
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = I2C0_SDA_PIN
+	SCL         = I2C0_SCL_PIN
+)
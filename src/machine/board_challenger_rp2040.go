@@ -117,3 +117,10 @@ var (
 	usb_VID uint16 = 0x2e8a
 	usb_PID uint16 = 0x1023
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
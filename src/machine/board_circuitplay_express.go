@@ -117,3 +117,21 @@ var (
 	usb_VID uint16 = 0x239A
 	usb_PID uint16 = 0x8018
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
+
+// User LEDs and buttons on the board.
+func init() {
+	LEDs = []BoardLED{
+		{Pin: LED},
+	}
+	Buttons = []BoardButton{
+		{Pin: BUTTONA, Mode: PinInputPulldown},
+		{Pin: BUTTONB, Mode: PinInputPulldown},
+	}
+}
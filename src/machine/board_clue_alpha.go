@@ -124,3 +124,18 @@ var (
 var (
 	DefaultUART = UART0
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
+
+// STEMMA QT connector, see QwiicI2C.
+const (
+	QWIIC_SDA_PIN = SDA_PIN
+	QWIIC_SCL_PIN = SCL_PIN
+)
+
+var qwiicI2C = I2C0
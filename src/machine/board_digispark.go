@@ -18,3 +18,8 @@ const (
 
 	LED = P1
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+)
@@ -87,3 +87,9 @@ const (
 	PWM1_PIN Pin = IO0
 	PWM2_PIN Pin = IO4
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
@@ -47,3 +47,8 @@ const (
 	UART_TX_PIN = TXD
 	UART_RX_PIN = RXD
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+)
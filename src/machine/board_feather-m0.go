@@ -74,6 +74,14 @@ const (
 // SPI on the Feather M0.
 var SPI0 = sercomSPIM4
 
+// RFM9x LoRa radio pins on the Feather M0 RFM95/RFM96 variants. DIO1 to DIO3
+// are not connected by default.
+const (
+	RFM95_CS_PIN   = D8
+	RFM95_RST_PIN  = D4
+	RFM95_DIO0_PIN = D3
+)
+
 // I2S pins
 const (
 	I2S_SCK_PIN = PA10
@@ -95,3 +103,10 @@ var (
 var (
 	DefaultUART = UART1
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
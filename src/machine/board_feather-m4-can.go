@@ -110,11 +110,17 @@ var (
 )
 
 func init() {
-	// turn on neopixel
-	D7.Configure(PinConfig{Mode: PinOutput})
-	D7.High()
+	PowerRails = []*PowerRail{NeopixelPower, CANPower}
+	NeopixelPower.Enable()
 }
 
+// Power rails of the NeoPixel, which is enabled at startup, and of the boost
+// converter that supplies the transceiver of CAN1.
+var (
+	NeopixelPower = &PowerRail{Pin: D7}
+	CANPower      = &PowerRail{Pin: BOOST_EN}
+)
+
 // I2C on the Feather M4 CAN.
 var (
 	I2C0 = sercomI2CM2
@@ -137,3 +143,10 @@ var (
 var (
 	DefaultUART = UART1
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
@@ -104,3 +104,21 @@ var (
 var (
 	DefaultUART = UART0
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
+
+// User LEDs and buttons on the board.
+func init() {
+	LEDs = []BoardLED{
+		{Pin: LED1},
+		{Pin: LED2},
+	}
+	Buttons = []BoardButton{
+		{Pin: BUTTON, Mode: PinInputPullup, ActiveLow: true},
+	}
+}
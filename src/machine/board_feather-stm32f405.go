@@ -254,3 +254,9 @@ var (
 )
 
 func initI2C() {}
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
@@ -106,3 +106,17 @@ var (
 	usb_VID uint16 = 0x239A
 	usb_PID uint16 = 0x80F1
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
+
+// STEMMA QT connector, see QwiicI2C.
+const (
+	QWIIC_SDA_PIN = I2C1_SDA_PIN
+	QWIIC_SCL_PIN = I2C1_SCL_PIN
+)
+
+var qwiicI2C = I2C1
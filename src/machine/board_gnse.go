@@ -87,3 +87,18 @@ func init() {
 	// Enable UARTs Interrupts
 	UART0.Interrupt = interrupt.New(stm32.IRQ_USART2, _UART0.handleInterrupt)
 }
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = I2C0_SDA_PIN
+	SCL         = I2C0_SCL_PIN
+)
+
+// Qwiic (J10) connector, see QwiicI2C.
+const (
+	QWIIC_SDA_PIN = I2C2_SDA_PIN
+	QWIIC_SCL_PIN = I2C2_SCL_PIN
+)
+
+var qwiicI2C = I2C2
@@ -108,3 +108,10 @@ var (
 var (
 	DefaultUART = UART1
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
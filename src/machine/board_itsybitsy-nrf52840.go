@@ -98,3 +98,10 @@ var (
 var (
 	DefaultUART = UART0
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
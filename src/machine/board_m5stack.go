@@ -123,3 +123,9 @@ const (
 	UART_TX_PIN = UART0_TX_PIN
 	UART_RX_PIN = UART0_RX_PIN
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
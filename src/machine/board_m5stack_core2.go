@@ -114,3 +114,17 @@ const (
 	UART_TX_PIN = UART0_TX_PIN
 	UART_RX_PIN = UART0_RX_PIN
 )
+
+// Grove UART connector (PORT C), see GroveUART.
+const (
+	GROVE_UART_TX_PIN = UART1_TX_PIN
+	GROVE_UART_RX_PIN = UART1_RX_PIN
+)
+
+var groveUART = UART1
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
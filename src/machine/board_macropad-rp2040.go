@@ -103,3 +103,18 @@ var (
 	usb_VID uint16 = 0x239A
 	usb_PID uint16 = 0x8107
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = I2C0_SDA_PIN
+	SCL         = I2C0_SCL_PIN
+)
+
+// STEMMA QT connector, see QwiicI2C.
+const (
+	QWIIC_SDA_PIN = I2C0_SDA_PIN
+	QWIIC_SCL_PIN = I2C0_SCL_PIN
+)
+
+var qwiicI2C = I2C0
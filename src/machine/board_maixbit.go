@@ -73,3 +73,10 @@ const (
 	I2C0_SDA_PIN = D34
 	I2C0_SCL_PIN = D35
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = I2C0_SDA_PIN
+	SCL         = I2C0_SCL_PIN
+)
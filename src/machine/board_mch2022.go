@@ -27,3 +27,9 @@ const (
 	SPI0_CS_ICE40_PIN Pin = 27
 	SPI0_CS_LCD_PIN   Pin = 32
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
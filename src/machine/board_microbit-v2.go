@@ -118,3 +118,9 @@ var (
 	usb_VID uint16 = 0x0d28
 	usb_PID uint16 = 0x0204
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
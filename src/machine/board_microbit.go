@@ -77,3 +77,12 @@ const (
 	LED_ROW_2 = P0_14
 	LED_ROW_3 = P0_15
 )
+
+// Pin names used by Arduino sketches.
+const (
+	A0  = ADC0
+	A1  = ADC1
+	A2  = ADC2
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
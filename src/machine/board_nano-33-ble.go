@@ -127,3 +127,41 @@ var (
 	usb_VID uint16 = 0x2341
 	usb_PID uint16 = 0x805a
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
+
+// User LEDs on the board. The RGB LED is active low, unlike LED.
+func init() {
+	LEDs = []BoardLED{
+		{Pin: LED},
+		{Pin: LED_RED, ActiveLow: true},
+		{Pin: LED_GREEN, ActiveLow: true},
+		{Pin: LED_BLUE, ActiveLow: true},
+	}
+
+	PowerRails = []*PowerRail{SensorPower, I2CPullupPower, MicPower}
+	I2CPullupPower.Enable()
+	SensorPower.Enable()
+}
+
+// Power rails of the onboard peripherals. The sensors and the I2C pull-up
+// resistors are enabled at startup, the microphone is not.
+var (
+	SensorPower    = &PowerRail{Pin: LSM_PWR, StartupTime: 1000} // IMU, pressure and humidity sensors
+	I2CPullupPower = &PowerRail{Pin: I2C_PULLUP}
+	MicPower       = &PowerRail{Pin: MIC_PWR}
+)
+
+// RGBLED is the RGB LED, dimmed with PWM.
+var RGBLED = &StatusLED{
+	LEDs: [3]BoardLED{
+		{Pin: LED_RED, ActiveLow: true},
+		{Pin: LED_GREEN, ActiveLow: true},
+		{Pin: LED_BLUE, ActiveLow: true},
+	},
+	PWM: [3]StatusLEDPWM{PWM0, PWM0, PWM0},
+}
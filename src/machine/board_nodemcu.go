@@ -34,3 +34,9 @@ const (
 	SDA_PIN = D2
 	SCL_PIN = D1
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
@@ -54,3 +54,9 @@ const (
 	I2C0_SCL_PIN = PB6
 	I2C0_SDA_PIN = PB7
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = I2C0_SDA_PIN
+	SCL = I2C0_SCL_PIN
+)
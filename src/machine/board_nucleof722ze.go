@@ -67,3 +67,9 @@ var (
 	}
 	I2C0 = I2C1
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = I2C0_SDA_PIN
+	SCL = I2C0_SCL_PIN
+)
@@ -59,3 +59,9 @@ var (
 func init() {
 	UART1.Interrupt = interrupt.New(stm32.IRQ_LPUART1, _UART1.handleInterrupt)
 }
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = I2C0_SDA_PIN
+	SCL = I2C0_SCL_PIN
+)
@@ -138,3 +138,10 @@ var (
 	usb_VID uint16 = 0x1354
 	usb_PID uint16 = 0x4000
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
@@ -108,3 +108,10 @@ var (
 	usb_VID uint16 = 0x2B04
 	usb_PID uint16 = 0xD00D
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
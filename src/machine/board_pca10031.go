@@ -40,3 +40,10 @@ const (
 	SPI0_SDO_PIN = NoPin
 	SPI0_SDI_PIN = NoPin
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
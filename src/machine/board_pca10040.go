@@ -54,3 +54,26 @@ const (
 	SPI0_SDO_PIN Pin = 23
 	SPI0_SDI_PIN Pin = 24
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
+
+// User LEDs and buttons on the board.
+func init() {
+	LEDs = []BoardLED{
+		{Pin: LED1, ActiveLow: true},
+		{Pin: LED2, ActiveLow: true},
+		{Pin: LED3, ActiveLow: true},
+		{Pin: LED4, ActiveLow: true},
+	}
+	Buttons = []BoardButton{
+		{Pin: BUTTON1, Mode: PinInputPullup, ActiveLow: true},
+		{Pin: BUTTON2, Mode: PinInputPullup, ActiveLow: true},
+		{Pin: BUTTON3, Mode: PinInputPullup, ActiveLow: true},
+		{Pin: BUTTON4, Mode: PinInputPullup, ActiveLow: true},
+	}
+}
@@ -64,3 +64,26 @@ var (
 	usb_VID uint16 = 0x239A
 	usb_PID uint16 = 0x8029
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
+
+// User LEDs and buttons on the board.
+func init() {
+	LEDs = []BoardLED{
+		{Pin: LED1, ActiveLow: true},
+		{Pin: LED2, ActiveLow: true},
+		{Pin: LED3, ActiveLow: true},
+		{Pin: LED4, ActiveLow: true},
+	}
+	Buttons = []BoardButton{
+		{Pin: BUTTON1, Mode: PinInputPullup, ActiveLow: true},
+		{Pin: BUTTON2, Mode: PinInputPullup, ActiveLow: true},
+		{Pin: BUTTON3, Mode: PinInputPullup, ActiveLow: true},
+		{Pin: BUTTON4, Mode: PinInputPullup, ActiveLow: true},
+	}
+}
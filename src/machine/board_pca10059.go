@@ -58,3 +58,10 @@ var (
 	usb_VID uint16 = 0x1915
 	usb_PID uint16 = 0xCAFE
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
@@ -112,3 +112,17 @@ var (
 	usb_VID uint16 = 0x2E8A
 	usb_PID uint16 = 0x000A
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = I2C0_SDA_PIN
+	SCL         = I2C0_SCL_PIN
+)
+
+// User LEDs and buttons on the board.
+func init() {
+	LEDs = []BoardLED{
+		{Pin: LED},
+	}
+}
@@ -62,3 +62,10 @@ const (
 	LCD_BACKLIGHT_MID  Pin = 22
 	LCD_BACKLIGHT_HIGH Pin = 23
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
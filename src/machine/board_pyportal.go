@@ -152,3 +152,10 @@ var (
 	usb_VID uint16 = 0x239A
 	usb_PID uint16 = 0x8035
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
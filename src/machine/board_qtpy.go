@@ -100,3 +100,9 @@ var (
 var (
 	DefaultUART = UART1
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
@@ -105,8 +105,14 @@ var DefaultUART = UART0
 func init() {
 	UART0.Interrupt = interrupt.New(rp.IRQ_UART0_IRQ, _UART0.handleInterrupt)
 	UART1.Interrupt = interrupt.New(rp.IRQ_UART1_IRQ, _UART1.handleInterrupt)
+
+	PowerRails = []*PowerRail{NeopixelPower}
+	NeopixelPower.Enable()
 }
 
+// NeopixelPower is the supply of the NeoPixel, enabled at startup.
+var NeopixelPower = &PowerRail{Pin: NEOPIXEL_POWER}
+
 // USB identifiers
 const (
 	usb_STRING_PRODUCT      = "QT Py RP2040"
@@ -117,3 +123,11 @@ var (
 	usb_VID uint16 = 0x239A
 	usb_PID uint16 = 0x80F7
 )
+
+// STEMMA QT connector, see QwiicI2C.
+const (
+	QWIIC_SDA_PIN = I2C1_QT_SDA_PIN
+	QWIIC_SCL_PIN = I2C1_QT_SCL_PIN
+)
+
+var qwiicI2C = I2C1
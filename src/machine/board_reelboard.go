@@ -55,14 +55,21 @@ const (
 // This controls the TPS610981 boost converter. You must turn the power supply active in order to use the EPD and
 // other onboard peripherals.
 func PowerSupplyActive(active bool) {
-	POWER_SUPPLY_PIN.Configure(PinConfig{Mode: PinOutput})
 	if active {
-		POWER_SUPPLY_PIN.High()
+		PeripheralPower.Enable()
 	} else {
-		POWER_SUPPLY_PIN.Low()
+		PeripheralPower.Disable()
 	}
 }
 
+// PeripheralPower is the boost converter that supplies the EPD and the other
+// onboard peripherals, see PowerSupplyActive.
+var PeripheralPower = &PowerRail{Pin: POWER_SUPPLY_PIN}
+
+func init() {
+	PowerRails = []*PowerRail{PeripheralPower}
+}
+
 // USB CDC identifiers
 const (
 	usb_STRING_PRODUCT      = "PHYTEC reelboard"
@@ -73,3 +80,10 @@ var (
 	usb_VID uint16 = 0x2FE3
 	usb_PID uint16 = 0x100
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
@@ -78,3 +78,9 @@ var (
 		AltFuncSelector: AF4_I2C1_2_3,
 	}
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = I2C0_SDA_PIN
+	SCL = I2C0_SCL_PIN
+)
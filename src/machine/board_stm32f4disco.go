@@ -105,3 +105,17 @@ var (
 		AltFuncSelector: AF4_I2C1_2_3,
 	}
 )
+
+// Pin names used by Arduino sketches.
+const (
+	A0  = ADC0
+	A1  = ADC1
+	A2  = ADC2
+	A3  = ADC3
+	A4  = ADC4
+	A5  = ADC5
+	A6  = ADC6
+	A7  = ADC7
+	SDA = I2C0_SDA_PIN
+	SCL = I2C0_SCL_PIN
+)
@@ -392,3 +392,8 @@ var (
 		},
 	}
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+)
@@ -318,3 +318,8 @@ const (
 	I2C3_SDA_PIN = D25
 	I2C3_SCL_PIN = D24
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+)
@@ -116,3 +116,17 @@ var (
 	usb_VID uint16 = 0x1B4F
 	usb_PID uint16 = 0x0026
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
+
+// Qwiic connector, see QwiicI2C.
+const (
+	QWIIC_SDA_PIN = I2C1_SDA_PIN
+	QWIIC_SCL_PIN = I2C1_SCL_PIN
+)
+
+var qwiicI2C = I2C1
@@ -61,3 +61,17 @@ var (
 	usb_VID uint16 = 0x239a
 	usb_PID uint16 = 0x8109
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = I2C0_SDA_PIN
+	SCL = I2C0_SCL_PIN
+)
+
+// STEMMA QT connector, see QwiicI2C.
+const (
+	QWIIC_SDA_PIN = I2C0_SDA_PIN
+	QWIIC_SCL_PIN = I2C0_SCL_PIN
+)
+
+var qwiicI2C = I2C0
@@ -102,4 +102,23 @@ var DefaultUART = UART0
 
 func init() {
 	UART0.Interrupt = interrupt.New(rp.IRQ_UART0_IRQ, _UART0.handleInterrupt)
+
+	PowerRails = []*PowerRail{SensorPower}
 }
+
+// SensorPower is the supply of the light sensor, off at startup.
+var SensorPower = &PowerRail{Pin: SENSOR_POWER}
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = I2C0_SDA_PIN
+	SCL = I2C0_SCL_PIN
+)
+
+// Qw/ST connector, see QwiicI2C.
+const (
+	QWIIC_SDA_PIN = I2C0_SDA_PIN
+	QWIIC_SCL_PIN = I2C0_SCL_PIN
+)
+
+var qwiicI2C = I2C0
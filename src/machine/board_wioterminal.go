@@ -420,3 +420,11 @@ var (
 	usb_VID uint16 = 0x2886
 	usb_PID uint16 = 0x802D
 )
+
+// Grove I2C connector on the left side, see GroveI2C.
+const (
+	GROVE_I2C_SDA_PIN = SDA1_PIN
+	GROVE_I2C_SCL_PIN = SCL1_PIN
+)
+
+var groveI2C = I2C1
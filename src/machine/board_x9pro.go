@@ -29,3 +29,10 @@ const (
 const HasLowFrequencyCrystal = true
 
 var DefaultUART = UART0
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
@@ -96,6 +96,16 @@ const (
 	MIC_DIN = P0_16
 )
 
+// Power rails of the peripherals of the XIAO BLE Sense, off at startup.
+var (
+	SensorPower = &PowerRail{Pin: LSM_PWR, StartupTime: 1000} // IMU
+	MicPower    = &PowerRail{Pin: MIC_PWR}
+)
+
+func init() {
+	PowerRails = []*PowerRail{SensorPower, MicPower}
+}
+
 // USB CDC identifiers
 const (
 	usb_STRING_PRODUCT      = "XIAO nRF52840 Sense"
@@ -110,3 +120,10 @@ var (
 var (
 	DefaultUART = UART0
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = SDA_PIN
+	SCL         = SCL_PIN
+)
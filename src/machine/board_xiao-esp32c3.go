@@ -52,3 +52,9 @@ const (
 	SPI_SDI_PIN = GPIO9
 	SPI_SDO_PIN = GPIO10
 )
+
+// Pin names used by Arduino sketches.
+const (
+	SDA = SDA_PIN
+	SCL = SCL_PIN
+)
@@ -104,3 +104,29 @@ var (
 	usb_VID uint16 = 0x2e8a
 	usb_PID uint16 = 0x000a
 )
+
+// Pin names used by Arduino sketches.
+const (
+	LED_BUILTIN = LED
+	SDA         = I2C0_SDA_PIN
+	SCL         = I2C0_SCL_PIN
+)
+
+// User LEDs and buttons on the board.
+func init() {
+	LEDs = []BoardLED{
+		{Pin: LED_RED, ActiveLow: true},
+		{Pin: LED_GREEN, ActiveLow: true},
+		{Pin: LED_BLUE, ActiveLow: true},
+	}
+}
+
+// RGBLED is the RGB LED, dimmed with PWM.
+var RGBLED = &StatusLED{
+	LEDs: [3]BoardLED{
+		{Pin: LED_RED, ActiveLow: true},
+		{Pin: LED_GREEN, ActiveLow: true},
+		{Pin: LED_BLUE, ActiveLow: true},
+	},
+	PWM: [3]StatusLEDPWM{PWM0, PWM0, PWM4},
+}
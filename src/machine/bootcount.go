@@ -0,0 +1,36 @@
+//go:build cortexm
+// +build cortexm
+
+package machine
+
+import "runtime/interrupt"
+
+// uptimeBase is the uptime in nanoseconds of the previous boots since
+// power-on, as far as it was recorded.
+var uptimeBase int64
+
+func init() {
+	setRetainedInternal(retainedBootCount, retainedInternal(retainedBootCount)+1)
+	uptimeBase = int64(retainedInternal(retainedUptimeHigh))<<32 | int64(retainedInternal(retainedUptimeLow))
+}
+
+// BootCount returns the number of times the chip has booted since power-on,
+// counting soft resets such as a watchdog reset or a press of the reset
+// button. It is 1 after power-on.
+func BootCount() uint32 {
+	return retainedInternal(retainedBootCount)
+}
+
+// Uptime returns the time in nanoseconds since power-on, including the time
+// spent in earlier boots. The uptime of a boot is recorded in retained memory
+// every time Uptime is called, so after a reset the time since the last call
+// is missing: call it periodically, for example when feeding the watchdog, to
+// keep the cumulative uptime accurate.
+func Uptime() int64 {
+	uptime := uptimeBase + nanotime()
+	mask := interrupt.Disable()
+	setRetainedInternal(retainedUptimeLow, uint32(uptime))
+	setRetainedInternal(retainedUptimeHigh, uint32(uptime>>32))
+	interrupt.Restore(mask)
+	return uptime
+}
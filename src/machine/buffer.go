@@ -12,6 +12,15 @@ type RingBuffer struct {
 	rxbuffer [bufferSize]volatile.Register8
 	head     volatile.Register8
 	tail     volatile.Register8
+	gaps     *ringBufferGaps // set by setFrameGap
+}
+
+// ringBufferGaps marks the bytes put in a RingBuffer after the line was idle,
+// for protocols that delimit frames by idle time.
+type ringBufferGaps struct {
+	gap   int64                 // idle time that starts a frame, in nanoseconds
+	last  int64                 // time of the last Put, in nanoseconds
+	start [bufferSize / 8]uint8 // bits of the bytes that start a frame
 }
 
 // NewRingBuffer returns a new ring buffer.
@@ -30,11 +39,49 @@ func (rb *RingBuffer) Put(val byte) bool {
 	if rb.Used() != bufferSize {
 		rb.head.Set(rb.head.Get() + 1)
 		rb.rxbuffer[rb.head.Get()%bufferSize].Set(val)
+		if rb.gaps != nil {
+			rb.markGap(rb.head.Get() % bufferSize)
+		}
 		return true
 	}
 	return false
 }
 
+// setFrameGap makes Put mark the bytes that come after an idle time of at
+// least gap nanoseconds, or stops marking them if gap is zero.
+func (rb *RingBuffer) setFrameGap(gap int64) {
+	if gap == 0 {
+		rb.gaps = nil
+		return
+	}
+	rb.gaps = &ringBufferGaps{gap: gap}
+}
+
+// markGap marks the byte at index i if it starts a frame. It is called by Put
+// from the interrupt that receives the byte, so the time is that of the byte.
+func (rb *RingBuffer) markGap(i uint8) {
+	g := rb.gaps
+	now := nanotime()
+	if now-g.last >= g.gap {
+		g.start[i/8] |= 1 << (i % 8)
+	} else {
+		g.start[i/8] &^= 1 << (i % 8)
+	}
+	g.last = now
+}
+
+// getFrameByte is Get, and also returns whether the byte starts a frame, as
+// marked by Put after setFrameGap.
+func (rb *RingBuffer) getFrameByte() (val byte, start, ok bool) {
+	if rb.Used() == 0 {
+		return 0, false, false
+	}
+	rb.tail.Set(rb.tail.Get() + 1)
+	i := rb.tail.Get() % bufferSize
+	start = rb.gaps != nil && rb.gaps.start[i/8]&(1<<(i%8)) != 0
+	return rb.rxbuffer[i].Get(), start, true
+}
+
 // Get returns a byte from the buffer. If the buffer is empty,
 // the method will return a false as the second value.
 func (rb *RingBuffer) Get() (byte, bool) {
@@ -0,0 +1,423 @@
+// Package cbor encodes and decodes CBOR (RFC 8949) without allocating, for
+// telemetry messages and update manifests on chips with little RAM. Values are
+// appended to a buffer that the caller provides, like strconv.AppendInt does,
+// and decoded in place: byte and text strings are returned as slices of the
+// input.
+//
+// A CBOR encoded sample fits in the data of a telemetry message:
+//
+//	var buf [telemetry.MaxDataSize]byte
+//	b := cbor.AppendMapHeader(buf[:0], 2)
+//	b = cbor.AppendUint(cbor.AppendUint(b, 1), uint64(temperature))
+//	b = cbor.AppendUint(cbor.AppendUint(b, 2), uint64(humidity))
+//	enc.WriteMessage(channelSample, b)
+//
+// Only definite lengths are supported, which is what encoders produce for
+// data of a known size.
+//
+// COSE_Sign1 messages (RFC 9052), as used to sign update manifests, are
+// parsed by ParseSign1, which builds the bytes covered by the signature to hand
+// to a signature check such as ed25519.Verify.
+package cbor
+
+import (
+	"errors"
+	"math"
+)
+
+// MajorType is the type of a CBOR data item.
+type MajorType uint8
+
+const (
+	TypeUint   MajorType = 0
+	TypeNegInt MajorType = 1
+	TypeBytes  MajorType = 2
+	TypeText   MajorType = 3
+	TypeArray  MajorType = 4
+	TypeMap    MajorType = 5
+	TypeTag    MajorType = 6
+	TypeSimple MajorType = 7 // false, true, null, undefined and floats
+)
+
+// Simple values and float headers of major type 7.
+const (
+	simpleFalse     = 0xf4
+	simpleTrue      = 0xf5
+	simpleNull      = 0xf6
+	simpleUndefined = 0xf7
+	simpleFloat16   = 0xf9
+	simpleFloat32   = 0xfa
+	simpleFloat64   = 0xfb
+)
+
+var (
+	ErrUnexpectedEnd = errors.New("cbor: unexpected end of data")
+	ErrType          = errors.New("cbor: unexpected type")
+	ErrOverflow      = errors.New("cbor: integer overflow")
+	ErrUnsupported   = errors.New("cbor: indefinite length or reserved value")
+)
+
+// appendHeader appends the header of an item: the major type and an argument,
+// in the shortest form.
+func appendHeader(b []byte, t MajorType, v uint64) []byte {
+	m := byte(t) << 5
+	switch {
+	case v < 24:
+		return append(b, m|byte(v))
+	case v <= math.MaxUint8:
+		return append(b, m|24, byte(v))
+	case v <= math.MaxUint16:
+		return append(b, m|25, byte(v>>8), byte(v))
+	case v <= math.MaxUint32:
+		return append(b, m|26, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		return append(b, m|27, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+			byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+}
+
+// AppendUint appends an unsigned integer.
+func AppendUint(b []byte, v uint64) []byte {
+	return appendHeader(b, TypeUint, v)
+}
+
+// AppendInt appends a signed integer.
+func AppendInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendHeader(b, TypeNegInt, uint64(-(v + 1)))
+	}
+	return appendHeader(b, TypeUint, uint64(v))
+}
+
+// AppendBytes appends a byte string.
+func AppendBytes(b, data []byte) []byte {
+	return append(appendHeader(b, TypeBytes, uint64(len(data))), data...)
+}
+
+// AppendText appends a text string, which must be valid UTF-8.
+func AppendText(b []byte, s string) []byte {
+	return append(appendHeader(b, TypeText, uint64(len(s))), s...)
+}
+
+// AppendArrayHeader appends the header of an array of n items, which must be
+// appended after it.
+func AppendArrayHeader(b []byte, n int) []byte {
+	return appendHeader(b, TypeArray, uint64(n))
+}
+
+// AppendMapHeader appends the header of a map of n pairs, whose keys and
+// values must be appended after it, each key followed by its value.
+func AppendMapHeader(b []byte, n int) []byte {
+	return appendHeader(b, TypeMap, uint64(n))
+}
+
+// AppendTag appends a tag, which applies to the item appended after it.
+func AppendTag(b []byte, tag uint64) []byte {
+	return appendHeader(b, TypeTag, tag)
+}
+
+// AppendBool appends true or false.
+func AppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, simpleTrue)
+	}
+	return append(b, simpleFalse)
+}
+
+// AppendNull appends null.
+func AppendNull(b []byte) []byte {
+	return append(b, simpleNull)
+}
+
+// AppendFloat32 appends a single precision float.
+func AppendFloat32(b []byte, v float32) []byte {
+	u := math.Float32bits(v)
+	return append(b, simpleFloat32, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+// AppendFloat64 appends a double precision float.
+func AppendFloat64(b []byte, v float64) []byte {
+	u := math.Float64bits(v)
+	return append(b, simpleFloat64, byte(u>>56), byte(u>>48), byte(u>>40), byte(u>>32),
+		byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+// Decoder reads the data items of CBOR encoded data one at a time, in the
+// order they are encoded: the items of an array follow its header, and so do
+// the keys and values of a map.
+type Decoder struct {
+	data []byte
+	off  int
+}
+
+// NewDecoder returns a Decoder that reads data.
+func NewDecoder(data []byte) Decoder {
+	return Decoder{data: data}
+}
+
+// Done returns whether all the data was read.
+func (d *Decoder) Done() bool {
+	return d.off >= len(d.data)
+}
+
+// Rest returns the data that wasn't read yet.
+func (d *Decoder) Rest() []byte {
+	return d.data[d.off:]
+}
+
+// Type returns the type of the next item.
+func (d *Decoder) Type() (MajorType, error) {
+	if d.off >= len(d.data) {
+		return 0, ErrUnexpectedEnd
+	}
+	return MajorType(d.data[d.off] >> 5), nil
+}
+
+// header reads the header of the next item, which must be of type t, and
+// returns its argument.
+func (d *Decoder) header(t MajorType) (uint64, error) {
+	if d.off >= len(d.data) {
+		return 0, ErrUnexpectedEnd
+	}
+	initial := d.data[d.off]
+	if MajorType(initial>>5) != t {
+		return 0, ErrType
+	}
+	info := initial & 0x1f
+	if info < 24 {
+		d.off++
+		return uint64(info), nil
+	}
+	if info > 27 {
+		return 0, ErrUnsupported
+	}
+	size := 1 << (info - 24)
+	if len(d.data)-d.off-1 < size {
+		return 0, ErrUnexpectedEnd
+	}
+	var v uint64
+	for _, c := range d.data[d.off+1 : d.off+1+size] {
+		v = v<<8 | uint64(c)
+	}
+	d.off += 1 + size
+	return v, nil
+}
+
+// Uint reads an unsigned integer.
+func (d *Decoder) Uint() (uint64, error) {
+	return d.header(TypeUint)
+}
+
+// Int reads an integer, which may be negative.
+func (d *Decoder) Int() (int64, error) {
+	t, err := d.Type()
+	if err != nil {
+		return 0, err
+	}
+	if t == TypeUint {
+		v, err := d.header(TypeUint)
+		if err == nil && v > math.MaxInt64 {
+			return 0, ErrOverflow
+		}
+		return int64(v), err
+	}
+	v, err := d.header(TypeNegInt)
+	if err == nil && v > math.MaxInt64 {
+		return 0, ErrOverflow
+	}
+	return -1 - int64(v), err
+}
+
+// Bytes reads a byte string. The returned slice is part of the input.
+func (d *Decoder) Bytes() ([]byte, error) {
+	return d.str(TypeBytes)
+}
+
+// Text reads a text string. The returned slice is part of the input, to which
+// it can be compared without converting it to a string, which allocates.
+func (d *Decoder) Text() ([]byte, error) {
+	return d.str(TypeText)
+}
+
+func (d *Decoder) str(t MajorType) ([]byte, error) {
+	start := d.off
+	n, err := d.header(t)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.data)-d.off) {
+		d.off = start
+		return nil, ErrUnexpectedEnd
+	}
+	s := d.data[d.off : d.off+int(n)]
+	d.off += int(n)
+	return s, nil
+}
+
+// ArrayHeader reads the header of an array, and returns its number of items.
+func (d *Decoder) ArrayHeader() (int, error) {
+	return d.count(TypeArray)
+}
+
+// MapHeader reads the header of a map, and returns its number of pairs.
+func (d *Decoder) MapHeader() (int, error) {
+	return d.count(TypeMap)
+}
+
+func (d *Decoder) count(t MajorType) (int, error) {
+	n, err := d.header(t)
+	if err != nil {
+		return 0, err
+	}
+	// Every item takes at least a byte, which also bounds n to an int.
+	if n > uint64(len(d.data)-d.off) {
+		return 0, ErrUnexpectedEnd
+	}
+	return int(n), nil
+}
+
+// Tag reads a tag, which applies to the next item.
+func (d *Decoder) Tag() (uint64, error) {
+	return d.header(TypeTag)
+}
+
+// Bool reads true or false.
+func (d *Decoder) Bool() (bool, error) {
+	if d.off >= len(d.data) {
+		return false, ErrUnexpectedEnd
+	}
+	switch d.data[d.off] {
+	case simpleFalse:
+		d.off++
+		return false, nil
+	case simpleTrue:
+		d.off++
+		return true, nil
+	}
+	return false, ErrType
+}
+
+// Null reads null, or undefined, and returns whether it was there. Any other
+// item is left to read.
+func (d *Decoder) Null() bool {
+	if d.off < len(d.data) && (d.data[d.off] == simpleNull || d.data[d.off] == simpleUndefined) {
+		d.off++
+		return true
+	}
+	return false
+}
+
+// Float reads a float of any precision, or an integer.
+func (d *Decoder) Float() (float64, error) {
+	t, err := d.Type()
+	if err != nil {
+		return 0, err
+	}
+	if t == TypeUint || t == TypeNegInt {
+		v, err := d.Int()
+		return float64(v), err
+	}
+	initial := d.data[d.off]
+	var size int
+	switch initial {
+	case simpleFloat16:
+		size = 2
+	case simpleFloat32:
+		size = 4
+	case simpleFloat64:
+		size = 8
+	default:
+		return 0, ErrType
+	}
+	if len(d.data)-d.off-1 < size {
+		return 0, ErrUnexpectedEnd
+	}
+	var u uint64
+	for _, c := range d.data[d.off+1 : d.off+1+size] {
+		u = u<<8 | uint64(c)
+	}
+	d.off += 1 + size
+	switch size {
+	case 2:
+		return float16(uint16(u)), nil
+	case 4:
+		return float64(math.Float32frombits(uint32(u))), nil
+	}
+	return math.Float64frombits(u), nil
+}
+
+// float16 converts a half precision float.
+func float16(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -v
+	}
+	return v
+}
+
+// Skip skips the next item, with all the items in it if it is an array, a
+// map or a tag. It doesn't recurse, so deeply nested data can't overflow the
+// stack.
+func (d *Decoder) Skip() error {
+	// left counts the items still to skip, as arrays and maps add theirs.
+	for left := 1; left > 0; left-- {
+		t, err := d.Type()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case TypeUint, TypeNegInt, TypeTag:
+			if _, err := d.header(t); err != nil {
+				return err
+			}
+			if t == TypeTag {
+				left++
+			}
+		case TypeBytes, TypeText:
+			if _, err := d.str(t); err != nil {
+				return err
+			}
+		case TypeArray, TypeMap:
+			n, err := d.count(t)
+			if err != nil {
+				return err
+			}
+			if t == TypeMap {
+				n *= 2
+			}
+			left += n
+		case TypeSimple:
+			switch initial := d.data[d.off]; {
+			case initial <= 0xf7:
+				d.off++
+			case initial == 0xf8:
+				d.off += 2
+			case initial <= simpleFloat64:
+				if _, err := d.Float(); err != nil {
+					return err
+				}
+			default:
+				return ErrUnsupported
+			}
+			if d.off > len(d.data) {
+				d.off = len(d.data)
+				return ErrUnexpectedEnd
+			}
+		}
+	}
+	return nil
+}
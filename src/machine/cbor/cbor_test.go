@@ -0,0 +1,241 @@
+package cbor
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+// Examples of RFC 8949, appendix A.
+var encodeTests = []struct {
+	encoded string
+	append  func([]byte) []byte
+}{
+	{"00", func(b []byte) []byte { return AppendUint(b, 0) }},
+	{"17", func(b []byte) []byte { return AppendUint(b, 23) }},
+	{"1818", func(b []byte) []byte { return AppendUint(b, 24) }},
+	{"1903e8", func(b []byte) []byte { return AppendUint(b, 1000) }},
+	{"1a000f4240", func(b []byte) []byte { return AppendUint(b, 1000000) }},
+	{"1b000000e8d4a51000", func(b []byte) []byte { return AppendUint(b, 1000000000000) }},
+	{"1bffffffffffffffff", func(b []byte) []byte { return AppendUint(b, math.MaxUint64) }},
+	{"20", func(b []byte) []byte { return AppendInt(b, -1) }},
+	{"3863", func(b []byte) []byte { return AppendInt(b, -100) }},
+	{"3903e7", func(b []byte) []byte { return AppendInt(b, -1000) }},
+	{"3b7fffffffffffffff", func(b []byte) []byte { return AppendInt(b, math.MinInt64) }},
+	{"fb3ff199999999999a", func(b []byte) []byte { return AppendFloat64(b, 1.1) }},
+	{"fa47c35000", func(b []byte) []byte { return AppendFloat32(b, 100000) }},
+	{"f4", func(b []byte) []byte { return AppendBool(b, false) }},
+	{"f5", func(b []byte) []byte { return AppendBool(b, true) }},
+	{"f6", AppendNull},
+	{"c11a514b67b0", func(b []byte) []byte { return AppendUint(AppendTag(b, 1), 1363896240) }},
+	{"4401020304", func(b []byte) []byte { return AppendBytes(b, []byte{1, 2, 3, 4}) }},
+	{"62c3bc", func(b []byte) []byte { return AppendText(b, "ü") }},
+	{"80", func(b []byte) []byte { return AppendArrayHeader(b, 0) }},
+	{"a201020304", func(b []byte) []byte {
+		b = AppendMapHeader(b, 2)
+		return AppendUint(AppendUint(AppendUint(AppendUint(b, 1), 2), 3), 4)
+	}},
+	{"a26161016162820203", func(b []byte) []byte {
+		b = AppendMapHeader(b, 2)
+		b = AppendUint(AppendText(b, "a"), 1)
+		b = AppendArrayHeader(AppendText(b, "b"), 2)
+		return AppendUint(AppendUint(b, 2), 3)
+	}},
+}
+
+func TestEncode(t *testing.T) {
+	for _, tc := range encodeTests {
+		if got := hex.EncodeToString(tc.append(nil)); got != tc.encoded {
+			t.Errorf("got %s, want %s", got, tc.encoded)
+		}
+	}
+}
+
+func decodeHex(t *testing.T, s string) Decoder {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewDecoder(data)
+}
+
+func TestDecodeIntegers(t *testing.T) {
+	for _, tc := range []struct {
+		encoded string
+		value   int64
+	}{
+		{"00", 0}, {"17", 23}, {"1818", 24}, {"1903e8", 1000},
+		{"1b000000e8d4a51000", 1000000000000},
+		{"20", -1}, {"3863", -100}, {"3b7fffffffffffffff", math.MinInt64},
+	} {
+		d := decodeHex(t, tc.encoded)
+		if v, err := d.Int(); err != nil || v != tc.value || !d.Done() {
+			t.Errorf("%s: got %d, %v, want %d", tc.encoded, v, err, tc.value)
+		}
+	}
+	d := decodeHex(t, "1bffffffffffffffff")
+	if _, err := d.Int(); err != ErrOverflow {
+		t.Errorf("got %v, want ErrOverflow", err)
+	}
+	d = decodeHex(t, "1903")
+	if _, err := d.Uint(); err != ErrUnexpectedEnd {
+		t.Errorf("got %v, want ErrUnexpectedEnd", err)
+	}
+	d = decodeHex(t, "20")
+	if _, err := d.Uint(); err != ErrType {
+		t.Errorf("got %v, want ErrType", err)
+	}
+}
+
+func TestDecodeFloats(t *testing.T) {
+	for _, tc := range []struct {
+		encoded string
+		value   float64
+	}{
+		{"f90000", 0}, {"f93c00", 1}, {"f93e00", 1.5}, {"f97bff", 65504},
+		{"f90001", 5.960464477539063e-8}, {"f9c400", -4},
+		{"f97c00", math.Inf(1)}, {"fa47c35000", 100000},
+		{"fb3ff199999999999a", 1.1}, {"1903e8", 1000}, {"3863", -100},
+	} {
+		d := decodeHex(t, tc.encoded)
+		if v, err := d.Float(); err != nil || v != tc.value || !d.Done() {
+			t.Errorf("%s: got %g, %v, want %g", tc.encoded, v, err, tc.value)
+		}
+	}
+	d := decodeHex(t, "f97e00")
+	if v, err := d.Float(); err != nil || !math.IsNaN(v) {
+		t.Errorf("got %g, %v, want NaN", v, err)
+	}
+}
+
+func TestDecodeStructure(t *testing.T) {
+	// {"a": 1, "b": [2, 3], "c": h'0102', "d": true, "e": null}
+	d := decodeHex(t, "a5616101616282020361634201026164f56165f6")
+	n, err := d.MapHeader()
+	if err != nil || n != 5 {
+		t.Fatalf("got %d pairs, %v", n, err)
+	}
+	for i := 0; i < n; i++ {
+		key, err := d.Text()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch string(key) {
+		case "a":
+			if v, err := d.Uint(); v != 1 || err != nil {
+				t.Errorf("a: got %d, %v", v, err)
+			}
+		case "b":
+			if err := d.Skip(); err != nil {
+				t.Errorf("b: %v", err)
+			}
+		case "c":
+			if v, err := d.Bytes(); !bytes.Equal(v, []byte{1, 2}) || err != nil {
+				t.Errorf("c: got %x, %v", v, err)
+			}
+		case "d":
+			if v, err := d.Bool(); !v || err != nil {
+				t.Errorf("d: got %v, %v", v, err)
+			}
+		case "e":
+			if !d.Null() {
+				t.Errorf("e: not null")
+			}
+		}
+	}
+	if !d.Done() {
+		t.Errorf("%x left", d.Rest())
+	}
+}
+
+func TestSkip(t *testing.T) {
+	for _, s := range []string{
+		"00", "3b7fffffffffffffff", "4401020304", "62c3bc", "f6", "f97c00", "f820",
+		"c11a514b67b0", "8301820203820405", "a26161016162820203",
+		"8181818181818181818100",
+	} {
+		d := decodeHex(t, s+"17")
+		if err := d.Skip(); err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if v, err := d.Uint(); v != 23 || err != nil || !d.Done() {
+			t.Errorf("%s: skipped to %x", s, d.Rest())
+		}
+	}
+	for _, s := range []string{"", "8301", "5f", "9f01ff", "4301", "c1"} {
+		d := decodeHex(t, s)
+		if err := d.Skip(); err == nil {
+			t.Errorf("%s: no error", s)
+		}
+	}
+}
+
+func TestNoAllocations(t *testing.T) {
+	var buf [64]byte
+	data := encodeTests[len(encodeTests)-1].append(nil)
+	allocs := testing.AllocsPerRun(100, func() {
+		b := AppendMapHeader(buf[:0], 2)
+		b = AppendText(b, "temperature")
+		b = AppendFloat32(b, 21.5)
+		b = AppendUint(AppendText(b, "uptime"), 123456)
+		d := NewDecoder(data)
+		d.Skip()
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations", allocs)
+	}
+}
+
+func TestSign1(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(bytes.NewReader(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	protected := AppendInt(AppendUint(AppendMapHeader(nil, 1), 1), -8) // alg: EdDSA
+	payload := []byte("manifest")
+	unsigned := Sign1{Protected: protected, Payload: payload}
+	sig := ed25519.Sign(priv, unsigned.AppendToBeSigned(nil, nil))
+
+	msg := AppendTag(nil, tagSign1)
+	msg = AppendArrayHeader(msg, 4)
+	msg = AppendBytes(msg, protected)
+	msg = AppendBytes(AppendUint(AppendMapHeader(msg, 1), 4), []byte("key1")) // kid
+	msg = AppendBytes(msg, payload)
+	msg = AppendBytes(msg, sig)
+
+	for _, m := range [][]byte{msg, msg[1:]} {
+		s, err := ParseSign1(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(s.Payload, payload) {
+			t.Errorf("got payload %q", s.Payload)
+		}
+		var buf [128]byte
+		if !ed25519.Verify(pub, s.AppendToBeSigned(buf[:0], nil), s.Signature) {
+			t.Errorf("signature doesn't verify")
+		}
+		if d, ok := s.Header(1); !ok {
+			t.Errorf("no algorithm")
+		} else if alg, err := d.Int(); alg != -8 || err != nil {
+			t.Errorf("got algorithm %d, %v", alg, err)
+		}
+		if d, ok := s.Header(4); !ok {
+			t.Errorf("no key ID")
+		} else if kid, err := d.Bytes(); string(kid) != "key1" || err != nil {
+			t.Errorf("got key ID %q, %v", kid, err)
+		}
+		if _, ok := s.Header(3); ok {
+			t.Errorf("found a content type")
+		}
+	}
+	if _, err := ParseSign1(append(msg, 0)); err == nil {
+		t.Errorf("trailing data accepted")
+	}
+	if _, err := ParseSign1(msg[:len(msg)-1]); err == nil {
+		t.Errorf("truncated message accepted")
+	}
+}
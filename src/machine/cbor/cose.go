@@ -0,0 +1,106 @@
+package cbor
+
+import "errors"
+
+// tagSign1 is the tag of a COSE_Sign1 message.
+const tagSign1 = 18
+
+var errSign1 = errors.New("cbor: malformed COSE_Sign1 message")
+
+// Sign1 is a COSE_Sign1 message: a payload signed by a single key. All its
+// fields are slices of the message it was parsed from.
+type Sign1 struct {
+	// Protected is the encoded map of the protected header parameters, which
+	// the signature covers, such as the algorithm.
+	Protected []byte
+
+	// Unprotected is the encoded map of the other header parameters, such as
+	// the key ID.
+	Unprotected []byte
+
+	Payload   []byte
+	Signature []byte
+}
+
+// ParseSign1 parses a COSE_Sign1 message, tagged or not. Its payload must be
+// included in the message.
+func ParseSign1(msg []byte) (Sign1, error) {
+	var s Sign1
+	d := NewDecoder(msg)
+	if t, err := d.Type(); err == nil && t == TypeTag {
+		if tag, err := d.Tag(); err != nil || tag != tagSign1 {
+			return s, errSign1
+		}
+	}
+	if n, err := d.ArrayHeader(); err != nil || n != 4 {
+		return s, errSign1
+	}
+	var err error
+	if s.Protected, err = d.Bytes(); err != nil {
+		return s, err
+	}
+	start := len(msg) - len(d.Rest())
+	if t, err := d.Type(); err != nil || t != TypeMap {
+		return s, errSign1
+	}
+	if err := d.Skip(); err != nil {
+		return s, err
+	}
+	s.Unprotected = msg[start : len(msg)-len(d.Rest())]
+	if s.Payload, err = d.Bytes(); err != nil {
+		return s, err
+	}
+	if s.Signature, err = d.Bytes(); err != nil {
+		return s, err
+	}
+	if !d.Done() {
+		return s, errSign1
+	}
+	return s, nil
+}
+
+// Header returns a Decoder positioned on the value of the header parameter
+// label, looking in the protected parameters first, and whether it was found.
+// The algorithm is label 1, and the key ID label 4.
+func (s *Sign1) Header(label int64) (Decoder, bool) {
+	// An empty protected header stands for an empty map.
+	if len(s.Protected) != 0 {
+		if d, ok := findLabel(s.Protected, label); ok {
+			return d, true
+		}
+	}
+	return findLabel(s.Unprotected, label)
+}
+
+func findLabel(header []byte, label int64) (Decoder, bool) {
+	d := NewDecoder(header)
+	n, err := d.MapHeader()
+	if err != nil {
+		return d, false
+	}
+	for i := 0; i < n; i++ {
+		if t, err := d.Type(); err == nil && (t == TypeUint || t == TypeNegInt) {
+			if key, err := d.Int(); err == nil && key == label {
+				return d, true
+			}
+		} else if d.Skip() != nil {
+			return d, false
+		}
+		if d.Skip() != nil {
+			return d, false
+		}
+	}
+	return d, false
+}
+
+// AppendToBeSigned appends the Sig_structure of the message, the bytes that
+// the signature was computed over, for external data aad (usually nil). The
+// protected header, aad and payload are copied, so the buffer needs room for
+// them and 40 more bytes to avoid allocating.
+func (s *Sign1) AppendToBeSigned(b []byte, aad []byte) []byte {
+	b = AppendArrayHeader(b, 4)
+	b = AppendText(b, "Signature1")
+	b = AppendBytes(b, s.Protected)
+	b = AppendBytes(b, aad)
+	return AppendBytes(b, s.Payload)
+}
@@ -0,0 +1,17 @@
+//go:build wioterminal
+// +build wioterminal
+
+package machine
+
+// Boards with a Grove I2C connector define GROVE_I2C_SDA_PIN,
+// GROVE_I2C_SCL_PIN and the bus wired to them (groveI2C).
+
+// GroveI2C configures and returns the I2C bus of the Grove I2C connector, at
+// the default frequency of 100kHz.
+func GroveI2C() (*I2C, error) {
+	err := groveI2C.Configure(I2CConfig{
+		SDA: GROVE_I2C_SDA_PIN,
+		SCL: GROVE_I2C_SCL_PIN,
+	})
+	return groveI2C, err
+}
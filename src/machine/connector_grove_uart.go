@@ -0,0 +1,18 @@
+//go:build m5stack_core2
+// +build m5stack_core2
+
+package machine
+
+// Boards with a Grove UART connector define GROVE_UART_TX_PIN,
+// GROVE_UART_RX_PIN and the UART wired to them (groveUART).
+
+// GroveUART configures and returns the UART of the Grove UART connector, at
+// the given baud rate, or 115200 baud if it is zero.
+func GroveUART(baudRate uint32) (*UART, error) {
+	groveUART.Configure(UARTConfig{
+		BaudRate: baudRate,
+		TX:       GROVE_UART_TX_PIN,
+		RX:       GROVE_UART_RX_PIN,
+	})
+	return groveUART, nil
+}
@@ -0,0 +1,18 @@
+//go:build clue_alpha || feather_rp2040 || gnse || macropad_rp2040 || qtpy_rp2040 || thingplus_rp2040 || trinkey_qt2040 || tufty2040
+// +build clue_alpha feather_rp2040 gnse macropad_rp2040 qtpy_rp2040 thingplus_rp2040 trinkey_qt2040 tufty2040
+
+package machine
+
+// Boards with a Qwiic or STEMMA QT connector define QWIIC_SDA_PIN,
+// QWIIC_SCL_PIN and the bus wired to them (qwiicI2C). Both connectors are the
+// same 4-pin JST SH I2C port with 3.3V power, so one set of names covers them.
+
+// QwiicI2C configures and returns the I2C bus of the Qwiic or STEMMA QT
+// connector, at the default frequency of 100kHz.
+func QwiicI2C() (*I2C, error) {
+	err := qwiicI2C.Configure(I2CConfig{
+		SDA: QWIIC_SDA_PIN,
+		SCL: QWIIC_SCL_PIN,
+	})
+	return qwiicI2C, err
+}
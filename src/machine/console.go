@@ -0,0 +1,128 @@
+package machine
+
+import (
+	"errors"
+	stdio "io"
+)
+
+// Console sends the output of the program to several sinks at once, such as
+// the USB CDC port and a UART, and reads the input from one of them. On boards
+// that use USB for their console it can replace Serial, so that println goes to
+// all the sinks:
+//
+//	machine.Serial = &machine.Console{
+//		Input: machine.USBCDC,
+//		Sinks: []machine.ConsoleSink{
+//			{Writer: machine.USBCDC, Ready: machine.USBCDC.DTR},
+//			{Writer: machine.UART1},
+//			{Writer: machine.RTT},
+//			{Writer: &crashLog},
+//		},
+//	}
+//
+// Every sink has its own policy for when it isn't ready, so that a USB port
+// without a terminal attached doesn't hold up the UART or the flash log. RTT,
+// on Cortex-M chips, goes to a debug probe. An EventLog sink gathers the
+// output into a record per line, rather than a record per write.
+type Console struct {
+	Sinks []ConsoleSink
+
+	// Input is where ReadByte reads from. It may be nil for a console that
+	// only writes.
+	Input interface {
+		ReadByte() (byte, error)
+		Buffered() int
+	}
+}
+
+// ConsoleSink is an output of a Console.
+type ConsoleSink struct {
+	Writer stdio.Writer
+
+	// Ready reports whether the sink can take output, such as the DTR of a
+	// USB CDC port, which is set while a terminal has the port open. A nil
+	// Ready means the sink is always ready.
+	Ready func() bool
+
+	// Policy is what to do with output while the sink isn't ready.
+	Policy ConsolePolicy
+
+	// Dropped counts the bytes that weren't written to the sink, because it
+	// wasn't ready or returned an error.
+	Dropped uint32
+}
+
+// ConsolePolicy selects what a Console does with output for a sink that isn't
+// ready.
+type ConsolePolicy uint8
+
+const (
+	// ConsoleDrop drops the output, and counts it in Dropped.
+	ConsoleDrop ConsolePolicy = iota
+
+	// ConsoleWait waits for the sink to be ready, which holds up the other
+	// sinks as well.
+	ConsoleWait
+)
+
+var errConsoleNoInput = errors.New("console: no input")
+
+// Configure does nothing: the sinks are configured on their own.
+func (c *Console) Configure(config UARTConfig) error {
+	return nil
+}
+
+// Write writes p to every sink. It never fails: output that a sink can't take
+// is counted in its Dropped.
+func (c *Console) Write(p []byte) (n int, err error) {
+	for i := range c.Sinks {
+		s := &c.Sinks[i]
+		if s.Ready != nil && !s.Ready() {
+			if s.Policy != ConsoleWait {
+				s.Dropped += uint32(len(p))
+				continue
+			}
+			for !s.Ready() {
+				gosched()
+			}
+		}
+		written, err := s.Writer.Write(p)
+		if err != nil || written < len(p) {
+			s.Dropped += uint32(len(p) - written)
+		}
+	}
+	return len(p), nil
+}
+
+// WriteByte writes a byte to every sink.
+func (c *Console) WriteByte(b byte) error {
+	buf := [1]byte{b}
+	c.Write(buf[:])
+	return nil
+}
+
+// ReadByte reads a byte from Input.
+func (c *Console) ReadByte() (byte, error) {
+	if c.Input == nil {
+		return 0, errConsoleNoInput
+	}
+	return c.Input.ReadByte()
+}
+
+// Buffered returns the number of bytes that can be read from Input.
+func (c *Console) Buffered() int {
+	if c.Input == nil {
+		return 0
+	}
+	return c.Input.Buffered()
+}
+
+// DTR always returns true: the console is always there.
+func (c *Console) DTR() bool {
+	return true
+}
+
+// RTS always returns true: the console is always there.
+func (c *Console) RTS() bool {
+	return true
+}
@@ -0,0 +1,13 @@
+package machine
+
+import "errors"
+
+// DisableDebugPins and EnableDebugPins switch the SWD (and JTAG) pins of the
+// chip between their debug function and regular GPIO use, on chips where they
+// are shared with GPIOs. Once the debug pins are disabled a debugger can no
+// longer attach to the running program, so boards that disable them should do
+// so some time after startup, or use the debugger's connect-under-reset mode
+// to recover.
+//
+// On chips with dedicated debug pins they return ErrDebugPinsDedicated.
+var ErrDebugPinsDedicated = errors.New("machine: debug pins are dedicated and cannot be used as GPIO")
@@ -0,0 +1,16 @@
+//go:build nrf || rp2040
+// +build nrf rp2040
+
+package machine
+
+// DisableDebugPins returns ErrDebugPinsDedicated: the SWD pins of this chip
+// are not shared with GPIOs.
+func DisableDebugPins() error {
+	return ErrDebugPinsDedicated
+}
+
+// EnableDebugPins returns ErrDebugPinsDedicated: the SWD pins of this chip
+// are always enabled.
+func EnableDebugPins() error {
+	return ErrDebugPinsDedicated
+}
@@ -0,0 +1,21 @@
+//go:build nrf || sam
+// +build nrf sam
+
+package machine
+
+// appendWordsBE appends the bytes of words to b, most significant first, so
+// that a device ID reads the same in hex as the words printed one after the
+// other.
+func appendWordsBE(b []byte, words ...uint32) []byte {
+	for _, w := range words {
+		b = append(b, byte(w>>24), byte(w>>16), byte(w>>8), byte(w))
+	}
+	return b
+}
+
+// usbSerialNumber returns the serial number of the USB device, which is the
+// device ID of the chip, so that the host names its ports the same way every
+// time the device is plugged in.
+func usbSerialNumber() []byte {
+	return DeviceID()
+}
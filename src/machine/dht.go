@@ -0,0 +1,123 @@
+//go:build !gameboyadvance
+// +build !gameboyadvance
+
+package machine
+
+import (
+	"errors"
+	"runtime/interrupt"
+)
+
+// DHTType is the type of a DHT temperature and humidity sensor.
+type DHTType uint8
+
+const (
+	DHT11 DHTType = iota
+	DHT22         // also AM2302
+)
+
+var (
+	errDHTTimeout  = errors.New("dht: no response from sensor")
+	errDHTChecksum = errors.New("dht: checksum mismatch")
+)
+
+// ReadDHT reads a DHT11 or DHT22 sensor on the given pin, and returns the
+// temperature in milli degrees Celsius and the relative humidity in hundredths
+// of a percent. The data line needs a pull-up resistor, which most sensor
+// modules have. Sensors must not be read more than once every two seconds
+// (once a second for the DHT11), or they return stale data.
+//
+// The sensor sends each bit as a low pulse of 50µs followed by a high pulse
+// of about 27µs for a zero or 70µs for a one. Goroutine switches and
+// interrupts easily stretch a pulse when it is measured in Go, so ReadDHT
+// disables interrupts for the 5ms of the transfer. It doesn't measure time
+// in microseconds either, as reading the time isn't precise enough on every
+// chip: it counts how often it sees the same level in a tight loop, and
+// decides each bit by comparing the length of its high and low pulse.
+func ReadDHT(pin Pin, sensor DHTType) (temperature int32, humidity int32, err error) {
+	data, err := readDHTData(pin, sensor)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch sensor {
+	case DHT11:
+		humidity = int32(data[0])*100 + int32(data[1])*10
+		temperature = int32(data[2]&0x7f)*1000 + int32(data[3])*100
+		if data[2]&0x80 != 0 {
+			temperature = -temperature
+		}
+	default:
+		humidity = (int32(data[0])<<8 | int32(data[1])) * 10
+		temperature = (int32(data[2]&0x7f)<<8 | int32(data[3])) * 100
+		if data[2]&0x80 != 0 {
+			temperature = -temperature
+		}
+	}
+	return temperature, humidity, nil
+}
+
+// readDHTData reads the 5 data bytes of a DHT sensor and verifies their
+// checksum.
+func readDHTData(pin Pin, sensor DHTType) (data [5]byte, err error) {
+	// Start signal: hold the line low for 18ms (DHT11) or 1ms (DHT22). This
+	// is also used to calibrate the timeout: count how often the wait loop
+	// runs in the last millisecond, in which it sees the low level all the
+	// time.
+	pin.Configure(PinConfig{Mode: PinOutput})
+	pin.Low()
+	if sensor == DHT11 {
+		sleepMicroseconds(17000)
+	}
+	var perMillisecond uint32
+	for end := nanotime() + 1e6; nanotime() < end; {
+		dhtWait(pin, false, 100)
+		perMillisecond += 100
+	}
+	// The longest pulse lasts 80µs, time out after about 200µs.
+	timeout := perMillisecond/5 + 1
+
+	mask := interrupt.Disable()
+	pin.Configure(PinConfig{Mode: PinInput})
+
+	// Response: the sensor pulls the line low after 20-40µs, for 80µs, and
+	// releases it for 80µs.
+	if dhtWait(pin, true, timeout) == 0 || dhtWait(pin, false, timeout) == 0 ||
+		dhtWait(pin, true, timeout) == 0 {
+		interrupt.Restore(mask)
+		return data, errDHTTimeout
+	}
+	var cycles [80]uint32
+	for i := 0; i < 80; i += 2 {
+		cycles[i] = dhtWait(pin, false, timeout)
+		cycles[i+1] = dhtWait(pin, true, timeout)
+	}
+	interrupt.Restore(mask)
+
+	for i := 0; i < 40; i++ {
+		low, high := cycles[2*i], cycles[2*i+1]
+		if low == 0 || high == 0 {
+			return data, errDHTTimeout
+		}
+		data[i/8] <<= 1
+		if high > low {
+			data[i/8] |= 1
+		}
+	}
+	if data[0]+data[1]+data[2]+data[3] != data[4] {
+		return data, errDHTChecksum
+	}
+	return data, nil
+}
+
+// dhtWait waits while the pin has the given level, and returns how many times
+// it checked, or 0 after checking timeout times.
+//
+//go:noinline
+func dhtWait(pin Pin, level bool, timeout uint32) uint32 {
+	for count := uint32(1); count <= timeout; count++ {
+		if pin.Get() != level {
+			return count
+		}
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+//go:build cortexm
+// +build cortexm
+
+package machine
+
+import (
+	"device/arm"
+	"unsafe"
+)
+
+// dmaSyncForDevice prepares buffers for a DMA transfer on cores with a data
+// cache: src is written back to memory so the DMA controller reads the current
+// data, and dst is written back and discarded so that no dirty cache line
+// overwrites the data the DMA controller writes.
+func dmaSyncForDevice(dst, src []byte) {
+	if !arm.DCacheEnabled() {
+		return
+	}
+	if len(src) != 0 {
+		arm.CleanDCache(unsafe.Pointer(&src[0]), uintptr(len(src)))
+	}
+	if len(dst) != 0 {
+		arm.CleanInvalidateDCache(unsafe.Pointer(&dst[0]), uintptr(len(dst)))
+	}
+}
+
+// dmaSyncForCPU discards the cache lines of dst after a DMA transfer to it, so
+// that the CPU reads the data the DMA controller wrote.
+func dmaSyncForCPU(dst []byte) {
+	if len(dst) != 0 && arm.DCacheEnabled() {
+		arm.InvalidateDCache(unsafe.Pointer(&dst[0]), uintptr(len(dst)))
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !cortexm
+// +build !cortexm
+
+package machine
+
+// Cores without a data cache don't need cache maintenance around DMA
+// transfers.
+
+func dmaSyncForDevice(dst, src []byte) {}
+
+func dmaSyncForCPU(dst []byte) {}
@@ -0,0 +1,41 @@
+//go:build sam || nrf52840
+// +build sam nrf52840
+
+package machine
+
+// Double-tap reset
+//
+// Many UF2 bootloaders enter the bootloader when the reset button is pressed
+// twice in quick succession. For bootloaders that don't, the same convention
+// can be implemented by the program itself with DetectDoubleTap, which keeps a
+// marker in retained memory during a short window after startup. Building
+// with -tags=doubletap calls it automatically at startup.
+
+// DefaultDoubleTapWindow is the window in microseconds during which a second
+// reset enters the bootloader, as used by the doubletap build tag.
+const DefaultDoubleTapWindow = 500_000
+
+const doubleTapMagic = 0xf01669ef
+
+// DetectDoubleTap checks whether the reset button was pressed twice within
+// window microseconds, and if so enters the bootloader with EnterBootloader.
+// Otherwise it waits until the window has passed and returns. It should be
+// called as early as possible after startup.
+func DetectDoubleTap(window int64) {
+	if retainedInternal(retainedDoubleTap) == doubleTapMagic {
+		// Reset within the window of the previous startup.
+		setRetainedInternal(retainedDoubleTap, 0)
+		EnterBootloader()
+	}
+	setRetainedInternal(retainedDoubleTap, doubleTapMagic)
+	sleepMicroseconds(window)
+	setRetainedInternal(retainedDoubleTap, 0)
+}
+
+// RequestBootloader makes the next soft reset enter the bootloader through
+// DetectDoubleTap, as if the reset button was pressed twice. Unlike
+// EnterBootloader, it does not reset the chip itself, so that the program can
+// finish what it is doing first.
+func RequestBootloader() {
+	setRetainedInternal(retainedDoubleTap, doubleTapMagic)
+}
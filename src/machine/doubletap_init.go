@@ -0,0 +1,9 @@
+//go:build doubletap && (sam || nrf52840)
+// +build doubletap
+// +build sam nrf52840
+
+package machine
+
+func init() {
+	DetectDoubleTap(DefaultDoubleTapWindow)
+}
@@ -0,0 +1,59 @@
+package machine
+
+import "errors"
+
+var (
+	ErrEthernetFrameTooLarge = errors.New("machine: Ethernet frame too large")
+	ErrEthernetNoFrame       = errors.New("machine: no Ethernet frame received")
+	ErrEthernetLinkDown      = errors.New("machine: Ethernet link is down")
+	ErrEthernetTimeout       = errors.New("machine: Ethernet timeout")
+)
+
+// EthernetMaxFrameSize is the size of the largest Ethernet frame (without
+// the frame check sequence) that can be sent or received.
+const EthernetMaxFrameSize = 1514
+
+// EthernetConfig is the configuration of an Ethernet MAC.
+type EthernetConfig struct {
+	// MAC is the hardware address of the interface.
+	MAC [6]byte
+
+	// FullDuplex enables full duplex operation. Without it, the MAC runs in
+	// half duplex mode, which works with any link partner.
+	FullDuplex bool
+
+	// Promiscuous disables the address filter, so that all frames on the
+	// network are received.
+	Promiscuous bool
+}
+
+// Ethernet is the interface implemented by Ethernet MACs, either built into
+// the chip or attached as an external controller. It is the hardware contract
+// used by network stacks.
+//
+// Frames are passed without preamble and frame check sequence, which are
+// generated and checked by the hardware. Implementations with a built-in MAC
+// transfer frames with DMA descriptor rings, external controllers use their
+// own packet buffer memory.
+type Ethernet interface {
+	// Configure initializes the MAC and PHY and starts receiving frames.
+	Configure(config EthernetConfig) error
+
+	// HardwareAddr returns the MAC address of the interface.
+	HardwareAddr() [6]byte
+
+	// SendFrame transmits a single Ethernet frame. It blocks until the frame
+	// has been queued for transmission.
+	SendFrame(frame []byte) error
+
+	// ReceiveFrame copies the oldest received frame into buf and returns its
+	// length. It returns ErrEthernetNoFrame if no frame is available.
+	ReceiveFrame(buf []byte) (int, error)
+
+	// LinkUp returns whether the PHY reports an established link.
+	LinkUp() bool
+
+	// SetLinkHandler sets a callback that is called when the link goes up or
+	// down.
+	SetLinkHandler(handler func(up bool))
+}
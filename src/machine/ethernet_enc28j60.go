@@ -0,0 +1,373 @@
+//go:build !baremetal || atmega || esp32 || fe310 || k210 || nrf || (nxp && !mk66f18) || rp2040 || sam || (stm32 && !stm32f7x2 && !stm32l5x2)
+// +build !baremetal atmega esp32 fe310 k210 nrf nxp,!mk66f18 rp2040 sam stm32,!stm32f7x2,!stm32l5x2
+
+package machine
+
+// ENC28J60 is a Microchip ENC28J60 Ethernet controller attached over SPI. It
+// implements the Ethernet interface.
+//
+// The link handler is called from ReceiveFrame and Poll, so one of them must
+// be called regularly.
+type ENC28J60 struct {
+	// Bus is the SPI bus the controller is attached to, in SPI mode 0 at up
+	// to 20MHz.
+	Bus interface {
+		Tx(w, r []byte) error
+	}
+
+	// CS is the chip select pin of the controller.
+	CS Pin
+
+	mac         [6]byte
+	bank        uint8
+	nextPacket  uint16
+	linkHandler func(up bool)
+	buf         [4]byte
+}
+
+// Packet buffer layout. The receive buffer must start at address 0 (errata
+// issue 5), the remainder is used for a single transmit frame.
+const (
+	enc28j60RXStart = 0x0000
+	enc28j60RXStop  = 0x19ff
+	enc28j60TXStart = 0x1a00
+)
+
+// SPI instructions.
+const (
+	enc28j60ReadControl  = 0x00
+	enc28j60ReadBuffer   = 0x3a
+	enc28j60WriteControl = 0x40
+	enc28j60WriteBuffer  = 0x7a
+	enc28j60BitSet       = 0x80
+	enc28j60BitClear     = 0xa0
+	enc28j60SoftReset    = 0xff
+)
+
+// Control registers: the address is in the lower 5 bits, the bank in bits 5
+// and 6. Bit 7 marks MAC and MII registers, which are read with a dummy byte.
+const (
+	enc28j60EIE   = 0x1b
+	enc28j60EIR   = 0x1c
+	enc28j60ESTAT = 0x1d
+	enc28j60ECON2 = 0x1e
+	enc28j60ECON1 = 0x1f
+
+	enc28j60ERDPTL   = 0x00
+	enc28j60EWRPTL   = 0x02
+	enc28j60ETXSTL   = 0x04
+	enc28j60ETXNDL   = 0x06
+	enc28j60ERXSTL   = 0x08
+	enc28j60ERXNDL   = 0x0a
+	enc28j60ERXRDPTL = 0x0c
+
+	enc28j60ERXFCON = 0x20 | 0x18
+	enc28j60EPKTCNT = 0x20 | 0x19
+
+	enc28j60MACON1   = 0x80 | 0x40 | 0x00
+	enc28j60MACON3   = 0x80 | 0x40 | 0x02
+	enc28j60MACON4   = 0x80 | 0x40 | 0x03
+	enc28j60MABBIPG  = 0x80 | 0x40 | 0x04
+	enc28j60MAIPGL   = 0x80 | 0x40 | 0x06
+	enc28j60MAIPGH   = 0x80 | 0x40 | 0x07
+	enc28j60MAMXFLL  = 0x80 | 0x40 | 0x0a
+	enc28j60MICMD    = 0x80 | 0x40 | 0x12
+	enc28j60MIREGADR = 0x80 | 0x40 | 0x14
+	enc28j60MIWRL    = 0x80 | 0x40 | 0x16
+	enc28j60MIWRH    = 0x80 | 0x40 | 0x17
+	enc28j60MIRDL    = 0x80 | 0x40 | 0x18
+	enc28j60MIRDH    = 0x80 | 0x40 | 0x19
+
+	enc28j60MAADR5 = 0x80 | 0x60 | 0x00
+	enc28j60MAADR6 = 0x80 | 0x60 | 0x01
+	enc28j60MAADR3 = 0x80 | 0x60 | 0x02
+	enc28j60MAADR4 = 0x80 | 0x60 | 0x03
+	enc28j60MAADR1 = 0x80 | 0x60 | 0x04
+	enc28j60MAADR2 = 0x80 | 0x60 | 0x05
+	enc28j60MISTAT = 0x80 | 0x60 | 0x0a
+)
+
+// PHY registers.
+const (
+	enc28j60PHCON1  = 0x00
+	enc28j60PHSTAT2 = 0x11
+	enc28j60PHCON2  = 0x10
+	enc28j60PHIE    = 0x12
+	enc28j60PHIR    = 0x13
+)
+
+// Register bits.
+const (
+	enc28j60ECON1_TXRST = 0x80
+	enc28j60ECON1_TXRTS = 0x08
+	enc28j60ECON1_RXEN  = 0x04
+	enc28j60ECON2_PKTDE = 0x40
+	enc28j60ECON2_AUTOI = 0x80
+	enc28j60ESTAT_CLKRD = 0x01
+	enc28j60EIR_LINKIF  = 0x10
+	enc28j60EIR_TXIF    = 0x08
+	enc28j60EIR_TXERIF  = 0x02
+	enc28j60EIE_INTIE   = 0x80
+	enc28j60EIE_LINKIE  = 0x10
+
+	enc28j60MACON1_TXPAUS = 0x08
+	enc28j60MACON1_RXPAUS = 0x04
+	enc28j60MACON1_MARXEN = 0x01
+	enc28j60MACON3_PADCFG = 0x20
+	enc28j60MACON3_TXCRCE = 0x10
+	enc28j60MACON3_FRMLNE = 0x02
+	enc28j60MACON3_FULDPX = 0x01
+	enc28j60MACON4_DEFER  = 0x40
+	enc28j60MICMD_MIIRD   = 0x01
+	enc28j60MISTAT_BUSY   = 0x01
+
+	enc28j60ERXFCON_UCEN  = 0x80
+	enc28j60ERXFCON_CRCEN = 0x20
+	enc28j60ERXFCON_BCEN  = 0x01
+
+	enc28j60PHCON1_PDPXMD = 0x0100
+	enc28j60PHCON2_HDLDIS = 0x0100
+	enc28j60PHSTAT2_LSTAT = 0x0400
+	enc28j60PHIE_PGEIE    = 0x0002
+	enc28j60PHIE_PLNKIE   = 0x0010
+)
+
+// Configure resets the controller, sets up the packet buffer, MAC and PHY and
+// enables reception.
+func (e *ENC28J60) Configure(config EthernetConfig) error {
+	e.CS.Configure(PinConfig{Mode: PinOutput})
+	e.CS.High()
+
+	e.command(enc28j60SoftReset, 0)
+	timeout := 100000
+	for e.read(enc28j60ESTAT)&enc28j60ESTAT_CLKRD == 0 {
+		timeout--
+		if timeout == 0 {
+			return ErrEthernetTimeout
+		}
+	}
+	e.bank = 0
+
+	// Packet buffer. ERXRDPT must be odd (errata issue 14).
+	e.nextPacket = enc28j60RXStart
+	e.write16(enc28j60ERXSTL, enc28j60RXStart)
+	e.write16(enc28j60ERXNDL, enc28j60RXStop)
+	e.write16(enc28j60ERXRDPTL, enc28j60RXStop)
+	e.write16(enc28j60ETXSTL, enc28j60TXStart)
+
+	filter := uint8(enc28j60ERXFCON_UCEN | enc28j60ERXFCON_CRCEN | enc28j60ERXFCON_BCEN)
+	if config.Promiscuous {
+		filter = enc28j60ERXFCON_CRCEN
+	}
+	e.write(enc28j60ERXFCON, filter)
+
+	// MAC
+	e.write(enc28j60MACON1, enc28j60MACON1_MARXEN|enc28j60MACON1_TXPAUS|enc28j60MACON1_RXPAUS)
+	macon3 := uint8(enc28j60MACON3_PADCFG | enc28j60MACON3_TXCRCE | enc28j60MACON3_FRMLNE)
+	if config.FullDuplex {
+		macon3 |= enc28j60MACON3_FULDPX
+		e.write(enc28j60MABBIPG, 0x15)
+		e.writePHY(enc28j60PHCON1, enc28j60PHCON1_PDPXMD)
+	} else {
+		e.write(enc28j60MACON4, enc28j60MACON4_DEFER)
+		e.write(enc28j60MABBIPG, 0x12)
+		e.write(enc28j60MAIPGH, 0x0c)
+		e.writePHY(enc28j60PHCON2, enc28j60PHCON2_HDLDIS)
+	}
+	e.write(enc28j60MACON3, macon3)
+	e.write(enc28j60MAIPGL, 0x12)
+	e.write16(enc28j60MAMXFLL, EthernetMaxFrameSize+4)
+
+	e.mac = config.MAC
+	e.write(enc28j60MAADR1, config.MAC[0])
+	e.write(enc28j60MAADR2, config.MAC[1])
+	e.write(enc28j60MAADR3, config.MAC[2])
+	e.write(enc28j60MAADR4, config.MAC[3])
+	e.write(enc28j60MAADR5, config.MAC[4])
+	e.write(enc28j60MAADR6, config.MAC[5])
+
+	// Report link changes in EIR.
+	e.writePHY(enc28j60PHIE, enc28j60PHIE_PGEIE|enc28j60PHIE_PLNKIE)
+	e.command(enc28j60BitSet|enc28j60EIE, enc28j60EIE_INTIE|enc28j60EIE_LINKIE)
+
+	e.command(enc28j60BitSet|enc28j60ECON2, enc28j60ECON2_AUTOI)
+	e.command(enc28j60BitSet|enc28j60ECON1, enc28j60ECON1_RXEN)
+	return nil
+}
+
+// HardwareAddr returns the MAC address of the interface.
+func (e *ENC28J60) HardwareAddr() [6]byte {
+	return e.mac
+}
+
+// LinkUp returns whether the PHY reports an established link.
+func (e *ENC28J60) LinkUp() bool {
+	return e.readPHY(enc28j60PHSTAT2)&enc28j60PHSTAT2_LSTAT != 0
+}
+
+// SetLinkHandler sets a callback that is called when the link goes up or
+// down. It is called from ReceiveFrame and Poll.
+func (e *ENC28J60) SetLinkHandler(handler func(up bool)) {
+	e.linkHandler = handler
+}
+
+// Poll checks for link changes and calls the link handler.
+func (e *ENC28J60) Poll() {
+	if e.read(enc28j60EIR)&enc28j60EIR_LINKIF == 0 {
+		return
+	}
+	// Reading PHIR clears the interrupt.
+	e.readPHY(enc28j60PHIR)
+	if e.linkHandler != nil {
+		e.linkHandler(e.LinkUp())
+	}
+}
+
+// SendFrame transmits a single Ethernet frame. It waits for the previous
+// frame to be transmitted first.
+func (e *ENC28J60) SendFrame(frame []byte) error {
+	if len(frame) > EthernetMaxFrameSize {
+		return ErrEthernetFrameTooLarge
+	}
+	timeout := 100000
+	for e.read(enc28j60ECON1)&enc28j60ECON1_TXRTS != 0 {
+		if e.read(enc28j60EIR)&enc28j60EIR_TXERIF != 0 {
+			break
+		}
+		timeout--
+		if timeout == 0 {
+			return ErrEthernetTimeout
+		}
+	}
+
+	// Reset the transmit logic to recover from errors (errata issue 12).
+	e.command(enc28j60BitSet|enc28j60ECON1, enc28j60ECON1_TXRST)
+	e.command(enc28j60BitClear|enc28j60ECON1, enc28j60ECON1_TXRST)
+	e.command(enc28j60BitClear|enc28j60EIR, enc28j60EIR_TXIF|enc28j60EIR_TXERIF)
+
+	// The frame is preceded by a per-packet control byte, zero means the
+	// settings in MACON3 are used.
+	e.write16(enc28j60EWRPTL, enc28j60TXStart)
+	e.write16(enc28j60ETXNDL, enc28j60TXStart+uint16(len(frame)))
+	e.buf[0] = enc28j60WriteBuffer
+	e.buf[1] = 0x00
+	e.CS.Low()
+	e.Bus.Tx(e.buf[:2], nil)
+	e.Bus.Tx(frame, nil)
+	e.CS.High()
+
+	e.command(enc28j60BitSet|enc28j60ECON1, enc28j60ECON1_TXRTS)
+	return nil
+}
+
+// ReceiveFrame copies the oldest received frame into buf and returns its
+// length. Frames that do not fit in buf are truncated.
+func (e *ENC28J60) ReceiveFrame(buf []byte) (int, error) {
+	e.Poll()
+	if e.read(enc28j60EPKTCNT) == 0 {
+		return 0, ErrEthernetNoFrame
+	}
+
+	// Each frame is preceded by the pointer to the next frame and the
+	// receive status vector.
+	var header [6]byte
+	e.write16(enc28j60ERDPTL, e.nextPacket)
+	e.readBuffer(header[:])
+	e.nextPacket = uint16(header[0]) | uint16(header[1])<<8
+	length := int(uint16(header[2])|uint16(header[3])<<8) - 4 // strip FCS
+	received := header[4]&0x80 != 0
+
+	n := 0
+	if received && length > 0 {
+		n = length
+		if n > len(buf) {
+			n = len(buf)
+		}
+		e.readBuffer(buf[:n])
+	}
+
+	// Free the buffer space. ERXRDPT must be odd (errata issue 14).
+	if e.nextPacket == enc28j60RXStart {
+		e.write16(enc28j60ERXRDPTL, enc28j60RXStop)
+	} else {
+		e.write16(enc28j60ERXRDPTL, e.nextPacket-1)
+	}
+	e.command(enc28j60BitSet|enc28j60ECON2, enc28j60ECON2_PKTDE)
+
+	if !received {
+		return 0, ErrEthernetNoFrame
+	}
+	return n, nil
+}
+
+func (e *ENC28J60) command(op, data uint8) {
+	e.buf[0] = op
+	e.buf[1] = data
+	e.CS.Low()
+	e.Bus.Tx(e.buf[:2], nil)
+	e.CS.High()
+}
+
+func (e *ENC28J60) setBank(reg uint8) {
+	if reg&0x1f >= enc28j60EIE {
+		// common register, available in every bank
+		return
+	}
+	bank := (reg >> 5) & 0x3
+	if bank != e.bank {
+		e.command(enc28j60BitClear|enc28j60ECON1, 0x03)
+		e.command(enc28j60BitSet|enc28j60ECON1, bank)
+		e.bank = bank
+	}
+}
+
+func (e *ENC28J60) read(reg uint8) uint8 {
+	e.setBank(reg)
+	n := 2
+	if reg&0x80 != 0 {
+		// MAC and MII registers return a dummy byte first.
+		n = 3
+	}
+	e.buf[0] = enc28j60ReadControl | (reg & 0x1f)
+	e.buf[1] = 0
+	e.buf[2] = 0
+	e.CS.Low()
+	e.Bus.Tx(e.buf[:n], e.buf[:n])
+	e.CS.High()
+	return e.buf[n-1]
+}
+
+func (e *ENC28J60) write(reg, value uint8) {
+	e.setBank(reg)
+	e.command(enc28j60WriteControl|(reg&0x1f), value)
+}
+
+func (e *ENC28J60) write16(reg uint8, value uint16) {
+	e.write(reg, uint8(value))
+	e.write(reg+1, uint8(value>>8))
+}
+
+func (e *ENC28J60) readBuffer(data []byte) {
+	e.buf[0] = enc28j60ReadBuffer
+	e.CS.Low()
+	e.Bus.Tx(e.buf[:1], nil)
+	e.Bus.Tx(nil, data)
+	e.CS.High()
+}
+
+func (e *ENC28J60) readPHY(reg uint8) uint16 {
+	e.write(enc28j60MIREGADR, reg)
+	e.write(enc28j60MICMD, enc28j60MICMD_MIIRD)
+	for e.read(enc28j60MISTAT)&enc28j60MISTAT_BUSY != 0 {
+	}
+	e.write(enc28j60MICMD, 0)
+	return uint16(e.read(enc28j60MIRDL)) | uint16(e.read(enc28j60MIRDH))<<8
+}
+
+func (e *ENC28J60) writePHY(reg uint8, value uint16) {
+	e.write(enc28j60MIREGADR, reg)
+	e.write(enc28j60MIWRL, uint8(value))
+	e.write(enc28j60MIWRH, uint8(value>>8))
+	for e.read(enc28j60MISTAT)&enc28j60MISTAT_BUSY != 0 {
+	}
+}
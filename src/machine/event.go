@@ -0,0 +1,90 @@
+//go:build !scheduler.none
+// +build !scheduler.none
+
+package machine
+
+import (
+	"internal/task"
+	"runtime/interrupt"
+	_ "unsafe" // for go:linkname
+)
+
+//go:linkname scheduleTask runtime.runqueuePushBack
+func scheduleTask(*task.Task)
+
+// Event notifies a goroutine of something that happened in an interrupt, such
+// as a finished DMA transfer, a timer compare or a change of the USB state.
+// The goroutine is paused while it waits, so the chip can sleep in the
+// meantime, unlike when it polls a flag.
+//
+// An Event is usually signaled from an interrupt callback:
+//
+//	var tick machine.Event
+//	timer.SetMatchInterrupt(0, func(uint8) { tick.Signal() })
+//	for {
+//		select {
+//		case <-tick.C():
+//			// ...
+//		case <-stop:
+//			return
+//		}
+//	}
+//
+// The zero value is an event that hasn't been signaled.
+type Event struct {
+	signaled bool
+	waiter   *task.Task
+	c        chan struct{}
+}
+
+// Signal wakes up the goroutine waiting for the event, or the next one to wait
+// if none does. Signals that nobody waited for are merged into one. It may be
+// called from an interrupt.
+func (e *Event) Signal() {
+	mask := interrupt.Disable()
+	if t := e.waiter; t != nil {
+		e.waiter = nil
+		scheduleTask(t)
+	} else {
+		e.signaled = true
+	}
+	interrupt.Restore(mask)
+}
+
+// Wait waits until the event is signaled. Only one goroutine may wait for an
+// event at a time.
+func (e *Event) Wait() {
+	mask := interrupt.Disable()
+	if e.signaled {
+		e.signaled = false
+		interrupt.Restore(mask)
+		return
+	}
+	e.waiter = task.Current()
+	interrupt.Restore(mask)
+	// A signal that arrives before the task is paused has already put it back
+	// in the run queue, so it resumes right away.
+	task.Pause()
+}
+
+// C returns a channel that receives a value every time the event is signaled,
+// to wait for it in a select statement. The first call starts a goroutine that
+// waits for the event, so Wait must not be used after that.
+func (e *Event) C() <-chan struct{} {
+	if e.c == nil {
+		e.c = make(chan struct{}, 1)
+		go e.forward()
+	}
+	return e.c
+}
+
+func (e *Event) forward() {
+	for {
+		e.Wait()
+		select {
+		case e.c <- struct{}{}:
+		default:
+			// The last signal hasn't been received yet.
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build scheduler.none
+// +build scheduler.none
+
+package machine
+
+import "runtime/volatile"
+
+// Event notifies the program of something that happened in an interrupt, such
+// as a finished DMA transfer, a timer compare or a change of the USB state.
+//
+// Without a scheduler, the program waits for an event by polling it.
+type Event struct {
+	signaled volatile.Register8
+}
+
+// Signal signals the event. It may be called from an interrupt.
+func (e *Event) Signal() {
+	e.signaled.Set(1)
+}
+
+// Wait waits until the event is signaled.
+func (e *Event) Wait() {
+	for e.signaled.Get() == 0 {
+	}
+	e.signaled.Set(0)
+}
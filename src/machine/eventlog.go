@@ -0,0 +1,220 @@
+package machine
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// EventLog is an append-only log of fixed-size records in flash, for black box
+// diagnostics: a device appends events as it runs, and reads them back after a
+// crash or a reboot. When the device is full, the oldest erase block of records
+// is erased to make room, so the log always holds the latest records.
+//
+//	log := machine.EventLog{Device: flash, RecordSize: 12}
+//	log.Configure()
+//	log.Append([]byte("boot"))
+//	log.Iterate(func(seq uint32, record []byte) bool {
+//		println(seq, string(record))
+//		return true
+//	})
+//
+// Every record is stored with a sequence number and a CRC, so that a record
+// that was being written when power was lost is skipped. The log uses the whole
+// device, which needs at least two erase blocks.
+type EventLog struct {
+	Device BlockDevice
+
+	// RecordSize is the size of a record in bytes. Shorter records are padded
+	// with zeros.
+	RecordSize int
+
+	slotSize int64 // bytes of a stored record, including the header and CRC
+	perBlock int64 // records per erase block
+	slots    int64 // records in the device
+	next     int64 // slot of the next record
+	seq      uint32
+	buf      []byte
+	line     []byte // bytes gathered by Write for the next record
+}
+
+// A stored record is a little endian sequence number, the record, and the
+// CRC-32 of both, padded to a multiple of the write block size with 0xff.
+const (
+	eventLogHeaderSize = 4
+	eventLogCRCSize    = 4
+	eventLogUnused     = 0xffffffff // sequence number of an erased slot
+)
+
+var (
+	errEventLogRecordSize = errors.New("eventlog: record too large")
+	errEventLogDevice     = errors.New("eventlog: device holds less than two erase blocks of records")
+)
+
+// Configure checks the device and finds the end of the log, scanning all
+// records. It must be called before the other methods.
+func (l *EventLog) Configure() error {
+	writeSize := l.Device.WriteBlockSize()
+	size := int64(eventLogHeaderSize + l.RecordSize + eventLogCRCSize)
+	l.slotSize = (size + writeSize - 1) / writeSize * writeSize
+	l.perBlock = l.Device.EraseBlockSize() / l.slotSize
+	blocks := l.Device.Size() / l.Device.EraseBlockSize()
+	if l.RecordSize <= 0 || l.perBlock == 0 || blocks < 2 {
+		return errEventLogDevice
+	}
+	l.slots = blocks * l.perBlock
+	l.buf = make([]byte, l.slotSize)
+	l.line = make([]byte, 0, l.RecordSize)
+
+	// The next record goes after the one with the highest sequence number.
+	l.next, l.seq = 0, 0
+	found := false
+	for slot := int64(0); slot < l.slots; slot++ {
+		seq, ok, err := l.read(slot)
+		if err != nil {
+			return err
+		}
+		if ok && (!found || seq >= l.seq) {
+			l.next, l.seq, found = slot+1, seq+1, true
+		}
+	}
+
+	// Skip the slots that were being written when power was lost: they can't
+	// be written again before they are erased.
+	for ; l.next%l.perBlock != 0; l.next++ {
+		if _, err := l.Device.ReadAt(l.buf, l.next*l.slotSize); err != nil {
+			return err
+		}
+		if eventLogErased(l.buf) {
+			break
+		}
+	}
+	l.next %= l.slots
+	return nil
+}
+
+// Append adds a record at the end of the log.
+func (l *EventLog) Append(record []byte) error {
+	if len(record) > l.RecordSize {
+		return errEventLogRecordSize
+	}
+	if l.next%l.perBlock == 0 {
+		// Start a new erase block, which drops the oldest records.
+		if err := l.Device.EraseBlocks(l.next/l.perBlock, 1); err != nil {
+			return err
+		}
+	}
+	binary.LittleEndian.PutUint32(l.buf, l.seq)
+	data := l.buf[eventLogHeaderSize : eventLogHeaderSize+l.RecordSize]
+	n := copy(data, record)
+	for i := n; i < len(data); i++ {
+		data[i] = 0
+	}
+	end := eventLogHeaderSize + l.RecordSize
+	binary.LittleEndian.PutUint32(l.buf[end:], crc32IEEE(l.buf[:end]))
+	for i := end + eventLogCRCSize; i < len(l.buf); i++ {
+		l.buf[i] = 0xff
+	}
+	if _, err := l.Device.WriteAt(l.buf, l.next*l.slotSize); err != nil {
+		return err
+	}
+	l.next = (l.next + 1) % l.slots
+	l.seq++
+	return SyncBlockDevice(l.Device)
+}
+
+// Write appends text to the log, so that the log can be an io.Writer such as a
+// sink of a Console, which gets the output of println a few bytes at a time.
+// The bytes are gathered into a record, which is appended at the end of a line
+// or once it holds RecordSize bytes; Flush appends an unfinished line.
+func (l *EventLog) Write(p []byte) (n int, err error) {
+	for _, b := range p {
+		l.line = append(l.line, b)
+		if b == '\n' || len(l.line) == l.RecordSize {
+			if err := l.Flush(); err != nil {
+				return n, err
+			}
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Flush appends the bytes that Write gathered for an unfinished line as a
+// record, padded with zeros.
+func (l *EventLog) Flush() error {
+	if len(l.line) == 0 {
+		return nil
+	}
+	err := l.Append(l.line)
+	l.line = l.line[:0]
+	return err
+}
+
+// Iterate calls fn for every record in the log, oldest first, with its
+// sequence number, until fn returns false. The record slice is only valid
+// during the call.
+func (l *EventLog) Iterate(fn func(seq uint32, record []byte) bool) error {
+	// The oldest records are in the erase block after the one being written,
+	// or in the next one to be erased.
+	start := l.next
+	if start%l.perBlock != 0 {
+		start = (start/l.perBlock + 1) * l.perBlock
+	}
+	for i := int64(0); i < l.slots; i++ {
+		slot := (start + i) % l.slots
+		seq, ok, err := l.read(slot)
+		if err != nil {
+			return err
+		}
+		if ok && !fn(seq, l.buf[eventLogHeaderSize:eventLogHeaderSize+l.RecordSize]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Clear erases all records. The sequence numbers continue where they were
+// until the log is configured again, after a reboot for example: Configure then
+// finds no records, and starts them at zero again.
+func (l *EventLog) Clear() error {
+	if err := l.Device.EraseBlocks(0, l.slots/l.perBlock); err != nil {
+		return err
+	}
+	l.next = 0
+	return nil
+}
+
+// read reads a slot into l.buf, and returns its sequence number and whether it
+// holds a valid record.
+func (l *EventLog) read(slot int64) (seq uint32, ok bool, err error) {
+	if _, err := l.Device.ReadAt(l.buf, slot*l.slotSize); err != nil {
+		return 0, false, err
+	}
+	seq = binary.LittleEndian.Uint32(l.buf)
+	end := eventLogHeaderSize + l.RecordSize
+	ok = seq != eventLogUnused && binary.LittleEndian.Uint32(l.buf[end:]) == crc32IEEE(l.buf[:end])
+	return seq, ok, nil
+}
+
+// eventLogErased returns whether a slot reads as erased flash.
+func eventLogErased(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// crc32IEEE returns the IEEE CRC-32 of data. It is computed bit by bit rather
+// than with hash/crc32, whose lookup tables take up kilobytes of RAM.
+func crc32IEEE(data []byte) uint32 {
+	crc := ^uint32(0)
+	for _, b := range data {
+		crc ^= uint32(b)
+		for i := 0; i < 8; i++ {
+			crc = crc>>1 ^ 0xedb88320&-(crc&1)
+		}
+	}
+	return ^crc
+}
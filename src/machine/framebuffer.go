@@ -0,0 +1,193 @@
+package machine
+
+// FramebufferLayout is the memory layout of a Framebuffer, which matches the
+// way a display controller expects the pixels so that the buffer can be sent
+// as is.
+type FramebufferLayout uint8
+
+const (
+	// FramebufferHorizontal stores rows of pixels, one bit per pixel, with the
+	// leftmost pixel in the most significant bit. A line is a row.
+	FramebufferHorizontal FramebufferLayout = iota
+
+	// FramebufferVertical stores pages of 8 rows, where each byte is a column
+	// of 8 pixels with the top pixel in the least significant bit, like the
+	// SSD1306 and similar OLED controllers. A line is a page.
+	FramebufferVertical
+
+	// FramebufferSharpMemory stores rows like the line update command of a
+	// Sharp Memory LCD: each row starts with its (1-based) line address and
+	// ends with a dummy byte, and the leftmost pixel is in the least
+	// significant bit. Send it with SPIConfig.LSBFirst set. A set bit is a
+	// white pixel, and a line is a row.
+	FramebufferSharpMemory
+)
+
+// Framebuffer is a monochrome framebuffer that keeps track of the region that
+// changed since the last flush, so that only that region is sent to the
+// display. Flush hands out the changed lines as a single contiguous slice of
+// the buffer, which can be sent in one SPI transfer: on targets where SPI uses
+// DMA, such as the nRF52, the CPU is free in the meantime.
+type Framebuffer struct {
+	width, height int16
+	layout        FramebufferLayout
+	stride        int // bytes per line
+	offset        int // bytes before the pixels of a line
+	buf           []byte
+
+	// Dirty rectangle, empty if x0 >= x1.
+	x0, y0, x1, y1 int16
+}
+
+// NewFramebuffer allocates a framebuffer of the given size and layout. All
+// pixels are cleared and marked dirty.
+func NewFramebuffer(width, height int16, layout FramebufferLayout) *Framebuffer {
+	fb := &Framebuffer{width: width, height: height, layout: layout}
+	lines := int(height)
+	switch layout {
+	case FramebufferVertical:
+		fb.stride = int(width)
+		lines = (int(height) + 7) / 8
+	case FramebufferSharpMemory:
+		fb.stride = (int(width)+7)/8 + 2
+		fb.offset = 1
+	default:
+		fb.stride = (int(width) + 7) / 8
+	}
+	fb.buf = make([]byte, fb.stride*lines)
+	if layout == FramebufferSharpMemory {
+		for y := 0; y < lines; y++ {
+			fb.buf[y*fb.stride] = byte(y + 1)
+		}
+	}
+	fb.MarkDirty(0, 0, width, height)
+	return fb
+}
+
+// Size returns the size of the framebuffer in pixels.
+func (fb *Framebuffer) Size() (width, height int16) {
+	return fb.width, fb.height
+}
+
+// Buffer returns the memory of the framebuffer. Call MarkDirty after
+// modifying it directly.
+func (fb *Framebuffer) Buffer() []byte {
+	return fb.buf
+}
+
+// index returns the byte and bit of the given pixel.
+func (fb *Framebuffer) index(x, y int16) (int, uint8) {
+	switch fb.layout {
+	case FramebufferVertical:
+		return int(y/8)*fb.stride + int(x), uint8(1) << (y % 8)
+	case FramebufferSharpMemory:
+		return int(y)*fb.stride + fb.offset + int(x/8), uint8(1) << (x % 8)
+	default:
+		return int(y)*fb.stride + int(x/8), uint8(0x80) >> (x % 8)
+	}
+}
+
+// SetPixel sets (on is true) or clears a pixel. Pixels outside the
+// framebuffer are ignored.
+func (fb *Framebuffer) SetPixel(x, y int16, on bool) {
+	if x < 0 || y < 0 || x >= fb.width || y >= fb.height {
+		return
+	}
+	i, bit := fb.index(x, y)
+	old := fb.buf[i]
+	if on {
+		fb.buf[i] |= bit
+	} else {
+		fb.buf[i] &^= bit
+	}
+	if fb.buf[i] != old {
+		fb.MarkDirty(x, y, x+1, y+1)
+	}
+}
+
+// Pixel returns whether a pixel is set.
+func (fb *Framebuffer) Pixel(x, y int16) bool {
+	if x < 0 || y < 0 || x >= fb.width || y >= fb.height {
+		return false
+	}
+	i, bit := fb.index(x, y)
+	return fb.buf[i]&bit != 0
+}
+
+// Fill sets or clears all pixels.
+func (fb *Framebuffer) Fill(on bool) {
+	var c byte
+	if on {
+		c = 0xff
+	}
+	for start := 0; start < len(fb.buf); start += fb.stride {
+		// Leave the line address and dummy byte of the Sharp layout alone.
+		pixels := fb.buf[start+fb.offset : start+fb.stride-fb.offset]
+		for i := range pixels {
+			pixels[i] = c
+		}
+	}
+	fb.MarkDirty(0, 0, fb.width, fb.height)
+}
+
+// MarkDirty adds the rectangle from (x0, y0) up to but not including (x1, y1)
+// to the region that is sent on the next flush.
+func (fb *Framebuffer) MarkDirty(x0, y0, x1, y1 int16) {
+	if x0 < 0 {
+		x0 = 0
+	}
+	if y0 < 0 {
+		y0 = 0
+	}
+	if x1 > fb.width {
+		x1 = fb.width
+	}
+	if y1 > fb.height {
+		y1 = fb.height
+	}
+	if x0 >= x1 || y0 >= y1 {
+		return
+	}
+	if fb.x0 >= fb.x1 {
+		fb.x0, fb.y0, fb.x1, fb.y1 = x0, y0, x1, y1
+		return
+	}
+	if x0 < fb.x0 {
+		fb.x0 = x0
+	}
+	if y0 < fb.y0 {
+		fb.y0 = y0
+	}
+	if x1 > fb.x1 {
+		fb.x1 = x1
+	}
+	if y1 > fb.y1 {
+		fb.y1 = y1
+	}
+}
+
+// Dirty returns the rectangle that changed since the last flush, from (x0,
+// y0) up to but not including (x1, y1), and whether anything changed at all.
+func (fb *Framebuffer) Dirty() (x0, y0, x1, y1 int16, ok bool) {
+	return fb.x0, fb.y0, fb.x1, fb.y1, fb.x0 < fb.x1
+}
+
+// Flush calls write with the lines (rows or pages, depending on the layout)
+// that changed since the last flush, and clears the dirty region if write
+// succeeds. The data covers whole lines, count lines starting at line first.
+// Flush does nothing if nothing changed.
+func (fb *Framebuffer) Flush(write func(first, count int16, data []byte) error) error {
+	_, y0, _, y1, ok := fb.Dirty()
+	if !ok {
+		return nil
+	}
+	if fb.layout == FramebufferVertical {
+		y0, y1 = y0/8, (y1+7)/8
+	}
+	err := write(y0, y1-y0, fb.buf[int(y0)*fb.stride:int(y1)*fb.stride])
+	if err != nil {
+		return err
+	}
+	fb.x0, fb.y0, fb.x1, fb.y1 = 0, 0, 0, 0
+	return nil
+}
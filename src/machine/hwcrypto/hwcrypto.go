@@ -0,0 +1,158 @@
+// Package hwcrypto provides cryptographic primitives that use the crypto
+// accelerator of the chip when there is one, and fall back to the software
+// implementations of the Go standard library otherwise, so that the calling
+// code doesn't change with the chip.
+//
+// On the nRF52840 the ARM CryptoCell 310 generates the random numbers and
+// computes SHA-256. ECDSA signatures are verified in software on every chip,
+// using the hardware SHA-256 where there is one for hashing the message.
+package hwcrypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"math/big"
+)
+
+// Accelerator is a hardware crypto engine. Each method reports whether it
+// handled the operation; when it returns false the software implementation is
+// used instead, so an accelerator only needs to implement what the hardware
+// supports.
+type Accelerator interface {
+	// Random fills b with random bytes from a true random number generator.
+	Random(b []byte) bool
+
+	// SHA256Block runs the SHA-256 compression function over blocks, whose
+	// length is a multiple of 64 bytes, starting from and updating the state
+	// h. It is first called without any blocks to find out whether the
+	// hardware computes SHA-256; if it returns true then, it handles every
+	// later call.
+	SHA256Block(h *[8]uint32, blocks []byte) bool
+
+	// VerifyP256 verifies an ECDSA signature over the NIST P-256 curve and
+	// stores the result in valid.
+	VerifyP256(pub *ecdsa.PublicKey, hash []byte, r, s *big.Int, valid *bool) bool
+}
+
+// accelerator is the crypto engine of the chip, or nil if it has none.
+var accelerator Accelerator
+
+// Accelerated returns whether SHA-256 is computed by the crypto engine of the
+// chip. It is false where it runs in software, even if the random numbers come
+// from the hardware.
+func Accelerated() bool {
+	var h [8]uint32
+	return accelerator != nil && accelerator.SHA256Block(&h, nil)
+}
+
+// Read fills b with random bytes. It implements io.Reader so that it can be
+// used in place of crypto/rand.Reader.
+func Read(b []byte) (n int, err error) {
+	if accelerator != nil && accelerator.Random(b) {
+		return len(b), nil
+	}
+	return rand.Read(b)
+}
+
+// Reader is a global random number generator backed by Read.
+var Reader reader
+
+type reader struct{}
+
+func (reader) Read(b []byte) (n int, err error) {
+	return Read(b)
+}
+
+// NewSHA256 returns a hash.Hash computing the SHA-256 checksum, in hardware
+// when the chip can. Data is hashed as it is written, so that large inputs
+// such as firmware images can be streamed through it.
+func NewSHA256() hash.Hash {
+	if !Accelerated() {
+		return sha256.New()
+	}
+	d := &digest{}
+	d.Reset()
+	return d
+}
+
+// SHA256 returns the SHA-256 digest of data.
+func SHA256(data []byte) (sum [sha256.Size]byte) {
+	h := NewSHA256()
+	h.Write(data)
+	h.Sum(sum[:0])
+	return sum
+}
+
+// VerifyP256 verifies the ECDSA signature r, s of hash using the public key
+// pub, which must be on the NIST P-256 curve.
+func VerifyP256(pub *ecdsa.PublicKey, hash []byte, r, s *big.Int) bool {
+	if pub.Curve != elliptic.P256() {
+		return false
+	}
+	var valid bool
+	if accelerator != nil && accelerator.VerifyP256(pub, hash, r, s, &valid) {
+		return valid
+	}
+	return ecdsa.Verify(pub, hash, r, s)
+}
+
+// digest implements SHA-256 on top of the SHA256Block method of the
+// accelerator. The padding is done here, so the hardware only ever sees whole
+// blocks.
+type digest struct {
+	h    [8]uint32
+	buf  [sha256.BlockSize]byte
+	nbuf int
+	len  uint64
+}
+
+func (d *digest) Reset() {
+	d.h = [8]uint32{0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19}
+	d.nbuf = 0
+	d.len = 0
+}
+
+func (d *digest) Size() int { return sha256.Size }
+
+func (d *digest) BlockSize() int { return sha256.BlockSize }
+
+func (d *digest) Write(p []byte) (n int, err error) {
+	n = len(p)
+	d.len += uint64(n)
+	if d.nbuf > 0 {
+		c := copy(d.buf[d.nbuf:], p)
+		d.nbuf += c
+		p = p[c:]
+		if d.nbuf < len(d.buf) {
+			return n, nil
+		}
+		accelerator.SHA256Block(&d.h, d.buf[:])
+		d.nbuf = 0
+	}
+	if whole := len(p) &^ (sha256.BlockSize - 1); whole > 0 {
+		accelerator.SHA256Block(&d.h, p[:whole])
+		p = p[whole:]
+	}
+	d.nbuf = copy(d.buf[:], p)
+	return n, nil
+}
+
+func (d *digest) Sum(b []byte) []byte {
+	// Pad a copy, so that more data can still be written to d.
+	e := *d
+	var pad [sha256.BlockSize + 8]byte
+	pad[0] = 0x80
+	padLen := 1 + (sha256.BlockSize*2-9-int(e.len%sha256.BlockSize))%sha256.BlockSize
+	binary.BigEndian.PutUint64(pad[padLen:], e.len<<3)
+	e.Write(pad[:padLen+8])
+
+	var sum [sha256.Size]byte
+	for i, v := range e.h {
+		binary.BigEndian.PutUint32(sum[i*4:], v)
+	}
+	return append(b, sum[:]...)
+}
@@ -0,0 +1,179 @@
+//go:build nrf52840
+// +build nrf52840
+
+package hwcrypto
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"math/big"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// Registers of the ARM CryptoCell 310 of the nRF52840. The CRYPTOCELL
+// peripheral only has the enable bit, the engine itself is mapped 4kB above
+// it.
+var (
+	ccEnable = (*volatile.Register32)(unsafe.Pointer(uintptr(0x5002A500)))
+
+	ccRNGISR        = cc(0x104)
+	ccRNGICR        = cc(0x108)
+	ccTRNGConfig    = cc(0x10C)
+	ccEHRData       = (*[6]volatile.Register32)(unsafe.Pointer(cc(0x114)))
+	ccRndSourceEn   = cc(0x12C)
+	ccSampleCnt1    = cc(0x130)
+	ccTRNGDebugCtrl = cc(0x138)
+	ccRNGSWReset    = cc(0x140)
+	ccRNGClkEnable  = cc(0x1C4)
+
+	ccHashH       = (*[8]volatile.Register32)(unsafe.Pointer(cc(0x640)))
+	ccHashControl = cc(0x7C0)
+	ccHashPadEn   = cc(0x7C4)
+	ccHashClkEn   = cc(0x818)
+	ccDMAClkEn    = cc(0x820)
+	ccCryptoCtl   = cc(0x900)
+	ccHashBusy    = cc(0x91C)
+	ccHostIRR     = cc(0xA00)
+	ccHostICR     = cc(0xA08)
+	ccSrcLLIWord0 = cc(0xC28)
+	ccSrcLLIWord1 = cc(0xC2C)
+)
+
+const (
+	ccRNGISREHRValid = 1 << 0
+	ccRNGISRErrors   = 0x0E // autocorrelation, CRNGT and von Neumann errors
+	ccRNGSampleCount = 1000 // ring oscillator cycles between two samples
+	ccTRNGShortOsc   = 0
+
+	ccCryptoCtlHash   = 7
+	ccHashControlSHA2 = 2
+	ccHostIRRMemToDin = 1 << 6
+
+	// The DMA of the CryptoCell can only read RAM, and moves at most 64kB
+	// per transfer, which is rounded down to whole blocks here.
+	ccRAMStart = 0x20000000
+	ccRAMEnd   = 0x20040000
+	ccDMAChunk = 0xFFC0
+)
+
+func cc(offset uintptr) *volatile.Register32 {
+	return (*volatile.Register32)(unsafe.Pointer(0x5002B000 + offset))
+}
+
+// cryptoCell is the ARM CryptoCell 310 of the nRF52840. Its TRNG provides the
+// random numbers and its hash engine computes SHA-256. The public key engine
+// isn't driven, so ECDSA is verified in software.
+//
+// The CryptoCell is only powered while it is in use, as it draws about 1mA
+// when enabled. It can only read data in RAM, so blocks stored in flash are
+// copied to RAM one block at a time.
+type cryptoCell struct{}
+
+func init() {
+	accelerator = cryptoCell{}
+}
+
+func (cryptoCell) Random(b []byte) bool {
+	ccEnable.Set(1)
+	defer ccEnable.Set(0)
+
+	ccRNGClkEnable.Set(1)
+	ccRNGSWReset.Set(1)
+	// The reset takes a few clock cycles, during which writes are lost.
+	for ccSampleCnt1.Get() != ccRNGSampleCount {
+		ccRNGClkEnable.Set(1)
+		ccSampleCnt1.Set(ccRNGSampleCount)
+	}
+	ccTRNGConfig.Set(ccTRNGShortOsc)
+	ccTRNGDebugCtrl.Set(0)
+	ccRNGICR.Set(0xFFFFFFFF)
+	ccRndSourceEn.Set(1)
+	defer func() {
+		ccRndSourceEn.Set(0)
+		ccRNGClkEnable.Set(0)
+	}()
+
+	for len(b) > 0 {
+		isr := ccRNGISR.Get()
+		if isr&ccRNGISRErrors != 0 {
+			// A failed statistical test discards the collected bits and starts
+			// over.
+			ccRNGICR.Set(isr)
+			continue
+		}
+		if isr&ccRNGISREHRValid == 0 {
+			continue
+		}
+		// Reading the last word of the 192 bit entropy register starts the
+		// collection of the next one.
+		var ehr [24]byte
+		for i := range ccEHRData {
+			binary.LittleEndian.PutUint32(ehr[i*4:], ccEHRData[i].Get())
+		}
+		ccRNGICR.Set(ccRNGISREHRValid)
+		b = b[copy(b, ehr[:]):]
+	}
+	return true
+}
+
+func (cryptoCell) SHA256Block(h *[8]uint32, blocks []byte) bool {
+	if len(blocks) == 0 {
+		return true
+	}
+	ccEnable.Set(1)
+	defer ccEnable.Set(0)
+
+	ccHashClkEn.Set(1)
+	ccDMAClkEn.Set(1)
+	ccCryptoCtl.Set(ccCryptoCtlHash)
+	ccHashControl.Set(ccHashControlSHA2)
+	// The padding is done by the caller.
+	ccHashPadEn.Set(0)
+	for i, v := range h {
+		ccHashH[i].Set(v)
+	}
+
+	addr := uintptr(unsafe.Pointer(&blocks[0]))
+	if addr >= ccRAMStart && addr+uintptr(len(blocks)) <= ccRAMEnd {
+		for len(blocks) > 0 {
+			n := len(blocks)
+			if n > ccDMAChunk {
+				n = ccDMAChunk
+			}
+			ccHashDMA(blocks[:n])
+			blocks = blocks[n:]
+		}
+	} else {
+		var buf [64]byte
+		for ; len(blocks) > 0; blocks = blocks[64:] {
+			copy(buf[:], blocks)
+			ccHashDMA(buf[:])
+		}
+	}
+
+	for ccHashBusy.Get() != 0 {
+	}
+	for i := range h {
+		h[i] = ccHashH[i].Get()
+	}
+	ccHashClkEn.Set(0)
+	ccDMAClkEn.Set(0)
+	return true
+}
+
+// ccHashDMA feeds data, which must be in RAM, to the hash engine and waits
+// until it has been read.
+func ccHashDMA(data []byte) {
+	ccHostICR.Set(ccHostIRRMemToDin)
+	ccSrcLLIWord0.Set(uint32(uintptr(unsafe.Pointer(&data[0]))))
+	// Writing the length starts the transfer.
+	ccSrcLLIWord1.Set(uint32(len(data)))
+	for ccHostIRR.Get()&ccHostIRRMemToDin == 0 {
+	}
+	ccHostICR.Set(ccHostIRRMemToDin)
+}
+
+func (cryptoCell) VerifyP256(pub *ecdsa.PublicKey, hash []byte, r, s *big.Int, valid *bool) bool {
+	return false
+}
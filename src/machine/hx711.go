@@ -0,0 +1,120 @@
+//go:build !gameboyadvance
+// +build !gameboyadvance
+
+package machine
+
+import (
+	"errors"
+	"runtime/interrupt"
+)
+
+// HX711Gain selects the input channel and gain of an HX711.
+type HX711Gain uint8
+
+const (
+	HX711GainA128 HX711Gain = iota // channel A, gain 128
+	HX711GainB32                   // channel B, gain 32
+	HX711GainA64                   // channel A, gain 64
+)
+
+var errHX711Timeout = errors.New("hx711: no conversion ready")
+
+// HX711 reads an HX711 load cell amplifier. It clocks the data out itself
+// instead of leaving that to a driver because the clock pulses have a maximum
+// length: the HX711 powers down when the clock stays high for 60µs, and a
+// goroutine switch or an interrupt in the middle of a pulse easily takes
+// longer, corrupting the reading. HX711 disables interrupts for each clock
+// pulse, which lasts about 1µs.
+//
+//	scale := machine.HX711{Clock: machine.D3, Data: machine.D2}
+//	scale.Configure()
+//	value, err := scale.Read()
+type HX711 struct {
+	Clock Pin
+	Data  Pin
+
+	// Gain used for the conversion after the next Read. The first conversion
+	// after power-up always uses HX711GainA128.
+	Gain HX711Gain
+
+	delay uint32 // loop iterations in 1µs
+}
+
+// Configure configures the pins and powers up the HX711.
+func (hx *HX711) Configure() {
+	hx.Clock.Configure(PinConfig{Mode: PinOutput})
+	hx.Clock.Low()
+	hx.Data.Configure(PinConfig{Mode: PinInput})
+
+	// Calibrate the delay loop, as the time to read a pin differs widely
+	// between chips.
+	var perMillisecond uint32
+	for end := nanotime() + 1e6; nanotime() < end; {
+		hx.wait(100)
+		perMillisecond += 100
+	}
+	hx.delay = perMillisecond/1000 + 1
+}
+
+// Ready returns whether a conversion is ready to be read.
+func (hx *HX711) Ready() bool {
+	return !hx.Data.Get()
+}
+
+// Read waits for the next conversion and returns it as a signed 24-bit value.
+// Conversions are ready 10 or 80 times per second, depending on the RATE pin.
+func (hx *HX711) Read() (int32, error) {
+	deadline := nanotime() + 500e6
+	for !hx.Ready() {
+		if nanotime() > deadline {
+			return 0, errHX711Timeout
+		}
+		gosched()
+	}
+	var value uint32
+	for i := 0; i < 24; i++ {
+		value = value<<1 | uint32(hx.pulse())
+	}
+	// One to three more pulses select the gain of the next conversion.
+	for i := 0; i <= int(hx.Gain); i++ {
+		hx.pulse()
+	}
+	// Sign extend.
+	return int32(value<<8) >> 8, nil
+}
+
+// PowerDown puts the HX711 in power down mode, until PowerUp.
+func (hx *HX711) PowerDown() {
+	hx.Clock.High()
+	sleepMicroseconds(100)
+}
+
+// PowerUp leaves power down mode. The next conversion uses HX711GainA128.
+func (hx *HX711) PowerUp() {
+	hx.Clock.Low()
+}
+
+// pulse sends one clock pulse, and returns the data bit the HX711 shifts out
+// with it.
+func (hx *HX711) pulse() uint8 {
+	mask := interrupt.Disable()
+	hx.Clock.High()
+	hx.wait(hx.delay)
+	bit := uint8(0)
+	if hx.Data.Get() {
+		bit = 1
+	}
+	hx.Clock.Low()
+	interrupt.Restore(mask)
+	hx.wait(hx.delay)
+	return bit
+}
+
+// wait reads the data pin n times, as a delay that doesn't depend on a timer.
+//
+//go:noinline
+func (hx *HX711) wait(n uint32) {
+	for i := uint32(0); i < n; i++ {
+		hx.Data.Get()
+	}
+}
@@ -0,0 +1,98 @@
+//go:build atmega || nrf || sam || stm32 || fe310 || k210 || rp2040
+// +build atmega nrf sam stm32 fe310 k210 rp2040
+
+package machine
+
+import "errors"
+
+var (
+	errI2CBusStuck         = errors.New("I2C error: SDA held low by a device")
+	errI2CInvalidCondition = errors.New("I2C error: invalid bus condition")
+)
+
+// I2CCondition is a raw bus condition that cannot be generated by a regular
+// I2C transaction.
+type I2CCondition uint8
+
+const (
+	// I2CWake holds SDA low for the given duration while SCL stays high. This
+	// is the wake condition of secure elements such as the ATECC508 and
+	// ATECC608, which need SDA low for at least 60µs.
+	I2CWake I2CCondition = iota
+
+	// I2CBusClear clocks SCL nine times with SDA released, followed by a STOP
+	// condition, to release a device that is stuck driving SDA low in the
+	// middle of a transfer. The duration is the SCL half period.
+	I2CBusClear
+
+	// I2CStop generates a single STOP condition. The duration is the setup
+	// time before SDA is released.
+	I2CStop
+)
+
+// I2CSendCondition generates a raw bus condition by driving the I2C pins as
+// GPIOs. The pins are driven open drain, relying on the bus pull-up resistors.
+// The duration is in microseconds.
+//
+// Because the pins are taken away from the I2C peripheral, the I2C bus must be
+// configured again with Configure before it can be used:
+//
+//	machine.I2CSendCondition(machine.SCL_PIN, machine.SDA_PIN, machine.I2CWake, 80)
+//	machine.I2C0.Configure(machine.I2CConfig{})
+//
+// Where the SCL and SDA pins cannot be driven separately, I2C.Wake provides a
+// fallback wake condition using the I2C peripheral itself.
+func I2CSendCondition(scl, sda Pin, cond I2CCondition, duration uint32) error {
+	us := int64(duration)
+	i2cRelease(scl)
+	i2cRelease(sda)
+	switch cond {
+	case I2CWake:
+		i2cDriveLow(sda)
+		sleepMicroseconds(us)
+		i2cRelease(sda)
+	case I2CBusClear:
+		for i := 0; i < 9 && !sda.Get(); i++ {
+			i2cDriveLow(scl)
+			sleepMicroseconds(us)
+			i2cRelease(scl)
+			sleepMicroseconds(us)
+		}
+		if !sda.Get() {
+			return errI2CBusStuck
+		}
+		i2cSendStop(scl, sda, us)
+	case I2CStop:
+		i2cSendStop(scl, sda, us)
+	default:
+		return errI2CInvalidCondition
+	}
+	return nil
+}
+
+// I2CWakeBaudRate is the highest bus frequency at which I2C.Wake holds SDA
+// low long enough to wake an ATECC608.
+const I2CWakeBaudRate = 100 * KHz
+
+// i2cSendStop generates a STOP: SDA rises while SCL is high.
+func i2cSendStop(scl, sda Pin, us int64) {
+	i2cDriveLow(scl)
+	i2cDriveLow(sda)
+	sleepMicroseconds(us)
+	i2cRelease(scl)
+	sleepMicroseconds(us)
+	i2cRelease(sda)
+	sleepMicroseconds(us)
+}
+
+// i2cDriveLow sets the output level before making the pin an output, so that
+// the pin never drives the line high, which would fight a device that holds it
+// low.
+func i2cDriveLow(pin Pin) {
+	pin.Low()
+	pin.Configure(PinConfig{Mode: PinOutput})
+}
+
+func i2cRelease(pin Pin) {
+	pin.Configure(PinConfig{Mode: PinInput})
+}
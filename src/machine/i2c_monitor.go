@@ -0,0 +1,174 @@
+//go:build nrf || sam || stm32 || rp2040 || k210 || esp32c3 || mimxrt1062
+// +build nrf sam stm32 rp2040 k210 esp32c3 mimxrt1062
+
+package machine
+
+import (
+	stdio "io"
+	"runtime/interrupt"
+)
+
+// I2CMonitor watches the traffic on an I2C bus without taking part in it, to
+// debug a sensor that misbehaves or a bus with several controllers. It decodes
+// the bus in the interrupts of two spare pins wired to SDA and SCL, so it can
+// follow a bus at 100kHz on most chips but usually not at 400kHz.
+//
+//	monitor := machine.I2CMonitor{SDA: machine.GPIO14, SCL: machine.GPIO15}
+//	monitor.Configure()
+//	for {
+//		monitor.Dump(machine.Serial)
+//		time.Sleep(10 * time.Millisecond)
+//	}
+//
+// Dump prints a transaction per line, for example a register read:
+//
+//	S 3c W A 0f A S 3c R A 81 N P
+//
+// with S for a start condition, the address and direction, each byte followed
+// by A for an ACK or N for a NACK, and P for a stop condition. A byte cut short
+// by a start or stop condition is followed by a question mark.
+type I2CMonitor struct {
+	SDA Pin
+	SCL Pin
+
+	// Decoder state.
+	data  uint8
+	count uint8 // bits of the current byte, before the ACK bit
+	flags uint16
+
+	// Events decoded by the interrupts, read by Dump.
+	events   [128]uint16
+	head     uint8
+	tail     uint8
+	overflow bool
+}
+
+// Bits of an event, above the byte value.
+const (
+	i2cMonitorNACK    = 1 << 8
+	i2cMonitorStart   = 1 << 9  // the byte is an address after a start
+	i2cMonitorStop    = 1 << 10 // a stop condition, without a byte
+	i2cMonitorPartial = 1 << 11 // a byte that was cut short by a start or stop
+)
+
+// Configure configures the pins and starts monitoring. The pins are inputs,
+// and don't load the bus.
+func (m *I2CMonitor) Configure() error {
+	m.SDA.Configure(PinConfig{Mode: PinInput})
+	m.SCL.Configure(PinConfig{Mode: PinInput})
+	if err := m.SDA.SetInterrupt(PinToggle, m.sdaEdge); err != nil {
+		return err
+	}
+	return m.SCL.SetInterrupt(PinToggle, m.sclEdge)
+}
+
+// sdaEdge detects start and stop conditions: SDA changes while SCL is high.
+func (m *I2CMonitor) sdaEdge(Pin) {
+	if !m.SCL.Get() {
+		return
+	}
+	if m.count != 0 {
+		m.push(uint16(m.data) | m.flags | i2cMonitorPartial)
+	}
+	m.count, m.data = 0, 0
+	if m.SDA.Get() {
+		m.push(i2cMonitorStop)
+		m.flags = 0
+	} else {
+		m.flags = i2cMonitorStart
+	}
+}
+
+// sclEdge samples SDA on the rising edges of SCL.
+func (m *I2CMonitor) sclEdge(Pin) {
+	if !m.SCL.Get() {
+		return
+	}
+	bit := m.SDA.Get()
+	if m.count < 8 {
+		m.data <<= 1
+		if bit {
+			m.data |= 1
+		}
+		m.count++
+		return
+	}
+	event := uint16(m.data) | m.flags
+	if bit {
+		event |= i2cMonitorNACK
+	}
+	m.push(event)
+	m.count, m.data, m.flags = 0, 0, 0
+}
+
+func (m *I2CMonitor) push(event uint16) {
+	if m.head-m.tail == uint8(len(m.events)) {
+		m.overflow = true
+		return
+	}
+	m.events[m.head%uint8(len(m.events))] = event
+	m.head++
+}
+
+var i2cMonitorOverflow = []byte("[overflow]\r\n")
+
+// Dump prints the transactions seen since the last call.
+func (m *I2CMonitor) Dump(w stdio.Writer) error {
+	var buf [10]byte
+	for {
+		mask := interrupt.Disable()
+		if m.overflow {
+			m.overflow = false
+			interrupt.Restore(mask)
+			if _, err := w.Write(i2cMonitorOverflow); err != nil {
+				return err
+			}
+			continue
+		}
+		if m.head == m.tail {
+			interrupt.Restore(mask)
+			return nil
+		}
+		event := m.events[m.tail%uint8(len(m.events))]
+		m.tail++
+		interrupt.Restore(mask)
+
+		line := buf[:0]
+		if event&i2cMonitorStop != 0 {
+			line = append(line, 'P', '\r', '\n')
+		} else {
+			b := uint8(event)
+			if event&i2cMonitorStart != 0 {
+				// The address byte holds the 7-bit address and the direction.
+				line = append(line, 'S', ' ')
+				b >>= 1
+			}
+			line = append(line, hexDigit(b>>4), hexDigit(b&0xf), ' ')
+			if event&i2cMonitorStart != 0 {
+				if event&1 != 0 {
+					line = append(line, 'R', ' ')
+				} else {
+					line = append(line, 'W', ' ')
+				}
+			}
+			switch {
+			case event&i2cMonitorPartial != 0:
+				line = append(line, '?', ' ')
+			case event&i2cMonitorNACK != 0:
+				line = append(line, 'N', ' ')
+			default:
+				line = append(line, 'A', ' ')
+			}
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+}
+
+func hexDigit(v uint8) byte {
+	if v < 10 {
+		return '0' + v
+	}
+	return 'a' + v - 10
+}
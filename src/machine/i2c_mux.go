@@ -0,0 +1,95 @@
+//go:build atmega || nrf || sam || stm32 || fe310 || k210 || rp2040
+// +build atmega nrf sam stm32 fe310 k210 rp2040
+
+package machine
+
+import "errors"
+
+var errI2CMuxChannel = errors.New("I2C error: invalid multiplexer channel")
+
+// I2CMuxAddress is the default address of a TCA9548A or PCA9548A I2C
+// multiplexer, with all address pins low.
+const I2CMuxAddress = 0x70
+
+// I2CMux is a TCA9548A/PCA9548A style I2C multiplexer, which connects the
+// upstream bus to one of eight downstream buses. It is used to talk to devices
+// with the same address on different channels.
+//
+// Each channel behaves like an I2C bus of its own, so drivers can use it in
+// place of an *I2C:
+//
+//	mux := &machine.I2CMux{Bus: machine.I2C0, Address: machine.I2CMuxAddress}
+//	sensorA := bme280.New(mux.Channel(0))
+//	sensorB := bme280.New(mux.Channel(1))
+type I2CMux struct {
+	// Bus is the upstream bus, which can itself be a multiplexer channel.
+	Bus interface {
+		Tx(addr uint16, w, r []byte) error
+	}
+	Address uint16
+
+	selected uint8 // bitmask of the enabled channels
+	valid    bool  // whether selected reflects the state of the device
+	buf      [1]byte
+}
+
+// I2CMuxChannel is one of the downstream buses of an I2CMux.
+type I2CMuxChannel struct {
+	mux     *I2CMux
+	channel uint8
+}
+
+// Channel returns the downstream bus with the given number (0-7).
+func (m *I2CMux) Channel(n int) *I2CMuxChannel {
+	return &I2CMuxChannel{mux: m, channel: uint8(n)}
+}
+
+// Select connects the given channel to the upstream bus, disconnecting the
+// others. The device is only written to when the selected channel changes.
+func (m *I2CMux) Select(channel int) error {
+	if channel < 0 || channel > 7 {
+		return errI2CMuxChannel
+	}
+	return m.selectMask(1 << channel)
+}
+
+// Deselect disconnects all channels from the upstream bus.
+func (m *I2CMux) Deselect() error {
+	return m.selectMask(0)
+}
+
+func (m *I2CMux) selectMask(mask uint8) error {
+	if m.valid && m.selected == mask {
+		return nil
+	}
+	m.buf[0] = mask
+	err := m.Bus.Tx(m.Address, m.buf[:], nil)
+	m.valid = err == nil
+	m.selected = mask
+	return err
+}
+
+// Tx selects the channel and does a single I2C transaction on it. See
+// I2C.Tx.
+func (c *I2CMuxChannel) Tx(addr uint16, w, r []byte) error {
+	if err := c.mux.Select(int(c.channel)); err != nil {
+		return err
+	}
+	return c.mux.Bus.Tx(addr, w, r)
+}
+
+// WriteRegister transmits first the register and then the data to the
+// peripheral device on this channel. See I2C.WriteRegister.
+func (c *I2CMuxChannel) WriteRegister(address uint8, register uint8, data []byte) error {
+	buf := make([]uint8, len(data)+1)
+	buf[0] = register
+	copy(buf[1:], data)
+	return c.Tx(uint16(address), buf, nil)
+}
+
+// ReadRegister transmits the register, restarts the connection as a read
+// operation, and reads the response from the device on this channel. See
+// I2C.ReadRegister.
+func (c *I2CMuxChannel) ReadRegister(address uint8, register uint8, data []byte) error {
+	return c.Tx(uint16(address), []byte{register}, data)
+}
@@ -0,0 +1,65 @@
+//go:build nrf || sam || stm32 || fe310 || k210 || rp2040
+// +build nrf sam stm32 fe310 k210 rp2040
+
+package machine
+
+import "errors"
+
+var (
+	errI2CClockStuck  = errors.New("I2C error: SCL held low by a device")
+	errI2CRecoverPins = errors.New("I2C error: SCL and SDA of the bus to recover are the same pin")
+	errI2CBusTimeout  = errors.New("I2C timeout, the bus was recovered")
+)
+
+const (
+	// i2cStretchTimeout is how long a device may hold SCL low, in
+	// microseconds, before the bus is considered stuck. Devices that stretch
+	// the clock usually do it for at most a few milliseconds.
+	i2cStretchTimeout = 25e3
+
+	// i2cRecoverHalfPeriod is the SCL half period during recovery, in
+	// microseconds (100kHz).
+	i2cRecoverHalfPeriod = 5
+)
+
+// Recover releases a bus that a device keeps busy, usually after a reset of
+// the controller in the middle of a transfer left the device driving SDA low
+// while it waits for clock pulses that never come. Recover clocks SCL up to
+// nine times until the device lets go of SDA, sends a stop condition, and
+// configures the bus again with config, which must hold the pins of the bus:
+// unlike Configure, Recover doesn't pick the default pins for a config without
+// them, and fails when SCL and SDA are the same pin.
+//
+// Recover fails if SDA stays low, or if a device keeps stretching the clock
+// by holding SCL low for longer than 25ms; the device then needs to be power
+// cycled.
+//
+// The nRF and RP2040 chips call Recover by themselves when a transfer times
+// out, and report an error of Recover in place of the timeout. On the SAMD,
+// STM32, FE310 and K210 chips a timeout only ends the transfer: the caller
+// has to call Recover before the bus can be used again.
+func (i2c *I2C) Recover(config I2CConfig) error {
+	if config.SCL == config.SDA {
+		return errI2CRecoverPins
+	}
+	err := i2c.recover(config.SCL, config.SDA)
+	if cerr := i2c.Configure(config); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// recover releases the bus by driving the pins as GPIOs, and leaves the pins
+// unconfigured.
+func (i2c *I2C) recover(scl, sda Pin) error {
+	i2cRelease(scl)
+	i2cRelease(sda)
+	deadline := nanotime() + i2cStretchTimeout*1000
+	for !scl.Get() {
+		if nanotime() > deadline {
+			return errI2CClockStuck
+		}
+		gosched()
+	}
+	return I2CSendCondition(scl, sda, I2CBusClear, i2cRecoverHalfPeriod)
+}
@@ -0,0 +1,157 @@
+//go:build atmega || nrf || sam || stm32 || fe310 || k210 || rp2040
+// +build atmega nrf sam stm32 fe310 k210 rp2040
+
+package machine
+
+import "errors"
+
+var errI2CTransaction = errors.New("I2C error: sequence of messages not supported")
+
+// I2CMsg is a message of an I2C transaction, like struct i2c_msg of Linux
+// i2c-dev.
+type I2CMsg struct {
+	Addr  uint16
+	Flags I2CMsgFlags
+	Buf   []byte
+}
+
+// I2CMsgFlags change how a message of a transaction is sent, like the I2C_M_*
+// flags of Linux i2c-dev.
+type I2CMsgFlags uint8
+
+const (
+	// I2CMsgRead reads into Buf instead of writing it (I2C_M_RD).
+	I2CMsgRead I2CMsgFlags = 1 << iota
+
+	// I2CMsgTenBit sends Addr as a 10-bit address (I2C_M_TEN).
+	I2CMsgTenBit
+
+	// I2CMsgNoStart continues the previous message: Buf is written without a
+	// repeated start and address (I2C_M_NOSTART). Both must be writes.
+	I2CMsgNoStart
+
+	// I2CMsgStop ends the message with a stop condition instead of a
+	// repeated start (I2C_M_STOP).
+	I2CMsgStop
+)
+
+// Transaction sends a sequence of messages. As with Linux i2c-dev, the
+// messages are separated by repeated starts, and the transaction ends with a
+// single stop condition, unless a message has flags that say otherwise.
+//
+// For example, this reads an EEPROM at its current address, and a register of
+// an SCCB camera, which doesn't allow a repeated start:
+//
+//	i2c.Transaction([]machine.I2CMsg{{Addr: 0x50, Flags: machine.I2CMsgRead, Buf: data}})
+//	i2c.Transaction([]machine.I2CMsg{
+//		{Addr: 0x21, Flags: machine.I2CMsgStop, Buf: []byte{reg}},
+//		{Addr: 0x21, Flags: machine.I2CMsgRead, Buf: value},
+//	})
+//
+// Reads must be at least one byte long. Some controllers can't send every
+// sequence, in which case Transaction returns an error before it sends
+// anything:
+//   - on the rp2040 and the K210, a repeated start must be to the same device,
+//     and writes must be at least one byte long;
+//   - on the nRF chips and the STM32F1 and STM32F4, a read must end with a
+//     stop.
+//
+// A 10-bit address is sent as the 7-bit address 0b11110xx followed by its low
+// byte, and for reads a repeated start and 0b11110xx again.
+func (i2c *I2C) Transaction(msgs []I2CMsg) error {
+	if err := checkI2CTransaction(msgs); err != nil {
+		return err
+	}
+	before := i2cStop
+	for i, msg := range msgs {
+		after := i2cStop
+		if i+1 < len(msgs) && msg.Flags&I2CMsgStop == 0 {
+			after = i2cRestart
+			if msgs[i+1].Flags&I2CMsgNoStart != 0 {
+				after = i2cNoStart
+			}
+		}
+		read := msg.Flags&I2CMsgRead != 0
+		addr := uint8(msg.Addr)
+		if msg.Flags&I2CMsgTenBit != 0 && before != i2cNoStart {
+			// The first byte holds the top two bits of the address, and the
+			// second byte the others.
+			addr = 0x78 | uint8(msg.Addr>>8&3)
+			low := [1]byte{byte(msg.Addr)}
+			join := i2cNoStart
+			if read {
+				join = i2cRestart
+			} else if len(msg.Buf) == 0 {
+				join = after
+			}
+			if err := i2c.transferMsg(addr, false, low[:], before, join); err != nil {
+				return err
+			}
+			before = join
+			if join == after {
+				continue
+			}
+		}
+		if err := i2c.transferMsg(addr, read, msg.Buf, before, after); err != nil {
+			return err
+		}
+		before = after
+	}
+	return nil
+}
+
+// i2cJoin is how two messages of a transaction follow each other on the bus.
+type i2cJoin uint8
+
+const (
+	i2cStop    i2cJoin = iota // a stop, and a start before the next message
+	i2cRestart                // a repeated start
+	i2cNoStart                // nothing: the next message continues this one
+)
+
+// Sequences of messages that the I2C controller of a chip can't send, in its
+// i2cTransactionLimits.
+const (
+	// A repeated start must be to the address of the previous message.
+	i2cNoAddressChange = 1 << iota
+
+	// A read must end with a stop condition.
+	i2cNoReadRestart
+
+	// A write must hold at least one byte.
+	i2cNoEmptyWrite
+)
+
+// checkI2CTransaction returns an error for a sequence of messages that the
+// hardware can't send.
+func checkI2CTransaction(msgs []I2CMsg) error {
+	for i, msg := range msgs {
+		read := msg.Flags&I2CMsgRead != 0
+		noStart := msg.Flags&I2CMsgNoStart != 0
+		last := i+1 == len(msgs) || msg.Flags&I2CMsgStop != 0
+		if read && len(msg.Buf) == 0 {
+			return errI2CTransaction
+		}
+		// Only a write can continue a write.
+		if noStart && (i == 0 || read || msgs[i-1].Flags&(I2CMsgRead|I2CMsgStop) != 0) {
+			return errI2CTransaction
+		}
+		// The low byte of a 10-bit address is written too.
+		empty := len(msg.Buf) == 0 && (noStart || msg.Flags&I2CMsgTenBit == 0)
+		if i2cTransactionLimits&i2cNoEmptyWrite != 0 && empty &&
+			(last || msgs[i+1].Flags&I2CMsgNoStart == 0) {
+			return errI2CTransaction
+		}
+		if last || msgs[i+1].Flags&I2CMsgNoStart != 0 {
+			continue
+		}
+		if i2cTransactionLimits&i2cNoReadRestart != 0 && read {
+			return errI2CTransaction
+		}
+		if i2cTransactionLimits&i2cNoAddressChange != 0 &&
+			(msgs[i+1].Addr != msg.Addr || (msgs[i+1].Flags^msg.Flags)&I2CMsgTenBit != 0) {
+			return errI2CTransaction
+		}
+	}
+	return nil
+}
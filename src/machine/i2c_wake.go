@@ -0,0 +1,16 @@
+//go:build atmega || nrf || sam || stm32 || fe310 || k210
+// +build atmega nrf sam stm32 fe310 k210
+
+package machine
+
+// Wake generates a wake condition on the bus using the I2C peripheral, as a
+// fallback for when I2CSendCondition cannot be used. It addresses the general
+// call address 0, which keeps SDA low for nine bit periods (90µs at 100kHz).
+// The bus must be running at I2CWakeBaudRate or slower.
+//
+// Devices do not acknowledge this transfer, so the error of the transfer says
+// nothing about whether the device woke up and Wake always returns nil.
+func (i2c *I2C) Wake() error {
+	i2c.Tx(0, []byte{0}, nil)
+	return nil
+}
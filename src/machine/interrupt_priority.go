@@ -0,0 +1,14 @@
+package machine
+
+import "errors"
+
+var errNoInterruptPriority = errors.New("machine: the interrupt priority of this driver can't be configured")
+
+// interruptPriority returns the priority configured for the interrupt of a
+// driver, or its default priority if none is configured.
+func interruptPriority(priority, defaultPriority uint8) uint8 {
+	if priority == 0 {
+		return defaultPriority
+	}
+	return priority
+}
@@ -0,0 +1,156 @@
+//go:build !gameboyadvance
+// +build !gameboyadvance
+
+package machine
+
+import "errors"
+
+// IRProtocol is an infrared remote control protocol.
+type IRProtocol uint8
+
+const (
+	// IRNEC is the NEC protocol, with a 38kHz carrier. Extended NEC, with a
+	// 16-bit address, is also supported.
+	IRNEC IRProtocol = iota
+
+	// IRRC5 is the Philips RC5 protocol, with a 36kHz carrier. Commands 64 to
+	// 127 of extended RC5 are also supported.
+	IRRC5
+)
+
+// IRCode is a command sent by a remote control.
+type IRCode struct {
+	Protocol IRProtocol
+	Address  uint16
+	Command  uint8
+
+	// Repeat is set for a NEC repeat code, which a remote sends while a button
+	// is held down. Address and Command are those of the last code.
+	Repeat bool
+
+	// Toggle is the RC5 toggle bit, which changes with every button press so
+	// that a held down button can be told apart from repeated presses.
+	Toggle bool
+}
+
+var errIRProtocol = errors.New("ir: unknown protocol")
+
+// Timing of the protocols, in microseconds.
+const (
+	irNECLeaderMark    = 9000
+	irNECLeaderSpace   = 4500
+	irNECRepeatSpace   = 2250
+	irNECBitMark       = 562
+	irNECZeroSpace     = 562
+	irNECOneSpace      = 1687
+	irRC5HalfBit       = 889
+	irNECCarrierPeriod = 1_000_000_000 / 38000 // nanoseconds
+	irRC5CarrierPeriod = 1_000_000_000 / 36000 // nanoseconds
+)
+
+// IRTransmitter sends remote control codes with an infrared LED. A PWM
+// peripheral generates the carrier, which is switched on and off to send the
+// marks and spaces of a code.
+//
+// The LED must be driven with a transistor, as it needs more current than a
+// pin supplies.
+type IRTransmitter struct {
+	PWM BuzzerPWM
+	Pin Pin
+
+	channel uint8
+	next    int64
+}
+
+// Configure configures the PWM peripheral and the pin.
+func (t *IRTransmitter) Configure() error {
+	err := t.PWM.Configure(PWMConfig{Period: irNECCarrierPeriod})
+	if err == nil {
+		t.channel, err = t.PWM.Channel(t.Pin)
+	}
+	t.PWM.Set(t.channel, 0)
+	return err
+}
+
+// Send sends a code, and returns when it is done, after 68ms for a NEC code
+// and 25ms for an RC5 code. It busy-waits to get the marks and spaces right.
+func (t *IRTransmitter) Send(code IRCode) error {
+	switch code.Protocol {
+	case IRNEC:
+		if err := t.PWM.SetPeriod(irNECCarrierPeriod); err != nil {
+			return err
+		}
+		t.next = nanotime()
+		t.mark(irNECLeaderMark)
+		if code.Repeat {
+			t.space(irNECRepeatSpace)
+			t.mark(irNECBitMark)
+			t.space(0)
+			return nil
+		}
+		t.space(irNECLeaderSpace)
+		address := uint32(code.Address)
+		if code.Address < 0x100 {
+			address |= uint32(^uint8(code.Address)) << 8
+		}
+		bits := address | uint32(code.Command)<<16 | uint32(^code.Command)<<24
+		for i := 0; i < 32; i++ {
+			t.mark(irNECBitMark)
+			if bits&(1<<i) != 0 {
+				t.space(irNECOneSpace)
+			} else {
+				t.space(irNECZeroSpace)
+			}
+		}
+		t.mark(irNECBitMark)
+		t.space(0)
+	case IRRC5:
+		if err := t.PWM.SetPeriod(irRC5CarrierPeriod); err != nil {
+			return err
+		}
+		// Start bit, field bit (the inverted bit 6 of the command), toggle
+		// bit, 5 address bits and 6 command bits.
+		bits := uint16(1)<<13 | uint16(code.Address&0x1f)<<6 | uint16(code.Command&0x3f)
+		if code.Command < 64 {
+			bits |= 1 << 12
+		}
+		if code.Toggle {
+			bits |= 1 << 11
+		}
+		t.next = nanotime()
+		for i := 13; i >= 0; i-- {
+			// Manchester code: a one is a space followed by a mark.
+			if bits&(1<<i) != 0 {
+				t.space(irRC5HalfBit)
+				t.mark(irRC5HalfBit)
+			} else {
+				t.mark(irRC5HalfBit)
+				t.space(irRC5HalfBit)
+			}
+		}
+		t.space(0)
+	default:
+		return errIRProtocol
+	}
+	return nil
+}
+
+// mark sends the carrier for us microseconds.
+func (t *IRTransmitter) mark(us int64) {
+	t.PWM.Set(t.channel, t.PWM.Top()/3)
+	t.wait(us)
+}
+
+// space switches the carrier off for us microseconds.
+func (t *IRTransmitter) space(us int64) {
+	t.PWM.Set(t.channel, 0)
+	t.wait(us)
+}
+
+// wait waits until us microseconds after the end of the previous mark or
+// space, so that the timing doesn't drift.
+func (t *IRTransmitter) wait(us int64) {
+	t.next += us * 1000
+	for nanotime() < t.next {
+	}
+}
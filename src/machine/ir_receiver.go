@@ -0,0 +1,189 @@
+//go:build nrf || sam || stm32 || rp2040 || k210 || esp32c3 || mimxrt1062
+// +build nrf sam stm32 rp2040 k210 esp32c3 mimxrt1062
+
+package machine
+
+// IRReceiver decodes NEC and RC5 remote control codes from an infrared
+// receiver module, such as the TSOP38238 or VS1838B. These demodulate the
+// carrier, and pull their output low while they receive it (a mark).
+//
+// The receiver measures the time between the edges of the signal in a pin
+// interrupt, which keeps the measurement accurate while goroutines run, and
+// decodes both protocols at the same time.
+type IRReceiver struct {
+	Pin Pin
+
+	// Handler is called for every code received. It runs in the pin
+	// interrupt, so it must be short and must not allocate memory.
+	Handler func(code IRCode)
+
+	last int64 // time of the last edge, in nanoseconds
+
+	necState uint8
+	necBits  uint32
+	necCount uint8
+	necLast  IRCode
+	necValid bool // necLast holds a code, for repeat codes
+
+	rc5State uint8
+	rc5Bits  uint16
+	rc5Count uint8
+}
+
+// States of the NEC decoder.
+const (
+	irNECIdle uint8 = iota
+	irNECLeader
+	irNECRepeat
+	irNECMark
+	irNECSpace
+)
+
+// States of the RC5 decoder: at the start or in the middle of a one or zero.
+const (
+	irRC5Idle uint8 = iota
+	irRC5Start1
+	irRC5Mid1
+	irRC5Start0
+	irRC5Mid0
+)
+
+// Configure configures the pin and starts receiving.
+func (r *IRReceiver) Configure() error {
+	r.Pin.Configure(PinConfig{Mode: PinInput})
+	r.last = nanotime()
+	return r.Pin.SetInterrupt(PinToggle, r.edge)
+}
+
+// edge is called on every edge of the signal.
+func (r *IRReceiver) edge(pin Pin) {
+	now := nanotime()
+	duration := now - r.last
+	r.last = now
+	if duration > 1e9 {
+		duration = 1e9
+	}
+	us := uint32(duration / 1000)
+	// The level before the edge: a high pin now means a mark ended.
+	mark := pin.Get()
+	r.decodeNEC(mark, us)
+	r.decodeRC5(mark, us)
+}
+
+// irNear returns whether a duration is within tolerance of the expected one.
+func irNear(us, expected, tolerance uint32) bool {
+	return us+tolerance >= expected && us <= expected+tolerance
+}
+
+func (r *IRReceiver) decodeNEC(mark bool, us uint32) {
+	if mark && irNear(us, irNECLeaderMark, 1500) {
+		r.necState = irNECLeader
+		return
+	}
+	switch r.necState {
+	case irNECLeader:
+		switch {
+		case !mark && irNear(us, irNECLeaderSpace, 1000):
+			r.necState = irNECMark
+			r.necBits = 0
+			r.necCount = 0
+		case !mark && irNear(us, irNECRepeatSpace, 500):
+			r.necState = irNECRepeat
+		default:
+			r.necState = irNECIdle
+		}
+	case irNECRepeat:
+		r.necState = irNECIdle
+		if mark && irNear(us, irNECBitMark, 300) && r.necValid {
+			code := r.necLast
+			code.Repeat = true
+			r.emit(code)
+		}
+	case irNECMark:
+		if !mark || !irNear(us, irNECBitMark, 300) {
+			r.necState = irNECIdle
+			return
+		}
+		r.necState = irNECSpace
+		if r.necCount == 32 {
+			r.necState = irNECIdle
+			address, invAddress := uint8(r.necBits), uint8(r.necBits>>8)
+			command, invCommand := uint8(r.necBits>>16), uint8(r.necBits>>24)
+			if command != ^invCommand {
+				return
+			}
+			code := IRCode{Protocol: IRNEC, Address: uint16(address), Command: command}
+			if address != ^invAddress {
+				code.Address = uint16(r.necBits)
+			}
+			r.necLast, r.necValid = code, true
+			r.emit(code)
+		}
+	case irNECSpace:
+		switch {
+		case !mark && irNear(us, irNECZeroSpace, 300):
+		case !mark && irNear(us, irNECOneSpace, 500):
+			r.necBits |= 1 << r.necCount
+		default:
+			r.necState = irNECIdle
+			return
+		}
+		r.necCount++
+		r.necState = irNECMark
+	}
+}
+
+func (r *IRReceiver) decodeRC5(mark bool, us uint32) {
+	short := irNear(us, irRC5HalfBit, 300)
+	long := irNear(us, 2*irRC5HalfBit, 300)
+	if r.rc5State == irRC5Idle {
+		// The first mark starts in the middle of the start bit, a one.
+		if !mark && us > 4*irRC5HalfBit {
+			r.rc5State = irRC5Mid1
+			r.rc5Bits = 1
+			r.rc5Count = 1
+		}
+		return
+	}
+	var bit uint16
+	switch {
+	case r.rc5State == irRC5Mid1 && mark && short:
+		r.rc5State = irRC5Start1
+		return
+	case r.rc5State == irRC5Mid1 && mark && long:
+		r.rc5State, bit = irRC5Mid0, 0
+	case r.rc5State == irRC5Mid0 && !mark && short:
+		r.rc5State = irRC5Start0
+		return
+	case r.rc5State == irRC5Mid0 && !mark && long:
+		r.rc5State, bit = irRC5Mid1, 1
+	case r.rc5State == irRC5Start1 && !mark && short:
+		r.rc5State, bit = irRC5Mid1, 1
+	case r.rc5State == irRC5Start0 && mark && short:
+		r.rc5State, bit = irRC5Mid0, 0
+	default:
+		r.rc5State = irRC5Idle
+		return
+	}
+	r.rc5Bits = r.rc5Bits<<1 | bit
+	r.rc5Count++
+	if r.rc5Count == 14 {
+		r.rc5State = irRC5Idle
+		command := uint8(r.rc5Bits & 0x3f)
+		if r.rc5Bits&(1<<12) == 0 {
+			command |= 64
+		}
+		r.emit(IRCode{
+			Protocol: IRRC5,
+			Address:  (r.rc5Bits >> 6) & 0x1f,
+			Command:  command,
+			Toggle:   r.rc5Bits&(1<<11) != 0,
+		})
+	}
+}
+
+func (r *IRReceiver) emit(code IRCode) {
+	if r.Handler != nil {
+		r.Handler(code)
+	}
+}
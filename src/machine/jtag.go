@@ -0,0 +1,235 @@
+//go:build !gameboyadvance
+// +build !gameboyadvance
+
+package machine
+
+import "errors"
+
+// JTAG is a JTAG host on four GPIO pins, which drives the test access port
+// (TAP) of the chips on a board for boundary scan tests, or to program a CPLD
+// or FPGA from an SVF or XSVF file:
+//
+//	jtag := machine.JTAG{TCK: machine.GPIO2, TMS: machine.GPIO3, TDI: machine.GPIO4, TDO: machine.GPIO5}
+//	jtag.Configure()
+//	jtag.Reset()
+//	idcode := jtag.IDCode()
+//	// Load the SAMPLE/PRELOAD instruction of a 4 bit IR, and read the
+//	// boundary scan register.
+//	jtag.ShiftIR([]byte{0x02}, nil, 4)
+//	jtag.ShiftDR(nil, pins, 112)
+//
+// Data is shifted least significant bit first, starting with bit 0 of the
+// first byte, which is how SVF files and BSDL descriptions number the bits.
+type JTAG struct {
+	TCK Pin
+	TMS Pin
+	TDI Pin // to the TDI of the first chip of the chain
+	TDO Pin // from the TDO of the last chip of the chain
+
+	// Delay is half the period of TCK in nanoseconds. Zero toggles TCK as
+	// fast as the pins allow.
+	Delay uint32
+
+	state JTAGState
+}
+
+// JTAGState is a state of the TAP controller.
+type JTAGState uint8
+
+const (
+	JTAGTestLogicReset JTAGState = iota
+	JTAGRunTestIdle
+	JTAGSelectDRScan
+	JTAGCaptureDR
+	JTAGShiftDR
+	JTAGExit1DR
+	JTAGPauseDR
+	JTAGExit2DR
+	JTAGUpdateDR
+	JTAGSelectIRScan
+	JTAGCaptureIR
+	JTAGShiftIR
+	JTAGExit1IR
+	JTAGPauseIR
+	JTAGExit2IR
+	JTAGUpdateIR
+)
+
+// jtagNext is the state that follows every state, for TMS low and high.
+var jtagNext = [16][2]JTAGState{
+	JTAGTestLogicReset: {JTAGRunTestIdle, JTAGTestLogicReset},
+	JTAGRunTestIdle:    {JTAGRunTestIdle, JTAGSelectDRScan},
+	JTAGSelectDRScan:   {JTAGCaptureDR, JTAGSelectIRScan},
+	JTAGCaptureDR:      {JTAGShiftDR, JTAGExit1DR},
+	JTAGShiftDR:        {JTAGShiftDR, JTAGExit1DR},
+	JTAGExit1DR:        {JTAGPauseDR, JTAGUpdateDR},
+	JTAGPauseDR:        {JTAGPauseDR, JTAGExit2DR},
+	JTAGExit2DR:        {JTAGShiftDR, JTAGUpdateDR},
+	JTAGUpdateDR:       {JTAGRunTestIdle, JTAGSelectDRScan},
+	JTAGSelectIRScan:   {JTAGCaptureIR, JTAGTestLogicReset},
+	JTAGCaptureIR:      {JTAGShiftIR, JTAGExit1IR},
+	JTAGShiftIR:        {JTAGShiftIR, JTAGExit1IR},
+	JTAGExit1IR:        {JTAGPauseIR, JTAGUpdateIR},
+	JTAGPauseIR:        {JTAGPauseIR, JTAGExit2IR},
+	JTAGExit2IR:        {JTAGShiftIR, JTAGUpdateIR},
+	JTAGUpdateIR:       {JTAGRunTestIdle, JTAGSelectDRScan},
+}
+
+var errJTAGShiftSize = errors.New("jtag: buffer too short for the number of bits")
+
+// Configure configures the pins, and resets the TAP controllers.
+func (j *JTAG) Configure() {
+	j.TCK.Configure(PinConfig{Mode: PinOutput})
+	j.TCK.Low()
+	j.TMS.Configure(PinConfig{Mode: PinOutput})
+	j.TDI.Configure(PinConfig{Mode: PinOutput})
+	j.TDO.Configure(PinConfig{Mode: PinInput})
+	j.Reset()
+}
+
+// Reset moves the TAP controllers to Test-Logic-Reset by holding TMS high for
+// five clocks, whatever state they were in, and then to Run-Test/Idle. After
+// a reset, the data register of every chip is its IDCODE register, or its
+// bypass register if it has none.
+func (j *JTAG) Reset() {
+	for i := 0; i < 5; i++ {
+		j.clock(true, false)
+	}
+	j.state = JTAGTestLogicReset
+	j.GoTo(JTAGRunTestIdle)
+}
+
+// State returns the current state of the TAP controllers.
+func (j *JTAG) State() JTAGState {
+	return j.state
+}
+
+// GoTo moves the TAP controllers to state by the shortest path.
+func (j *JTAG) GoTo(state JTAGState) {
+	for j.state != state {
+		tms := jtagPath(j.state, state)
+		j.clock(tms, false)
+		if tms {
+			j.state = jtagNext[j.state][1]
+		} else {
+			j.state = jtagNext[j.state][0]
+		}
+	}
+}
+
+// jtagPath returns the value of TMS for the first step of the shortest path
+// from one state to another, with a breadth first search of the 16 states.
+func jtagPath(from, to JTAGState) bool {
+	var first [16]int8 // TMS of the first step to reach a state, plus one
+	queue := [16]JTAGState{}
+	head, tail := 0, 0
+	for tms := 0; tms < 2; tms++ {
+		s := jtagNext[from][tms]
+		if first[s] == 0 && s != from {
+			first[s] = int8(tms + 1)
+			queue[tail] = s
+			tail++
+		}
+	}
+	for head < tail {
+		s := queue[head]
+		head++
+		if s == to {
+			return first[s] == 2
+		}
+		for tms := 0; tms < 2; tms++ {
+			n := jtagNext[s][tms]
+			if first[n] == 0 && n != from {
+				first[n] = first[s]
+				queue[tail] = n
+				tail++
+			}
+		}
+	}
+	return false
+}
+
+// Idle clocks the TAP controllers n times in Run-Test/Idle, which some
+// instructions use to wait for an operation, such as the erase of a CPLD.
+func (j *JTAG) Idle(n int) {
+	j.GoTo(JTAGRunTestIdle)
+	for i := 0; i < n; i++ {
+		j.clock(false, false)
+	}
+}
+
+// ShiftIR shifts n bits of tdi into the instruction registers of the chain,
+// and the bits shifted out into tdo, and ends in Run-Test/Idle. Either buffer
+// may be nil.
+func (j *JTAG) ShiftIR(tdi, tdo []byte, n int) error {
+	return j.shift(JTAGShiftIR, tdi, tdo, n)
+}
+
+// ShiftDR shifts n bits of tdi into the selected data registers of the
+// chain, and the bits shifted out into tdo, and ends in Run-Test/Idle. Either
+// buffer may be nil, which shifts in zeros.
+func (j *JTAG) ShiftDR(tdi, tdo []byte, n int) error {
+	return j.shift(JTAGShiftDR, tdi, tdo, n)
+}
+
+// IDCode returns the IDCODE of the chip nearest to TDO, or zero when it has
+// no IDCODE register. It resets the TAP controllers first.
+func (j *JTAG) IDCode() uint32 {
+	j.Reset()
+	var buf [4]byte
+	j.ShiftDR(nil, buf[:], 32)
+	id := uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16 | uint32(buf[3])<<24
+	// A bypass register reads as a single 0 bit, an IDCODE always starts
+	// with a 1 bit.
+	if id&1 == 0 {
+		return 0
+	}
+	return id
+}
+
+func (j *JTAG) shift(state JTAGState, tdi, tdo []byte, n int) error {
+	if (tdi != nil && len(tdi)*8 < n) || (tdo != nil && len(tdo)*8 < n) {
+		return errJTAGShiftSize
+	}
+	j.GoTo(state)
+	for i := 0; i < n; i++ {
+		bit := tdi != nil && tdi[i/8]>>(i%8)&1 != 0
+		// The last bit is shifted while leaving the Shift state.
+		last := i == n-1
+		out := j.clock(last, bit)
+		if tdo != nil {
+			if out {
+				tdo[i/8] |= 1 << (i % 8)
+			} else {
+				tdo[i/8] &^= 1 << (i % 8)
+			}
+		}
+	}
+	if n > 0 {
+		j.state = jtagNext[state][1]
+	}
+	j.GoTo(JTAGRunTestIdle)
+	return nil
+}
+
+// clock sends a clock cycle with the given TMS and TDI, and returns TDO. The
+// chips sample TMS and TDI on the rising edge of TCK and change TDO on the
+// falling edge.
+func (j *JTAG) clock(tms, tdi bool) bool {
+	j.TMS.Set(tms)
+	j.TDI.Set(tdi)
+	j.wait()
+	tdo := j.TDO.Get()
+	j.TCK.High()
+	j.wait()
+	j.TCK.Low()
+	return tdo
+}
+
+func (j *JTAG) wait() {
+	if j.Delay == 0 {
+		return
+	}
+	for deadline := nanotime() + int64(j.Delay); nanotime() < deadline; {
+	}
+}
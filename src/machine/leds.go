@@ -0,0 +1,84 @@
+//go:build !gameboyadvance
+// +build !gameboyadvance
+
+package machine
+
+// LEDs and Buttons are the user LEDs and buttons of the board, for the boards
+// that list them. A board that lists an LED as active low, or behind a
+// transistor that inverts it, makes On and Off of its pin invert it too.
+var (
+	LEDs    []BoardLED
+	Buttons []BoardButton
+)
+
+// BoardLED is an LED on the board. Boards that know their LEDs list them in
+// LEDs, so that status indication code can work on any of them without
+// knowing whether an LED lights when its pin is high or low.
+type BoardLED struct {
+	Pin       Pin
+	ActiveLow bool // the LED lights when the pin is low
+}
+
+// Configure makes the pin an output and turns the LED off.
+func (l BoardLED) Configure() {
+	l.Pin.Configure(PinConfig{Mode: PinOutput})
+	l.Off()
+}
+
+// On turns the LED on.
+func (l BoardLED) On() {
+	l.Set(true)
+}
+
+// Off turns the LED off.
+func (l BoardLED) Off() {
+	l.Set(false)
+}
+
+// Set turns the LED on or off.
+func (l BoardLED) Set(on bool) {
+	l.Pin.Set(on != l.ActiveLow)
+}
+
+// On turns on what the pin drives, such as machine.LED: it sets the pin low if
+// the board lists it in LEDs as active low, and high otherwise, so that the
+// same code lights the LED on every board.
+func (p Pin) On() {
+	p.Set(!p.activeLow())
+}
+
+// Off turns off what the pin drives, see On.
+func (p Pin) Off() {
+	p.Set(p.activeLow())
+}
+
+// activeLow returns whether the board lists the pin as an active low LED.
+func (p Pin) activeLow() bool {
+	for _, led := range LEDs {
+		if led.Pin == p {
+			return led.ActiveLow
+		}
+	}
+	return false
+}
+
+// BoardButton is a user button on the board, listed in Buttons.
+type BoardButton struct {
+	Pin Pin
+
+	// Mode is the input mode the button needs, with a pull-up or pull-down
+	// resistor unless the board has one.
+	Mode PinMode
+
+	ActiveLow bool // the pin reads low while the button is pressed
+}
+
+// Configure configures the pin as an input.
+func (b BoardButton) Configure() {
+	b.Pin.Configure(PinConfig{Mode: b.Mode})
+}
+
+// Pressed returns whether the button is pressed.
+func (b BoardButton) Pressed() bool {
+	return b.Pin.Get() != b.ActiveLow
+}
@@ -0,0 +1,158 @@
+//go:build sam || nrf || rp2040 || stm32
+// +build sam nrf rp2040 stm32
+
+package machine
+
+import (
+	"errors"
+	"runtime/interrupt"
+	"runtime/volatile"
+)
+
+var (
+	ErrLoRaBusyTimeout = errors.New("machine: timeout waiting for LoRa radio")
+	ErrLoRaInvalidDIO  = errors.New("machine: invalid LoRa DIO number")
+)
+
+// LoRaNumDIO is the number of DIO interrupt lines of a LoRa radio that can be
+// routed to pin interrupts.
+const LoRaNumDIO = 4
+
+// LoRa is the SPI transport to a Semtech SX127x (RFM95/96/98) or SX126x LoRa
+// radio. It handles chip select, reset and BUSY sequencing, and routes the
+// DIO lines of the radio to pin interrupts with a timestamp of the moment the
+// interrupt fired, as needed by LoRaWAN receive windows.
+type LoRa struct {
+	Bus interface {
+		Tx(w, r []byte) error
+	}
+	CS    Pin
+	RESET Pin
+
+	// BUSY is only present on SX126x radios. Leave it at NoPin for SX127x
+	// radios.
+	BUSY Pin
+
+	// DIO are the interrupt lines of the radio, use NoPin for the lines that
+	// are not connected. SX126x radios only use DIO1 to signal interrupts.
+	DIO [LoRaNumDIO]Pin
+
+	handlers   [LoRaNumDIO]func(dio int, timestamp int64)
+	timestamps [LoRaNumDIO]int64
+	pending    volatile.Register8
+}
+
+// Timing of the reset and BUSY handshake, in microseconds.
+const (
+	loraResetLow     = 100
+	loraResetStartup = 5_000
+	loraBusyTimeout  = 10_000
+)
+
+// Configure sets up the pins and resets the radio. The SPI bus must be
+// configured separately, in mode 0.
+func (l *LoRa) Configure() error {
+	l.CS.Configure(PinConfig{Mode: PinOutput})
+	l.CS.High()
+	if l.BUSY != NoPin {
+		l.BUSY.Configure(PinConfig{Mode: PinInput})
+	}
+	for _, dio := range l.DIO {
+		if dio != NoPin {
+			dio.Configure(PinConfig{Mode: PinInputPulldown})
+		}
+	}
+	return l.Reset()
+}
+
+// Reset pulses the reset line of the radio and waits until it has started.
+func (l *LoRa) Reset() error {
+	if l.RESET == NoPin {
+		return nil
+	}
+	l.RESET.Configure(PinConfig{Mode: PinOutput})
+	l.RESET.Low()
+	sleepMicroseconds(loraResetLow)
+	if l.BUSY != NoPin {
+		// SX126x: NRESET is a regular input.
+		l.RESET.High()
+	} else {
+		// SX127x: the reset pin must be left floating after reset.
+		l.RESET.Configure(PinConfig{Mode: PinInput})
+	}
+	sleepMicroseconds(loraResetStartup)
+	return l.WaitBusy()
+}
+
+// WaitBusy waits until the radio is ready to accept a command. It returns
+// immediately for radios without BUSY line.
+func (l *LoRa) WaitBusy() error {
+	if l.BUSY == NoPin {
+		return nil
+	}
+	deadline := nanotime() + loraBusyTimeout*1000
+	for l.BUSY.Get() {
+		if nanotime() > deadline {
+			return ErrLoRaBusyTimeout
+		}
+		gosched()
+	}
+	return nil
+}
+
+// Tx executes a single SPI transaction with the radio: it waits for BUSY,
+// asserts chip select and transfers w and r.
+func (l *LoRa) Tx(w, r []byte) error {
+	if err := l.WaitBusy(); err != nil {
+		return err
+	}
+	l.CS.Low()
+	err := l.Bus.Tx(w, r)
+	l.CS.High()
+	return err
+}
+
+// EnableDIO enables the pin interrupt for the given DIO line of the radio.
+// Every time the line goes high, the value of the monotonic clock (in
+// nanoseconds) is recorded and handler is called from the interrupt, if it is
+// not nil. Events can also be processed outside of interrupt context with
+// DIOEvent.
+func (l *LoRa) EnableDIO(dio int, handler func(dio int, timestamp int64)) error {
+	if dio < 0 || dio >= LoRaNumDIO || l.DIO[dio] == NoPin {
+		return ErrLoRaInvalidDIO
+	}
+	l.handlers[dio] = handler
+	return l.DIO[dio].SetInterrupt(PinRising, func(Pin) {
+		now := nanotime()
+		l.timestamps[dio] = now
+		l.pending.SetBits(1 << dio)
+		if h := l.handlers[dio]; h != nil {
+			h(dio, now)
+		}
+	})
+}
+
+// DisableDIO disables the pin interrupt for the given DIO line.
+func (l *LoRa) DisableDIO(dio int) error {
+	if dio < 0 || dio >= LoRaNumDIO || l.DIO[dio] == NoPin {
+		return ErrLoRaInvalidDIO
+	}
+	l.handlers[dio] = nil
+	return l.DIO[dio].SetInterrupt(0, nil)
+}
+
+// DIOEvent returns whether the given DIO line has fired since the last call
+// and the timestamp of the last interrupt.
+func (l *LoRa) DIOEvent(dio int) (fired bool, timestamp int64) {
+	if dio < 0 || dio >= LoRaNumDIO {
+		return false, 0
+	}
+	// The interrupt must not fire between reading and clearing the flag, or
+	// its event would be lost.
+	mask := interrupt.Disable()
+	fired = l.pending.HasBits(1 << dio)
+	l.pending.ClearBits(1 << dio)
+	timestamp = l.timestamps[dio]
+	interrupt.Restore(mask)
+	return fired, timestamp
+}
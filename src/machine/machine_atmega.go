@@ -70,6 +70,37 @@ func (i2c *I2C) Tx(addr uint16, w, r []byte) error {
 	return nil
 }
 
+// The TWI sends start and stop conditions on request, so Transaction can send
+// any sequence of messages.
+const i2cTransactionLimits = 0
+
+// transferMsg sends a message of a Transaction, see i2c_transaction.go. The
+// last byte of a read is not acknowledged, as the device then expects a
+// repeated start or a stop.
+func (i2c *I2C) transferMsg(addr uint8, read bool, buf []byte, before, after i2cJoin) error {
+	if before != i2cNoStart {
+		i2c.start(addr, !read)
+	}
+	for i := range buf {
+		if !read {
+			i2c.writeByte(buf[i])
+			continue
+		}
+		if i == len(buf)-1 {
+			avr.TWCR.Set(avr.TWCR_TWEN | avr.TWCR_TWINT)
+			for !avr.TWCR.HasBits(avr.TWCR_TWINT) {
+			}
+			buf[i] = avr.TWDR.Get()
+		} else {
+			buf[i] = i2c.readByte()
+		}
+	}
+	if after == i2cStop {
+		i2c.stop()
+	}
+	return nil
+}
+
 // start starts an I2C communication session.
 func (i2c *I2C) start(address uint8, write bool) {
 	// Clear TWI interrupt flag, put start condition on SDA, and enable TWI.
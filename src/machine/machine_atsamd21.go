@@ -11,6 +11,7 @@ import (
 	"device/arm"
 	"device/sam"
 	"runtime/interrupt"
+	"runtime/volatile"
 	"unsafe"
 )
 
@@ -604,6 +605,7 @@ func (uart *UART) Configure(config UARTConfig) error {
 	uart.Bus.INTENSET.Set(sam.SERCOM_USART_INTENSET_RXC)
 
 	// Enable RX IRQ.
+	uart.Interrupt.SetPriority(interruptPriority(config.Priority, 0x00))
 	uart.Interrupt.Enable()
 
 	return nil
@@ -1789,3 +1791,13 @@ func syncDAC() {
 	for sam.DAC.STATUS.HasBits(sam.DAC_STATUS_SYNCBUSY) {
 	}
 }
+
+// DeviceID returns the 128-bit serial number of the chip, which is unique to
+// the chip.
+func DeviceID() []byte {
+	return appendWordsBE(make([]byte, 0, 16),
+		volatile.LoadUint32((*uint32)(unsafe.Pointer(uintptr(0x0080A00C)))),
+		volatile.LoadUint32((*uint32)(unsafe.Pointer(uintptr(0x0080A040)))),
+		volatile.LoadUint32((*uint32)(unsafe.Pointer(uintptr(0x0080A044)))),
+		volatile.LoadUint32((*uint32)(unsafe.Pointer(uintptr(0x0080A048)))))
+}
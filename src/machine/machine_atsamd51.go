@@ -11,6 +11,7 @@ import (
 	"device/arm"
 	"device/sam"
 	"runtime/interrupt"
+	"runtime/volatile"
 	"unsafe"
 )
 
@@ -2075,3 +2076,13 @@ func GetRNG() (uint32, error) {
 	ret := sam.TRNG.DATA.Get()
 	return ret, nil
 }
+
+// DeviceID returns the 128-bit serial number of the chip, which is unique to
+// the chip.
+func DeviceID() []byte {
+	return appendWordsBE(make([]byte, 0, 16),
+		volatile.LoadUint32((*uint32)(unsafe.Pointer(uintptr(0x008061FC)))),
+		volatile.LoadUint32((*uint32)(unsafe.Pointer(uintptr(0x00806010)))),
+		volatile.LoadUint32((*uint32)(unsafe.Pointer(uintptr(0x00806014)))),
+		volatile.LoadUint32((*uint32)(unsafe.Pointer(uintptr(0x00806018)))))
+}
@@ -22,9 +22,22 @@ const (
 	usb_DEVICE_PCKSIZE_MULTI_PACKET_SIZE_Mask = 0x3FFF
 )
 
-// Configure the USB peripheral. The config is here for compatibility with the UART interface.
+// Configure the USB peripheral. The config is here for compatibility with the
+// UART interface, only its Priority is used. The runtime configures the USB
+// peripheral at startup, after which Configure only sets a nonzero Priority.
 func (dev *USBDevice) Configure(config UARTConfig) {
+	intrs := [...]interrupt.Interrupt{
+		interrupt.New(sam.IRQ_USB_OTHER, handleUSBIRQ),
+		interrupt.New(sam.IRQ_USB_SOF_HSOF, handleUSBIRQ),
+		interrupt.New(sam.IRQ_USB_TRCPT0, handleUSBIRQ),
+		interrupt.New(sam.IRQ_USB_TRCPT1, handleUSBIRQ),
+	}
 	if dev.initcomplete {
+		if config.Priority != 0 {
+			for _, intr := range intrs {
+				intr.SetPriority(config.Priority)
+			}
+		}
 		return
 	}
 
@@ -49,25 +62,39 @@ func (dev *USBDevice) Configure(config UARTConfig) {
 	// set full speed
 	sam.USB_DEVICE.CTRLB.SetBits(sam.USB_DEVICE_CTRLB_SPDCONF_FS << sam.USB_DEVICE_CTRLB_SPDCONF_Pos)
 
-	// attach
-	sam.USB_DEVICE.CTRLB.ClearBits(sam.USB_DEVICE_CTRLB_DETACH)
-
 	// enable interrupt for end of reset
 	sam.USB_DEVICE.INTENSET.SetBits(sam.USB_DEVICE_INTENSET_EORST)
 
 	// enable interrupt for start of frame
 	sam.USB_DEVICE.INTENSET.SetBits(sam.USB_DEVICE_INTENSET_SOF)
 
+	// enable interrupt for suspend, when the start of frame packets stop
+	sam.USB_DEVICE.INTENSET.SetBits(sam.USB_DEVICE_INTENSET_SUSPEND)
+
 	// enable USB
 	sam.USB_DEVICE.CTRLA.SetBits(sam.USB_DEVICE_CTRLA_ENABLE)
 
 	// enable IRQ
-	interrupt.New(sam.IRQ_USB_OTHER, handleUSBIRQ).Enable()
-	interrupt.New(sam.IRQ_USB_SOF_HSOF, handleUSBIRQ).Enable()
-	interrupt.New(sam.IRQ_USB_TRCPT0, handleUSBIRQ).Enable()
-	interrupt.New(sam.IRQ_USB_TRCPT1, handleUSBIRQ).Enable()
+	for _, intr := range intrs {
+		intr.SetPriority(interruptPriority(config.Priority, 0x00))
+		intr.Enable()
+	}
 
 	dev.initcomplete = true
+	if dev.attachOnConfigure() {
+		dev.Attach()
+	}
+}
+
+// Attach connects the device to the bus, so that the host enumerates it.
+func (dev *USBDevice) Attach() {
+	sam.USB_DEVICE.CTRLB.ClearBits(sam.USB_DEVICE_CTRLB_DETACH)
+}
+
+// Detach disconnects the device from the bus, as if the cable was unplugged.
+func (dev *USBDevice) Detach() {
+	sam.USB_DEVICE.CTRLB.SetBits(sam.USB_DEVICE_CTRLB_DETACH)
+	usbDetached()
 }
 
 func handlePadCalibration() {
@@ -124,6 +151,8 @@ func handleUSBIRQ(intr interrupt.Interrupt) {
 		initEndpoint(0, usb.ENDPOINT_TYPE_CONTROL)
 
 		usbConfiguration = 0
+		USBStateChange.Signal()
+		usbSetSuspended(false)
 
 		// ack the End-Of-Reset interrupt
 		sam.USB_DEVICE.INTFLAG.Set(sam.USB_DEVICE_INTFLAG_EORST)
@@ -132,6 +161,12 @@ func handleUSBIRQ(intr interrupt.Interrupt) {
 	// Start of frame
 	if (flags & sam.USB_DEVICE_INTFLAG_SOF) > 0 {
 		// if you want to blink LED showing traffic, this would be the place...
+		usbSetSuspended(false)
+	}
+
+	// Suspend
+	if (flags & sam.USB_DEVICE_INTFLAG_SUSPEND) > 0 {
+		usbSetSuspended(true)
 	}
 
 	// Endpoint 0 Setup interrupt
@@ -146,16 +181,7 @@ func handleUSBIRQ(intr interrupt.Interrupt) {
 		setEPSTATUSCLR(0, sam.USB_DEVICE_ENDPOINT_EPSTATUSCLR_BK0RDY)
 		usbEndpointDescriptors[0].DeviceDescBank[0].PCKSIZE.ClearBits(usb_DEVICE_PCKSIZE_BYTE_COUNT_Mask << usb_DEVICE_PCKSIZE_BYTE_COUNT_Pos)
 
-		ok := false
-		if (setup.BmRequestType & usb.REQUEST_TYPE) == usb.REQUEST_STANDARD {
-			// Standard Requests
-			ok = handleStandardSetup(setup)
-		} else {
-			// Class Interface Requests
-			if setup.WIndex < uint16(len(usbSetupHandler)) && usbSetupHandler[setup.WIndex] != nil {
-				ok = usbSetupHandler[setup.WIndex](setup)
-			}
-		}
+		ok := handleSetup(setup)
 
 		if ok {
 			// set Bank1 ready
@@ -185,7 +211,7 @@ func handleUSBIRQ(intr interrupt.Interrupt) {
 			if usbRxHandler[i] != nil {
 				usbRxHandler[i](buf)
 			}
-			handleEndpointRxComplete(i)
+			usbRxComplete(i)
 		} else if (epFlags & sam.USB_DEVICE_ENDPOINT_EPINTFLAG_TRCPT1) > 0 {
 			if usbTxHandler[i] != nil {
 				usbTxHandler[i]()
@@ -198,7 +224,7 @@ func initEndpoint(ep, config uint32) {
 	switch config {
 	case usb.ENDPOINT_TYPE_INTERRUPT | usb.EndpointIn:
 		// set packet size
-		usbEndpointDescriptors[ep].DeviceDescBank[1].PCKSIZE.SetBits(epPacketSize(64) << usb_DEVICE_PCKSIZE_SIZE_Pos)
+		usbEndpointDescriptors[ep].DeviceDescBank[1].PCKSIZE.SetBits(epPacketSize(usb.EndpointPacketSize) << usb_DEVICE_PCKSIZE_SIZE_Pos)
 
 		// set data buffer address
 		usbEndpointDescriptors[ep].DeviceDescBank[1].ADDR.Set(uint32(uintptr(unsafe.Pointer(&udd_ep_in_cache_buffer[ep]))))
@@ -210,7 +236,7 @@ func initEndpoint(ep, config uint32) {
 
 	case usb.ENDPOINT_TYPE_BULK | usb.EndpointOut:
 		// set packet size
-		usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.SetBits(epPacketSize(64) << usb_DEVICE_PCKSIZE_SIZE_Pos)
+		usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.SetBits(epPacketSize(usb.EndpointPacketSize) << usb_DEVICE_PCKSIZE_SIZE_Pos)
 
 		// set data buffer address
 		usbEndpointDescriptors[ep].DeviceDescBank[0].ADDR.Set(uint32(uintptr(unsafe.Pointer(&udd_ep_out_cache_buffer[ep]))))
@@ -228,11 +254,27 @@ func initEndpoint(ep, config uint32) {
 		setEPSTATUSCLR(ep, sam.USB_DEVICE_ENDPOINT_EPSTATUSCLR_BK0RDY)
 
 	case usb.ENDPOINT_TYPE_INTERRUPT | usb.EndpointOut:
-		// TODO: not really anything, seems like...
+		// set packet size
+		usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.SetBits(epPacketSize(usb.EndpointPacketSize) << usb_DEVICE_PCKSIZE_SIZE_Pos)
+
+		// set data buffer address
+		usbEndpointDescriptors[ep].DeviceDescBank[0].ADDR.Set(uint32(uintptr(unsafe.Pointer(&udd_ep_out_cache_buffer[ep]))))
+
+		// set endpoint type
+		setEPCFG(ep, ((usb.ENDPOINT_TYPE_INTERRUPT + 1) << sam.USB_DEVICE_ENDPOINT_EPCFG_EPTYPE0_Pos))
+
+		// receive interrupts when current transfer complete
+		setEPINTENSET(ep, sam.USB_DEVICE_ENDPOINT_EPINTENSET_TRCPT0)
+
+		// set byte count to zero, we have not received anything yet
+		usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.ClearBits(usb_DEVICE_PCKSIZE_BYTE_COUNT_Mask << usb_DEVICE_PCKSIZE_BYTE_COUNT_Pos)
+
+		// ready for next transfer
+		setEPSTATUSCLR(ep, sam.USB_DEVICE_ENDPOINT_EPSTATUSCLR_BK0RDY)
 
 	case usb.ENDPOINT_TYPE_BULK | usb.EndpointIn:
 		// set packet size
-		usbEndpointDescriptors[ep].DeviceDescBank[1].PCKSIZE.SetBits(epPacketSize(64) << usb_DEVICE_PCKSIZE_SIZE_Pos)
+		usbEndpointDescriptors[ep].DeviceDescBank[1].PCKSIZE.SetBits(epPacketSize(usb.EndpointPacketSize) << usb_DEVICE_PCKSIZE_SIZE_Pos)
 
 		// set data buffer address
 		usbEndpointDescriptors[ep].DeviceDescBank[1].ADDR.Set(uint32(uintptr(unsafe.Pointer(&udd_ep_in_cache_buffer[ep]))))
@@ -245,10 +287,26 @@ func initEndpoint(ep, config uint32) {
 
 		setEPINTENSET(ep, sam.USB_DEVICE_ENDPOINT_EPINTENSET_TRCPT1)
 
+	case usb.ENDPOINT_TYPE_ISOCHRONOUS | usb.EndpointIn:
+		// set packet size
+		usbEndpointDescriptors[ep].DeviceDescBank[1].PCKSIZE.SetBits(epPacketSize(usb.EndpointPacketSize) << usb_DEVICE_PCKSIZE_SIZE_Pos)
+
+		// set data buffer address
+		usbEndpointDescriptors[ep].DeviceDescBank[1].ADDR.Set(uint32(uintptr(unsafe.Pointer(&udd_ep_in_cache_buffer[ep]))))
+
+		// set endpoint type
+		setEPCFG(ep, ((usb.ENDPOINT_TYPE_ISOCHRONOUS + 1) << sam.USB_DEVICE_ENDPOINT_EPCFG_EPTYPE1_Pos))
+
+		// The bank is filled in once the host selects the alternate setting
+		// of the interface that streams.
+		setEPSTATUSCLR(ep, sam.USB_DEVICE_ENDPOINT_EPSTATUSCLR_BK1RDY)
+
+		setEPINTENSET(ep, sam.USB_DEVICE_ENDPOINT_EPINTENSET_TRCPT1)
+
 	case usb.ENDPOINT_TYPE_CONTROL:
 		// Control OUT
 		// set packet size
-		usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.SetBits(epPacketSize(64) << usb_DEVICE_PCKSIZE_SIZE_Pos)
+		usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.SetBits(epPacketSize(usb.EndpointPacketSize) << usb_DEVICE_PCKSIZE_SIZE_Pos)
 
 		// set data buffer address
 		usbEndpointDescriptors[ep].DeviceDescBank[0].ADDR.Set(uint32(uintptr(unsafe.Pointer(&udd_ep_out_cache_buffer[ep]))))
@@ -258,7 +316,7 @@ func initEndpoint(ep, config uint32) {
 
 		// Control IN
 		// set packet size
-		usbEndpointDescriptors[ep].DeviceDescBank[1].PCKSIZE.SetBits(epPacketSize(64) << usb_DEVICE_PCKSIZE_SIZE_Pos)
+		usbEndpointDescriptors[ep].DeviceDescBank[1].PCKSIZE.SetBits(epPacketSize(usb.EndpointPacketSize) << usb_DEVICE_PCKSIZE_SIZE_Pos)
 
 		// set data buffer address
 		usbEndpointDescriptors[ep].DeviceDescBank[1].ADDR.Set(uint32(uintptr(unsafe.Pointer(&udd_ep_in_cache_buffer[ep]))))
@@ -268,7 +326,7 @@ func initEndpoint(ep, config uint32) {
 
 		// Prepare OUT endpoint for receive
 		// set multi packet size for expected number of receive bytes on control OUT
-		usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.SetBits(64 << usb_DEVICE_PCKSIZE_MULTI_PACKET_SIZE_Pos)
+		usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.SetBits(usb.EndpointPacketSize << usb_DEVICE_PCKSIZE_MULTI_PACKET_SIZE_Pos)
 
 		// set byte count to zero, we have not received anything yet
 		usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.ClearBits(usb_DEVICE_PCKSIZE_BYTE_COUNT_Mask << usb_DEVICE_PCKSIZE_BYTE_COUNT_Pos)
@@ -282,8 +340,8 @@ func initEndpoint(ep, config uint32) {
 }
 
 func handleUSBSetAddress(setup usb.Setup) bool {
-	// set packet size 64 with auto Zlp after transfer
-	usbEndpointDescriptors[0].DeviceDescBank[1].PCKSIZE.Set((epPacketSize(64) << usb_DEVICE_PCKSIZE_SIZE_Pos) |
+	// set packet size with auto Zlp after transfer
+	usbEndpointDescriptors[0].DeviceDescBank[1].PCKSIZE.Set((epPacketSize(usb.EndpointPacketSize) << usb_DEVICE_PCKSIZE_SIZE_Pos) |
 		uint32(1<<31)) // autozlp
 
 	// ack the transfer is complete from the request
@@ -400,13 +458,27 @@ func handleEndpointRxComplete(ep uint32) {
 	// set byte count to zero
 	usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.ClearBits(usb_DEVICE_PCKSIZE_BYTE_COUNT_Mask << usb_DEVICE_PCKSIZE_BYTE_COUNT_Pos)
 
-	// set multi packet size to 64
-	usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.SetBits(64 << usb_DEVICE_PCKSIZE_MULTI_PACKET_SIZE_Pos)
+	// set multi packet size to one packet
+	usbEndpointDescriptors[ep].DeviceDescBank[0].PCKSIZE.SetBits(usb.EndpointPacketSize << usb_DEVICE_PCKSIZE_MULTI_PACKET_SIZE_Pos)
 
 	// set ready for next data
 	setEPSTATUSCLR(ep, sam.USB_DEVICE_ENDPOINT_EPSTATUSCLR_BK0RDY)
 }
 
+// setEndpointStall makes the endpoint answer the host with STALL, or resumes
+// it. Resuming it also resets its data toggle to DATA0.
+func setEndpointStall(ep uint32, in, stall bool) {
+	req, dtgl := uint8(sam.USB_DEVICE_ENDPOINT_EPSTATUSSET_STALLRQ0), uint8(sam.USB_DEVICE_ENDPOINT_EPSTATUSCLR_DTGLOUT)
+	if in {
+		req, dtgl = sam.USB_DEVICE_ENDPOINT_EPSTATUSSET_STALLRQ1, sam.USB_DEVICE_ENDPOINT_EPSTATUSCLR_DTGLIN
+	}
+	if stall {
+		setEPSTATUSSET(ep, req)
+	} else {
+		setEPSTATUSCLR(ep, req|dtgl)
+	}
+}
+
 func SendZlp() {
 	usbEndpointDescriptors[0].DeviceDescBank[1].PCKSIZE.ClearBits(usb_DEVICE_PCKSIZE_BYTE_COUNT_Mask << usb_DEVICE_PCKSIZE_BYTE_COUNT_Pos)
 }
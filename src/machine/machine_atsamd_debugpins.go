@@ -0,0 +1,21 @@
+//go:build (sam && atsamd21) || (sam && atsamd51) || (sam && atsame5x)
+// +build sam,atsamd21 sam,atsamd51 sam,atsame5x
+
+package machine
+
+// DisableDebugPins releases SWCLK (PA30) and SWDIO (PA31) from the SWD
+// function, after which they can be configured as GPIOs. See EnableDebugPins
+// to restore them.
+func DisableDebugPins() error {
+	PA30.Configure(PinConfig{Mode: PinInput})
+	PA31.Configure(PinConfig{Mode: PinInput})
+	return nil
+}
+
+// EnableDebugPins switches PA30 and PA31 back to SWCLK and SWDIO, so that a
+// debugger can attach again.
+func EnableDebugPins() error {
+	PA30.Configure(PinConfig{Mode: PinCom})
+	PA31.Configure(PinConfig{Mode: PinCom})
+	return nil
+}
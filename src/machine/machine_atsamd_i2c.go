@@ -0,0 +1,56 @@
+//go:build (sam && atsamd21) || (sam && atsamd51) || (sam && atsame5x)
+// +build sam,atsamd21 sam,atsamd51 sam,atsame5x
+
+package machine
+
+import "device/sam"
+
+// The SERCOM can send any sequence of messages.
+const i2cTransactionLimits = 0
+
+// transferMsg sends a message of a Transaction, see i2c_transaction.go.
+//
+// The SERCOM sends a repeated start when the address is written while it owns
+// the bus, after acknowledging the last byte read according to ACKACT, which
+// is why ACKACT is left set to NACK after every read.
+func (i2c *I2C) transferMsg(addr uint8, read bool, buf []byte, before, after i2cJoin) error {
+	if before != i2cNoStart {
+		i2c.sendAddress(uint16(addr), !read)
+		timeout := i2cTimeout
+		for i2c.Bus.INTFLAG.Get()&(sam.SERCOM_I2CM_INTFLAG_MB|sam.SERCOM_I2CM_INTFLAG_SB) == 0 {
+			timeout--
+			if timeout == 0 {
+				i2c.signalStop()
+				return errI2CWriteTimeout
+			}
+		}
+		// A NACK of a read address sets MB rather than SB.
+		if i2c.Bus.STATUS.HasBits(sam.SERCOM_I2CM_STATUS_RXNACK) ||
+			(read && !i2c.Bus.INTFLAG.HasBits(sam.SERCOM_I2CM_INTFLAG_SB)) {
+			i2c.signalStop()
+			return errI2CAckExpected
+		}
+	}
+	if read {
+		// The first byte is read along with the address.
+		for i := range buf {
+			if i > 0 {
+				i2c.Bus.CTRLB.ClearBits(sam.SERCOM_I2CM_CTRLB_ACKACT)
+				i2c.signalRead()
+			}
+			buf[i] = i2c.readByte()
+		}
+		i2c.Bus.CTRLB.SetBits(sam.SERCOM_I2CM_CTRLB_ACKACT)
+	} else {
+		for _, b := range buf {
+			if err := i2c.WriteByte(b); err != nil {
+				i2c.signalStop()
+				return err
+			}
+		}
+	}
+	if after == i2cStop {
+		return i2c.signalStop()
+	}
+	return nil
+}
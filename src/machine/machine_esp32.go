@@ -313,6 +313,30 @@ func (uart *UART) Configure(config UARTConfig) {
 		config.BaudRate = 115200
 	}
 	uart.Bus.CLKDIV.Set(peripheralClock / config.BaudRate)
+	// Route the UART through the GPIO matrix to the pins that are set. A pin
+	// that isn't set stays on its default: GPIO1 and GPIO3 for UART0, and no
+	// pin for UART1 and UART2, whose default pins are wired to the flash or
+	// the PSRAM on most modules.
+	signal := uart.signal()
+	if config.TX != 0 {
+		config.TX.configure(PinConfig{Mode: PinOutput}, signal)
+	}
+	if config.RX != 0 {
+		config.RX.configure(PinConfig{Mode: PinInput}, signal)
+	}
+}
+
+// signal returns the number of the TXD output and RXD input signals of the
+// UART in the GPIO matrix.
+func (uart *UART) signal() uint32 {
+	switch uart.Bus {
+	case esp.UART1:
+		return 17
+	case esp.UART2:
+		return 198
+	default:
+		return 14
+	}
 }
 
 func (uart *UART) WriteByte(b byte) error {
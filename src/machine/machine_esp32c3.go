@@ -282,6 +282,9 @@ type registerSet struct {
 }
 
 func (uart *UART) Configure(config UARTConfig) error {
+	if config.Priority != 0 {
+		return errNoInterruptPriority
+	}
 	if config.BaudRate == 0 {
 		config.BaudRate = 115200
 	}
@@ -23,8 +23,20 @@ const (
 	PinI2C = PinSPI
 )
 
-// Configure this pin with the given configuration.
+// The functions of the pins other than GPIO (IOF0 and IOF1) are fixed: a
+// peripheral can only use the pins listed in targets/pins/hifive1b.json, from
+// which the fe310...Pins constants are generated into machine_fe310_pins.go.
+// PWM is IOF1: the outputs of PWM0 on P00-P03, of PWM2 on P10-P13, and of
+// PWM1 on P20, P19, P21 and P22. The compare register of channel 0 sets the
+// period, so P00, P10 and P20 aren't PWM pins. SPI1 and I2C0 are IOF0, and the
+// chip selects of SPI1 are on P02, P08, P09 and P10.
+
+// Configure this pin with the given configuration. Configuring a pin without a
+// PWM output as PinPWM panics, as the pin would never output the signal.
 func (p Pin) Configure(config PinConfig) {
+	if config.Mode == PinPWM && uint32(fe310PWMPins)&(1<<uint8(p)) == 0 {
+		panic("machine: pin has no PWM output")
+	}
 	sifive.GPIO0.INPUT_EN.SetBits(1 << uint8(p))
 	switch config.Mode {
 	case PinOutput:
@@ -96,10 +108,16 @@ func (uart *UART) Configure(config UARTConfig) {
 	sifive.UART0.RXCTRL.Set(sifive.UART_RXCTRL_ENABLE)
 	sifive.UART0.IE.Set(sifive.UART_IE_RXWM) // enable the receive interrupt (only)
 	intr := interrupt.New(sifive.IRQ_UART0, _UART0.handleInterrupt)
-	intr.SetPriority(5)
+	intr.SetPriority(interruptPriority(config.Priority, 0xc0)) // low priority by default
 	intr.Enable()
 }
 
+// handleInterrupt is placed in RAM (see riscv.ld), so that reading the byte
+// doesn't wait for the SPI flash after an instruction cache miss. Receive,
+// which stores the byte in the ring buffer, still runs from flash.
+//
+//go:section .ramfunc.uartHandleInterrupt
+//go:noinline
 func (uart *UART) handleInterrupt(interrupt.Interrupt) {
 	rxdata := uart.Bus.RXDATA.Get()
 	c := byte(rxdata)
@@ -133,6 +151,14 @@ type SPIConfig struct {
 	SDI       Pin
 	LSBFirst  bool
 	Mode      uint8
+
+	// Delays for slow devices, in nanoseconds, rounded up to SCK periods
+	// (at most 255): from the assertion of CS to the first clock edge, from
+	// the last clock edge to the release of CS, and between two words. Zero
+	// keeps the shortest delays.
+	CSSetup   uint32
+	CSHold    uint32
+	WordDelay uint32
 }
 
 // Configure is intended to setup the SPI interface.
@@ -142,6 +168,19 @@ func (spi SPI) Configure(config SPIConfig) error {
 		config.SCK = SPI0_SCK_PIN
 		config.SDO = SPI0_SDO_PIN
 		config.SDI = SPI0_SDI_PIN
+		if spi.Bus == sifive.QSPI1 {
+			config.SCK, config.SDO, config.SDI = fe310SPI1SCK, fe310SPI1SDO, fe310SPI1SDI
+		}
+	}
+
+	// The pins of SPI1 are fixed, and SDO or SDI may be left out.
+	if spi.Bus == sifive.QSPI1 {
+		if config.SCK != fe310SPI1SCK {
+			return ErrInvalidClockPin
+		}
+		if (config.SDO != fe310SPI1SDO && config.SDO != NoPin) || (config.SDI != fe310SPI1SDI && config.SDI != NoPin) {
+			return ErrInvalidDataPin
+		}
 	}
 
 	// enable pins for SPI
@@ -158,6 +197,22 @@ func (spi SPI) Configure(config SPIConfig) error {
 	div := CPUFrequency()/(2*config.Frequency) - 1
 	spi.Bus.DIV.Set(div)
 
+	// Delays in SCK periods: CS to SCK and SCK to CS in DELAY0, the minimum
+	// time CS is released and the delay between two frames in DELAY1. The
+	// CS delays are at least one period.
+	delay := func(ns, min uint32) uint32 {
+		n := spiDelayCycles(ns, config.Frequency)
+		if n < min {
+			return min
+		}
+		if n > 0xff {
+			return 0xff
+		}
+		return n
+	}
+	spi.Bus.DELAY0.Set(delay(config.CSSetup, 1) | delay(config.CSHold, 1)<<16)
+	spi.Bus.DELAY1.Set(delay(config.WordDelay, 1) | delay(config.WordDelay, 0)<<16)
+
 	// set mode
 	switch config.Mode {
 	case 0:
@@ -239,6 +294,13 @@ func (i2c *I2C) Configure(config I2CConfig) error {
 		config.SCL = I2C0_SCL_PIN
 	}
 
+	if config.SCL != fe310I2C0SCL {
+		return ErrInvalidClockPin
+	}
+	if config.SDA != fe310I2C0SDA {
+		return ErrInvalidDataPin
+	}
+
 	var prescaler = i2cClockFrequency/(5*config.Frequency) - 1
 
 	// disable controller before setting the prescale registers
@@ -307,6 +369,45 @@ func (i2c *I2C) Tx(addr uint16, w, r []byte) error {
 	return nil
 }
 
+// The controller takes a start, read, write, acknowledge and stop bit with
+// every byte, so messages can be chained in any order.
+const i2cTransactionLimits = 0
+
+// transferMsg sends a message of a Transaction, see i2c_transaction.go.
+func (i2c *I2C) transferMsg(addr uint8, read bool, buf []byte, before, after i2cJoin) error {
+	if before != i2cNoStart {
+		i2c.sendAddress(uint16(addr), !read)
+		if i2c.Bus.CR_SR.HasBits(sifive.I2C_SR_RX_ACK) {
+			i2c.Bus.CR_SR.Set(sifive.I2C_CR_STO)
+			return errI2CAckExpected
+		}
+	}
+	for i := range buf {
+		if !read {
+			if err := i2c.writeByte(buf[i]); err != nil {
+				i2c.Bus.CR_SR.Set(sifive.I2C_CR_STO)
+				return err
+			}
+			continue
+		}
+		cmd := uint32(sifive.I2C_CR_RD)
+		if i == len(buf)-1 {
+			// Don't acknowledge the last byte.
+			cmd |= sifive.I2C_CR_ACK
+		}
+		i2c.Bus.CR_SR.Set(cmd)
+		for i2c.Bus.CR_SR.HasBits(sifive.I2C_SR_TIP) {
+		}
+		buf[i] = byte(i2c.Bus.TXR_RXR.Get())
+	}
+	if after == i2cStop {
+		i2c.Bus.CR_SR.Set(sifive.I2C_CR_STO)
+		for i2c.Bus.CR_SR.HasBits(sifive.I2C_SR_TIP) {
+		}
+	}
+	return nil
+}
+
 // Writes a single byte to the I2C bus.
 func (i2c *I2C) writeByte(data byte) error {
 	// Send data byte
@@ -0,0 +1,150 @@
+//go:build fe310
+// +build fe310
+
+package machine
+
+import (
+	"runtime/volatile"
+	"unsafe"
+)
+
+// The always-on (AON) domain of the FE310 keeps running while the rest of the
+// chip is powered down. It contains the RTC, the power management unit (PMU)
+// that sequences sleep and wake-up, and a set of backup registers whose
+// contents survive sleep. Waking up from sleep resets the core, so the program
+// starts again from the beginning: use WakeupCause and the backup registers to
+// tell a wake-up apart from a power-on.
+
+// NumBackupRegisters is the number of 32-bit backup registers in the AON
+// domain that are available to the program: 13 of the 16. DeepSleep stores the
+// Ticks count and the RTC count at the start of the sleep in the last three,
+// which are the only memory that survives the sleep, so that Ticks can add the
+// time spent sleeping after the wake-up.
+const NumBackupRegisters = aonNumBackup - 3
+
+// Backup registers used by the machine package.
+const (
+	aonNumBackup     = 16
+	backupTicksLow   = NumBackupRegisters // Ticks when going to sleep
+	backupTicksHigh  = NumBackupRegisters + 1
+	backupSleepStart = NumBackupRegisters + 2 // RTC count when going to sleep
+)
+
+// pmuKey must be written to pmukey right before every write to a PMU
+// register.
+const pmuKey = 0x51f15e
+
+const (
+	pmuieRTC     = 1 << 1
+	pmuieDWakeup = 1 << 2
+
+	pmucauseWakeupMsk = 0x3
+	pmucauseResetPos  = 8
+	pmucauseResetMsk  = 0x3 << pmucauseResetPos
+
+	// aonRTCFrequency is the frequency of the AON low frequency clock, which
+	// drives the RTC.
+	aonRTCFrequency = 32768
+)
+
+type aonType struct {
+	_          [0x50 / 4]uint32
+	rtcs       volatile.Register32 // 0x050: scaled RTC count
+	_          [3]uint32
+	rtccmp0    volatile.Register32 // 0x060: RTC compare
+	_          [7]uint32
+	backup     [aonNumBackup]volatile.Register32 // 0x080
+	_          [(0x100 - 0x0c0) / 4]uint32
+	pmuwakeupi [8]volatile.Register32 // 0x100: wake-up program
+	pmusleepi  [8]volatile.Register32 // 0x120: sleep program
+	pmuie      volatile.Register32    // 0x140: wake-up sources
+	pmucause   volatile.Register32    // 0x144: wake-up and reset cause
+	pmusleep   volatile.Register32    // 0x148: sleep initiation
+	pmukey     volatile.Register32    // 0x14c: PMU key
+}
+
+var aon = (*aonType)(unsafe.Pointer(uintptr(0x10000000)))
+
+// BackupRegister returns the value of the backup register at index i. Backup
+// registers keep their value while the chip sleeps.
+func BackupRegister(i int) uint32 {
+	return aon.backup[:NumBackupRegisters][i].Get()
+}
+
+// SetBackupRegister stores a value in the backup register at index i.
+func SetBackupRegister(i int, value uint32) {
+	aon.backup[:NumBackupRegisters][i].Set(value)
+}
+
+// WakeupCause is the reason the chip last started running.
+type WakeupCause uint8
+
+const (
+	WakeupReset WakeupCause = iota // power-on or reset, not a wake-up
+	WakeupRTC                      // the RTC compare value was reached
+	WakeupPin                      // the WAKE pin was pulled low
+)
+
+// ResetCause is the reason for the last reset of the chip.
+type ResetCause uint8
+
+const (
+	ResetPowerOn ResetCause = iota
+	ResetExternal
+	ResetWatchdog
+)
+
+// GetWakeupCause returns why the chip started running: after a reset or after
+// a wake-up from sleep.
+func GetWakeupCause() WakeupCause {
+	return WakeupCause(aon.pmucause.Get() & pmucauseWakeupMsk)
+}
+
+// GetResetCause returns the cause of the last reset. This is only meaningful
+// if GetWakeupCause returns WakeupReset.
+func GetResetCause() ResetCause {
+	return ResetCause((aon.pmucause.Get() & pmucauseResetMsk) >> pmucauseResetPos)
+}
+
+// DeepSleep powers down everything but the AON domain. The chip wakes up after
+// the given number of microseconds if it is not zero, or when the WAKE pin is
+// pulled low if wakePin is set. Waking up resets the chip, so DeepSleep does
+// not return. Without any wake-up source, only a reset wakes the chip.
+//
+// Ticks continues after the wake-up with the time spent sleeping added, as
+// long as the chip sleeps less than 36 hours.
+func DeepSleep(us uint64, wakePin bool) {
+	// The runtime runs the RTC unscaled from the 32.768kHz clock.
+	now := aon.rtcs.Get()
+	ticks := Ticks()
+	aon.backup[backupTicksLow].Set(uint32(ticks))
+	aon.backup[backupTicksHigh].Set(uint32(ticks >> 32))
+	aon.backup[backupSleepStart].Set(now)
+
+	var ie uint32
+	if us != 0 {
+		aon.rtccmp0.Set(now + uint32(us*aonRTCFrequency/1000_000))
+		ie |= pmuieRTC
+	}
+	if wakePin {
+		ie |= pmuieDWakeup
+	}
+	aon.pmukey.Set(pmuKey)
+	aon.pmuie.Set(ie)
+	aon.pmukey.Set(pmuKey)
+	aon.pmusleep.Set(0)
+	for {
+		// The PMU is shutting down the core.
+	}
+}
+
+func init() {
+	if GetWakeupCause() == WakeupReset {
+		return
+	}
+	// Woke up from DeepSleep: continue Ticks where it stopped, plus the time
+	// spent sleeping according to the RTC, which kept running.
+	ticks := uint64(aon.backup[backupTicksHigh].Get())<<32 | uint64(aon.backup[backupTicksLow].Get())
+	slept := aon.rtcs.Get() - aon.backup[backupSleepStart].Get()
+	ticksOffset = int64(ticks) + int64(uint64(slept)*1000_000/aonRTCFrequency)
+}
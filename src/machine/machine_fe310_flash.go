@@ -0,0 +1,347 @@
+//go:build fe310
+// +build fe310
+
+package machine
+
+import (
+	"device/riscv"
+	"errors"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// The program runs directly from the external SPI flash chip, which the QSPI0
+// controller maps into memory at FlashBase (execute in place, or XIP). To send
+// other commands to the flash chip, such as erase and program commands, the
+// memory mapping must be switched off. No code may run from flash in the
+// meantime, so the routines that do this are placed in RAM (see the .ramfunc
+// sections in riscv.ld) and run with interrupts disabled.
+
+// FlashBase is the address where the flash chip is mapped into memory.
+const FlashBase = 0x20000000
+
+const (
+	// FlashSectorSize is the smallest region of the flash that can be erased.
+	FlashSectorSize = 4096
+
+	// FlashPageSize is the largest region of the flash that can be programmed
+	// in one go.
+	FlashPageSize = 256
+)
+
+var (
+	errFlashAlignment = errors.New("flash: address not aligned to a sector")
+	errFlashRange     = errors.New("flash: address outside of the flash")
+	errFlashProtected = errors.New("flash: address within the running program")
+)
+
+// FlashReadMode describes the command the QSPI0 controller sends to the flash
+// chip to fetch memory-mapped data.
+type FlashReadMode struct {
+	Command     uint8
+	DummyCycles uint8
+	// Protocol (number of data lines: 1, 2 or 4) of the address and of the
+	// data. The command is always sent on a single line.
+	AddressLines uint8
+	DataLines    uint8
+}
+
+var (
+	// FlashReadNormal is the slow read command that every flash chip
+	// supports, limited to about 50MHz.
+	FlashReadNormal = FlashReadMode{Command: 0x03, AddressLines: 1, DataLines: 1}
+
+	// FlashReadFast is the fast read command, which adds dummy cycles to
+	// allow higher clock frequencies.
+	FlashReadFast = FlashReadMode{Command: 0x0b, DummyCycles: 8, AddressLines: 1, DataLines: 1}
+
+	// FlashReadDualOutput is like FlashReadFast, but transfers data on two
+	// lines.
+	FlashReadDualOutput = FlashReadMode{Command: 0x3b, DummyCycles: 8, AddressLines: 1, DataLines: 2}
+)
+
+// Flash chip commands and QSPI0 register bits.
+const (
+	flashCmdWriteEnable = 0x06
+	flashCmdReadStatus  = 0x05
+	flashCmdPageProgram = 0x02
+	flashCmdSectorErase = 0x20
+	flashStatusBusy     = 1 << 0
+
+	qspiCSModeAuto       = 0
+	qspiCSModeHold       = 2
+	qspiFctrlEnable      = 1 << 0
+	qspiFmtLength8       = 8 << 16
+	qspiRxdataEmpty      = 1 << 31
+	qspiFfmtCmdEnable    = 1 << 0
+	qspiFfmtAddrLenPos   = 1
+	qspiFfmtPadCntPos    = 4
+	qspiFfmtAddrProtoPos = 10
+	qspiFfmtDataProtoPos = 12
+	qspiFfmtCmdCodePos   = 16
+	qspi0Base            = 0x10014000
+)
+
+type qspiFlashType struct {
+	sckdiv  volatile.Register32 // 0x00
+	sckmode volatile.Register32 // 0x04
+	_       [2]uint32
+	csid    volatile.Register32 // 0x10
+	csdef   volatile.Register32 // 0x14
+	csmode  volatile.Register32 // 0x18
+	_       [9]uint32
+	fmt     volatile.Register32 // 0x40
+	_       uint32
+	txdata  volatile.Register32 // 0x48
+	rxdata  volatile.Register32 // 0x4c
+	_       [4]uint32
+	fctrl   volatile.Register32 // 0x60
+	ffmt    volatile.Register32 // 0x64
+}
+
+// flashPage holds the data of a program command, as the data to be written
+// may itself be in flash and can't be read while the memory mapping is off.
+var flashPage [FlashPageSize]byte
+
+// SetFlashReadMode changes the read command and clock frequency used to fetch
+// memory-mapped data from the flash, to speed up code running from flash. The
+// frequency is rounded down to what the controller can generate, and defaults
+// to 50MHz. Make sure the flash chip supports the command at the given
+// frequency, or the program will crash.
+func SetFlashReadMode(mode FlashReadMode, frequency uint32) {
+	if frequency == 0 {
+		frequency = 50 * MHz
+	}
+	// fsck = fin / (2 * (div + 1))
+	div := (CPUFrequency()+2*frequency-1)/(2*frequency) - 1
+	ffmt := uint32(qspiFfmtCmdEnable |
+		3<<qspiFfmtAddrLenPos |
+		uint32(mode.DummyCycles)<<qspiFfmtPadCntPos |
+		flashProtocol(mode.AddressLines)<<qspiFfmtAddrProtoPos |
+		flashProtocol(mode.DataLines)<<qspiFfmtDataProtoPos |
+		uint32(mode.Command)<<qspiFfmtCmdCodePos)
+	mask := riscv.DisableInterrupts()
+	flashSetReadMode(div, ffmt)
+	riscv.EnableInterrupts(mask)
+}
+
+func flashProtocol(lines uint8) uint32 {
+	switch lines {
+	case 2:
+		return 1
+	case 4:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// ReadFlash reads data from the flash at the given offset from FlashBase.
+func ReadFlash(offset uint32, data []byte) error {
+	if err := flashCheckRange(offset, len(data)); err != nil {
+		return err
+	}
+	copy(data, unsafe.Slice((*byte)(unsafe.Pointer(uintptr(FlashBase+offset))), len(data)))
+	return nil
+}
+
+// EraseFlashSector erases the sector at the given offset from FlashBase, which
+// must be a multiple of FlashSectorSize. Erased flash reads as 0xff.
+func EraseFlashSector(offset uint32) error {
+	if offset%FlashSectorSize != 0 {
+		return errFlashAlignment
+	}
+	if err := flashCheckWritable(offset, FlashSectorSize); err != nil {
+		return err
+	}
+	mask := riscv.DisableInterrupts()
+	flashCommand(flashCmdSectorErase, offset, 0)
+	riscv.Asm("fence.i")
+	riscv.EnableInterrupts(mask)
+	return nil
+}
+
+// WriteFlash programs data into the flash at the given offset from FlashBase.
+// Programming can only clear bits, so the region must have been erased first.
+func WriteFlash(offset uint32, data []byte) error {
+	if err := flashCheckWritable(offset, len(data)); err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		// A program command must not cross a page boundary.
+		n := FlashPageSize - int(offset%FlashPageSize)
+		if n > len(data) {
+			n = len(data)
+		}
+		copy(flashPage[:], data[:n])
+		mask := riscv.DisableInterrupts()
+		flashCommand(flashCmdPageProgram, offset, n)
+		riscv.Asm("fence.i")
+		riscv.EnableInterrupts(mask)
+		offset += uint32(n)
+		data = data[n:]
+	}
+	return nil
+}
+
+func flashCheckRange(offset uint32, size int) error {
+	if uint64(offset)+uint64(size) > flashSize {
+		return errFlashRange
+	}
+	return nil
+}
+
+// flashCheckWritable checks that the region is in the flash and does not
+// overlap the bootloader or the program image.
+func flashCheckWritable(offset uint32, size int) error {
+	if err := flashCheckRange(offset, size); err != nil {
+		return err
+	}
+	if offset < flashProgramEnd() {
+		return errFlashProtected
+	}
+	return nil
+}
+
+// flashProgramEnd returns the offset from FlashBase where the program image,
+// including the initial values of .data, ends.
+func flashProgramEnd() uint32 {
+	end := uintptr(unsafe.Pointer(&_sidata)) + (uintptr(unsafe.Pointer(&_edata)) - uintptr(unsafe.Pointer(&_sdata)))
+	return uint32(end - FlashBase)
+}
+
+// Flash is the part of the flash chip after the program, as a BlockDevice for
+// filesystems and other persistent data. It starts at the first sector after
+// the program, so it moves when the program grows.
+var Flash flashBlockDevice
+
+type flashBlockDevice struct{}
+
+func (f flashBlockDevice) start() uint32 {
+	return (flashProgramEnd() + FlashSectorSize - 1) &^ (FlashSectorSize - 1)
+}
+
+// ReadAt reads len(p) bytes at offset off.
+func (f flashBlockDevice) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > f.Size() {
+		return 0, errFlashRange
+	}
+	if err := ReadFlash(f.start()+uint32(off), p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteAt programs len(p) bytes at offset off. The region must have been
+// erased.
+func (f flashBlockDevice) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > f.Size() {
+		return 0, errFlashRange
+	}
+	if err := WriteFlash(f.start()+uint32(off), p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Size returns the number of bytes available after the program.
+func (f flashBlockDevice) Size() int64 {
+	return int64(flashSize) - int64(f.start())
+}
+
+// WriteBlockSize returns 1: the flash can be programmed byte by byte.
+func (f flashBlockDevice) WriteBlockSize() int64 {
+	return 1
+}
+
+// EraseBlockSize returns the size of a flash sector.
+func (f flashBlockDevice) EraseBlockSize() int64 {
+	return FlashSectorSize
+}
+
+// EraseBlocks erases count sectors, starting at sector start.
+func (f flashBlockDevice) EraseBlocks(start, count int64) error {
+	if start < 0 || count < 0 || (start+count)*FlashSectorSize > f.Size() {
+		return errFlashRange
+	}
+	for block := start; block < start+count; block++ {
+		feedWatchdog()
+		if err := EraseFlashSector(f.start() + uint32(block*FlashSectorSize)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//go:extern _sidata
+var _sidata [0]byte
+
+//go:extern _sdata
+var _sdata [0]byte
+
+//go:extern _edata
+var _edata [0]byte
+
+// The functions below run from RAM while the memory mapping of the flash is
+// off. They must not call functions in flash or read constants from flash.
+
+//go:section .ramfunc.flashSetReadMode
+//go:noinline
+func flashSetReadMode(div, ffmt uint32) {
+	qspi := (*qspiFlashType)(unsafe.Pointer(uintptr(qspi0Base)))
+	qspi.fctrl.Set(0)
+	qspi.sckdiv.Set(div)
+	qspi.ffmt.Set(ffmt)
+	qspi.fctrl.Set(qspiFctrlEnable)
+}
+
+// flashCommand sends a write enable command followed by the given erase or
+// program command with n bytes of data from flashPage, and waits until the
+// flash chip is done.
+//
+//go:section .ramfunc.flashCommand
+//go:noinline
+func flashCommand(cmd uint8, addr uint32, n int) {
+	qspi := (*qspiFlashType)(unsafe.Pointer(uintptr(qspi0Base)))
+	qspi.fctrl.Set(0)
+	qspi.fmt.Set(qspiFmtLength8)
+	for qspi.rxdata.Get()&qspiRxdataEmpty == 0 {
+		// Drain stale data.
+	}
+
+	qspi.csmode.Set(qspiCSModeHold)
+	flashTransfer(qspi, flashCmdWriteEnable)
+	qspi.csmode.Set(qspiCSModeAuto)
+
+	qspi.csmode.Set(qspiCSModeHold)
+	flashTransfer(qspi, cmd)
+	flashTransfer(qspi, uint8(addr>>16))
+	flashTransfer(qspi, uint8(addr>>8))
+	flashTransfer(qspi, uint8(addr))
+	for i := 0; i < n; i++ {
+		flashTransfer(qspi, flashPage[i])
+	}
+	qspi.csmode.Set(qspiCSModeAuto)
+
+	for {
+		qspi.csmode.Set(qspiCSModeHold)
+		flashTransfer(qspi, flashCmdReadStatus)
+		status := flashTransfer(qspi, 0)
+		qspi.csmode.Set(qspiCSModeAuto)
+		if status&flashStatusBusy == 0 {
+			break
+		}
+	}
+	qspi.fctrl.Set(qspiFctrlEnable)
+}
+
+//go:section .ramfunc.flashTransfer
+func flashTransfer(qspi *qspiFlashType, b uint8) uint8 {
+	qspi.txdata.Set(uint32(b))
+	for {
+		rx := qspi.rxdata.Get()
+		if rx&qspiRxdataEmpty == 0 {
+			return uint8(rx)
+		}
+	}
+}
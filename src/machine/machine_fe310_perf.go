@@ -0,0 +1,157 @@
+//go:build fe310
+// +build fe310
+
+package machine
+
+import (
+	"device/riscv"
+	"errors"
+)
+
+// The E31 core of the FE310 has two fixed counters, for cycles and retired
+// instructions, and two hardware performance monitor counters that count a
+// selectable set of events. They count all the time, so a measurement is the
+// difference between two readings. A PerfStopwatch does the bookkeeping:
+//
+//	sw := machine.PerfStopwatch{Counter: machine.PerfCycles}
+//	sw.Start()
+//	display.Display()
+//	sw.Stop()
+//	println("cycles:", sw.Count())
+
+var errPerfCounterFixed = errors.New("perf: counter event cannot be changed")
+
+// PerfCounter is one of the performance counters of the core.
+type PerfCounter uint8
+
+const (
+	PerfCycles       PerfCounter = iota // mcycle: clock cycles
+	PerfInstructions                    // minstret: retired instructions
+	PerfHPM3                            // mhpmcounter3: events selected with SetEvent
+	PerfHPM4                            // mhpmcounter4: events selected with SetEvent
+)
+
+// Events that can be counted by PerfHPM3 and PerfHPM4. Events of the same
+// class can be combined with a bitwise or, the counter then counts all of
+// them.
+const (
+	// Instruction commit events.
+	PerfEventException      uint32 = 1 << 8
+	PerfEventLoad           uint32 = 1 << 9
+	PerfEventStore          uint32 = 1 << 10
+	PerfEventAtomic         uint32 = 1 << 11
+	PerfEventSystem         uint32 = 1 << 12
+	PerfEventArithmetic     uint32 = 1 << 13
+	PerfEventBranch         uint32 = 1 << 14
+	PerfEventJAL            uint32 = 1 << 15
+	PerfEventJALR           uint32 = 1 << 16
+	PerfEventMultiplication uint32 = 1 << 17
+	PerfEventDivision       uint32 = 1 << 18
+
+	// Microarchitectural events.
+	PerfEventLoadUseInterlock  uint32 = 1 | 1<<8
+	PerfEventLongLatency       uint32 = 1 | 1<<9
+	PerfEventCSRReadInterlock  uint32 = 1 | 1<<10
+	PerfEventICacheBusy        uint32 = 1 | 1<<11
+	PerfEventDCacheBusy        uint32 = 1 | 1<<12
+	PerfEventBranchMispredict  uint32 = 1 | 1<<13
+	PerfEventTargetMispredict  uint32 = 1 | 1<<14
+	PerfEventCSRWriteFlush     uint32 = 1 | 1<<15
+	PerfEventOtherFlush        uint32 = 1 | 1<<16
+	PerfEventMultiplyInterlock uint32 = 1 | 1<<17
+
+	// Memory system events.
+	PerfEventICacheMiss uint32 = 2 | 1<<8
+	PerfEventMMIO       uint32 = 2 | 1<<9
+)
+
+// SetEvent selects the events counted by a hardware performance monitor
+// counter and resets it to zero. The fixed counters cannot be changed.
+func (c PerfCounter) SetEvent(event uint32) error {
+	switch c {
+	case PerfHPM3:
+		riscv.MHPMEVENT3.Set(uintptr(event))
+		riscv.MHPMCOUNTER3.Set(0)
+		riscv.MHPMCOUNTER3H.Set(0)
+	case PerfHPM4:
+		riscv.MHPMEVENT4.Set(uintptr(event))
+		riscv.MHPMCOUNTER4.Set(0)
+		riscv.MHPMCOUNTER4H.Set(0)
+	default:
+		return errPerfCounterFixed
+	}
+	return nil
+}
+
+// Read returns the current value of the counter.
+func (c PerfCounter) Read() uint64 {
+	// The CSR intrinsics need a constant CSR, so each counter is read
+	// separately. The high word is read twice to detect a carry out of the low
+	// word in between.
+	var high, low, high2 uintptr
+	for {
+		switch c {
+		case PerfCycles:
+			high, low, high2 = riscv.MCYCLEH.Get(), riscv.MCYCLE.Get(), riscv.MCYCLEH.Get()
+		case PerfInstructions:
+			high, low, high2 = riscv.MINSTRETH.Get(), riscv.MINSTRET.Get(), riscv.MINSTRETH.Get()
+		case PerfHPM3:
+			high, low, high2 = riscv.MHPMCOUNTER3H.Get(), riscv.MHPMCOUNTER3.Get(), riscv.MHPMCOUNTER3H.Get()
+		case PerfHPM4:
+			high, low, high2 = riscv.MHPMCOUNTER4H.Get(), riscv.MHPMCOUNTER4.Get(), riscv.MHPMCOUNTER4H.Get()
+		default:
+			return 0
+		}
+		if high == high2 {
+			return uint64(high)<<32 | uint64(low)
+		}
+	}
+}
+
+// Since returns how much the counter advanced since it had the value start,
+// as returned by Read.
+func (c PerfCounter) Since(start uint64) uint64 {
+	return c.Read() - start
+}
+
+// PerfStopwatch accumulates the count of a performance counter over one or
+// more measurements, between calls to Start and Stop.
+type PerfStopwatch struct {
+	Counter PerfCounter
+
+	total   uint64
+	start   uint64
+	running bool
+}
+
+// Start starts a measurement. It does nothing if one is already running.
+func (s *PerfStopwatch) Start() {
+	if s.running {
+		return
+	}
+	s.running = true
+	s.start = s.Counter.Read()
+}
+
+// Stop ends the running measurement and adds it to the total.
+func (s *PerfStopwatch) Stop() {
+	if !s.running {
+		return
+	}
+	s.total += s.Counter.Since(s.start)
+	s.running = false
+}
+
+// Reset clears the total and stops the running measurement, if any.
+func (s *PerfStopwatch) Reset() {
+	s.total = 0
+	s.running = false
+}
+
+// Count returns the total of all measurements, including the running one.
+func (s *PerfStopwatch) Count() uint64 {
+	if s.running {
+		return s.total + s.Counter.Since(s.start)
+	}
+	return s.total
+}
@@ -0,0 +1,26 @@
+//go:build fe310
+// +build fe310
+
+// Code generated by gen-board-pins from targets/pins/hifive1b.json. DO NOT EDIT.
+
+package machine
+
+// The chip pins of each peripheral function, as a bit mask of the pin
+// numbers, and the pin of a function that has a single one.
+const (
+	fe310I2C0SCLPins     = 0x2000 // P13
+	fe310I2C0SCL     Pin = 13
+	fe310I2C0SDAPins     = 0x1000 // P12
+	fe310I2C0SDA     Pin = 12
+	fe310PWMPins         = 0x68380e // P01, P02, P03, P11, P12, P13, P19, P21, P22
+	fe310SPI1SCKPins     = 0x20     // P05
+	fe310SPI1SCK     Pin = 5
+	fe310SPI1SDIPins     = 0x10 // P04
+	fe310SPI1SDI     Pin = 4
+	fe310SPI1SDOPins     = 0x8 // P03
+	fe310SPI1SDO     Pin = 3
+	fe310UART0RXPins     = 0x10000 // P16
+	fe310UART0RX     Pin = 16
+	fe310UART0TXPins     = 0x20000 // P17
+	fe310UART0TX     Pin = 17
+)
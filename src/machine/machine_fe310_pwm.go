@@ -0,0 +1,127 @@
+//go:build fe310
+// +build fe310
+
+package machine
+
+import (
+	"runtime/volatile"
+	"unsafe"
+)
+
+// PWM is one of the three PWM peripherals of the FE310, each with a counter
+// and four comparators. The first comparator sets the period, so only the
+// channels 1 to 3 drive a pin: P01-P03 on PWM0, P19, P21 and P22 on PWM1
+// (the green, blue and red LED of the HiFive1 Rev B), and P11-P13 on PWM2.
+// The comparators of PWM0 have 8 bits, those of PWM1 and PWM2 have 16.
+type PWM struct {
+	base      uintptr
+	bits      uint8  // width of the comparators
+	pins      [4]Pin // pins of the channels
+	inverting uint8  // bits of the inverted channels
+}
+
+var (
+	PWM0 = &PWM{base: 0x10015000, bits: 8, pins: [4]Pin{0, 1, 2, 3}}
+	PWM1 = &PWM{base: 0x10025000, bits: 16, pins: [4]Pin{20, 19, 21, 22}}
+	PWM2 = &PWM{base: 0x10035000, bits: 16, pins: [4]Pin{10, 11, 12, 13}}
+)
+
+type pwmType struct {
+	pwmcfg   volatile.Register32 // 0x00: configuration
+	_        uint32
+	pwmcount volatile.Register32 // 0x08: counter
+	_        uint32
+	pwms     volatile.Register32 // 0x10: scaled counter
+	_        [3]uint32
+	pwmcmp   [4]volatile.Register32 // 0x20: comparators
+}
+
+const (
+	pwmcfgScaleMsk = 0xf
+	pwmcfgZeroCmp  = 1 << 9  // reset the counter after it reaches pwmcmp0
+	pwmcfgDeglitch = 1 << 10 // keep the outputs from glitching within a period
+	pwmcfgEnAlways = 1 << 12 // run continuously
+)
+
+func (pwm *PWM) regs() *pwmType {
+	return (*pwmType)(unsafe.Pointer(pwm.base))
+}
+
+// Configure enables and configures this PWM. A period of zero picks the
+// shortest period with the full resolution of the comparators, which works
+// well for LEDs. The longest period is about 6.7s on PWM1 and PWM2.
+func (pwm *PWM) Configure(config PWMConfig) error {
+	// The counter counts from 0 to top, and a channel is switched off with
+	// a comparator value of top+1, which must fit in the comparator.
+	maxTicks := uint64(1)<<pwm.bits - 1
+	ticks := maxTicks
+	if config.Period != 0 {
+		ticks = config.Period * uint64(CPUFrequency()) / 1e9
+	}
+	scale := uint32(0)
+	for ticks > maxTicks {
+		if scale == pwmcfgScaleMsk {
+			return ErrPWMPeriodTooLong
+		}
+		scale++
+		ticks >>= 1
+	}
+	if ticks < 2 {
+		ticks = 2
+	}
+
+	r := pwm.regs()
+	r.pwmcfg.Set(0)
+	r.pwmcount.Set(0)
+	r.pwmcmp[0].Set(uint32(ticks - 1))
+	for ch := uint8(1); ch < 4; ch++ {
+		pwm.Set(ch, 0)
+	}
+	r.pwmcfg.Set(scale | pwmcfgZeroCmp | pwmcfgDeglitch | pwmcfgEnAlways)
+	return nil
+}
+
+// Top returns the current counter top, for use in duty cycle calculation.
+func (pwm *PWM) Top() uint32 {
+	return pwm.regs().pwmcmp[0].Get()
+}
+
+// Channel configures a pin as the output of a channel and returns the channel.
+// The pins of channel 0 can't be used, as its comparator sets the period.
+func (pwm *PWM) Channel(pin Pin) (uint8, error) {
+	for ch := uint8(1); ch < 4; ch++ {
+		if pwm.pins[ch] == pin {
+			pin.Configure(PinConfig{Mode: PinPWM})
+			return ch, nil
+		}
+	}
+	return 0, ErrInvalidOutputPin
+}
+
+// SetInverting sets whether to invert the output of this channel.
+func (pwm *PWM) SetInverting(channel uint8, inverting bool) {
+	if inverting == (pwm.inverting&(1<<channel) != 0) {
+		return
+	}
+	pwm.inverting ^= 1 << channel
+	// Inverting the output is the same as inverting the duty cycle.
+	cmp := &pwm.regs().pwmcmp[channel]
+	cmp.Set(pwm.Top() + 1 - cmp.Get())
+}
+
+// Set updates the channel value, from 0 (always low) to Top() (always high)
+// unless the output is inverted.
+func (pwm *PWM) Set(channel uint8, value uint32) {
+	top := pwm.Top()
+	if value > top {
+		value = top
+	}
+	// A channel is high while the count is at least its comparator value, so
+	// it is high for the last top+1-cmp ticks of the period.
+	high := uint32((uint64(value)*uint64(top+1) + uint64(top)/2) / uint64(top))
+	cmp := top + 1 - high
+	if pwm.inverting&(1<<channel) != 0 {
+		cmp = high
+	}
+	pwm.regs().pwmcmp[channel].Set(cmp)
+}
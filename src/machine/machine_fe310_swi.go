@@ -0,0 +1,59 @@
+//go:build fe310
+// +build fe310
+
+package machine
+
+import (
+	"device/riscv"
+	"device/sifive"
+	_ "unsafe" // for go:linkname
+)
+
+// The machine software interrupt (MSIP in the CLINT) can be triggered by
+// software at any time. When an external interrupt is pending at the same
+// time, the external interrupt is taken first, and interrupts don't nest: an
+// interrupt handler that triggers the software interrupt returns before the
+// software interrupt handler runs. This makes it possible to defer work from a
+// peripheral interrupt to a lower priority context, like PendSV on Cortex-M:
+//
+//	machine.SetSoftwareInterruptHandler(processSamples)
+//
+//	func handleADC(interrupt.Interrupt) {
+//		// store the sample somewhere
+//		machine.TriggerSoftwareInterrupt()
+//	}
+
+var softwareInterruptHandler func()
+
+// SetSoftwareInterruptHandler sets the function that is called in interrupt
+// context when the software interrupt is triggered. Passing nil disables the
+// software interrupt.
+func SetSoftwareInterruptHandler(handler func()) {
+	riscv.MIE.ClearBits(1 << 3) // MSIE
+	softwareInterruptHandler = handler
+	if handler != nil {
+		riscv.MIE.SetBits(1 << 3)
+	}
+}
+
+// TriggerSoftwareInterrupt makes the software interrupt pending. The handler
+// is called once, even if the interrupt was triggered several times before it
+// ran.
+func TriggerSoftwareInterrupt() {
+	sifive.CLINT.MSIP.Set(1)
+}
+
+// handleSoftwareInterrupt runs from RAM, like the trap handler that calls it.
+// The handler set with SetSoftwareInterruptHandler runs from flash, unless it
+// is placed in RAM too.
+//
+//go:linkname handleSoftwareInterrupt runtime.machineSoftwareInterrupt
+//go:section .ramfunc.handleSoftwareInterrupt
+//go:noinline
+func handleSoftwareInterrupt() {
+	// Clear the interrupt first, so that the handler may trigger it again.
+	sifive.CLINT.MSIP.Set(0)
+	if softwareInterruptHandler != nil {
+		softwareInterruptHandler()
+	}
+}
@@ -377,10 +377,21 @@ func (uart *UART) Configure(config UARTConfig) {
 	uart.Bus.IE.Set(kendryte.UARTHS_IE_RXWM)
 
 	intr := interrupt.New(kendryte.IRQ_UARTHS, _UART0.handleInterrupt)
-	intr.SetPriority(5)
+	if config.Priority != 0 {
+		intr.SetPriority(plicLevel(config.Priority))
+	} else {
+		intr.SetPriority(5)
+	}
 	intr.Enable()
 }
 
+// plicLevel maps a priority of UARTConfig, where lower values are more urgent
+// like on Cortex-M, onto the PLIC levels from 7 (highest) for 0x00 to 1 for
+// 0xff, which interrupt.Interrupt.SetPriority takes as they are on the K210.
+func plicLevel(priority uint8) uint8 {
+	return uint8(7 - uint32(priority)*7/256)
+}
+
 func (uart *UART) handleInterrupt(interrupt.Interrupt) {
 	rxdata := uart.Bus.RXDATA.Get()
 	c := byte(rxdata)
@@ -650,3 +661,67 @@ func (i2c *I2C) Tx(addr uint16, w, r []byte) error {
 
 	return nil
 }
+
+// The address can only be changed while the controller is disabled, which
+// ends the transfer, and every start or stop is sent along with a byte.
+const i2cTransactionLimits = i2cNoAddressChange | i2cNoEmptyWrite
+
+// Bits of DATA_CMD next to the data byte.
+const (
+	i2cCmdRead    = 1 << 8
+	i2cCmdStop    = 1 << 9
+	i2cCmdRestart = 1 << 10
+)
+
+// transferMsg sends a message of a Transaction, see i2c_transaction.go. The
+// controller sends the start or repeated start and address by itself before
+// the first byte, and the commands are queued without waiting in between, so
+// that the bus isn't released before the last byte.
+func (i2c *I2C) transferMsg(addr uint8, read bool, buf []byte, before, after i2cJoin) error {
+	if before == i2cStop {
+		i2c.Bus.ENABLE.Set(0)
+		i2c.Bus.TAR.Set(uint32(addr))
+		i2c.Bus.ENABLE.Set(1)
+		i2c.Bus.CLR_TX_ABRT.Get()
+	}
+	di := 0
+	for ci := range buf {
+		cmd := uint32(buf[ci])
+		if read {
+			cmd = i2cCmdRead
+		}
+		if ci == 0 && before == i2cRestart {
+			cmd |= i2cCmdRestart
+		}
+		if ci == len(buf)-1 && after == i2cStop {
+			cmd |= i2cCmdStop
+		}
+		for i2c.Bus.TXFLR.Get() >= 8 {
+			if read && i2c.Bus.RXFLR.Get() != 0 {
+				buf[di] = byte(i2c.Bus.DATA_CMD.Get())
+				di++
+			}
+		}
+		i2c.Bus.DATA_CMD.Set(cmd)
+		if i2c.Bus.TX_ABRT_SOURCE.Get() != 0 {
+			return errI2CTxAbort
+		}
+	}
+	for read && di < len(buf) {
+		if i2c.Bus.RXFLR.Get() != 0 {
+			buf[di] = byte(i2c.Bus.DATA_CMD.Get())
+			di++
+		}
+		if i2c.Bus.TX_ABRT_SOURCE.Get() != 0 {
+			return errI2CTxAbort
+		}
+	}
+	if after == i2cStop {
+		for i2c.Bus.STATUS.HasBits(kendryte.I2C_STATUS_ACTIVITY) || !i2c.Bus.STATUS.HasBits(kendryte.I2C_STATUS_TFE) {
+		}
+		if i2c.Bus.TX_ABRT_SOURCE.Get() != 0 {
+			return errI2CTxAbort
+		}
+	}
+	return nil
+}
@@ -20,6 +20,15 @@ type SPIConfig struct {
 	CS        Pin
 	LSBFirst  bool
 	Mode      uint8
+
+	// Delays for slow devices, in nanoseconds, rounded up to cycles of the
+	// 132MHz LPSPI clock (at most about 1.9µs): from the assertion of CS to
+	// the first clock edge, from the last clock edge to the release of CS,
+	// and between two words. Zero keeps the default delays of half an SCK
+	// period.
+	CSSetup   uint32
+	CSHold    uint32
+	WordDelay uint32
 }
 
 func (c SPIConfig) getPins() (di, do, ck, cs Pin) {
@@ -105,8 +114,9 @@ func (spi *SPI) Configure(config SPIConfig) {
 	// configure LPSPI clock divisor and CS assertion delays
 	div := spi.getClockDivisor(config.Frequency)
 	ccr := (div << nxp.LPSPI_CCR_SCKDIV_Pos) & nxp.LPSPI_CCR_SCKDIV_Msk
-	ccr |= ((div / 2) << nxp.LPSPI_CCR_DBT_Pos) & nxp.LPSPI_CCR_DBT_Msk
-	ccr |= ((div / 2) << nxp.LPSPI_CCR_PCSSCK_Pos) & nxp.LPSPI_CCR_PCSSCK_Msk
+	ccr |= (spiDelay(config.WordDelay, div/2, 2) << nxp.LPSPI_CCR_DBT_Pos) & nxp.LPSPI_CCR_DBT_Msk
+	ccr |= (spiDelay(config.CSSetup, div/2, 1) << nxp.LPSPI_CCR_PCSSCK_Pos) & nxp.LPSPI_CCR_PCSSCK_Msk
+	ccr |= (spiDelay(config.CSHold, 0, 1) << nxp.LPSPI_CCR_SCKPCS_Pos) & nxp.LPSPI_CCR_SCKPCS_Msk
 	spi.Bus.CCR.Set(ccr)
 
 	// 8-bit frame size (words)
@@ -201,13 +211,16 @@ func (spi *SPI) hasHardwareCSPin() bool {
 	return spi.isHardwareCSPin(spi.cs)
 }
 
+// spiClock is the LPSPI root clock frequency (PLL2).
+const spiClock = 132000000
+
 // getClockDivisor finds the SPI prescalar that minimizes the error between
 // requested frequency and possible frequencies available with the LPSPI clock.
 // this routine is based on Teensyduino (libraries/SPI/SPI.cpp):
 //
 //	void SPIClass::setClockDivider_noInline(uint32_t clk)
 func (spi *SPI) getClockDivisor(freq uint32) uint32 {
-	const clock = 132000000 // LPSPI root clock frequency (PLL2)
+	const clock = spiClock
 	d := uint32(clock)
 	if freq > 0 {
 		d /= freq
@@ -224,6 +237,23 @@ func (spi *SPI) getClockDivisor(freq uint32) uint32 {
 	return 0
 }
 
+// spiDelay returns the value of a delay field of the CCR register, for a
+// delay of ns nanoseconds, or def if ns is zero. The delay is the field value
+// plus offset in cycles of the LPSPI clock.
+func spiDelay(ns, def, offset uint32) uint32 {
+	if ns == 0 {
+		return def
+	}
+	n := spiDelayCycles(ns, spiClock)
+	if n < offset {
+		return 0
+	}
+	if n-offset > 0xff {
+		return 0xff
+	}
+	return n - offset
+}
+
 func (spi *SPI) getFIFOSize() (rx, tx uint32) {
 	param := spi.Bus.PARAM.Get()
 	return uint32(1) << ((param & nxp.LPSPI_PARAM_RXFIFO_Msk) >> nxp.LPSPI_PARAM_RXFIFO_Pos),
@@ -129,7 +129,7 @@ func (uart *UART) Configure(config UARTConfig) {
 	uart.Bus.STAT.Set(uart.Bus.STAT.Get())
 
 	// enable RX interrupt
-	uart.Interrupt.SetPriority(0xC0)
+	uart.Interrupt.SetPriority(interruptPriority(config.Priority, 0xC0))
 	uart.Interrupt.Enable()
 
 	uart.configured = true
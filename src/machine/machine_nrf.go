@@ -132,6 +132,8 @@ func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
 // UART on the NRF.
 type UART struct {
 	Buffer *RingBuffer
+
+	tx, rx Pin
 }
 
 // UART
@@ -141,8 +143,13 @@ var (
 	UART0  = &_UART0
 )
 
-// Configure the UART.
-func (uart *UART) Configure(config UARTConfig) {
+// Configure the UART. With a SoftDevice, a priority at one of its levels is
+// rejected.
+func (uart *UART) Configure(config UARTConfig) error {
+	if err := checkInterruptPriority(config.Priority); err != nil {
+		return err
+	}
+
 	// Default baud rate to 115200.
 	if config.BaudRate == 0 {
 		config.BaudRate = 115200
@@ -153,10 +160,10 @@ func (uart *UART) Configure(config UARTConfig) {
 	// Set TX and RX pins
 	if config.TX == 0 && config.RX == 0 {
 		// Use default pins
-		uart.setPins(UART_TX_PIN, UART_RX_PIN)
-	} else {
-		uart.setPins(config.TX, config.RX)
+		config.TX, config.RX = UART_TX_PIN, UART_RX_PIN
 	}
+	uart.tx, uart.rx = config.TX, config.RX
+	uart.setPins(config.TX, config.RX)
 
 	nrf.UART0.ENABLE.Set(nrf.UART_ENABLE_ENABLE_Enabled)
 	nrf.UART0.TASKS_STARTTX.Set(1)
@@ -165,8 +172,9 @@ func (uart *UART) Configure(config UARTConfig) {
 
 	// Enable RX IRQ.
 	intr := interrupt.New(nrf.IRQ_UART0, _UART0.handleInterrupt)
-	intr.SetPriority(0xc0) // low priority
+	intr.SetPriority(interruptPriority(config.Priority, 0xc0)) // low priority by default
 	intr.Enable()
+	return nil
 }
 
 // SetBaudRate sets the communication speed for the UART.
@@ -184,6 +192,44 @@ func (uart *UART) SetBaudRate(br uint32) {
 	nrf.UART0.BAUDRATE.Set(rate)
 }
 
+// EnableFlowControl enables RTS/CTS hardware flow control on the given pins.
+// The UART stops transmitting while CTS is high and raises RTS when its
+// receive buffer is about to overflow.
+func (uart *UART) EnableFlowControl(rts, cts Pin) {
+	uart.setFlowControlPins(rts, cts)
+	nrf.UART0.CONFIG.SetBits(nrf.UART_CONFIG_HWFC_Msk)
+}
+
+// DisableFlowControl disables hardware flow control and disconnects the RTS
+// and CTS pins.
+func (uart *UART) DisableFlowControl() {
+	nrf.UART0.CONFIG.ClearBits(nrf.UART_CONFIG_HWFC_Msk)
+	uart.setFlowControlPins(NoPin, NoPin)
+}
+
+// SetBreak holds the TX pin low, which the other side sees as a break
+// condition, until it is called again with on set to false.
+func (uart *UART) SetBreak(on bool) {
+	if on {
+		nrf.UART0.TASKS_STOPTX.Set(1)
+		uart.tx.Configure(PinConfig{Mode: PinOutput})
+		uart.tx.Low()
+		uart.setPins(NoPin, uart.rx)
+	} else {
+		uart.setPins(uart.tx, uart.rx)
+		nrf.UART0.TASKS_STARTTX.Set(1)
+	}
+}
+
+// pinSelect returns the PSEL register value for the given pin, NoPin
+// disconnects the peripheral signal.
+func pinSelect(p Pin) uint32 {
+	if p == NoPin {
+		return 0xffffffff
+	}
+	return uint32(p)
+}
+
 // WriteByte writes a byte of data to the UART.
 func (uart *UART) WriteByte(c byte) error {
 	nrf.UART0.EVENTS_TXDRDY.Set(0)
@@ -218,8 +264,12 @@ type I2CConfig struct {
 	SDA       Pin
 }
 
-// Configure is intended to setup the I2C interface.
+// Configure is intended to setup the I2C interface. It returns an error when
+// the SPI bus with the same number is in use, as they share their hardware.
 func (i2c *I2C) Configure(config I2CConfig) error {
+	if !claimSharedPeripheral(unsafe.Pointer(i2c), sharedPeripheralI2C) {
+		return errI2CSharedWithSPI
+	}
 
 	i2c.Bus.ENABLE.Set(nrf.TWI_ENABLE_ENABLE_Disabled)
 
@@ -233,32 +283,37 @@ func (i2c *I2C) Configure(config I2CConfig) error {
 		config.SCL = SCL_PIN
 	}
 
-	// do config
-	sclPort, sclPin := config.SCL.getPortPin()
+	i2c.configurePins(config.SCL, config.SDA)
+
+	if config.Frequency >= 400*KHz {
+		i2c.Bus.FREQUENCY.Set(nrf.TWI_FREQUENCY_FREQUENCY_K400)
+	} else {
+		i2c.Bus.FREQUENCY.Set(nrf.TWI_FREQUENCY_FREQUENCY_K100)
+	}
+
+	i2c.Bus.ENABLE.Set(nrf.TWI_ENABLE_ENABLE_Enabled)
+
+	return nil
+}
+
+// configurePins configures the pins as open drain inputs with pull-ups, and
+// selects them for the peripheral.
+func (i2c *I2C) configurePins(scl, sda Pin) {
+	sclPort, sclPin := scl.getPortPin()
 	sclPort.PIN_CNF[sclPin].Set((nrf.GPIO_PIN_CNF_DIR_Input << nrf.GPIO_PIN_CNF_DIR_Pos) |
 		(nrf.GPIO_PIN_CNF_INPUT_Connect << nrf.GPIO_PIN_CNF_INPUT_Pos) |
 		(nrf.GPIO_PIN_CNF_PULL_Pullup << nrf.GPIO_PIN_CNF_PULL_Pos) |
 		(nrf.GPIO_PIN_CNF_DRIVE_S0D1 << nrf.GPIO_PIN_CNF_DRIVE_Pos) |
 		(nrf.GPIO_PIN_CNF_SENSE_Disabled << nrf.GPIO_PIN_CNF_SENSE_Pos))
 
-	sdaPort, sdaPin := config.SDA.getPortPin()
+	sdaPort, sdaPin := sda.getPortPin()
 	sdaPort.PIN_CNF[sdaPin].Set((nrf.GPIO_PIN_CNF_DIR_Input << nrf.GPIO_PIN_CNF_DIR_Pos) |
 		(nrf.GPIO_PIN_CNF_INPUT_Connect << nrf.GPIO_PIN_CNF_INPUT_Pos) |
 		(nrf.GPIO_PIN_CNF_PULL_Pullup << nrf.GPIO_PIN_CNF_PULL_Pos) |
 		(nrf.GPIO_PIN_CNF_DRIVE_S0D1 << nrf.GPIO_PIN_CNF_DRIVE_Pos) |
 		(nrf.GPIO_PIN_CNF_SENSE_Disabled << nrf.GPIO_PIN_CNF_SENSE_Pos))
 
-	if config.Frequency >= 400*KHz {
-		i2c.Bus.FREQUENCY.Set(nrf.TWI_FREQUENCY_FREQUENCY_K400)
-	} else {
-		i2c.Bus.FREQUENCY.Set(nrf.TWI_FREQUENCY_FREQUENCY_K100)
-	}
-
-	i2c.setPins(config.SCL, config.SDA)
-
-	i2c.Bus.ENABLE.Set(nrf.TWI_ENABLE_ENABLE_Enabled)
-
-	return nil
+	i2c.setPins(scl, sda)
 }
 
 // Tx does a single I2C transaction at the specified address.
@@ -276,8 +331,7 @@ func (i2c *I2C) Tx(addr uint16, w, r []byte) (err error) {
 		i2c.Bus.TASKS_STARTTX.Set(1) // start transmission for writing
 		for _, b := range w {
 			if err = i2c.writeByte(b); err != nil {
-				i2c.signalStop()
-				return
+				return i2c.abort(err)
 			}
 		}
 	}
@@ -296,8 +350,7 @@ func (i2c *I2C) Tx(addr uint16, w, r []byte) (err error) {
 			}
 			if r[i], err = i2c.readByte(); err != nil {
 				i2c.Bus.SHORTS.Set(nrf.TWI_SHORTS_BB_SUSPEND_Disabled)
-				i2c.signalStop()
-				return
+				return i2c.abort(err)
 			}
 		}
 		i2c.Bus.SHORTS.Set(nrf.TWI_SHORTS_BB_SUSPEND_Disabled)
@@ -307,28 +360,89 @@ func (i2c *I2C) Tx(addr uint16, w, r []byte) (err error) {
 	// It may execute after I2C peripheral has already been stopped by the shortcut in the read block,
 	// so stop task will trigger first thing in a subsequent transaction, hanging it.
 	if len(r) == 0 {
-		i2c.signalStop()
+		if err = i2c.signalStop(); err != nil {
+			err = i2c.abort(err)
+		}
 	}
 
 	return
 }
 
+// The TWI acknowledges the last byte of a read unless it is followed by a stop,
+// so a read can't be followed by a repeated start.
+const i2cTransactionLimits = i2cNoReadRestart
+
+// transferMsg sends a message of a Transaction, see i2c_transaction.go. A
+// start task sent after a write without a stop makes a repeated start.
+func (i2c *I2C) transferMsg(addr uint8, read bool, buf []byte, before, after i2cJoin) (err error) {
+	if read {
+		// Reads always end with a stop, see i2cTransactionLimits.
+		return i2c.Tx(uint16(addr), nil, buf)
+	}
+	if before != i2cNoStart {
+		i2c.Bus.ADDRESS.Set(uint32(addr))
+		i2c.Bus.TASKS_STARTTX.Set(1)
+	}
+	for _, b := range buf {
+		if err = i2c.writeByte(b); err != nil {
+			return i2c.abort(err)
+		}
+	}
+	if after == i2cStop {
+		if err = i2c.signalStop(); err != nil {
+			err = i2c.abort(err)
+		}
+	}
+	return
+}
+
+// abort ends a transaction that failed with err and returns the error to
+// report. After a timeout, where a device holds the bus, the bus is recovered
+// and the peripheral set up again; if the device doesn't let go of the bus,
+// the error of the recovery is returned instead.
+func (i2c *I2C) abort(err error) error {
+	stopErr := errI2CBusTimeout
+	if err != errI2CBusTimeout {
+		stopErr = i2c.signalStop()
+	}
+	if stopErr == errI2CBusTimeout {
+		scl, sda := i2c.getPins()
+		i2c.Bus.ENABLE.Set(nrf.TWI_ENABLE_ENABLE_Disabled)
+		rerr := i2c.recover(scl, sda)
+		i2c.configurePins(scl, sda)
+		i2c.Bus.ENABLE.Set(nrf.TWI_ENABLE_ENABLE_Enabled)
+		if rerr != nil {
+			return rerr
+		}
+	}
+	return err
+}
+
 // signalStop sends a stop signal to the I2C peripheral and waits for confirmation.
-func (i2c *I2C) signalStop() {
+func (i2c *I2C) signalStop() error {
 	i2c.Bus.TASKS_STOP.Set(1)
+	deadline := nanotime() + i2cStretchTimeout*1000
 	for i2c.Bus.EVENTS_STOPPED.Get() == 0 {
+		if nanotime() > deadline {
+			return errI2CBusTimeout
+		}
 	}
 	i2c.Bus.EVENTS_STOPPED.Set(0)
+	return nil
 }
 
 // writeByte writes a single byte to the I2C bus and waits for confirmation.
 func (i2c *I2C) writeByte(data byte) error {
 	i2c.Bus.TXD.Set(uint32(data))
+	deadline := nanotime() + i2cStretchTimeout*1000
 	for i2c.Bus.EVENTS_TXDSENT.Get() == 0 {
 		if e := i2c.Bus.EVENTS_ERROR.Get(); e != 0 {
 			i2c.Bus.EVENTS_ERROR.Set(0)
 			return errI2CBusError
 		}
+		if nanotime() > deadline {
+			return errI2CBusTimeout
+		}
 	}
 	i2c.Bus.EVENTS_TXDSENT.Set(0)
 	return nil
@@ -336,11 +450,15 @@ func (i2c *I2C) writeByte(data byte) error {
 
 // readByte reads a single byte from the I2C bus when it is ready.
 func (i2c *I2C) readByte() (byte, error) {
+	deadline := nanotime() + i2cStretchTimeout*1000
 	for i2c.Bus.EVENTS_RXDREADY.Get() == 0 {
 		if e := i2c.Bus.EVENTS_ERROR.Get(); e != 0 {
 			i2c.Bus.EVENTS_ERROR.Set(0)
 			return 0, errI2CBusError
 		}
+		if nanotime() > deadline {
+			return 0, errI2CBusTimeout
+		}
 	}
 	i2c.Bus.EVENTS_RXDREADY.Set(0)
 	return byte(i2c.Bus.RXD.Get()), nil
@@ -383,3 +501,9 @@ func ReadTemperature() int32 {
 	nrf.TEMP.EVENTS_DATARDY.Set(0)
 	return temp
 }
+
+// DeviceID returns the 64-bit identifier of the chip that the factory stores
+// in the DEVICEID registers of the FICR. It is unique to the chip.
+func DeviceID() []byte {
+	return appendWordsBE(make([]byte, 0, 8), nrf.FICR.DEVICEID[1].Get(), nrf.FICR.DEVICEID[0].Get())
+}
@@ -5,6 +5,7 @@ package machine
 
 import (
 	"device/nrf"
+	"unsafe"
 )
 
 // Get peripheral and pin number for this GPIO pin.
@@ -13,8 +14,13 @@ func (p Pin) getPortPin() (*nrf.GPIO_Type, uint32) {
 }
 
 func (uart *UART) setPins(tx, rx Pin) {
-	nrf.UART0.PSELTXD.Set(uint32(tx))
-	nrf.UART0.PSELRXD.Set(uint32(rx))
+	nrf.UART0.PSELTXD.Set(pinSelect(tx))
+	nrf.UART0.PSELRXD.Set(pinSelect(rx))
+}
+
+func (uart *UART) setFlowControlPins(rts, cts Pin) {
+	nrf.UART0.PSELRTS.Set(pinSelect(rts))
+	nrf.UART0.PSELCTS.Set(pinSelect(cts))
 }
 
 func (i2c *I2C) setPins(scl, sda Pin) {
@@ -22,6 +28,11 @@ func (i2c *I2C) setPins(scl, sda Pin) {
 	i2c.Bus.PSELSDA.Set(uint32(sda))
 }
 
+// getPins returns the pins that setPins selected.
+func (i2c *I2C) getPins() (scl, sda Pin) {
+	return Pin(i2c.Bus.PSELSCL.Get()), Pin(i2c.Bus.PSELSDA.Get())
+}
+
 // SPI on the NRF.
 type SPI struct {
 	Bus *nrf.SPI_Type
@@ -43,8 +54,13 @@ type SPIConfig struct {
 	Mode      uint8
 }
 
-// Configure is intended to setup the SPI interface.
-func (spi SPI) Configure(config SPIConfig) {
+// Configure is intended to setup the SPI interface. It returns an error when
+// the I2C bus with the same number is in use, as they share their hardware.
+func (spi SPI) Configure(config SPIConfig) error {
+	if !claimSharedPeripheral(unsafe.Pointer(spi.Bus), sharedPeripheralSPI) {
+		return errSPISharedWithI2C
+	}
+
 	// Disable bus to configure it
 	spi.Bus.ENABLE.Set(nrf.SPI_ENABLE_ENABLE_Disabled)
 
@@ -112,6 +128,8 @@ func (spi SPI) Configure(config SPIConfig) {
 
 	// Re-enable bus now that it is configured.
 	spi.Bus.ENABLE.Set(nrf.SPI_ENABLE_ENABLE_Enabled)
+
+	return nil
 }
 
 // Transfer writes/reads a single byte using the SPI interface.
@@ -49,8 +49,13 @@ func (p Pin) getPortPin() (*nrf.GPIO_Type, uint32) {
 }
 
 func (uart *UART) setPins(tx, rx Pin) {
-	nrf.UART0.PSELTXD.Set(uint32(tx))
-	nrf.UART0.PSELRXD.Set(uint32(rx))
+	nrf.UART0.PSELTXD.Set(pinSelect(tx))
+	nrf.UART0.PSELRXD.Set(pinSelect(rx))
+}
+
+func (uart *UART) setFlowControlPins(rts, cts Pin) {
+	nrf.UART0.PSELRTS.Set(pinSelect(rts))
+	nrf.UART0.PSELCTS.Set(pinSelect(cts))
 }
 
 func (i2c *I2C) setPins(scl, sda Pin) {
@@ -58,6 +63,11 @@ func (i2c *I2C) setPins(scl, sda Pin) {
 	i2c.Bus.PSELSDA.Set(uint32(sda))
 }
 
+// getPins returns the pins that setPins selected.
+func (i2c *I2C) getPins() (scl, sda Pin) {
+	return Pin(i2c.Bus.PSELSCL.Get()), Pin(i2c.Bus.PSELSDA.Get())
+}
+
 // PWM
 var (
 	PWM0 = &PWM{PWM: nrf.PWM0}
@@ -17,8 +17,13 @@ func (p Pin) getPortPin() (*nrf.GPIO_Type, uint32) {
 }
 
 func (uart *UART) setPins(tx, rx Pin) {
-	nrf.UART0.PSEL.TXD.Set(uint32(tx))
-	nrf.UART0.PSEL.RXD.Set(uint32(rx))
+	nrf.UART0.PSEL.TXD.Set(pinSelect(tx))
+	nrf.UART0.PSEL.RXD.Set(pinSelect(rx))
+}
+
+func (uart *UART) setFlowControlPins(rts, cts Pin) {
+	nrf.UART0.PSEL.RTS.Set(pinSelect(rts))
+	nrf.UART0.PSEL.CTS.Set(pinSelect(cts))
 }
 
 func (i2c *I2C) setPins(scl, sda Pin) {
@@ -26,6 +31,11 @@ func (i2c *I2C) setPins(scl, sda Pin) {
 	i2c.Bus.PSEL.SDA.Set(uint32(sda))
 }
 
+// getPins returns the pins that setPins selected.
+func (i2c *I2C) getPins() (scl, sda Pin) {
+	return Pin(i2c.Bus.PSEL.SCL.Get()), Pin(i2c.Bus.PSEL.SDA.Get())
+}
+
 // PWM
 var (
 	PWM0 = &PWM{PWM: nrf.PWM0}
@@ -40,9 +40,20 @@ func exitCriticalSection() {
 	easyDMABusy.ClearBits(1)
 }
 
-// Configure the USB peripheral. The config is here for compatibility with the UART interface.
+// Configure the USB peripheral. The config is here for compatibility with the
+// UART interface, only its Priority is used. The runtime configures the USB
+// peripheral at startup, after which Configure only sets a nonzero Priority.
+// With a SoftDevice, a priority at one of its levels panics, as Configure has
+// no error result.
 func (dev *USBDevice) Configure(config UARTConfig) {
+	if err := checkInterruptPriority(config.Priority); err != nil {
+		panic(err.Error())
+	}
+	intr := interrupt.New(nrf.IRQ_USBD, handleUSBIRQ)
 	if dev.initcomplete {
+		if config.Priority != 0 {
+			intr.SetPriority(config.Priority)
+		}
 		return
 	}
 
@@ -55,8 +66,7 @@ func (dev *USBDevice) Configure(config UARTConfig) {
 	// that it is possible to print to the console from a BLE interrupt. You
 	// shouldn't generally do that but it is useful for debugging and panic
 	// logging.
-	intr := interrupt.New(nrf.IRQ_USBD, handleUSBIRQ)
-	intr.SetPriority(0x40) // interrupt priority 2 (lower number means more important)
+	intr.SetPriority(interruptPriority(config.Priority, 0x40)) // interrupt priority 2 by default (lower number means more important)
 	intr.Enable()
 
 	// enable interrupt for end of reset and start of frame
@@ -86,6 +96,24 @@ func (dev *USBDevice) Configure(config UARTConfig) {
 	(*volatile.Register32)(unsafe.Pointer(uintptr(0x4006EC00))).Set(0x00009375)
 
 	dev.initcomplete = true
+	// The pull-up is enabled by the interrupt that follows the USBD ready
+	// event.
+	dev.attached = dev.attachOnConfigure()
+}
+
+// Attach connects the device to the bus, so that the host enumerates it.
+func (dev *USBDevice) Attach() {
+	dev.attached = true
+	if dev.initcomplete {
+		nrf.USBD.USBPULLUP.Set(1)
+	}
+}
+
+// Detach disconnects the device from the bus, as if the cable was unplugged.
+func (dev *USBDevice) Detach() {
+	dev.attached = false
+	nrf.USBD.USBPULLUP.Set(0)
+	usbDetached()
 }
 
 func handleUSBIRQ(interrupt.Interrupt) {
@@ -102,9 +130,20 @@ func handleUSBIRQ(interrupt.Interrupt) {
 
 			// Configure control endpoint
 			initEndpoint(0, usb.ENDPOINT_TYPE_CONTROL)
-			nrf.USBD.USBPULLUP.Set(1)
+			if USBDev.attached {
+				nrf.USBD.USBPULLUP.Set(1)
+			}
 
 			usbConfiguration = 0
+			USBStateChange.Signal()
+		}
+		if (nrf.USBD.EVENTCAUSE.Get() & nrf.USBD_EVENTCAUSE_SUSPEND) > 0 {
+			nrf.USBD.EVENTCAUSE.Set(nrf.USBD_EVENTCAUSE_SUSPEND)
+			usbSetSuspended(true)
+		}
+		if (nrf.USBD.EVENTCAUSE.Get() & nrf.USBD_EVENTCAUSE_RESUME) > 0 {
+			nrf.USBD.EVENTCAUSE.Set(nrf.USBD_EVENTCAUSE_RESUME)
+			usbSetSuspended(false)
 		}
 		nrf.USBD.EVENTCAUSE.Set(0)
 	}
@@ -148,16 +187,7 @@ func handleUSBIRQ(interrupt.Interrupt) {
 		// parse setup
 		setup := parseUSBSetupRegisters()
 
-		ok := false
-		if (setup.BmRequestType & usb.REQUEST_TYPE) == usb.REQUEST_STANDARD {
-			// Standard Requests
-			ok = handleStandardSetup(setup)
-		} else {
-			// Class Interface Requests
-			if setup.WIndex < uint16(len(usbSetupHandler)) && usbSetupHandler[setup.WIndex] != nil {
-				ok = usbSetupHandler[setup.WIndex](setup)
-			}
-		}
+		ok := handleSetup(setup)
 
 		if !ok {
 			// Stall endpoint
@@ -197,7 +227,7 @@ func handleUSBIRQ(interrupt.Interrupt) {
 			if usbRxHandler[i] != nil {
 				usbRxHandler[i](buf)
 			}
-			handleEndpointRxComplete(uint32(i))
+			usbRxComplete(uint32(i))
 			exitCriticalSection()
 		}
 	}
@@ -295,6 +325,23 @@ func handleEndpointRxComplete(ep uint32) {
 	nrf.USBD.SIZE.EPOUT[ep].Set(0)
 }
 
+// setEndpointStall makes the endpoint answer the host with STALL, or resumes
+// it. Resuming it also resets its data toggle to DATA0.
+func setEndpointStall(ep uint32, in, stall bool) {
+	val := ep << nrf.USBD_EPSTALL_EP_Pos
+	if in {
+		val |= nrf.USBD_EPSTALL_IO_In << nrf.USBD_EPSTALL_IO_Pos
+	}
+	if stall {
+		nrf.USBD.EPSTALL.Set(val | nrf.USBD_EPSTALL_STALL_Stall<<nrf.USBD_EPSTALL_STALL_Pos)
+		return
+	}
+	nrf.USBD.EPSTALL.Set(val)
+	// The endpoint and direction are selected first, then the toggle is set.
+	nrf.USBD.DTOGGLE.Set(val)
+	nrf.USBD.DTOGGLE.Set(val | nrf.USBD_DTOGGLE_VALUE_Data0<<nrf.USBD_DTOGGLE_VALUE_Pos)
+}
+
 func SendZlp() {
 	nrf.USBD.TASKS_EP0STATUS.Set(1)
 }
@@ -328,7 +375,7 @@ func ReceiveUSBControlPacket() ([cdcLineInfoSize]byte, error) {
 	nrf.USBD.TASKS_EP0RCVOUT.Set(1)
 
 	nrf.USBD.EPOUT[0].PTR.Set(uint32(uintptr(unsafe.Pointer(&udd_ep_out_cache_buffer[0]))))
-	nrf.USBD.EPOUT[0].MAXCNT.Set(64)
+	nrf.USBD.EPOUT[0].MAXCNT.Set(usb.EndpointPacketSize)
 
 	timeout := 300000
 	count := 0
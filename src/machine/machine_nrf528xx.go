@@ -136,8 +136,13 @@ type SPIConfig struct {
 	Mode      uint8
 }
 
-// Configure is intended to setup the SPI interface.
-func (spi SPI) Configure(config SPIConfig) {
+// Configure is intended to setup the SPI interface. It returns an error when
+// the I2C bus with the same number is in use, as they share their hardware.
+func (spi SPI) Configure(config SPIConfig) error {
+	if !claimSharedPeripheral(unsafe.Pointer(spi.Bus), sharedPeripheralSPI) {
+		return errSPISharedWithI2C
+	}
+
 	// Disable bus to configure it
 	spi.Bus.ENABLE.Set(nrf.SPIM_ENABLE_ENABLE_Disabled)
 
@@ -205,6 +210,8 @@ func (spi SPI) Configure(config SPIConfig) {
 
 	// Re-enable bus now that it is configured.
 	spi.Bus.ENABLE.Set(nrf.SPIM_ENABLE_ENABLE_Enabled)
+
+	return nil
 }
 
 // Transfer writes/reads a single byte using the SPI interface.
@@ -215,6 +222,15 @@ func (spi SPI) Transfer(w byte) (byte, error) {
 	return buf[0], err
 }
 
+// spiBounceBuffer holds the chunks of data in flash that SPI transfers send.
+var spiBounceBuffer [64]byte
+
+// easyDMAAccessible returns whether EasyDMA can access the memory at p: data
+// RAM starts at 0x20000000, everything below is flash or code RAM.
+func easyDMAAccessible(p *byte) bool {
+	return uintptr(unsafe.Pointer(p)) >= 0x20000000
+}
+
 // Tx handles read/write operation for SPI interface. Since SPI is a syncronous
 // write/read interface, there must always be the same number of bytes written
 // as bytes read. Therefore, if the number of bytes don't match it will be
@@ -228,20 +244,31 @@ func (spi SPI) Tx(w, r []byte) error {
 	// supported.
 	for len(r) != 0 || len(w) != 0 {
 		// Prepare the SPI transfer: set the DMA pointers and lengths.
+		chunk := uint32(255)
+		txPtr := unsafe.Pointer(nil)
+		if len(w) != 0 {
+			txPtr = unsafe.Pointer(&w[0])
+			if !easyDMAAccessible(&w[0]) {
+				// EasyDMA can only read RAM: send data in flash, such as
+				// string constants, through a bounce buffer.
+				chunk = uint32(copy(spiBounceBuffer[:], w))
+				txPtr = unsafe.Pointer(&spiBounceBuffer[0])
+			}
+		}
 		if len(r) != 0 {
 			spi.Bus.RXD.PTR.Set(uint32(uintptr(unsafe.Pointer(&r[0]))))
 			n := uint32(len(r))
-			if n > 255 {
-				n = 255
+			if n > chunk {
+				n = chunk
 			}
 			spi.Bus.RXD.MAXCNT.Set(n)
 			r = r[n:]
 		}
 		if len(w) != 0 {
-			spi.Bus.TXD.PTR.Set(uint32(uintptr(unsafe.Pointer(&w[0]))))
+			spi.Bus.TXD.PTR.Set(uint32(uintptr(txPtr)))
 			n := uint32(len(w))
-			if n > 255 {
-				n = 255
+			if n > chunk {
+				n = chunk
 			}
 			spi.Bus.TXD.MAXCNT.Set(n)
 			w = w[n:]
@@ -0,0 +1,10 @@
+//go:build nrf && !softdevice
+// +build nrf,!softdevice
+
+package machine
+
+// checkInterruptPriority accepts any priority: without a SoftDevice, all
+// levels are free.
+func checkInterruptPriority(priority uint8) error {
+	return nil
+}
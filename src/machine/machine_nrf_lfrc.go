@@ -0,0 +1,142 @@
+//go:build nrf
+// +build nrf
+
+package machine
+
+import (
+	"device/nrf"
+	"runtime/interrupt"
+	"runtime/volatile"
+)
+
+// Boards without a 32.768kHz crystal (HasLowFrequencyCrystal is false) run
+// the RTC, and with it all timers, from the internal LFRC oscillator. Its
+// frequency drifts with temperature, so it must be calibrated against the
+// HFXO crystal regularly to stay within spec. The calibration timer of the
+// CLOCK peripheral wakes up periodically and a calibration is done whenever
+// the die temperature changed since the last one, and at least every
+// lfrcMaxSkip intervals.
+//
+// Don't use this together with a SoftDevice: it takes over the CLOCK
+// peripheral and calibrates the LFRC itself.
+
+// Accuracy of the LFRC oscillator in ppm from the datasheet, calibrated or
+// not, and the accuracy assumed for a crystal.
+const (
+	lfrcUncalibratedPPM = 20000
+	lfrcCalibratedPPM   = 500
+	lfxoPPM             = 50
+)
+
+// lfrcTempThreshold is the temperature change in milli-degrees Celsius that
+// triggers a calibration.
+const lfrcTempThreshold = 500
+
+// lfrcMaxSkip is the number of calibration intervals after which a
+// calibration is done even if the temperature is unchanged.
+const lfrcMaxSkip = 8
+
+var lfrc struct {
+	running     bool
+	calibrated  volatile.Register8
+	hfxoStarted bool
+	lastTemp    int32
+	skipped     uint8
+}
+
+// StartLFRCCalibration starts the periodic calibration of the LFRC oscillator,
+// checking every interval quarter seconds (1 to 127) whether a calibration is
+// needed. It does nothing on boards with a low frequency crystal.
+func StartLFRCCalibration(interval uint8) {
+	if HasLowFrequencyCrystal || lfrc.running {
+		return
+	}
+	if interval == 0 {
+		interval = 1
+	} else if interval > 127 {
+		interval = 127
+	}
+	lfrc.running = true
+	lfrc.lastTemp = ReadTemperature()
+	nrf.CLOCK.CTIV.Set(uint32(interval))
+	nrf.CLOCK.EVENTS_DONE.Set(0)
+	nrf.CLOCK.EVENTS_CTTO.Set(0)
+	nrf.CLOCK.EVENTS_HFCLKSTARTED.Set(0)
+	nrf.CLOCK.INTENSET.Set(nrf.CLOCK_INTENSET_DONE_Msk | nrf.CLOCK_INTENSET_CTTO_Msk | nrf.CLOCK_INTENSET_HFCLKSTARTED_Msk)
+	intr := interrupt.New(nrf.IRQ_POWER_CLOCK, handleClockInterrupt)
+	intr.SetPriority(0xc0) // low priority
+	intr.Enable()
+
+	// Calibrate right away, the first interval may be long.
+	lfrcStartCalibration()
+}
+
+// StopLFRCCalibration stops the periodic calibration of the LFRC oscillator.
+func StopLFRCCalibration() {
+	if !lfrc.running {
+		return
+	}
+	nrf.CLOCK.INTENCLR.Set(nrf.CLOCK_INTENSET_DONE_Msk | nrf.CLOCK_INTENSET_CTTO_Msk | nrf.CLOCK_INTENSET_HFCLKSTARTED_Msk)
+	nrf.CLOCK.TASKS_CTSTOP.Set(1)
+	if lfrc.hfxoStarted {
+		nrf.CLOCK.TASKS_HFCLKSTOP.Set(1)
+		lfrc.hfxoStarted = false
+	}
+	lfrc.running = false
+}
+
+// LowFrequencyClockError returns the estimated worst case error of the low
+// frequency clock in ppm: the accuracy of the LFRC oscillator depending on
+// whether it is calibrated, or that of a typical crystal.
+func LowFrequencyClockError() uint32 {
+	if HasLowFrequencyCrystal {
+		return lfxoPPM
+	}
+	if lfrc.calibrated.Get() != 0 {
+		return lfrcCalibratedPPM
+	}
+	return lfrcUncalibratedPPM
+}
+
+// lfrcStartCalibration starts the HFXO, which triggers the calibration once it
+// is running.
+func lfrcStartCalibration() {
+	if nrf.CLOCK.HFCLKSTAT.HasBits(nrf.CLOCK_HFCLKSTAT_SRC_Msk) {
+		// The HFXO is already running for someone else.
+		nrf.CLOCK.TASKS_CAL.Set(1)
+		return
+	}
+	lfrc.hfxoStarted = true
+	nrf.CLOCK.TASKS_HFCLKSTART.Set(1)
+}
+
+func handleClockInterrupt(interrupt.Interrupt) {
+	if nrf.CLOCK.EVENTS_HFCLKSTARTED.Get() != 0 {
+		nrf.CLOCK.EVENTS_HFCLKSTARTED.Set(0)
+		if lfrc.hfxoStarted {
+			nrf.CLOCK.TASKS_CAL.Set(1)
+		}
+	}
+	if nrf.CLOCK.EVENTS_DONE.Get() != 0 {
+		nrf.CLOCK.EVENTS_DONE.Set(0)
+		if lfrc.hfxoStarted {
+			nrf.CLOCK.TASKS_HFCLKSTOP.Set(1)
+			lfrc.hfxoStarted = false
+		}
+		lfrc.calibrated.Set(1)
+		lfrc.skipped = 0
+		nrf.CLOCK.TASKS_CTSTART.Set(1)
+	}
+	if nrf.CLOCK.EVENTS_CTTO.Get() != 0 {
+		nrf.CLOCK.EVENTS_CTTO.Set(0)
+		temp := ReadTemperature()
+		diff := temp - lfrc.lastTemp
+		if diff >= lfrcTempThreshold || diff <= -lfrcTempThreshold || lfrc.skipped >= lfrcMaxSkip {
+			lfrc.lastTemp = temp
+			lfrcStartCalibration()
+		} else {
+			lfrc.skipped++
+			nrf.CLOCK.TASKS_CTSTART.Set(1)
+		}
+	}
+}
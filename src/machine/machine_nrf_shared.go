@@ -0,0 +1,50 @@
+//go:build nrf
+// +build nrf
+
+package machine
+
+import (
+	"device/nrf"
+	"errors"
+	"unsafe"
+)
+
+// The SPI and I2C buses with the same number, SPI0 and I2C0 or SPI1 and I2C1,
+// are the same hardware block with a shared ID and registers: configuring the
+// second one silently breaks the first. Configure returns an error instead.
+// The UART doesn't share its hardware.
+var (
+	errI2CSharedWithSPI = errors.New("I2C error: the bus shares its hardware with an SPI bus that is in use")
+	errSPISharedWithI2C = errors.New("SPI error: the bus shares its hardware with an I2C bus that is in use")
+)
+
+// Users of a shared peripheral.
+const (
+	sharedPeripheralFree = iota
+	sharedPeripheralI2C
+	sharedPeripheralSPI
+)
+
+// sharedPeripheralUsers holds the user of the peripherals with ID 3 (SPI0 and
+// I2C0) and ID 4 (SPI1 and I2C1).
+var sharedPeripheralUsers [2]uint8
+
+// claimSharedPeripheral records that user uses the peripheral at base, and
+// reports whether it was free or already used by the same user. Peripherals
+// that aren't shared can always be claimed.
+func claimSharedPeripheral(base unsafe.Pointer, user uint8) bool {
+	var index int
+	switch uintptr(base) {
+	case uintptr(unsafe.Pointer(nrf.TWI0)):
+		index = 0
+	case uintptr(unsafe.Pointer(nrf.TWI1)):
+		index = 1
+	default:
+		return true
+	}
+	if sharedPeripheralUsers[index] != sharedPeripheralFree && sharedPeripheralUsers[index] != user {
+		return false
+	}
+	sharedPeripheralUsers[index] = user
+	return true
+}
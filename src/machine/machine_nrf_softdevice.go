@@ -0,0 +1,23 @@
+//go:build nrf && softdevice
+// +build nrf,softdevice
+
+package machine
+
+import "errors"
+
+var errInterruptPriorityReserved = errors.New("machine: interrupt priority levels 0, 1 and 4 are reserved by the SoftDevice")
+
+// checkInterruptPriority returns an error if a driver is configured with a
+// priority that the SoftDevice reserves for itself: levels 0, 1 and 4 of the 8
+// levels of the top three priority bits, that is the priorities 0x01-0x3f and
+// 0x80-0x9f. An interrupt at one of those levels would delay the radio.
+func checkInterruptPriority(priority uint8) error {
+	if priority == 0 {
+		return nil // the default of the driver
+	}
+	switch priority >> 5 {
+	case 0, 1, 4:
+		return errInterruptPriorityReserved
+	}
+	return nil
+}
@@ -38,8 +38,6 @@ import (
 	"errors"
 	"runtime/interrupt"
 	"runtime/volatile"
-
-	_ "unsafe" // for go:linkname
 )
 
 const (
@@ -60,9 +58,6 @@ var (
 	ErrNotConfigured  = errors.New("device has not been configured")
 )
 
-//go:linkname gosched runtime.Gosched
-func gosched()
-
 // PutcharUART writes a byte to the UART synchronously, without using interrupts
 // or calling the scheduler
 func PutcharUART(u *UART, c byte) {
@@ -184,7 +179,7 @@ func (u *UART) configure(config UARTConfig, canSched bool) {
 
 		// setup interrupts
 		u.C2.Set(uartC2TXInactive)
-		u.Interrupt.SetPriority(uartIRQPriority)
+		u.Interrupt.SetPriority(interruptPriority(config.Priority, uartIRQPriority))
 		u.Interrupt.Enable()
 	}
 }
@@ -78,6 +78,8 @@ func machineInit() {
 
 	// Peripheral clocks should now all be running
 	unresetBlockWait(RESETS_RESET_Msk)
+
+	readFlashUniqueID()
 }
 
 //go:linkname ticks runtime.machineTicks
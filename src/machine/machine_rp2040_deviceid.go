@@ -0,0 +1,117 @@
+//go:build rp2040
+// +build rp2040
+
+package machine
+
+/*
+// https://github.com/raspberrypi/pico-sdk
+// src/rp2_common/hardware_flash/flash.c
+
+#define ROM_FUNC_CONNECT_INTERNAL_FLASH ROM_TABLE_CODE('I', 'F')
+#define ROM_FUNC_FLASH_EXIT_XIP         ROM_TABLE_CODE('E', 'X')
+#define ROM_FUNC_FLASH_FLUSH_CACHE      ROM_TABLE_CODE('F', 'C')
+
+#define ROM_TABLE_CODE(c1, c2) ((c1) | ((c2) << 8))
+
+#define XIP_BASE        0x10000000
+#define IO_QSPI_SS_CTRL (*(volatile uint32_t *)0x4001800c)
+#define SSI_SR          (*(volatile uint32_t *)0x18000028)
+#define SSI_DR0         (*(volatile uint32_t *)0x18000060)
+
+#define IO_QSPI_SS_CTRL_OUTOVER_BITS 0x00000300
+#define IO_QSPI_SS_CTRL_OUTOVER_LOW  0x00000200
+#define IO_QSPI_SS_CTRL_OUTOVER_HIGH 0x00000300
+#define SSI_SR_TFNF_BITS             0x00000002
+#define SSI_SR_RFNE_BITS             0x00000008
+
+#define FLASH_RUID_CMD   0x4b
+#define FLASH_RUID_DUMMY 4
+#define FLASH_RUID_SIZE  8
+
+typedef unsigned char uint8_t;
+typedef unsigned long uint32_t;
+typedef unsigned long uintptr_t;
+
+typedef void (*rom_void_fn)(void);
+
+void *rom_func_lookup(uint32_t code);
+
+// The second stage bootloader, which sets up the fast XIP mode of the flash
+// chip again after the command.
+static uint32_t boot2_copyout[64];
+
+// flash_read_unique_id_ram sends the Read Unique ID command to the flash chip
+// with XIP off, so it runs from RAM, like the ROM functions it calls.
+__attribute__((section(".ramfunc.flash_read_unique_id_ram"), noinline))
+static void flash_read_unique_id_ram(rom_void_fn connect_internal_flash, rom_void_fn flash_exit_xip, rom_void_fn flash_flush_cache, uint8_t *id) {
+	connect_internal_flash();
+	flash_exit_xip();
+
+	IO_QSPI_SS_CTRL = (IO_QSPI_SS_CTRL & ~IO_QSPI_SS_CTRL_OUTOVER_BITS) | IO_QSPI_SS_CTRL_OUTOVER_LOW;
+	const int count = 1 + FLASH_RUID_DUMMY + FLASH_RUID_SIZE;
+	int tx_remaining = count;
+	int rx_remaining = count;
+	while (tx_remaining || rx_remaining) {
+		uint32_t flags = SSI_SR;
+		// Keep the RX FIFO of 16 entries from overflowing.
+		if ((flags & SSI_SR_TFNF_BITS) && tx_remaining && rx_remaining - tx_remaining < 16 - 2) {
+			SSI_DR0 = tx_remaining == count ? FLASH_RUID_CMD : 0;
+			tx_remaining--;
+		}
+		if ((flags & SSI_SR_RFNE_BITS) && rx_remaining) {
+			uint8_t b = (uint8_t)SSI_DR0;
+			if (rx_remaining <= FLASH_RUID_SIZE) {
+				id[FLASH_RUID_SIZE - rx_remaining] = b;
+			}
+			rx_remaining--;
+		}
+	}
+	IO_QSPI_SS_CTRL = (IO_QSPI_SS_CTRL & ~IO_QSPI_SS_CTRL_OUTOVER_BITS) | IO_QSPI_SS_CTRL_OUTOVER_HIGH;
+
+	flash_flush_cache();
+	((rom_void_fn)((uintptr_t)boot2_copyout + 1))();
+}
+
+void flash_read_unique_id(uint8_t *id) {
+	rom_void_fn connect_internal_flash = (rom_void_fn)rom_func_lookup(ROM_FUNC_CONNECT_INTERNAL_FLASH);
+	rom_void_fn flash_exit_xip = (rom_void_fn)rom_func_lookup(ROM_FUNC_FLASH_EXIT_XIP);
+	rom_void_fn flash_flush_cache = (rom_void_fn)rom_func_lookup(ROM_FUNC_FLASH_FLUSH_CACHE);
+	for (int i = 0; i < 64; i++) {
+		boot2_copyout[i] = ((volatile uint32_t *)XIP_BASE)[i];
+	}
+	__asm__ volatile ("" ::: "memory");
+	flash_read_unique_id_ram(connect_internal_flash, flash_exit_xip, flash_flush_cache, id);
+}
+*/
+import "C"
+
+import (
+	"runtime/interrupt"
+	"unsafe"
+)
+
+// flashUniqueID is the unique ID of the flash chip, which machineInit reads
+// at boot, before the second core can run code from the flash.
+var flashUniqueID [8]byte
+
+// readFlashUniqueID reads the 64-bit unique ID of the flash chip into
+// flashUniqueID, with the interrupts disabled as the flash is unavailable
+// while it does.
+func readFlashUniqueID() {
+	mask := interrupt.Disable()
+	C.flash_read_unique_id((*C.uint8_t)(unsafe.Pointer(&flashUniqueID[0])))
+	interrupt.Restore(mask)
+}
+
+// DeviceID returns the 64-bit unique ID of the flash chip of the board, as the
+// RP2040 has no ID of its own. It is unique to the board.
+func DeviceID() []byte {
+	return flashUniqueID[:]
+}
+
+// usbSerialNumber returns the serial number of the USB device, which is the
+// unique ID of the flash chip, so that the host names its ports the same way
+// every time the device is plugged in.
+func usbSerialNumber() []byte {
+	return DeviceID()
+}
@@ -0,0 +1,238 @@
+//go:build rp2040
+// +build rp2040
+
+package machine
+
+import (
+	"device/rp"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// machine_rp2040_dma.go uses idle DMA channels for large memory to memory
+// copies and fills, so that the CPU is free while framebuffers or audio
+// buffers are moved around.
+
+// dmaMinSize is the size below which a transfer is done by the CPU, as setting
+// up the DMA channel would take longer than the transfer itself.
+const dmaMinSize = 64
+
+const (
+	_NUMDMACHANNELS = 12
+
+	dmaCtrlEN          = 1 << 0
+	dmaCtrlDataSizePos = 2
+	dmaCtrlIncrRead    = 1 << 4
+	dmaCtrlIncrWrite   = 1 << 5
+	dmaCtrlChainToPos  = 11
+	dmaCtrlTreqSelPos  = 15
+	dmaCtrlBusy        = 1 << 24
+
+	// dmaTreqPermanent runs the transfer as fast as possible, without
+	// waiting for a peripheral.
+	dmaTreqPermanent = 0x3f
+)
+
+type dmaChannel struct {
+	readAddr   volatile.Register32
+	writeAddr  volatile.Register32
+	transCount volatile.Register32
+	ctrlTrig   volatile.Register32
+	_          [12]volatile.Register32 // alias registers
+}
+
+var dmaChannels = (*[_NUMDMACHANNELS]dmaChannel)(unsafe.Pointer(rp.DMA))
+
+var (
+	// dmaClaimed has a bit set for every channel that is in use.
+	dmaClaimed uint32
+
+	// dmaFill holds the source word of a fill for every channel, as the
+	// channel reads it while the transfer runs.
+	dmaFill [_NUMDMACHANNELS]uint32
+
+	// dmaEvents holds the event to signal when the transfer of a channel is
+	// done, for the channels that have one.
+	dmaEvents [_NUMDMACHANNELS]*Event
+)
+
+// DMATransfer is a memory transfer that runs in the background on a DMA
+// channel. The buffers of the transfer must not be used until it is done.
+type DMATransfer struct {
+	ch       int8
+	dst, src []byte
+}
+
+// Busy returns whether the transfer is still running.
+func (t *DMATransfer) Busy() bool {
+	if t.ch < 0 {
+		return false
+	}
+	return dmaChannels[t.ch].ctrlTrig.HasBits(dmaCtrlBusy)
+}
+
+// Wait waits until the transfer is done, and releases its DMA channel.
+func (t *DMATransfer) Wait() {
+	if t.ch < 0 {
+		return
+	}
+	for t.Busy() {
+		feedWatchdog()
+	}
+	mask := interrupt.Disable()
+	rp.DMA.INTE0.ClearBits(1 << t.ch)
+	dmaEvents[t.ch] = nil
+	interrupt.Restore(mask)
+	dmaSyncForCPU(t.dst)
+	dmaRelease(t.ch)
+	t.ch = -1
+	t.dst, t.src = nil, nil
+}
+
+// Notify signals e when the transfer is done, so that a goroutine can wait for
+// it in a select statement. Wait must still be called afterwards to release the
+// DMA channel.
+func (t *DMATransfer) Notify(e *Event) {
+	if t.ch < 0 {
+		e.Signal()
+		return
+	}
+	interrupt.New(rp.IRQ_DMA_IRQ_0, handleDMAInterrupt).Enable()
+	mask := interrupt.Disable()
+	dmaEvents[t.ch] = e
+	// The raw interrupt of a transfer that is already done fires as soon as
+	// it is enabled.
+	rp.DMA.INTE0.SetBits(1 << t.ch)
+	interrupt.Restore(mask)
+}
+
+func handleDMAInterrupt(interrupt.Interrupt) {
+	status := rp.DMA.INTS0.Get()
+	rp.DMA.INTS0.Set(status)
+	rp.DMA.INTE0.ClearBits(status)
+	for ch := 0; status != 0; ch, status = ch+1, status>>1 {
+		if status&1 != 0 && dmaEvents[ch] != nil {
+			dmaEvents[ch].Signal()
+			dmaEvents[ch] = nil
+		}
+	}
+}
+
+// DMACopy copies src to dst like the copy builtin, using a DMA channel for
+// large buffers that don't overlap. It returns the number of bytes copied.
+func DMACopy(dst, src []byte) int {
+	t, n := StartDMACopy(dst, src)
+	t.Wait()
+	return n
+}
+
+// DMASet sets all bytes of dst to c, using a DMA channel for large buffers.
+func DMASet(dst []byte, c byte) {
+	t := StartDMASet(dst, c)
+	t.Wait()
+}
+
+// StartDMACopy starts copying src to dst in the background and returns the
+// number of bytes that will be copied. Small or overlapping buffers, or all
+// buffers when no DMA channel is free, are copied by the CPU before
+// StartDMACopy returns.
+func StartDMACopy(dst, src []byte) (DMATransfer, int) {
+	n := len(src)
+	if len(dst) < n {
+		n = len(dst)
+	}
+	ch := int8(-1)
+	if n >= dmaMinSize && !dmaOverlap(dst[:n], src[:n]) {
+		ch = dmaClaim()
+	}
+	if ch < 0 {
+		copy(dst, src)
+		return DMATransfer{ch: -1}, n
+	}
+	dst, src = dst[:n], src[:n]
+	size, count := dmaSize(dst, src, n)
+	dmaSyncForDevice(dst, src)
+	dmaStart(ch, unsafe.Pointer(&src[0]), unsafe.Pointer(&dst[0]), count,
+		size<<dmaCtrlDataSizePos|dmaCtrlIncrRead|dmaCtrlIncrWrite)
+	return DMATransfer{ch: ch, dst: dst, src: src}, n
+}
+
+// dmaOverlap returns whether dst and src, which are not empty, share memory.
+// The DMA reads ahead of its writes, so it can't move data within a buffer the
+// way copy does.
+func dmaOverlap(dst, src []byte) bool {
+	d := uintptr(unsafe.Pointer(&dst[0]))
+	s := uintptr(unsafe.Pointer(&src[0]))
+	return d < s+uintptr(len(src)) && s < d+uintptr(len(dst))
+}
+
+// StartDMASet starts setting all bytes of dst to c in the background. Small
+// buffers, or all buffers when no DMA channel is free, are filled by the CPU
+// before StartDMASet returns.
+func StartDMASet(dst []byte, c byte) DMATransfer {
+	ch := int8(-1)
+	if len(dst) >= dmaMinSize {
+		ch = dmaClaim()
+	}
+	if ch < 0 {
+		for i := range dst {
+			dst[i] = c
+		}
+		return DMATransfer{ch: -1}
+	}
+	dmaFill[ch] = uint32(c) * 0x01010101
+	size, count := dmaSize(dst, nil, len(dst))
+	dmaSyncForDevice(dst, nil)
+	dmaStart(ch, unsafe.Pointer(&dmaFill[ch]), unsafe.Pointer(&dst[0]), count,
+		size<<dmaCtrlDataSizePos|dmaCtrlIncrWrite)
+	return DMATransfer{ch: ch, dst: dst}
+}
+
+// dmaSize returns the widest transfer size (0 for bytes, 1 for halfwords or 2
+// for words) that the buffers allow, and the number of transfers of that size.
+func dmaSize(dst, src []byte, n int) (size uint32, count uint32) {
+	align := uintptr(unsafe.Pointer(&dst[0])) | uintptr(n)
+	if src != nil {
+		align |= uintptr(unsafe.Pointer(&src[0]))
+	}
+	switch {
+	case align&3 == 0:
+		return 2, uint32(n / 4)
+	case align&1 == 0:
+		return 1, uint32(n / 2)
+	default:
+		return 0, uint32(n)
+	}
+}
+
+func dmaStart(ch int8, src, dst unsafe.Pointer, count, ctrl uint32) {
+	c := &dmaChannels[ch]
+	c.readAddr.Set(uint32(uintptr(src)))
+	c.writeAddr.Set(uint32(uintptr(dst)))
+	c.transCount.Set(count)
+	// Chaining to itself disables chaining.
+	c.ctrlTrig.Set(ctrl | dmaCtrlEN | uint32(ch)<<dmaCtrlChainToPos | dmaTreqPermanent<<dmaCtrlTreqSelPos)
+}
+
+// dmaClaim returns a free DMA channel, or -1 if all channels are in use.
+func dmaClaim() int8 {
+	mask := interrupt.Disable()
+	defer interrupt.Restore(mask)
+	for ch := int8(0); ch < _NUMDMACHANNELS; ch++ {
+		if dmaClaimed&(1<<ch) == 0 {
+			dmaClaimed |= 1 << ch
+			// Clear the interrupt of the last transfer of the channel, for
+			// Notify.
+			rp.DMA.INTR.Set(1 << ch)
+			return ch
+		}
+	}
+	return -1
+}
+
+func dmaRelease(ch int8) {
+	mask := interrupt.Disable()
+	dmaClaimed &^= 1 << ch
+	interrupt.Restore(mask)
+}
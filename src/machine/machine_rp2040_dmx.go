@@ -0,0 +1,84 @@
+//go:build rp2040
+// +build rp2040
+
+package machine
+
+import (
+	"device/rp"
+	"errors"
+	"unsafe"
+)
+
+// DMX512 sends up to 512 slots of lighting data at 250000 baud, 8N2. Every
+// frame starts with a break of at least 88µs and a mark after break of at
+// least 8µs, followed by the start code and the slots.
+
+const (
+	dmxBaudRate = 250000
+	dmxBreak    = 100 // µs
+	dmxMAB      = 12  // µs
+
+	dmaTreqUART0TX = 20
+	dmaTreqUART1TX = 22
+)
+
+var (
+	errDMXFrameSize = errors.New("dmx: frame must hold a start code and at most 512 slots")
+	errDMXNoDMA     = errors.New("dmx: no free DMA channel")
+)
+
+// ConfigureDMX configures the UART for DMX512 output. Only the TX pin of the
+// config is used.
+func (uart *UART) ConfigureDMX(config UARTConfig) error {
+	config.BaudRate = dmxBaudRate
+	if err := uart.Configure(config); err != nil {
+		return err
+	}
+	uart.SetFormat(8, 2, ParityNone)
+	uart.Bus.UARTDMACR.SetBits(rp.UART0_UARTDMACR_TXDMAE)
+	return nil
+}
+
+// WriteDMX sends a DMX512 frame: the start code (0 for dimmer data) followed
+// by up to 512 slots. It waits for the previous frame to be sent, sends the
+// break and mark after break, and returns while a DMA channel streams the
+// frame to the UART. The frame must not be modified until WaitDMX returns or
+// the next WriteDMX starts.
+func (uart *UART) WriteDMX(frame []byte) error {
+	if len(frame) < 1 || len(frame) > 513 {
+		return errDMXFrameSize
+	}
+	uart.WaitDMX()
+
+	uart.Bus.UARTLCR_H.SetBits(rp.UART0_UARTLCR_H_BRK)
+	sleepMicroseconds(dmxBreak)
+	uart.Bus.UARTLCR_H.ClearBits(rp.UART0_UARTLCR_H_BRK)
+	sleepMicroseconds(dmxMAB)
+
+	ch := dmaClaim()
+	if ch < 0 {
+		return errDMXNoDMA
+	}
+	treq := uint32(dmaTreqUART0TX)
+	if uart.Bus == rp.UART1 {
+		treq = dmaTreqUART1TX
+	}
+	dmaSyncForDevice(nil, frame)
+	c := &dmaChannels[ch]
+	c.readAddr.Set(uint32(uintptr(unsafe.Pointer(&frame[0]))))
+	c.writeAddr.Set(uint32(uintptr(unsafe.Pointer(&uart.Bus.UARTDR))))
+	c.transCount.Set(uint32(len(frame)))
+	c.ctrlTrig.Set(dmaCtrlEN | dmaCtrlIncrRead | uint32(ch)<<dmaCtrlChainToPos | treq<<dmaCtrlTreqSelPos)
+	uart.dmx = DMATransfer{ch: ch, src: frame}
+	return nil
+}
+
+// WaitDMX waits until the last frame has been sent completely.
+func (uart *UART) WaitDMX() {
+	if uart.dmx.src != nil {
+		uart.dmx.Wait()
+	}
+	for uart.Bus.UARTFR.HasBits(rp.UART0_UARTFR_BUSY) {
+		gosched()
+	}
+}
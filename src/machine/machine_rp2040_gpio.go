@@ -148,6 +148,13 @@ func (p Pin) setSlew(sr bool) {
 	p.padCtrl().ReplaceBits(boolToBit(sr)<<rp.PADS_BANK0_GPIO0_SLEWFAST_Pos, rp.PADS_BANK0_GPIO0_SLEWFAST_Msk, 0)
 }
 
+// SetSlewRate sets the speed of the edges of the pin when it is an output. The
+// RP2040 has a slow and a fast slew rate: PinSpeedHigh and PinSpeedVeryHigh
+// select the fast one.
+func (p Pin) SetSlewRate(speed PinSpeed) {
+	p.setSlew(speed >= PinSpeedHigh)
+}
+
 // setSchmitt enables or disables Schmitt trigger.
 func (p Pin) setSchmitt(trigger bool) {
 	p.padCtrl().ReplaceBits(boolToBit(trigger)<<rp.PADS_BANK0_GPIO0_SCHMITT_Pos, rp.PADS_BANK0_GPIO0_SCHMITT_Msk, 0)
@@ -230,6 +237,8 @@ const (
 	PinFalling PinChange = 4 << iota
 	// Edge rising
 	PinRising
+	// Both edges
+	PinToggle = PinFalling | PinRising
 )
 
 // Callbacks to be called for pins configured with SetInterrupt.
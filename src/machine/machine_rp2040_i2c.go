@@ -51,6 +51,7 @@ type I2CConfig struct {
 type I2C struct {
 	Bus           *rp.I2C0_Type
 	restartOnNext bool
+	config        I2CConfig // to recover the bus after a timeout
 }
 
 var (
@@ -58,6 +59,7 @@ var (
 	ErrInvalidTgtAddr     = errors.New("invalid target i2c address not in 0..0x80 or is reserved")
 	ErrI2CGeneric         = errors.New("i2c error")
 	ErrRP2040I2CDisable   = errors.New("i2c rp2040 peripheral timeout in disable")
+	errI2CNotConfigured   = errors.New("i2c rp2040 peripheral is not configured")
 )
 
 // Tx performs a write and then a read transfer placing the result in
@@ -73,10 +75,20 @@ var (
 //	i2c.Tx(addr, w, nil)
 //
 // Performs only a write transfer.
+//
+// When the transfer times out, because a device holds SCL or SDA low, Tx
+// recovers the bus with Recover before it returns the error, or the error of
+// Recover if the bus could not be released.
 func (i2c *I2C) Tx(addr uint16, w, r []byte) error {
 	// timeout in microseconds.
 	const timeout = 40 * 1000 // 40ms is a reasonable time for a real-time system.
-	return i2c.tx(uint8(addr), w, r, timeout)
+	err := i2c.tx(uint8(addr), w, r, timeout)
+	if err == errI2CWriteTimeout || err == errI2CReadTimeout {
+		if rerr := i2c.Recover(i2c.config); rerr != nil {
+			err = rerr
+		}
+	}
+	return err
 }
 
 // Configure initializes i2c peripheral and configures I2C config's pins passed.
@@ -105,11 +117,26 @@ func (i2c *I2C) Configure(config I2CConfig) error {
 	if config.Frequency == 0 {
 		config.Frequency = defaultBaud
 	}
+	i2c.config = config
 	config.SDA.Configure(PinConfig{PinI2C})
 	config.SCL.Configure(PinConfig{PinI2C})
 	return i2c.init(config)
 }
 
+// Wake generates the wake condition of secure elements such as the ATECC608.
+// The controller refuses to address the general call address that the other
+// chips use for this, so SDA is held low as a GPIO instead, after which the
+// pins are given back to the I2C peripheral.
+func (i2c *I2C) Wake() error {
+	if i2c.config.SCL == 0 {
+		return errI2CNotConfigured
+	}
+	err := I2CSendCondition(i2c.config.SCL, i2c.config.SDA, I2CWake, 80)
+	i2c.config.SDA.Configure(PinConfig{PinI2C})
+	i2c.config.SCL.Configure(PinConfig{PinI2C})
+	return err
+}
+
 // SetBaudRate sets the I2C frequency. It has the side effect of also
 // enabling the I2C hardware if disabled beforehand.
 //
@@ -356,6 +383,79 @@ func (i2c *I2C) tx(addr uint8, tx, rx []byte, timeout_us uint64) (err error) {
 	return err
 }
 
+// The address can only be changed while the controller is disabled, which
+// ends the transfer, and every restart or stop is sent along with a byte.
+const i2cTransactionLimits = i2cNoAddressChange | i2cNoEmptyWrite
+
+// transferMsg sends a message of a Transaction, see i2c_transaction.go. The
+// controller holds SCL low while its TX FIFO is empty, so the bus stays
+// claimed between messages until a byte is sent with the STOP bit. Like Tx,
+// it recovers the bus after a timeout.
+func (i2c *I2C) transferMsg(addr uint8, read bool, buf []byte, before, after i2cJoin) (err error) {
+	const timeout = 40 * 1000
+	deadline := ticks() + timeout
+	if before == i2cStop {
+		if addr >= 0x80 || isReservedI2CAddr(addr) {
+			return ErrInvalidTgtAddr
+		}
+		if err := i2c.disable(); err != nil {
+			return err
+		}
+		i2c.Bus.IC_TAR.Set(uint32(addr))
+		i2c.enable()
+	}
+	defer func() {
+		if err == errI2CWriteTimeout || err == errI2CReadTimeout {
+			if rerr := i2c.Recover(i2c.config); rerr != nil {
+				err = rerr
+			}
+		}
+	}()
+	for i := range buf {
+		cmd := boolToBit(i == 0 && before == i2cRestart)<<rp.I2C0_IC_DATA_CMD_RESTART_Pos |
+			boolToBit(i == len(buf)-1 && after == i2cStop)<<rp.I2C0_IC_DATA_CMD_STOP_Pos
+		if read {
+			cmd |= rp.I2C0_IC_DATA_CMD_CMD
+		} else {
+			cmd |= uint32(buf[i])
+		}
+		for i2c.writeAvailable() == 0 {
+			if ticks() > deadline {
+				return errI2CWriteTimeout
+			}
+		}
+		i2c.Bus.IC_DATA_CMD.Set(cmd)
+		for read && i2c.readAvailable() == 0 || !read && !i2c.interrupted(rp.I2C0_IC_RAW_INTR_STAT_TX_EMPTY) {
+			if reason := i2c.getAbortReason(); reason != 0 {
+				// The controller sends a stop after an abort.
+				i2c.clearAbortReason()
+				if reason&rp.I2C0_IC_TX_ABRT_SOURCE_ABRT_7B_ADDR_NOACK != 0 {
+					return ErrI2CGeneric
+				}
+				return makeI2CAbortError(reason)
+			}
+			if ticks() > deadline {
+				if read {
+					return errI2CReadTimeout
+				}
+				return errI2CWriteTimeout
+			}
+		}
+		if read {
+			buf[i] = uint8(i2c.Bus.IC_DATA_CMD.Get())
+		}
+	}
+	if after == i2cStop {
+		for !i2c.interrupted(rp.I2C0_IC_RAW_INTR_STAT_STOP_DET) {
+			if ticks() > deadline {
+				return errI2CWriteTimeout
+			}
+		}
+		i2c.Bus.IC_CLR_STOP_DET.Get()
+	}
+	return nil
+}
+
 // writeAvailable determines non-blocking write space available
 //
 //go:inline
@@ -0,0 +1,91 @@
+//go:build rp2040
+// +build rp2040
+
+package machine
+
+import (
+	"device/rp"
+	"errors"
+	"runtime/volatile"
+)
+
+// Each core of the RP2040 has its own NVIC, so a peripheral interrupt is
+// serviced by the core (or cores) that enabled it. To prevent both cores from
+// servicing the same peripheral, the UART and StepGenerator drivers record
+// which core owns their interrupt: the first core to configure the peripheral
+// takes ownership, and configuring it from the other core fails with
+// ErrInterruptOwned. SetInterruptCore assigns an interrupt up front or hands it
+// over.
+//
+// The other drivers don't take ownership. Pin interrupts have a separate set
+// of registers and callbacks for each core, and the sleep alarm interrupt wakes
+// whichever core sleeps, so both cores can use them. The USB and DMA
+// interrupts are enabled on the core that calls USBDevice.Configure and
+// DMATransfer.Notify.
+
+var (
+	ErrInterruptOwned   = errors.New("machine: interrupt is owned by the other core")
+	ErrInvalidInterrupt = errors.New("machine: invalid interrupt number")
+)
+
+// interruptCore holds the core that services each peripheral interrupt, plus
+// one. Zero means the interrupt is not assigned yet.
+var interruptCore [_NUMIRQ]volatile.Register8
+
+// interruptLock guards interruptCore against concurrent updates from both
+// cores.
+const interruptLock = Spinlock(_PICO_SPINLOCK_ID_IRQ)
+
+// SetInterruptCore assigns the given peripheral interrupt (such as
+// rp.IRQ_UART0_IRQ) to a core. If the calling core is not the new owner, the
+// interrupt is disabled on the calling core; the new owner must then configure
+// the peripheral to enable the interrupt on its side.
+func SetInterruptCore(irq uint32, core int) error {
+	if irq >= _NUMIRQ {
+		return ErrInvalidInterrupt
+	}
+	if core < 0 || core >= NumCores() {
+		return ErrInvalidCore
+	}
+	interruptLock.Lock()
+	interruptCore[irq].Set(uint8(core + 1))
+	interruptLock.Unlock()
+	if core != CurrentCore() {
+		irqSet(irq, false)
+	}
+	return nil
+}
+
+// InterruptCore returns the core that owns the given peripheral interrupt, or
+// -1 if no core owns it.
+func InterruptCore(irq uint32) int {
+	if irq >= _NUMIRQ {
+		return -1
+	}
+	return int(interruptCore[irq].Get()) - 1
+}
+
+// claimInterrupt takes ownership of the given interrupt for the calling core,
+// unless the other core already owns it. Drivers call it before enabling a
+// peripheral interrupt.
+func claimInterrupt(irq uint32) error {
+	self := uint8(CurrentCore() + 1)
+	interruptLock.Lock()
+	defer interruptLock.Unlock()
+	switch interruptCore[irq].Get() {
+	case 0:
+		interruptCore[irq].Set(self)
+	case self:
+	default:
+		return ErrInterruptOwned
+	}
+	return nil
+}
+
+// uartIRQ returns the interrupt number of the given UART.
+func uartIRQ(uart *UART) uint32 {
+	if uart.Bus == rp.UART1 {
+		return rp.IRQ_UART1_IRQ
+	}
+	return rp.IRQ_UART0_IRQ
+}
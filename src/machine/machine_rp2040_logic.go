@@ -0,0 +1,199 @@
+//go:build rp2040
+// +build rp2040
+
+package machine
+
+import (
+	"device/rp"
+	"errors"
+	stdio "io"
+	"unsafe"
+)
+
+// LogicAnalyzer samples all GPIO pins at a fixed rate, up to the system clock
+// frequency, and serves the captures to a host with the SUMP protocol, which
+// sigrok (PulseView) and the OLS client support as "Openbench Logic Sniffer":
+//
+//	la := machine.LogicAnalyzer{Port: machine.Serial, Samples: make([]uint32, 16384)}
+//	la.Serve()
+//
+// The pins are sampled by state machine 3 of PIO1, which runs a single
+// instruction stored at the end of its instruction memory, and a DMA channel
+// copies the samples to RAM. The pins keep working as usual while they are
+// sampled, so the analyzer can watch the pins that the program itself drives.
+//
+// Triggers aren't supported: a capture starts as soon as the host asks for it.
+type LogicAnalyzer struct {
+	Port stdio.ReadWriter
+
+	// Samples is the capture buffer, one word of GPIO states per sample.
+	Samples []uint32
+
+	rate    uint32 // in Hz
+	count   int    // samples to send
+	flags   uint32
+	command [5]byte
+	in      [1]byte
+}
+
+const (
+	logicAnalyzerInstr  = 31     // instruction memory slot
+	logicAnalyzerProbes = 30     // GPIO pins
+	logicSUMPClock      = 100e6  // reference clock of the SUMP divider
+	pioInPins32         = 0x4000 // in pins, 32
+	pioJmp              = 0x0000 // jmp (address in the low 5 bits)
+	dmaTreqPIO1RX3      = 15
+
+	// Flags of the SUMP flags command.
+	logicSUMPGroupsDisabledPos = 2
+)
+
+var (
+	errLogicAnalyzerRate = errors.New("logic analyzer: sample rate out of range")
+	errLogicAnalyzerDMA  = errors.New("logic analyzer: no free DMA channel")
+)
+
+// Capture fills samples with the state of the GPIO pins, sampled rate times
+// per second, and returns when it is done. Bit n of every sample holds the
+// level of GPIOn.
+func (la *LogicAnalyzer) Capture(samples []uint32, rate uint32) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	// The divider of the state machine has 16 integer and 8 fraction bits.
+	if rate == 0 || rate > CPUFrequency() {
+		return errLogicAnalyzerRate
+	}
+	div := uint64(CPUFrequency()) * 256 / uint64(rate)
+	if div>>8 > 0xffff {
+		return errLogicAnalyzerRate
+	}
+	ch := dmaClaim()
+	if ch < 0 {
+		return errLogicAnalyzerDMA
+	}
+
+	pio := rp.PIO1
+	const sm = 3
+	pio.CTRL.ClearBits(1 << sm)
+	pio.INSTR_MEM31.Set(pioInPins32)
+	pio.SM3_CLKDIV.Set(uint32(div) << 8)
+	pio.SM3_EXECCTRL.Set(logicAnalyzerInstr<<rp.PIO0_SM0_EXECCTRL_WRAP_TOP_Pos |
+		logicAnalyzerInstr<<rp.PIO0_SM0_EXECCTRL_WRAP_BOTTOM_Pos)
+	// Push every sample (a threshold of 0 means 32 bits) into the joined, 8
+	// words deep RX FIFO. Shifting right keeps GPIO0 in bit 0.
+	pio.SM3_SHIFTCTRL.Set(rp.PIO0_SM0_SHIFTCTRL_FJOIN_RX | rp.PIO0_SM0_SHIFTCTRL_AUTOPUSH |
+		rp.PIO0_SM0_SHIFTCTRL_IN_SHIFTDIR)
+	pio.SM3_PINCTRL.Set(0) // IN_BASE is GPIO0
+	pio.CTRL.SetBits(1 << (rp.PIO0_CTRL_SM_RESTART_Pos + sm))
+	pio.CTRL.SetBits(1 << (rp.PIO0_CTRL_CLKDIV_RESTART_Pos + sm))
+	pio.SM3_INSTR.Set(pioJmp | logicAnalyzerInstr)
+
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&samples[0])), len(samples)*4)
+	dmaSyncForDevice(buf, nil)
+	c := &dmaChannels[ch]
+	c.readAddr.Set(uint32(uintptr(unsafe.Pointer(&pio.RXF3))))
+	c.writeAddr.Set(uint32(uintptr(unsafe.Pointer(&samples[0]))))
+	c.transCount.Set(uint32(len(samples)))
+	c.ctrlTrig.Set(dmaCtrlEN | 2<<dmaCtrlDataSizePos | dmaCtrlIncrWrite |
+		uint32(ch)<<dmaCtrlChainToPos | dmaTreqPIO1RX3<<dmaCtrlTreqSelPos)
+	pio.CTRL.SetBits(1 << sm)
+
+	t := DMATransfer{ch: ch, dst: buf}
+	t.Wait()
+	pio.CTRL.ClearBits(1 << sm)
+	return nil
+}
+
+// Serve answers the SUMP commands of the host on Port, and never returns.
+func (la *LogicAnalyzer) Serve() {
+	la.rate = logicSUMPClock
+	la.count = len(la.Samples)
+	for {
+		la.command[0] = la.readByte()
+		if la.command[0]&0x80 != 0 {
+			// Long commands have 4 bytes of parameters.
+			for i := 1; i < len(la.command); i++ {
+				la.command[i] = la.readByte()
+			}
+		}
+		la.handle()
+	}
+}
+
+func (la *LogicAnalyzer) readByte() byte {
+	for {
+		n, _ := la.Port.Read(la.in[:])
+		if n == 1 {
+			return la.in[0]
+		}
+		gosched()
+	}
+}
+
+// handle handles the SUMP command in la.command.
+func (la *LogicAnalyzer) handle() {
+	param := uint32(la.command[1]) | uint32(la.command[2])<<8 | uint32(la.command[3])<<16 | uint32(la.command[4])<<24
+	switch la.command[0] {
+	case 0x00: // reset
+	case 0x01: // run
+		la.run()
+	case 0x02: // ID
+		la.Port.Write([]byte("1ALS"))
+	case 0x04: // metadata
+		la.metadata()
+	case 0x80: // divider
+		la.rate = logicSUMPClock / (param&0xffffff + 1)
+	case 0x81: // read and delay count, in units of 4 samples
+		la.count = int(param&0xffff+1) * 4
+		if la.count > len(la.Samples) {
+			la.count = len(la.Samples)
+		}
+	case 0x82: // flags
+		la.flags = param
+	}
+}
+
+// run captures the samples and sends them, the last one first as SUMP wants,
+// with only the bytes of the enabled channel groups.
+func (la *LogicAnalyzer) run() {
+	samples := la.Samples[:la.count]
+	if la.Capture(samples, la.rate) != nil {
+		return
+	}
+	disabled := la.flags >> logicSUMPGroupsDisabledPos
+	var buf [64]byte
+	n := 0
+	for i := len(samples) - 1; i >= 0; i-- {
+		for group := 0; group < 4; group++ {
+			if disabled&(1<<group) == 0 {
+				buf[n] = byte(samples[i] >> (group * 8))
+				n++
+			}
+		}
+		if n > len(buf)-4 || i == 0 {
+			la.Port.Write(buf[:n])
+			n = 0
+		}
+	}
+}
+
+// metadata sends the description of the analyzer.
+func (la *LogicAnalyzer) metadata() {
+	var buf [40]byte
+	b := append(buf[:0], 0x01)
+	b = append(b, "TinyGo RP2040"...)
+	b = append(b, 0)
+	for _, item := range [...]struct {
+		key   byte
+		value uint32
+	}{
+		{0x20, logicAnalyzerProbes},
+		{0x21, uint32(len(la.Samples) * 4)}, // sample memory in bytes
+		{0x23, CPUFrequency()},              // maximum sample rate
+	} {
+		b = append(b, item.key, byte(item.value>>24), byte(item.value>>16), byte(item.value>>8), byte(item.value))
+	}
+	b = append(b, 0x41, 2, 0x00) // protocol version 2, end
+	la.Port.Write(b)
+}
@@ -0,0 +1,167 @@
+//go:build rp2040
+// +build rp2040
+
+package machine
+
+import (
+	"device/arm"
+	"device/rp"
+	"errors"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// machine_rp2040_multicore.go contains the inter-core primitives of the
+// RP2040: hardware spinlocks, the inter-core FIFOs and the launch of code on
+// the second core. They follow the multicore.c and sync.h APIs of the Pico
+// SDK.
+
+var (
+	ErrInvalidCore     = errors.New("machine: invalid core number")
+	ErrInvalidCoreArgs = errors.New("machine: invalid core entry function or stack")
+)
+
+// NumSpinlocks is the number of hardware spinlocks.
+const NumSpinlocks = _NUMSPINLOCKS
+
+// Spinlock is one of the hardware spinlocks of the chip. Spinlocks are shared
+// by all cores: a lock taken on one core excludes the other core until it is
+// unlocked. They do not disable interrupts, so a spinlock that is taken from
+// an interrupt handler must be taken with interrupts disabled everywhere else.
+//
+// Spinlock 9 is reserved for the machine package itself.
+type Spinlock uint8
+
+func (s Spinlock) reg() *volatile.Register32 {
+	return (*volatile.Register32)(unsafe.Pointer(uintptr(unsafe.Pointer(&rp.SIO.SPINLOCK0)) + 4*uintptr(s&(NumSpinlocks-1))))
+}
+
+// TryLock tries to take the spinlock and returns whether it succeeded.
+func (s Spinlock) TryLock() bool {
+	// Reading the spinlock register claims the lock, a read of zero means
+	// some core already holds it.
+	return s.reg().Get() != 0
+}
+
+// Lock takes the spinlock, waiting for the other core to release it.
+func (s Spinlock) Lock() {
+	for !s.TryLock() {
+	}
+}
+
+// Unlock releases the spinlock.
+func (s Spinlock) Unlock() {
+	s.reg().Set(0)
+}
+
+// CoreFIFOReadable returns whether the FIFO from the other core holds data.
+func CoreFIFOReadable() bool {
+	return rp.SIO.FIFO_ST.HasBits(rp.SIO_FIFO_ST_VLD)
+}
+
+// CoreFIFOWritable returns whether the FIFO to the other core has room.
+func CoreFIFOWritable() bool {
+	return rp.SIO.FIFO_ST.HasBits(rp.SIO_FIFO_ST_RDY)
+}
+
+// CoreFIFOWrite sends a word to the other core, waiting while the FIFO is
+// full. Each direction of the FIFO holds eight words.
+func CoreFIFOWrite(v uint32) {
+	for !CoreFIFOWritable() {
+	}
+	rp.SIO.FIFO_WR.Set(v)
+	// Wake up the other core if it is waiting in CoreFIFORead.
+	arm.Asm("sev")
+}
+
+// CoreFIFORead receives a word from the other core, waiting until one is
+// available.
+func CoreFIFORead() uint32 {
+	for !CoreFIFOReadable() {
+		arm.Asm("wfe")
+	}
+	return rp.SIO.FIFO_RD.Get()
+}
+
+// CoreFIFODrain discards all words sent by the other core.
+func CoreFIFODrain() {
+	for CoreFIFOReadable() {
+		rp.SIO.FIFO_RD.Get()
+	}
+}
+
+// core1Entry is the function started on core 1 by StartCore.
+var core1Entry func()
+
+// core1Start is the first Go code that runs on core 1.
+func core1Start() {
+	core1Entry()
+	for {
+		arm.Asm("wfe")
+	}
+}
+
+// StartCore resets the given core and starts running entry on it, using stack
+// as its stack. Only core 1 can be started. When entry returns the core
+// sleeps until it is started again.
+//
+// The entry function runs outside of the scheduler and the garbage collector,
+// which only know about core 0: it must not allocate memory, start goroutines
+// or block on channels. Use the FIFOs and spinlocks to communicate with core
+// 0 instead, or share memory guarded by a spinlock.
+func StartCore(core int, entry func(), stack []uint32) error {
+	if core != 1 {
+		return ErrInvalidCore
+	}
+	if entry == nil || len(stack) < 16 {
+		return ErrInvalidCoreArgs
+	}
+	resetCore1()
+	core1Entry = entry
+
+	// The stack grows down from the end of the buffer and must be 8-byte
+	// aligned.
+	sp := (uintptr(unsafe.Pointer(&stack[0])) + uintptr(len(stack))*4) &^ 7
+
+	// A func value is a context pointer followed by the function pointer.
+	// core1Start is a plain function so its context is unused.
+	start := core1Start
+	pc := (*[2]uintptr)(unsafe.Pointer(&start))[1]
+
+	// Launch protocol of the bootrom: every word is echoed back by core 1, a
+	// mismatch restarts the sequence.
+	cmds := [...]uint32{0, 0, 1, rp.PPB.VTOR.Get(), uint32(sp), uint32(pc)}
+	for seq := 0; seq < len(cmds); {
+		cmd := cmds[seq]
+		if cmd == 0 {
+			// Core 1 may have pushed a word before it was reset.
+			CoreFIFODrain()
+			arm.Asm("sev")
+		}
+		CoreFIFOWrite(cmd)
+		if CoreFIFORead() == cmd {
+			seq++
+		} else {
+			seq = 0
+		}
+	}
+	return nil
+}
+
+// StopCore resets the given core, which stops it. Only core 1 can be stopped.
+func StopCore(core int) error {
+	if core != 1 {
+		return ErrInvalidCore
+	}
+	resetCore1()
+	return nil
+}
+
+// resetCore1 forces core 1 off and back on, after which it waits in the
+// bootrom for the launch protocol.
+func resetCore1() {
+	rp.PSM.FRCE_OFF.SetBits(rp.PSM_FRCE_OFF_PROC1)
+	for !rp.PSM.FRCE_OFF.HasBits(rp.PSM_FRCE_OFF_PROC1) {
+	}
+	rp.PSM.FRCE_OFF.ClearBits(rp.PSM_FRCE_OFF_PROC1)
+}
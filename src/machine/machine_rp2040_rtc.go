@@ -0,0 +1,76 @@
+//go:build rp2040
+// +build rp2040
+
+package machine
+
+import "device/rp"
+
+// The RTC of the RP2040 keeps the date and time in calendar form, counting
+// seconds from clk_rtc. It is not battery backed, so it is only set by
+// SetTime.
+
+const (
+	rtcClockFrequency = 46875 // clk_rtc, see clocks.init
+
+	rtcCtrlEnable = 1 << 0
+	rtcCtrlActive = 1 << 1
+	rtcCtrlLoad   = 1 << 4
+
+	rtcSetup0YearPos  = 12
+	rtcSetup0MonthPos = 8
+	rtcSetup1DotwPos  = 24
+	rtcSetup1HourPos  = 16
+	rtcSetup1MinPos   = 8
+)
+
+// rtcSetTime loads the given Unix time into the RTC and starts it.
+func rtcSetTime(unixNano int64) {
+	secs := unixNano / 1000_000_000
+	days := secs / 86400
+	rem := secs % 86400
+	if rem < 0 {
+		rem += 86400
+		days--
+	}
+	year, month, day := civilFromDays(days)
+	dotw := (days + 4) % 7 // 1970-01-01 was a Thursday
+	if dotw < 0 {
+		dotw += 7
+	}
+
+	rp.RTC.CTRL.ClearBits(rtcCtrlEnable)
+	for rp.RTC.CTRL.HasBits(rtcCtrlActive) {
+	}
+	rp.RTC.CLKDIV_M1.Set(rtcClockFrequency - 1)
+	rp.RTC.SETUP_0.Set(uint32(year)<<rtcSetup0YearPos | uint32(month)<<rtcSetup0MonthPos | uint32(day))
+	rp.RTC.SETUP_1.Set(uint32(dotw)<<rtcSetup1DotwPos | uint32(rem/3600)<<rtcSetup1HourPos | uint32(rem/60%60)<<rtcSetup1MinPos | uint32(rem%60))
+	rp.RTC.CTRL.Set(rtcCtrlLoad)
+	rp.RTC.CTRL.Set(rtcCtrlEnable)
+	for !rp.RTC.CTRL.HasBits(rtcCtrlActive) {
+	}
+}
+
+// civilFromDays converts days since 1970-01-01 to a date in the proleptic
+// Gregorian calendar. See http://howardhinnant.github.io/date_algorithms.html.
+func civilFromDays(days int64) (year int64, month, day int) {
+	days += 719468
+	era := days / 146097
+	if days < 0 && days%146097 != 0 {
+		era--
+	}
+	doe := days - era*146097                               // day of era, [0, 146096]
+	yoe := (doe - doe/1460 + doe/36524 - doe/146096) / 365 // year of era, [0, 399]
+	doy := doe - (365*yoe + yoe/4 - yoe/100)               // day of year, [0, 365]
+	mp := (5*doy + 2) / 153                                // month from March, [0, 11]
+	day = int(doy - (153*mp+2)/5 + 1)
+	if mp < 10 {
+		month = int(mp + 3)
+	} else {
+		month = int(mp - 9)
+	}
+	year = yoe + era*400
+	if month <= 2 {
+		year++
+	}
+	return year, month, day
+}
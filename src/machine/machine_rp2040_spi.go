@@ -6,6 +6,7 @@ package machine
 import (
 	"device/rp"
 	"errors"
+	"unsafe"
 )
 
 // SPI on the RP2040
@@ -35,8 +36,16 @@ type SPIConfig struct {
 	SDO Pin
 	// RX or Serial Data In (MISO if rp2040 is master)
 	SDI Pin
+	// Delay between two words in nanoseconds, for slow devices. The PL022
+	// has no delay setting, so the words are then sent one by one by the CPU.
+	// The program drives CS, so the CS delays are those of SPIDevice.
+	WordDelay uint32
 }
 
+// spiWordDelay holds the WordDelay of both SPI buses, as SPI is passed by
+// value.
+var spiWordDelay [2]uint32
+
 var (
 	ErrLSBNotSupported = errors.New("SPI LSB unsupported on PL022")
 	ErrSPITimeout      = errors.New("SPI timeout")
@@ -50,6 +59,12 @@ type SPI struct {
 // time to wait on a transaction before dropping. Unit in Microseconds for compatibility with ticks().
 const _SPITimeout = 10 * 1000 // 10 ms
 
+// DMA requests of the SPI TX FIFOs.
+const (
+	dmaTreqSPI0TX = 16
+	dmaTreqSPI1TX = 18
+)
+
 // Tx handles read/write operation for SPI interface. Since SPI is a syncronous write/read
 // interface, there must always be the same number of bytes written as bytes read.
 // The Tx method knows about this, and offers a few different ways of calling it.
@@ -76,6 +91,9 @@ const _SPITimeout = 10 * 1000 // 10 ms
 // This form sends 0xff and puts the result into rx buffer. Useful for reading from SD cards
 // which require 0xff input on SI.
 func (spi SPI) Tx(w, r []byte) (err error) {
+	if delay := spiWordDelay[spi.index()]; delay != 0 {
+		return spiTxDelayed(spi, w, r, delay)
+	}
 	switch {
 	case w == nil:
 		// read only, so write zero and read a result.
@@ -93,6 +111,14 @@ func (spi SPI) Tx(w, r []byte) (err error) {
 	return err
 }
 
+// index returns the number of the SPI bus.
+func (spi SPI) index() int {
+	if spi.Bus == rp.SPI1 {
+		return 1
+	}
+	return 0
+}
+
 // Write a single byte and read a single byte from TX/RX FIFO.
 func (spi SPI) Transfer(w byte) (byte, error) {
 	var deadline = ticks() + _SPITimeout
@@ -182,6 +208,7 @@ func (spi SPI) Configure(config SPIConfig) error {
 	if config.Frequency == 0 {
 		config.Frequency = defaultBaud
 	}
+	spiWordDelay[spi.index()] = config.WordDelay
 	// SPI pin configuration
 	config.SCK.setFunc(fnSPI)
 	config.SDO.setFunc(fnSPI)
@@ -292,6 +319,11 @@ func (spi SPI) tx(tx []byte) error {
 		}
 		spi.Bus.SSPDR.Set(uint32(tx[i]))
 	}
+	return spi.finishTx(deadline)
+}
+
+// finishTx waits for the end of a write that ignores Rx.
+func (spi SPI) finishTx(deadline uint64) error {
 	// Drain RX FIFO, then wait for shifting to finish (which may be *after*
 	// TX FIFO drains), then drain RX FIFO again
 	for spi.isReadable() {
@@ -310,6 +342,53 @@ func (spi SPI) tx(tx []byte) error {
 	return nil
 }
 
+// TxBuffers writes the buffers one after the other, ignoring Rx, as if they
+// were a single buffer: a driver can send a command header and its payload
+// without copying them together. It feeds the TX FIFO with a DMA channel, so
+// there is no gap between the buffers, or writes them with the CPU if all DMA
+// channels are in use.
+func (spi SPI) TxBuffers(buffers [][]byte) error {
+	ch := dmaClaim()
+	if ch < 0 {
+		for _, buf := range buffers {
+			if err := spi.tx(buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	defer dmaRelease(ch)
+
+	treq := uint32(dmaTreqSPI0TX)
+	if spi.Bus == rp.SPI1 {
+		treq = dmaTreqSPI1TX
+	}
+	spi.Bus.SSPDMACR.SetBits(rp.SPI0_SSPDMACR_TXDMAE)
+	defer spi.Bus.SSPDMACR.ClearBits(rp.SPI0_SSPDMACR_TXDMAE)
+	c := &dmaChannels[ch]
+	deadline := ticks() + _SPITimeout
+	for _, buf := range buffers {
+		if len(buf) == 0 {
+			continue
+		}
+		deadline = ticks() + _SPITimeout
+		dmaSyncForDevice(nil, buf)
+		c.readAddr.Set(uint32(uintptr(unsafe.Pointer(&buf[0]))))
+		c.writeAddr.Set(uint32(uintptr(unsafe.Pointer(&spi.Bus.SSPDR))))
+		c.transCount.Set(uint32(len(buf)))
+		c.ctrlTrig.Set(dmaCtrlEN | dmaCtrlIncrRead | uint32(ch)<<dmaCtrlChainToPos | treq<<dmaCtrlTreqSelPos)
+		for c.ctrlTrig.HasBits(dmaCtrlBusy) {
+			if ticks() > deadline {
+				rp.DMA.CHAN_ABORT.Set(1 << ch)
+				for rp.DMA.CHAN_ABORT.Get() != 0 {
+				}
+				return ErrSPITimeout
+			}
+		}
+	}
+	return spi.finishTx(deadline)
+}
+
 // rx reads buffer to SPI ignoring x.
 // txrepeat is output repeatedly on SO as data is read in from SI.
 // Generally this can be 0, but some devices require a specific value here,
@@ -0,0 +1,238 @@
+//go:build rp2040
+// +build rp2040
+
+package machine
+
+import (
+	"device/rp"
+	"errors"
+	"math"
+	"runtime/interrupt"
+)
+
+// StepGen generates the step pulses of a stepper motor driver, such as the
+// A4988, DRV8825 or TMC2209, with a PWM slice: the slice outputs one pulse
+// per period, and its wrap interrupt counts the steps and changes the period
+// to follow an acceleration ramp. The pulses are timed by the hardware, so
+// they stay clean at tens of kHz whatever the goroutines do.
+//
+//	stepper := machine.StepGen{PWM: machine.PWM1, Step: machine.GPIO2, Dir: machine.GPIO4,
+//		MaxSpeed: 20000, Acceleration: 50000}
+//	stepper.Configure()
+//	stepper.MoveTo(32000)
+//	for stepper.Running() {
+//		time.Sleep(time.Millisecond)
+//	}
+//
+// The Step pin must be an output of the PWM slice, and each StepGen needs a
+// slice of its own.
+type StepGen struct {
+	PWM  *pwmGroup
+	Step Pin
+	Dir  Pin
+
+	// MaxSpeed is the top speed in steps per second, up to 250000.
+	MaxSpeed uint32
+
+	// Acceleration in steps per second². Zero runs at MaxSpeed right away.
+	Acceleration uint32
+
+	// DirInverted reverses the Dir pin: it is low for positive moves, instead
+	// of high.
+	DirInverted bool
+
+	channel  uint8
+	position int32
+	target   int32
+	dir      int32 // +1 or -1 while running, 0 when stopped
+	stopping bool  // the last pulse has been started
+
+	// Acceleration ramp: the current period in µs (cn) and the step number
+	// within the ramp (n), negative while decelerating, following "Generate
+	// stepper-motor speed profiles in real time" by David Austin.
+	cn, c0, cmin uint32
+	n            int32
+}
+
+const (
+	stepGenPulseWidth = 2 // µs
+	stepGenMaxPeriod  = 0xffff
+)
+
+var (
+	errStepGenBusy  = errors.New("stepgen: motor is moving")
+	errStepGenSpeed = errors.New("stepgen: MaxSpeed out of range")
+
+	stepGens [8]*StepGen
+)
+
+// Configure configures the PWM slice and the pins.
+func (s *StepGen) Configure() error {
+	if s.MaxSpeed == 0 || s.MaxSpeed > 1e6/(2*stepGenPulseWidth) {
+		return errStepGenSpeed
+	}
+	if err := claimInterrupt(rp.IRQ_PWM_IRQ_WRAP); err != nil {
+		return err
+	}
+	if err := s.PWM.init(PWMConfig{}, false); err != nil {
+		return err
+	}
+	// The counter counts microseconds.
+	s.PWM.setClockDiv(uint8(CPUFrequency()/MHz), 0)
+	ch, err := s.PWM.Channel(s.Step)
+	if err != nil {
+		return err
+	}
+	s.channel = ch
+	s.Dir.Configure(PinConfig{Mode: PinOutput})
+
+	s.cmin = 1e6 / s.MaxSpeed
+	s.c0 = s.cmin
+	if s.Acceleration != 0 {
+		// First period of the ramp, with the correction factor of 0.676 from
+		// the paper.
+		c0 := 0.676 * 1e6 * math.Sqrt(2/float64(s.Acceleration))
+		if c0 > stepGenMaxPeriod {
+			c0 = stepGenMaxPeriod
+		}
+		if uint32(c0) > s.c0 {
+			s.c0 = uint32(c0)
+		}
+	}
+
+	stepGens[s.PWM.peripheral()] = s
+	intr := interrupt.New(rp.IRQ_PWM_IRQ_WRAP, handleStepGenInterrupt)
+	intr.Enable()
+	return nil
+}
+
+// Position returns the current position in steps.
+func (s *StepGen) Position() int32 {
+	return s.position
+}
+
+// SetPosition changes the current position, for example to 0 after homing.
+// The motor must be stopped.
+func (s *StepGen) SetPosition(position int32) error {
+	if s.Running() {
+		return errStepGenBusy
+	}
+	s.position = position
+	s.target = position
+	return nil
+}
+
+// Running returns whether the motor is moving.
+func (s *StepGen) Running() bool {
+	return s.dir != 0
+}
+
+// Move moves the motor by a number of steps, relative to the current position.
+func (s *StepGen) Move(steps int32) error {
+	return s.MoveTo(s.position + steps)
+}
+
+// MoveTo moves the motor to a position, accelerating up to MaxSpeed and
+// decelerating to stop at the position. It returns right away; the motor must
+// be stopped.
+func (s *StepGen) MoveTo(position int32) error {
+	if s.Running() {
+		return errStepGenBusy
+	}
+	if position == s.position {
+		return nil
+	}
+	s.target = position
+	s.dir = 1
+	if position < s.position {
+		s.dir = -1
+	}
+	s.Dir.Set((s.dir > 0) != s.DirInverted)
+	s.stopping = false
+	s.n = 0
+	s.cn = s.c0
+
+	// Start with a pulse right away: the counter wraps at the next tick.
+	s.PWM.setChanLevel(s.channel, stepGenPulseWidth)
+	s.PWM.setWrap(uint16(s.cn - 1))
+	s.PWM.CTR.Set(s.cn - 1)
+	mask := uint32(1) << s.PWM.peripheral()
+	rp.PWM.INTR.Set(mask)
+	rp.PWM.INTE.SetBits(mask)
+	s.PWM.enable(true)
+	return nil
+}
+
+// Stop decelerates the motor and stops it as soon as possible.
+func (s *StepGen) Stop() {
+	mask := interrupt.Disable()
+	if s.Running() && !s.stopping {
+		// Decelerating from step n of the ramp takes n steps.
+		steps := s.n
+		if steps < 0 {
+			steps = -steps
+		}
+		if steps == 0 || s.Acceleration == 0 {
+			steps = 1
+		}
+		if target := s.position + s.dir*steps; (target-s.target)*s.dir < 0 {
+			s.target = target
+		}
+	}
+	interrupt.Restore(mask)
+}
+
+// wrap is called at the start of every pulse.
+func (s *StepGen) wrap() {
+	if s.stopping {
+		// The last pulse is done.
+		s.PWM.enable(false)
+		rp.PWM.INTE.ClearBits(1 << s.PWM.peripheral())
+		s.dir = 0
+		return
+	}
+	s.position += s.dir
+	remaining := (s.target - s.position) * s.dir
+	if remaining <= 0 {
+		// No more pulses after this one.
+		s.PWM.setChanLevel(s.channel, 0)
+		s.stopping = true
+		return
+	}
+	if s.Acceleration == 0 {
+		return
+	}
+
+	// Decelerate once the remaining steps are needed to stop, which takes
+	// as many steps as the acceleration took.
+	if s.n > 0 && s.n >= remaining {
+		s.n = -s.n
+	}
+	s.n++
+	if s.n == 0 {
+		s.cn = s.c0
+	} else {
+		n := s.n
+		cn := int32(s.cn) - 2*int32(s.cn)/(4*n+1)
+		if cn < int32(s.cmin) {
+			// Top speed: stay at step n of the ramp.
+			cn = int32(s.cmin)
+			s.n--
+		}
+		if cn > stepGenMaxPeriod {
+			cn = stepGenMaxPeriod
+		}
+		s.cn = uint32(cn)
+	}
+	s.PWM.setWrap(uint16(s.cn - 1))
+}
+
+func handleStepGenInterrupt(interrupt.Interrupt) {
+	status := rp.PWM.INTS.Get()
+	rp.PWM.INTR.Set(status)
+	for slice, s := range stepGens {
+		if s != nil && status&(1<<slice) != 0 {
+			s.wrap()
+		}
+	}
+}
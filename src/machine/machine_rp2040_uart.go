@@ -13,10 +13,16 @@ type UART struct {
 	Buffer    *RingBuffer
 	Bus       *rp.UART0_Type
 	Interrupt interrupt.Interrupt
+
+	dmx DMATransfer // DMX frame being sent, see WriteDMX
 }
 
-// Configure the UART.
+// Configure the UART. It fails with ErrInterruptOwned if the UART is owned by
+// the other core.
 func (uart *UART) Configure(config UARTConfig) error {
+	if err := claimInterrupt(uartIRQ(uart)); err != nil {
+		return err
+	}
 	initUART(uart)
 
 	// Default baud rate to 115200.
@@ -46,7 +52,7 @@ func (uart *UART) Configure(config UARTConfig) error {
 	config.RX.Configure(PinConfig{Mode: PinUART})
 
 	// Enable RX IRQ.
-	uart.Interrupt.SetPriority(0x80)
+	uart.Interrupt.SetPriority(interruptPriority(config.Priority, 0x80))
 	uart.Interrupt.Enable()
 
 	// setup interrupt on receive
@@ -102,13 +108,43 @@ func (uart *UART) SetFormat(databits, stopbits uint8, parity UARTParity) error {
 	if parity == ParityEven {
 		pev = rp.UART0_UARTLCR_H_EPS
 	}
-	uart.Bus.UARTLCR_H.SetBits(uint32((databits-5)<<rp.UART0_UARTLCR_H_WLEN_Pos |
-		(stopbits-1)<<rp.UART0_UARTLCR_H_STP2_Pos |
-		pen | pev))
+	// Clear the previous format, as the line coding of a USB serial bridge
+	// changes it at any time.
+	uart.Bus.UARTLCR_H.ReplaceBits(uint32((databits-5)<<rp.UART0_UARTLCR_H_WLEN_Pos|
+		(stopbits-1)<<rp.UART0_UARTLCR_H_STP2_Pos|
+		pen|pev),
+		rp.UART0_UARTLCR_H_WLEN_Msk|rp.UART0_UARTLCR_H_STP2_Msk|rp.UART0_UARTLCR_H_PEN_Msk|rp.UART0_UARTLCR_H_EPS_Msk, 0)
 
 	return nil
 }
 
+// SetBreak holds the TX pin low, which the other side sees as a break
+// condition, until it is called again with on set to false.
+func (uart *UART) SetBreak(on bool) {
+	if on {
+		uart.Bus.UARTLCR_H.SetBits(rp.UART0_UARTLCR_H_BRK)
+	} else {
+		uart.Bus.UARTLCR_H.ClearBits(rp.UART0_UARTLCR_H_BRK)
+	}
+}
+
+// ConfigureSBUS configures the UART to receive SBUS from an RC receiver, see
+// SBUSDecoder. The RX pin inverts its input, so that no external inverter is
+// needed.
+func (uart *UART) ConfigureSBUS(config UARTConfig) error {
+	if config.TX == 0 && config.RX == 0 {
+		config.TX = UART_TX_PIN
+		config.RX = UART_RX_PIN
+	}
+	config.BaudRate = 100000
+	if err := uart.Configure(config); err != nil {
+		return err
+	}
+	uart.SetFormat(8, 2, ParityEven)
+	config.RX.ioCtrl().SetBits(rp.IO_BANK0_GPIO0_CTRL_INOVER_INVERT << rp.IO_BANK0_GPIO0_CTRL_INOVER_Pos)
+	return nil
+}
+
 func initUART(uart *UART) {
 	var resetVal uint32
 	switch {
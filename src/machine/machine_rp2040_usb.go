@@ -31,7 +31,7 @@ func (dev *USBDevice) Configure(config UARTConfig) {
 	// Enable USB interrupt at processor
 	rp.USBCTRL_REGS.INTE.Set(0)
 	intr := interrupt.New(rp.IRQ_USBCTRL_IRQ, handleUSBIRQ)
-	intr.SetPriority(0x00)
+	intr.SetPriority(interruptPriority(config.Priority, 0x00))
 	intr.Enable()
 	irqSet(rp.IRQ_USBCTRL_IRQ, true)
 
@@ -48,15 +48,39 @@ func (dev *USBDevice) Configure(config UARTConfig) {
 	rp.USBCTRL_REGS.SIE_CTRL.Set(rp.USBCTRL_REGS_SIE_CTRL_EP0_INT_1BUF)
 
 	// Enable interrupts for when a buffer is done, when the bus is reset,
-	// and when a setup packet is received
+	// when a setup packet is received, and when the bus is suspended and
+	// resumed
 	rp.USBCTRL_REGS.INTE.Set(rp.USBCTRL_REGS_INTE_BUFF_STATUS |
 		rp.USBCTRL_REGS_INTE_BUS_RESET |
-		rp.USBCTRL_REGS_INTE_SETUP_REQ)
+		rp.USBCTRL_REGS_INTE_SETUP_REQ |
+		rp.USBCTRL_REGS_INTE_DEV_SUSPEND |
+		rp.USBCTRL_REGS_INTE_DEV_RESUME_FROM_HOST)
 
+	if dev.attachOnConfigure() {
+		dev.Attach()
+	}
+}
+
+// Attach connects the device to the bus, so that the host enumerates it.
+func (dev *USBDevice) Attach() {
 	// Present full speed device by enabling pull up on DP
 	rp.USBCTRL_REGS.SIE_CTRL.SetBits(rp.USBCTRL_REGS_SIE_CTRL_PULLUP_EN)
 }
 
+// Detach disconnects the device from the bus, as if the cable was unplugged.
+func (dev *USBDevice) Detach() {
+	rp.USBCTRL_REGS.SIE_CTRL.ClearBits(rp.USBCTRL_REGS_SIE_CTRL_PULLUP_EN)
+	usbDetached()
+}
+
+// handleUSBIRQ runs from RAM, so that the interrupt entry and the checks of
+// the status registers don't wait for the flash chip to be read over QSPI
+// after a miss in the XIP cache. Only this function is moved: the setup
+// handling, the class handlers and the runtime functions it calls (like the
+// copy of the packets) still run from flash, and may still miss the cache.
+//
+//go:section .ramfunc.handleUSBIRQ
+//go:noinline
 func handleUSBIRQ(intr interrupt.Interrupt) {
 	status := rp.USBCTRL_REGS.INTS.Get()
 
@@ -65,16 +89,7 @@ func handleUSBIRQ(intr interrupt.Interrupt) {
 		rp.USBCTRL_REGS.SIE_STATUS.Set(rp.USBCTRL_REGS_SIE_STATUS_SETUP_REC)
 		setup := usb.NewSetup(usbDPSRAM.setupBytes())
 
-		ok := false
-		if (setup.BmRequestType & usb.REQUEST_TYPE) == usb.REQUEST_STANDARD {
-			// Standard Requests
-			ok = handleStandardSetup(setup)
-		} else {
-			// Class Interface Requests
-			if setup.WIndex < uint16(len(usbSetupHandler)) && usbSetupHandler[setup.WIndex] != nil {
-				ok = usbSetupHandler[setup.WIndex](setup)
-			}
-		}
+		ok := handleSetup(setup)
 
 		if !ok {
 			// Stall endpoint?
@@ -109,7 +124,7 @@ func handleUSBIRQ(intr interrupt.Interrupt) {
 				if usbRxHandler[i] != nil {
 					usbRxHandler[i](buf)
 				}
-				handleEndpointRxComplete(uint32(i))
+				usbRxComplete(uint32(i))
 			}
 		}
 
@@ -131,6 +146,21 @@ func handleUSBIRQ(intr interrupt.Interrupt) {
 		rp.USBCTRL_REGS.ADDR_ENDP.Set(0)
 
 		initEndpoint(0, usb.ENDPOINT_TYPE_CONTROL)
+		usbConfiguration = 0
+		USBStateChange.Signal()
+		usbSetSuspended(false)
+	}
+
+	// Bus is suspended: no start of frame packets for 3ms
+	if (status & rp.USBCTRL_REGS_INTS_DEV_SUSPEND) > 0 {
+		rp.USBCTRL_REGS.SIE_STATUS.Set(rp.USBCTRL_REGS_SIE_STATUS_SUSPENDED)
+		usbSetSuspended(true)
+	}
+
+	// Bus is resumed by the host
+	if (status & rp.USBCTRL_REGS_INTS_DEV_RESUME_FROM_HOST) > 0 {
+		rp.USBCTRL_REGS.SIE_STATUS.Set(rp.USBCTRL_REGS_SIE_STATUS_RESUME)
+		usbSetSuspended(false)
 	}
 }
 
@@ -151,7 +181,10 @@ func initEndpoint(ep, config uint32) {
 		usbDPSRAM.EPxBufferControl[ep].Out.SetBits(usbBuf0CtrlAvail)
 
 	case usb.ENDPOINT_TYPE_INTERRUPT | usb.EndpointOut:
-		// TODO: not really anything, seems like...
+		val |= usbEpControlEndpointTypeInterrupt
+		usbDPSRAM.EPxControl[ep].Out.Set(val)
+		usbDPSRAM.EPxBufferControl[ep].Out.Set(USBBufferLen & usbBuf0CtrlLenMask)
+		usbDPSRAM.EPxBufferControl[ep].Out.SetBits(usbBuf0CtrlAvail)
 
 	case usb.ENDPOINT_TYPE_BULK | usb.EndpointIn:
 		val |= usbEpControlEndpointTypeBulk
@@ -249,6 +282,35 @@ func handleEndpointRxComplete(ep uint32) {
 	usbDPSRAM.EPxBufferControl[ep].Out.SetBits(usbBuf0CtrlAvail)
 }
 
+// setEndpointStall makes the endpoint answer the host with STALL, or resumes
+// it. Resuming it also resets its data toggle to DATA0. The endpoint 0 is
+// stalled with sendStallViaEPIn instead, which arms the stall for the next
+// setup transfer.
+func setEndpointStall(ep uint32, in, stall bool) {
+	ctrl := &usbDPSRAM.EPxBufferControl[ep].Out
+	if in {
+		ctrl = &usbDPSRAM.EPxBufferControl[ep].In
+	}
+	if stall {
+		ctrl.SetBits(usbBuf0CtrlStall)
+		return
+	}
+	ctrl.ClearBits(usbBuf0CtrlStall)
+	// epXdata0 is toggled before every packet: an IN packet is sent as DATA0
+	// when it is true after the toggle, an OUT buffer expects DATA0 when it is
+	// false. An OUT buffer that is already available is made to expect DATA0
+	// as well.
+	switch {
+	case in:
+		epXdata0[ep] = false
+	case ctrl.HasBits(usbBuf0CtrlAvail):
+		ctrl.ClearBits(usbBuf0CtrlData1Pid)
+		epXdata0[ep] = false
+	default:
+		epXdata0[ep] = true
+	}
+}
+
 func SendZlp() {
 	sendUSBPacket(0, []byte{}, 0)
 }
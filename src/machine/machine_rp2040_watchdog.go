@@ -5,6 +5,7 @@ package machine
 
 import (
 	"device/rp"
+	"errors"
 	"runtime/volatile"
 	"unsafe"
 )
@@ -26,3 +27,49 @@ var watchdog = (*watchdogType)(unsafe.Pointer(rp.WATCHDOG))
 func (wd *watchdogType) startTick(cycles uint32) {
 	wd.tick.Set(cycles | rp.WATCHDOG_TICK_ENABLE)
 }
+
+// WatchdogMaxTimeout is the longest watchdog timeout, in milliseconds. The
+// counter has 24 bits, and counts down twice per microsecond because of
+// erratum RP2040-E1.
+const WatchdogMaxTimeout = 0xffffff / 2000
+
+var errWatchdogTimeout = errors.New("watchdog: timeout too long")
+
+// Watchdog is the watchdog timer, which resets the chip when it isn't fed in
+// time.
+var Watchdog = &watchdogImpl{}
+
+type watchdogImpl struct {
+	load uint32
+}
+
+// Configure sets the timeout of the watchdog. It also stops the watchdog, if
+// it was running.
+func (wd *watchdogImpl) Configure(config WatchdogConfig) error {
+	if config.TimeoutMillis > WatchdogMaxTimeout {
+		return errWatchdogTimeout
+	}
+	wd.load = config.TimeoutMillis * 1000 * 2
+	watchdog.ctrl.ClearBits(rp.WATCHDOG_CTRL_ENABLE)
+
+	// Reset everything apart from the oscillators, which keep the watchdog tick
+	// running.
+	rp.PSM.WDSEL.Set(0x0001ffff &^ (rp.PSM_WDSEL_ROSC | rp.PSM_WDSEL_XOSC))
+
+	// Don't reset the chip while it is halted by a debugger.
+	watchdog.ctrl.SetBits(rp.WATCHDOG_CTRL_PAUSE_DBG0 | rp.WATCHDOG_CTRL_PAUSE_DBG1 | rp.WATCHDOG_CTRL_PAUSE_JTAG)
+	watchdog.load.Set(wd.load)
+	watchdogFeed = wd.Feed
+	return nil
+}
+
+// Start starts the watchdog. From then on, it must be fed within the timeout.
+func (wd *watchdogImpl) Start() {
+	watchdog.load.Set(wd.load)
+	watchdog.ctrl.SetBits(rp.WATCHDOG_CTRL_ENABLE)
+}
+
+// Feed restarts the timeout of the watchdog.
+func (wd *watchdogImpl) Feed() {
+	watchdog.load.Set(wd.load)
+}
@@ -0,0 +1,32 @@
+//go:build stm32 && !stm32f103
+// +build stm32,!stm32f103
+
+package machine
+
+// DisableDebugPins releases SWDIO (PA13) and SWCLK (PA14) from the SWD
+// function and leaves them as floating inputs, after which they can be
+// configured as GPIOs. See EnableDebugPins to restore them.
+func DisableDebugPins() error {
+	PA13.Configure(PinConfig{Mode: PinInputFloating})
+	PA14.Configure(PinConfig{Mode: PinInputFloating})
+	return nil
+}
+
+// EnableDebugPins switches PA13 and PA14 back to SWDIO and SWCLK, with the
+// pull-up and pull-down they have after reset, so that a debugger can attach
+// again.
+func EnableDebugPins() error {
+	for _, p := range [...]Pin{PA13, PA14} {
+		p.enableClock()
+		port := p.getPort()
+		pos := (uint8(p) % 16) * 2
+		port.MODER.ReplaceBits(gpioModeAlternate, gpioModeMask, pos)
+		if p == PA13 {
+			port.PUPDR.ReplaceBits(gpioPullUp, gpioPullMask, pos)
+		} else {
+			port.PUPDR.ReplaceBits(gpioPullDown, gpioPullMask, pos)
+		}
+		p.SetAltFunc(0) // AF0: system functions
+	}
+	return nil
+}
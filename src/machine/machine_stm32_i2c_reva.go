@@ -173,6 +173,45 @@ func (i2c *I2C) Tx(addr uint16, w, r []byte) error {
 	return nil
 }
 
+// The last byte of a read must be followed by a stop, which is requested while
+// the data is still being received, so a read can't be followed by a repeated
+// start here.
+const i2cTransactionLimits = i2cNoReadRestart
+
+// transferMsg sends a message of a Transaction, see i2c_transaction.go. After
+// a write without a stop the bus is held with SCL low, and setting START then
+// sends a repeated start.
+func (i2c *I2C) transferMsg(addr uint8, read bool, buf []byte, before, after i2cJoin) error {
+	if before == i2cStop && !i2c.waitForFlag(flagBUSY, false) {
+		return errI2CBusReadyTimeout
+	}
+	if read {
+		// Reads always end with a stop, see i2cTransactionLimits.
+		return i2c.receive(uint16(addr), buf)
+	}
+	if before != i2cNoStart {
+		i2c.Bus.CR1.ClearBits(stm32.I2C_CR1_POS)
+		if err := i2c.controllerRequestWrite(uint16(addr), frameNoOption); err != nil {
+			return err
+		}
+		i2c.clearFlagADDR()
+	}
+	for _, b := range buf {
+		if !i2c.waitForFlagOrError(flagTXE, true) {
+			return errI2CAckExpected
+		}
+		i2c.Bus.DR.Set(uint32(b))
+	}
+	// Wait until the last byte has been sent, as before a stop.
+	if len(buf) != 0 && !i2c.waitForFlagOrError(flagBTF, true) {
+		return errI2CWriteTimeout
+	}
+	if after == i2cStop {
+		i2c.Bus.CR1.SetBits(stm32.I2C_CR1_STOP)
+	}
+	return nil
+}
+
 func (i2c *I2C) controllerTransmit(addr uint16, w []byte) error {
 
 	if !i2c.waitForFlag(flagBUSY, false) {
@@ -256,7 +295,12 @@ func (i2c *I2C) controllerReceive(addr uint16, r []byte) error {
 	if !i2c.waitForFlag(flagBUSY, false) {
 		return errI2CBusReadyTimeout
 	}
+	return i2c.receive(addr, r)
+}
 
+// receive reads r from addr and sends a stop, once the bus is free or held for
+// a repeated start.
+func (i2c *I2C) receive(addr uint16, r []byte) error {
 	// disable POS
 	i2c.Bus.CR1.ClearBits(stm32.I2C_CR1_POS)
 
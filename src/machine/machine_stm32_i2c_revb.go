@@ -101,6 +101,84 @@ func (i2c *I2C) Tx(addr uint16, w, r []byte) error {
 	return nil
 }
 
+// The controller can send any sequence of messages.
+const i2cTransactionLimits = 0
+
+// transferMsg sends a message of a Transaction, see i2c_transaction.go. The
+// bytes are sent in chunks of at most MAX_NBYTE_SIZE. The last chunk ends with
+// AUTOEND for a stop, with RELOAD when the next message continues this one,
+// and with neither for a repeated start, which leaves the bus held until
+// START is set for the next message.
+func (i2c *I2C) transferMsg(addr uint8, read bool, buf []byte, before, after i2cJoin) error {
+	start := ticks()
+
+	request := uint32(I2C_NO_STARTSTOP)
+	switch {
+	case before == i2cNoStart:
+		if !i2c.waitOnFlagUntilTimeout(flagTCR, true, start) {
+			return errI2CWriteTimeout
+		}
+	case read:
+		request = I2C_GENERATE_START_READ
+	default:
+		request = I2C_GENERATE_START_WRITE
+	}
+	if before == i2cStop && !i2c.waitOnFlagUntilTimeout(flagBUSY, false, start) {
+		return errI2CBusReadyTimeout
+	}
+
+	pos := 0
+	for {
+		n := len(buf) - pos
+		mode := uint32(0)
+		switch {
+		case n > MAX_NBYTE_SIZE:
+			n = MAX_NBYTE_SIZE
+			mode = stm32.I2C_CR2_RELOAD
+		case after == i2cStop:
+			mode = stm32.I2C_CR2_AUTOEND
+		case after == i2cNoStart:
+			mode = stm32.I2C_CR2_RELOAD
+		}
+		i2c.transferConfig(uint16(addr), uint8(n), mode, request)
+		request = I2C_NO_STARTSTOP
+
+		for end := pos + n; pos < end; pos++ {
+			if read {
+				if !i2c.waitOnRXNEFlagUntilTimeout(start) {
+					return errI2CReadTimeout
+				}
+				buf[pos] = uint8(i2c.Bus.RXDR.Get())
+			} else {
+				if !i2c.waitOnTXISFlagUntilTimeout(start) {
+					return errI2CWriteTimeout
+				}
+				i2c.Bus.TXDR.Set(uint32(buf[pos]))
+			}
+		}
+		if pos == len(buf) {
+			break
+		}
+		if !i2c.waitOnFlagUntilTimeout(flagTCR, true, start) {
+			return errI2CWriteTimeout
+		}
+	}
+
+	switch after {
+	case i2cStop:
+		if !i2c.waitOnStopFlagUntilTimeout(start) {
+			return errI2CWriteTimeout
+		}
+		i2c.clearFlag(stm32.I2C_ISR_STOPF)
+		i2c.resetCR2()
+	case i2cRestart:
+		if !i2c.waitOnFlagUntilTimeout(stm32.I2C_ISR_TC, true, start) {
+			return errI2CWriteTimeout
+		}
+	}
+	return nil
+}
+
 func (i2c *I2C) configurePins(config I2CConfig) {
 	config.SCL.ConfigureAltFunc(PinConfig{Mode: PinModeI2CSCL}, i2c.AltFuncSelector)
 	config.SDA.ConfigureAltFunc(PinConfig{Mode: PinModeI2CSDA}, i2c.AltFuncSelector)
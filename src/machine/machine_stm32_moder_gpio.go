@@ -37,6 +37,7 @@ const (
 
 	// for PWM
 	PinModePWMOutput PinMode = 12
+	PinModePWMInput  PinMode = 13
 )
 
 // Define several bitfields that have different names across chip families but
@@ -144,6 +145,10 @@ func (p Pin) ConfigureAltFunc(config PinConfig, altFunc uint8) {
 		port.OSPEEDR.ReplaceBits(gpioOutputSpeedHigh, gpioOutputSpeedMask, pos)
 		port.PUPDR.ReplaceBits(gpioPullFloating, gpioPullMask, pos)
 		p.SetAltFunc(altFunc)
+	case PinModePWMInput:
+		port.MODER.ReplaceBits(gpioModeAlternate, gpioModeMask, pos)
+		port.PUPDR.ReplaceBits(gpioPullUp, gpioPullMask, pos)
+		p.SetAltFunc(altFunc)
 
 	// ADC
 	case PinInputAnalog:
@@ -151,6 +156,14 @@ func (p Pin) ConfigureAltFunc(config PinConfig, altFunc uint8) {
 	}
 }
 
+// SetSlewRate sets the speed of the edges of the pin when it is an output. The
+// speeds are the values of OSPEEDR. Configure sets a speed that depends on the
+// mode, so call SetSlewRate after it.
+func (p Pin) SetSlewRate(speed PinSpeed) {
+	pos := (uint8(p) % 16) * 2
+	p.getPort().OSPEEDR.ReplaceBits(uint32(speed), gpioOutputSpeedMask, pos)
+}
+
 // SetAltFunc maps the given alternative function to the I/O pin
 func (p Pin) SetAltFunc(af uint8) {
 	port := p.getPort()
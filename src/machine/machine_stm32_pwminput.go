@@ -0,0 +1,137 @@
+//go:build stm32 && !stm32f1
+// +build stm32,!stm32f1
+
+package machine
+
+import "device/stm32"
+
+// PWMInput measures the frequency and duty cycle of a signal, such as the
+// tachometer output of a fan, a servo signal or the output of a light sensor.
+// It uses the PWM input mode of a timer: both capture channels of the timer
+// see the signal, the first captures the time between rising edges (the
+// period) and the second the time until the falling edge (the pulse width).
+//
+//	tach := machine.PWMInput{Timer: &machine.TIM3, Pin: machine.PA6}
+//	tach.Configure(machine.PWMConfig{Period: 100e6})
+//	rpm := tach.Frequency() * 60 / 2 // two pulses per revolution
+//
+// The pin must be channel 1 or 2 of the timer, and the timer can't be used for
+// anything else. The pin has its pull-up enabled, for open collector outputs.
+type PWMInput struct {
+	Timer *TIM
+	Pin   Pin
+
+	period, width uint8 // capture channels
+	periodTicks   uint32
+	widthTicks    uint32
+}
+
+// Configure configures the timer and the pin. The Period of the config is the
+// longest period to measure: a slower signal, or a signal that stops, reads as
+// 0. The longer it is, the lower the resolution. It is 100ms (10Hz) when left
+// at 0.
+func (p *PWMInput) Configure(config PWMConfig) error {
+	t := p.Timer
+	var pf PinFunction
+	found := false
+	for chi := uint8(0); chi < 2 && !found; chi++ {
+		for _, f := range t.Channels[chi].Pins {
+			if f.Pin == p.Pin {
+				pf, p.period, p.width, found = f, chi, 1-chi, true
+				break
+			}
+		}
+	}
+	if !found {
+		return ErrInvalidInputPin
+	}
+
+	t.EnableRegister.SetBits(t.EnableFlag)
+	if config.Period == 0 {
+		config.Period = 100e6
+	}
+	if err := t.setPeriod(config.Period, true); err != nil {
+		return err
+	}
+	p.Pin.ConfigureAltFunc(PinConfig{Mode: PinModePWMInput}, pf.AltFunc)
+
+	// The capture channels can only be changed while they are off.
+	t.Device.CCER.Set(0)
+
+	// Capture the pin input (CCxS = 01) on the period channel and the input of
+	// the other channel (CCxS = 10) on the width channel. CCxS is at the
+	// same position in input mode as OCxM is in output mode.
+	ccmr := uint32(0b01)<<(p.period*8) | uint32(0b10)<<(p.width*8)
+	t.Device.CCMR1_Output.Set(ccmr)
+
+	// Period on the rising edge, width on the falling edge.
+	t.Device.CCER.Set(stm32.TIM_CCER_CC1E<<(p.period*4) |
+		(stm32.TIM_CCER_CC1E|stm32.TIM_CCER_CC1P)<<(p.width*4))
+
+	// Reset the counter on the rising edge of the pin (TI1FP1 or TI2FP2).
+	trigger := uint32(0b101) + uint32(p.period)
+	t.Device.SMCR.Set(trigger<<stm32.TIM_SMCR_TS_Pos | 0b100<<stm32.TIM_SMCR_SMS_Pos)
+
+	// Only let counter overflows, which mean there was no rising edge for a
+	// whole period, set the update flag: not the resets.
+	t.Device.CR1.SetBits(stm32.TIM_CR1_URS)
+	t.Device.EGR.SetBits(stm32.TIM_EGR_UG)
+	t.Device.SR.Set(0)
+	t.Device.CR1.SetBits(stm32.TIM_CR1_CEN)
+	p.periodTicks, p.widthTicks = 0, 0
+	return nil
+}
+
+// update reads the last capture, or notes the absence of a signal.
+func (p *PWMInput) update() {
+	t := p.Timer
+	sr := t.Device.SR.Get()
+	if sr&(stm32.TIM_SR_CC1IF<<p.period) != 0 {
+		// Reading the capture clears its flag.
+		p.periodTicks = uint32(t.channelCCR(p.period).Get())
+		p.widthTicks = uint32(t.channelCCR(p.width).Get())
+		t.Device.SR.ClearBits(stm32.TIM_SR_UIF)
+	} else if sr&stm32.TIM_SR_UIF != 0 {
+		p.periodTicks, p.widthTicks = 0, 0
+	}
+}
+
+// ticksToNanoseconds converts a number of timer ticks to nanoseconds.
+func (p *PWMInput) ticksToNanoseconds(ticks uint32) uint64 {
+	psc := uint64(p.Timer.Device.PSC.Get()) + 1
+	return uint64(ticks) * psc * 1e9 / p.Timer.busFreq
+}
+
+// Period returns the period of the signal in nanoseconds, or 0 if there is no
+// signal.
+func (p *PWMInput) Period() uint64 {
+	p.update()
+	return p.ticksToNanoseconds(p.periodTicks)
+}
+
+// PulseWidth returns the time the signal is high in each period, in
+// nanoseconds, or 0 if there is no signal.
+func (p *PWMInput) PulseWidth() uint64 {
+	p.update()
+	return p.ticksToNanoseconds(p.widthTicks)
+}
+
+// Frequency returns the frequency of the signal in Hz, or 0 if there is no
+// signal.
+func (p *PWMInput) Frequency() uint32 {
+	period := p.Period()
+	if period == 0 {
+		return 0
+	}
+	return uint32((1e9 + period/2) / period)
+}
+
+// DutyCycle returns the part of each period the signal is high, in hundredths
+// of a percent (0 to 10000), or 0 if there is no signal.
+func (p *PWMInput) DutyCycle() uint32 {
+	p.update()
+	if p.periodTicks == 0 || p.widthTicks > p.periodTicks {
+		return 0
+	}
+	return uint32(uint64(p.widthTicks) * 10000 / uint64(p.periodTicks))
+}
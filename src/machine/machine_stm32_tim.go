@@ -34,8 +34,14 @@ type TIM struct {
 	UpInterrupt    interrupt.Interrupt
 	OCInterrupt    interrupt.Interrupt
 
+	// ComplementaryChannels are the pins of the complementary outputs of the
+	// first three channels (CH1N to CH3N), on advanced timers.
+	ComplementaryChannels [3]TimerChannel
+
 	wraparoundCallback TimerCallback
 	channelCallbacks   [4]ChannelCallback
+	complementary      uint8 // channels with their complementary output in use
+	priority           uint8 // of the interrupts, as configured
 
 	busFreq uint64
 }
@@ -44,6 +50,7 @@ type TIM struct {
 func (t *TIM) Configure(config PWMConfig) error {
 	// Enable device
 	t.EnableRegister.SetBits(t.EnableFlag)
+	t.priority = config.Priority
 
 	err := t.setPeriod(config.Period, true)
 	if err != nil {
@@ -80,7 +87,7 @@ func (t *TIM) SetWraparoundInterrupt(callback TimerCallback) error {
 	t.Device.SR.ClearBits(stm32.TIM_SR_UIF)
 
 	t.wraparoundCallback = callback
-	t.UpInterrupt.SetPriority(0xc1)
+	t.UpInterrupt.SetPriority(interruptPriority(t.priority, 0xc1))
 	t.UpInterrupt.Enable()
 
 	// Enable the hardware interrupt
@@ -103,7 +110,7 @@ func (t *TIM) SetMatchInterrupt(channel uint8, callback ChannelCallback) error {
 	t.Device.SR.ClearBits(stm32.TIM_SR_CC1IF << channel)
 
 	// Enable the interrupt
-	t.OCInterrupt.SetPriority(0xc1)
+	t.OCInterrupt.SetPriority(interruptPriority(t.priority, 0xc1))
 	t.OCInterrupt.Enable()
 
 	// Enable the hardware interrupt
@@ -192,6 +199,59 @@ func (t *TIM) Channel(pin Pin) (uint8, error) {
 	return 0, ErrInvalidOutputPin
 }
 
+// ComplementaryChannel returns the PWM channel for the given pin, which must be
+// a complementary output (CH1N to CH3N) of an advanced timer. The pin outputs
+// the inverse of the channel output, with the dead time set by SetDeadTime
+// inserted at each edge, to drive the low side of a half-bridge.
+func (t *TIM) ComplementaryChannel(pin Pin) (uint8, error) {
+	for chi, ch := range t.ComplementaryChannels {
+		for _, p := range ch.Pins {
+			if p.Pin == pin {
+				t.configurePin(uint8(chi), p)
+				t.complementary |= 1 << chi
+				ccer := uint32(stm32.TIM_CCER_CC1NE)
+				if t.Device.CCER.HasBits(stm32.TIM_CCER_CC1P << (chi * 4)) {
+					// Keep the output the inverse of the inverted channel.
+					ccer |= stm32.TIM_CCER_CC1NP
+				}
+				t.Device.CCER.SetBits(ccer << (chi * 4))
+				return uint8(chi), nil
+			}
+		}
+	}
+
+	return 0, ErrInvalidOutputPin
+}
+
+// SetDeadTime sets the time, in nanoseconds, that both the channel output and
+// its complementary output stay inactive when they switch, so that the two
+// transistors of a half-bridge are never on together. The dead time applies to
+// all channels of the timer and is limited to 1008 ticks of the timer clock,
+// about 6µs at 168MHz.
+func (t *TIM) SetDeadTime(deadTime uint32) error {
+	if len(t.ComplementaryChannels[0].Pins) == 0 {
+		return ErrPWMNoDeadTime
+	}
+
+	// The dead time generator counts timer clock ticks, before the prescaler,
+	// with a step that gets coarser for longer dead times.
+	ticks := ceil(uint64(deadTime)*t.busFreq, 1e9)
+	var dtg uint64
+	switch {
+	case ticks <= 127:
+		dtg = ticks
+	case ticks <= 2*127:
+		dtg = 0x80 | (ceil(ticks, 2) - 64)
+	case ticks <= 8*63:
+		dtg = 0xc0 | (ceil(ticks, 8) - 32)
+	case ticks <= 16*63:
+		dtg = 0xe0 | (ceil(ticks, 16) - 32)
+	default:
+		return ErrPWMDeadTimeTooLong
+	}
+	return t.setDeadTime(uint32(dtg))
+}
+
 // Set updates the channel value. This is used to control the channel duty
 // cycle. For example, to set it to a 25% duty cycle, use:
 //
@@ -218,7 +278,11 @@ func (t *TIM) Set(channel uint8, value uint32) {
 	ccr.Set(arrtype(value))
 
 	// Enable the channel (if not already)
-	t.Device.CCER.ReplaceBits(stm32.TIM_CCER_CC1E, 0xD, channel*4)
+	ccer := uint32(stm32.TIM_CCER_CC1E)
+	if t.complementary&(1<<channel) != 0 {
+		ccer |= stm32.TIM_CCER_CC1NE
+	}
+	t.Device.CCER.ReplaceBits(ccer, stm32.TIM_CCER_CC1E|stm32.TIM_CCER_CC1NE, channel*4)
 
 	// Force update
 	t.Device.EGR.SetBits(stm32.TIM_EGR_CC1G << channel)
@@ -261,11 +325,20 @@ func (t *TIM) SetInverting(channel uint8, inverting bool) {
 	// Enable the channel (if not already)
 
 	var val = uint32(0)
+	var mask = uint32(stm32.TIM_CCER_CC1P_Msk)
+	if t.complementary&(1<<channel) != 0 {
+		// Invert the complementary output too, so that it stays the inverse
+		// of the channel output.
+		mask |= stm32.TIM_CCER_CC1NP_Msk
+		if inverting {
+			val |= stm32.TIM_CCER_CC1NP
+		}
+	}
 	if inverting {
 		val |= stm32.TIM_CCER_CC1P
 	}
 
-	t.Device.CCER.ReplaceBits(val, stm32.TIM_CCER_CC1P_Msk, channel*4)
+	t.Device.CCER.ReplaceBits(val, mask, channel*4)
 }
 
 func (t *TIM) handleUPInterrupt(interrupt.Interrupt) {
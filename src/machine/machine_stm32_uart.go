@@ -57,7 +57,7 @@ func (uart *UART) Configure(config UARTConfig) {
 	uart.Bus.CR1.Set(stm32.USART_CR1_TE | stm32.USART_CR1_RE | stm32.USART_CR1_RXNEIE | stm32.USART_CR1_UE)
 
 	// Enable RX IRQ
-	uart.Interrupt.SetPriority(0xc0)
+	uart.Interrupt.SetPriority(interruptPriority(config.Priority, 0xc0))
 	uart.Interrupt.Enable()
 }
 
@@ -0,0 +1,23 @@
+//go:build stm32f7 || stm32l0 || stm32l4 || stm32l5 || stm32wlx
+// +build stm32f7 stm32l0 stm32l4 stm32l5 stm32wlx
+
+package machine
+
+import "device/stm32"
+
+// ConfigureSBUS configures the UART to receive SBUS from an RC receiver, see
+// SBUSDecoder: 100000 baud, 8 data bits with even parity and 2 stop bits. The
+// USART inverts its RX input, so that no external inverter is needed.
+func (uart *UART) ConfigureSBUS(config UARTConfig) error {
+	config.BaudRate = 100000
+	uart.Configure(config)
+
+	// The frame format can only be changed while the USART is disabled. The
+	// parity bit counts as a data bit, which makes it a 9 bit word.
+	uart.Bus.CR1.ClearBits(stm32.USART_CR1_UE)
+	uart.Bus.CR2.ReplaceBits(2<<stm32.USART_CR2_STOP_Pos|stm32.USART_CR2_RXINV,
+		stm32.USART_CR2_STOP_Msk|stm32.USART_CR2_RXINV, 0)
+	uart.Bus.CR1.ClearBits(stm32.USART_CR1_PS)
+	uart.Bus.CR1.SetBits(stm32.USART_CR1_M0 | stm32.USART_CR1_PCE | stm32.USART_CR1_UE)
+	return nil
+}
@@ -472,6 +472,16 @@ var (
 			TimerChannel{Pins: []PinFunction{{PE13, 0b11}, {PA10, 0b00}}},
 			TimerChannel{Pins: []PinFunction{{PE14, 0b11}, {PA11, 0b00}}},
 		},
+		// The remapping applies to all the pins of the timer: the
+		// complementary outputs on PB13 to PB15 and on PA7, PB0 and PB1 go
+		// with the channels on PA8 to PA11, and those on PE8, PE10 and PE12
+		// with the channels on PE9 to PE14. Configure the channels first, as
+		// the partial remapping of PA7, PB0 and PB1 keeps PA8 to PA11.
+		ComplementaryChannels: [3]TimerChannel{
+			TimerChannel{Pins: []PinFunction{{PB13, 0b00}, {PA7, 0b01}, {PE8, 0b11}}},
+			TimerChannel{Pins: []PinFunction{{PB14, 0b00}, {PB0, 0b01}, {PE10, 0b11}}},
+			TimerChannel{Pins: []PinFunction{{PB15, 0b00}, {PB1, 0b01}, {PE12, 0b11}}},
+		},
 		busFreq: APB2_TIM_FREQ,
 	}
 
@@ -724,6 +734,11 @@ func (t *TIM) enableMainOutput() {
 	t.Device.BDTR.SetBits(stm32.TIM_BDTR_MOE)
 }
 
+func (t *TIM) setDeadTime(dtg uint32) error {
+	t.Device.BDTR.ReplaceBits(dtg, stm32.TIM_BDTR_DTG_Msk, 0)
+	return nil
+}
+
 type arrtype = uint32
 type arrRegType = volatile.Register32
 
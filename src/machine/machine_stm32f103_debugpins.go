@@ -0,0 +1,38 @@
+//go:build stm32f103
+// +build stm32f103
+
+package machine
+
+import "device/stm32"
+
+// Values of the SWJ_CFG field of AFIO_MAPR.
+const (
+	afioSWJFull        = 0b000 // JTAG and SWD enabled
+	afioSWJNoJTAG      = 0b010 // only SWD enabled
+	afioSWJDisabledAll = 0b100 // JTAG and SWD disabled
+)
+
+// DisableDebugPins disables both JTAG and SWD, which frees PA13, PA14, PA15,
+// PB3 and PB4 for use as GPIOs. See DisableJTAGPins to keep SWD available.
+func DisableDebugPins() error {
+	setSWJConfig(afioSWJDisabledAll)
+	return nil
+}
+
+// DisableJTAGPins disables JTAG but keeps SWD, which frees PA15, PB3 and PB4
+// for use as GPIOs while a debugger can still attach through SWD.
+func DisableJTAGPins() error {
+	setSWJConfig(afioSWJNoJTAG)
+	return nil
+}
+
+// EnableDebugPins enables JTAG and SWD again, as they are after reset.
+func EnableDebugPins() error {
+	setSWJConfig(afioSWJFull)
+	return nil
+}
+
+func setSWJConfig(cfg uint32) {
+	stm32.RCC.APB2ENR.SetBits(stm32.RCC_APB2ENR_AFIOEN)
+	stm32.AFIO.MAPR.ReplaceBits(cfg, stm32.AFIO_MAPR_SWJ_CFG_Msk>>stm32.AFIO_MAPR_SWJ_CFG_Pos, stm32.AFIO_MAPR_SWJ_CFG_Pos)
+}
@@ -356,6 +356,11 @@ var (
 			TimerChannel{Pins: []PinFunction{{PA10, AF1_TIM1_2}, {PE13, AF1_TIM1_2}}},
 			TimerChannel{Pins: []PinFunction{{PA11, AF1_TIM1_2}, {PE14, AF1_TIM1_2}}},
 		},
+		ComplementaryChannels: [3]TimerChannel{
+			TimerChannel{Pins: []PinFunction{{PA7, AF1_TIM1_2}, {PB13, AF1_TIM1_2}, {PE8, AF1_TIM1_2}}},
+			TimerChannel{Pins: []PinFunction{{PB0, AF1_TIM1_2}, {PB14, AF1_TIM1_2}, {PE10, AF1_TIM1_2}}},
+			TimerChannel{Pins: []PinFunction{{PB1, AF1_TIM1_2}, {PB15, AF1_TIM1_2}, {PE12, AF1_TIM1_2}}},
+		},
 		busFreq: APB2_TIM_FREQ,
 	}
 
@@ -447,6 +452,11 @@ var (
 			TimerChannel{Pins: []PinFunction{{PC8, AF3_TIM8_9_10_11}, {PI7, AF3_TIM8_9_10_11}}},
 			TimerChannel{Pins: []PinFunction{{PC9, AF3_TIM8_9_10_11}, {PI2, AF3_TIM8_9_10_11}}},
 		},
+		ComplementaryChannels: [3]TimerChannel{
+			TimerChannel{Pins: []PinFunction{{PA5, AF3_TIM8_9_10_11}, {PA7, AF3_TIM8_9_10_11}, {PH13, AF3_TIM8_9_10_11}}},
+			TimerChannel{Pins: []PinFunction{{PB0, AF3_TIM8_9_10_11}, {PB14, AF3_TIM8_9_10_11}, {PH14, AF3_TIM8_9_10_11}}},
+			TimerChannel{Pins: []PinFunction{{PB1, AF3_TIM8_9_10_11}, {PB15, AF3_TIM8_9_10_11}, {PH15, AF3_TIM8_9_10_11}}},
+		},
 		busFreq: APB2_TIM_FREQ,
 	}
 
@@ -603,6 +613,11 @@ func (t *TIM) enableMainOutput() {
 	t.Device.BDTR.SetBits(stm32.TIM_BDTR_MOE)
 }
 
+func (t *TIM) setDeadTime(dtg uint32) error {
+	t.Device.BDTR.ReplaceBits(dtg, stm32.TIM_BDTR_DTG_Msk, 0)
+	return nil
+}
+
 type arrtype = uint32
 type arrRegType = volatile.Register32
 
@@ -376,6 +376,23 @@ var (
 				{PE14, AF1_TIM1_2},
 			}},
 		},
+		ComplementaryChannels: [3]TimerChannel{
+			TimerChannel{Pins: []PinFunction{
+				{PA7, AF1_TIM1_2},
+				{PB13, AF1_TIM1_2},
+				{PE8, AF1_TIM1_2},
+			}},
+			TimerChannel{Pins: []PinFunction{
+				{PB0, AF1_TIM1_2},
+				{PB14, AF1_TIM1_2},
+				{PE10, AF1_TIM1_2},
+			}},
+			TimerChannel{Pins: []PinFunction{
+				{PB1, AF1_TIM1_2},
+				{PB15, AF1_TIM1_2},
+				{PE12, AF1_TIM1_2},
+			}},
+		},
 		busFreq: APB2_TIM_FREQ,
 	}
 
@@ -530,6 +547,23 @@ var (
 				{PI2, AF3_TIM8_9_10_11_LPTIM1},
 			}},
 		},
+		ComplementaryChannels: [3]TimerChannel{
+			TimerChannel{Pins: []PinFunction{
+				{PA5, AF3_TIM8_9_10_11_LPTIM1},
+				{PA7, AF3_TIM8_9_10_11_LPTIM1},
+				{PH13, AF3_TIM8_9_10_11_LPTIM1},
+			}},
+			TimerChannel{Pins: []PinFunction{
+				{PB0, AF3_TIM8_9_10_11_LPTIM1},
+				{PB14, AF3_TIM8_9_10_11_LPTIM1},
+				{PH14, AF3_TIM8_9_10_11_LPTIM1},
+			}},
+			TimerChannel{Pins: []PinFunction{
+				{PB1, AF3_TIM8_9_10_11_LPTIM1},
+				{PB15, AF3_TIM8_9_10_11_LPTIM1},
+				{PH15, AF3_TIM8_9_10_11_LPTIM1},
+			}},
+		},
 		busFreq: APB2_TIM_FREQ,
 	}
 
@@ -710,6 +744,11 @@ func (t *TIM) enableMainOutput() {
 	t.Device.BDTR.SetBits(stm32.TIM_BDTR_MOE)
 }
 
+func (t *TIM) setDeadTime(dtg uint32) error {
+	t.Device.BDTR.ReplaceBits(dtg, stm32.TIM_BDTR_DTG_Msk, 0)
+	return nil
+}
+
 type arrtype = uint32
 type arrRegType = volatile.Register32
 
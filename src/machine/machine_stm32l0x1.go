@@ -189,6 +189,11 @@ func (t *TIM) enableMainOutput() {
 	// nothing to do - no BDTR register
 }
 
+func (t *TIM) setDeadTime(dtg uint32) error {
+	// no BDTR register
+	return ErrPWMNoDeadTime
+}
+
 type arrtype = uint16
 type arrRegType = volatile.Register16
 
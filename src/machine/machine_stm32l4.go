@@ -528,6 +528,11 @@ func (t *TIM) enableMainOutput() {
 	// nothing to do - no BDTR register
 }
 
+func (t *TIM) setDeadTime(dtg uint32) error {
+	// no BDTR register
+	return ErrPWMNoDeadTime
+}
+
 type arrtype = uint32
 type arrRegType = volatile.Register32
 
@@ -540,6 +540,11 @@ func (t *TIM) enableMainOutput() {
 	t.Device.BDTR.SetBits(stm32.TIM_BDTR_MOE)
 }
 
+func (t *TIM) setDeadTime(dtg uint32) error {
+	t.Device.BDTR.ReplaceBits(dtg, stm32.TIM_BDTR_DTG_Msk, 0)
+	return nil
+}
+
 type arrtype = uint32
 type arrRegType = volatile.Register32
 
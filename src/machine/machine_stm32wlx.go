@@ -406,6 +406,11 @@ func (t *TIM) enableMainOutput() {
 	t.Device.BDTR.SetBits(stm32.TIM_BDTR_MOE)
 }
 
+func (t *TIM) setDeadTime(dtg uint32) error {
+	t.Device.BDTR.ReplaceBits(dtg, stm32.TIM_BDTR_DTG_Msk, 0)
+	return nil
+}
+
 func initRNG() {
 	stm32.RCC.AHB3ENR.SetBits(stm32.RCC_AHB3ENR_RNGEN)
 	stm32.RNG.CR.SetBits(stm32.RNG_CR_RNGEN)
@@ -0,0 +1,185 @@
+package machine
+
+import "errors"
+
+// modbusTimedPort is a port that times the received bytes itself, like
+// *UART, so that the frames are told apart however late Update is called.
+type modbusTimedPort interface {
+	SetFrameGap(gap int64)
+	ReadFrameByte() (c byte, start bool, err error)
+	LineIdle() bool
+}
+
+// ModbusRTU splits the bytes received on a serial port into Modbus RTU
+// frames. Modbus RTU has no start or end markers: a frame ends when the line
+// stays idle for 3.5 characters, so the frames can only be told apart by
+// timing the received bytes. ModbusRTU checks the CRC of every frame and passes
+// the valid ones on, without their CRC.
+//
+//	bus := machine.ModbusRTU{Port: machine.UART1, BaudRate: 19200, Handler: handleRequest}
+//	for {
+//		bus.Update()
+//		time.Sleep(10 * time.Millisecond)
+//	}
+//
+// A *UART times the bytes in its receive interrupt (see UART.SetFrameGap), so
+// Update only needs to be called before the receive buffer of the UART fills
+// up. Other ports are timed by Update, which then must be called more often
+// than the 3.5 character idle time (2ms at 19200 baud).
+type ModbusRTU struct {
+	// Port is usually a UART with an RS-485 transceiver.
+	Port SerialPort
+
+	// BaudRate of the port, for the idle time. Modbus uses 11 bits per
+	// character: 8 data bits, a parity bit or a second stop bit, and the
+	// start and stop bits.
+	BaudRate uint32
+
+	// Handler is called by Update for every valid frame received: the
+	// address, function code and data. The slice is only valid during the
+	// call.
+	Handler func(frame []byte)
+
+	frame   [256]byte
+	n       int
+	dropped bool  // the frame is too long, and is dropped
+	last    int64 // time the last byte was seen, in nanoseconds
+
+	timed      modbusTimedPort // Port, if it times the bytes itself
+	timedSetUp bool
+}
+
+var errModbusFrameSize = errors.New("modbus: frame too long")
+
+// idle returns the 3.5 character time in nanoseconds that ends a frame. It is
+// fixed at 1.75ms above 19200 baud, as the spec says.
+func (m *ModbusRTU) idle() int64 {
+	if m.BaudRate == 0 || m.BaudRate > 19200 {
+		return 1750e3
+	}
+	return 35 * 1e9 / 10 * 11 / int64(m.BaudRate)
+}
+
+// timedPort returns Port if it times the received bytes itself, after setting
+// its frame gap the first time.
+func (m *ModbusRTU) timedPort() modbusTimedPort {
+	if !m.timedSetUp {
+		m.timedSetUp = true
+		if port, ok := m.Port.(modbusTimedPort); ok {
+			port.SetFrameGap(m.idle())
+			m.timed = port
+		}
+	}
+	return m.timed
+}
+
+// Update reads the bytes received since the last call, and calls Handler for
+// every frame that the line being idle long enough has ended.
+func (m *ModbusRTU) Update() {
+	if port := m.timedPort(); port != nil {
+		for m.Port.Buffered() > 0 {
+			c, start, err := port.ReadFrameByte()
+			if err != nil {
+				break
+			}
+			if start {
+				m.endFrame()
+			}
+			m.put(c)
+		}
+		if port.LineIdle() {
+			m.endFrame()
+		}
+		return
+	}
+
+	now := nanotime()
+	received := false
+	for m.Port.Buffered() > 0 {
+		c, err := m.Port.ReadByte()
+		if err != nil {
+			break
+		}
+		received = true
+		m.put(c)
+	}
+	if received {
+		m.last = now
+		return
+	}
+	if now-m.last >= m.idle() {
+		m.endFrame()
+	}
+}
+
+// put adds a received byte to the frame.
+func (m *ModbusRTU) put(c byte) {
+	if m.n == len(m.frame) {
+		m.dropped = true
+		return
+	}
+	m.frame[m.n] = c
+	m.n++
+}
+
+// endFrame passes the frame received so far to Handler if its CRC is valid,
+// and starts the next one.
+func (m *ModbusRTU) endFrame() {
+	if m.n == 0 && !m.dropped {
+		return
+	}
+	frame := m.frame[:m.n]
+	dropped := m.dropped
+	m.n, m.dropped = 0, false
+	if dropped || len(frame) < 4 {
+		return
+	}
+	crc := modbusCRC(frame[:len(frame)-2])
+	if frame[len(frame)-2] != byte(crc) || frame[len(frame)-1] != byte(crc>>8) {
+		return
+	}
+	if m.Handler != nil {
+		m.Handler(frame[:len(frame)-2])
+	}
+}
+
+// WriteFrame sends a frame: the address, function code and data, to which it
+// appends the CRC. It waits for the line to be idle for 3.5 characters first,
+// so frame must be at most 254 bytes.
+func (m *ModbusRTU) WriteFrame(frame []byte) error {
+	if len(frame) > len(m.frame)-2 {
+		return errModbusFrameSize
+	}
+	if port := m.timedPort(); port != nil {
+		for !port.LineIdle() {
+			gosched()
+		}
+	}
+	for nanotime()-m.last < m.idle() {
+		gosched()
+	}
+	crc := modbusCRC(frame)
+	if _, err := m.Port.Write(frame); err != nil {
+		return err
+	}
+	trailer := [2]byte{byte(crc), byte(crc >> 8)}
+	_, err := m.Port.Write(trailer[:])
+	m.last = nanotime()
+	return err
+}
+
+// modbusCRC returns the CRC-16/MODBUS of data, which is sent low byte first.
+func modbusCRC(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,195 @@
+//go:build sam || nrf || rp2040 || stm32
+// +build sam nrf rp2040 stm32
+
+package machine
+
+import (
+	"errors"
+	"runtime/interrupt"
+	"runtime/volatile"
+)
+
+var (
+	ErrModemPowerTimeout = errors.New("machine: timeout waiting for modem power status")
+	ErrModemNoPowerKey   = errors.New("machine: modem has no power key pin")
+)
+
+// ModemTiming describes the power key and reset sequencing of a cellular
+// modem. All durations are in milliseconds.
+type ModemTiming struct {
+	PowerOnPulse  uint32 // time PWRKEY must be held active to power on
+	PowerOffPulse uint32 // time PWRKEY must be held active to power off
+	ResetPulse    uint32 // time RESET must be held active to reset
+	StatusTimeout uint32 // maximum time for STATUS to change after a pulse
+}
+
+// Timing for common LTE-M modules, taken from the module datasheets.
+var (
+	ModemSARAR4 = ModemTiming{
+		PowerOnPulse:  200,
+		PowerOffPulse: 2000,
+		ResetPulse:    10000,
+		StatusTimeout: 5000,
+	}
+	ModemSIM7000 = ModemTiming{
+		PowerOnPulse:  1000,
+		PowerOffPulse: 1300,
+		ResetPulse:    300,
+		StatusTimeout: 5000,
+	}
+)
+
+// Modem handles power, reset and ring indicator wake-up of a cellular modem
+// that is attached to a UART, such as a u-blox SARA-R4 or a SIMCom SIM7000.
+// The UART itself must be configured separately, with hardware flow control
+// where the chip supports it (see UART.EnableFlowControl).
+//
+// The PWRKEY and RESET inputs of these modules are active low. Many boards
+// drive them through a transistor, which inverts the signal; set Inverted in
+// that case.
+type Modem struct {
+	PWRKEY Pin
+	RESET  Pin
+
+	// STATUS is high while the modem is powered (V_INT on u-blox modules,
+	// STATUS on SIMCom modules). Use NoPin if it is not connected.
+	STATUS Pin
+
+	// RI is the ring indicator, which the modem pulls low for an incoming
+	// call, SMS or URC. Use NoPin if it is not connected.
+	RI Pin
+
+	Inverted bool
+	Timing   ModemTiming
+
+	ringHandler func()
+	ring        volatile.Register8
+}
+
+// Configure sets up the pins of the modem, leaving PWRKEY and RESET
+// inactive. It does not power on the modem.
+func (m *Modem) Configure() {
+	if m.PWRKEY != NoPin {
+		m.PWRKEY.Configure(PinConfig{Mode: PinOutput})
+		m.release(m.PWRKEY)
+	}
+	if m.RESET != NoPin {
+		m.RESET.Configure(PinConfig{Mode: PinOutput})
+		m.release(m.RESET)
+	}
+	if m.STATUS != NoPin {
+		m.STATUS.Configure(PinConfig{Mode: PinInput})
+	}
+	if m.RI != NoPin {
+		m.RI.Configure(PinConfig{Mode: PinInputPullup})
+	}
+}
+
+// Powered returns whether the modem is powered on. Without a STATUS pin it
+// always returns true.
+func (m *Modem) Powered() bool {
+	if m.STATUS == NoPin {
+		return true
+	}
+	return m.STATUS.Get()
+}
+
+// PowerOn pulses PWRKEY to turn on the modem and waits until STATUS reports
+// that it is powered. It does nothing if the modem is already on.
+func (m *Modem) PowerOn() error {
+	if m.STATUS != NoPin && m.Powered() {
+		return nil
+	}
+	if m.PWRKEY == NoPin {
+		return ErrModemNoPowerKey
+	}
+	m.pulse(m.PWRKEY, m.Timing.PowerOnPulse)
+	return m.waitStatus(true)
+}
+
+// PowerOff pulses PWRKEY to turn off the modem and waits until STATUS reports
+// that it is off. Prefer a graceful AT command shutdown where possible.
+func (m *Modem) PowerOff() error {
+	if m.STATUS != NoPin && !m.Powered() {
+		return nil
+	}
+	if m.PWRKEY == NoPin {
+		return ErrModemNoPowerKey
+	}
+	m.pulse(m.PWRKEY, m.Timing.PowerOffPulse)
+	return m.waitStatus(false)
+}
+
+// Reset pulses RESET to restart the modem. If there is no RESET pin, the
+// modem is power cycled instead.
+func (m *Modem) Reset() error {
+	if m.RESET == NoPin {
+		if err := m.PowerOff(); err != nil {
+			return err
+		}
+		return m.PowerOn()
+	}
+	m.pulse(m.RESET, m.Timing.ResetPulse)
+	return m.waitStatus(true)
+}
+
+// EnableRingInterrupt enables the pin interrupt on RI. The handler, which may
+// be nil, is called from the interrupt when the modem asserts RI, for example
+// to wake up the main loop. RingPending also reports these events.
+func (m *Modem) EnableRingInterrupt(handler func()) error {
+	if m.RI == NoPin {
+		return nil
+	}
+	m.ringHandler = handler
+	return m.RI.SetInterrupt(PinFalling, func(Pin) {
+		m.ring.Set(1)
+		if m.ringHandler != nil {
+			m.ringHandler()
+		}
+	})
+}
+
+// DisableRingInterrupt disables the pin interrupt on RI.
+func (m *Modem) DisableRingInterrupt() error {
+	if m.RI == NoPin {
+		return nil
+	}
+	m.ringHandler = nil
+	return m.RI.SetInterrupt(PinFalling, nil)
+}
+
+// RingPending returns whether RI was asserted since the last call, and clears
+// the flag. EnableRingInterrupt must have been called first.
+func (m *Modem) RingPending() bool {
+	// A ring between the read and the clear of the flag would be lost.
+	mask := interrupt.Disable()
+	pending := m.ring.Get() != 0
+	m.ring.Set(0)
+	interrupt.Restore(mask)
+	return pending
+}
+
+// pulse drives the given active low input of the modem for ms milliseconds.
+func (m *Modem) pulse(pin Pin, ms uint32) {
+	pin.Set(m.Inverted)
+	sleepMicroseconds(int64(ms) * 1000)
+	m.release(pin)
+}
+
+func (m *Modem) release(pin Pin) {
+	pin.Set(!m.Inverted)
+}
+
+func (m *Modem) waitStatus(on bool) error {
+	if m.STATUS == NoPin {
+		return nil
+	}
+	deadline := nanotime() + int64(m.Timing.StatusTimeout)*1000_000
+	for m.STATUS.Get() != on {
+		if nanotime() > deadline {
+			return ErrModemPowerTimeout
+		}
+		gosched()
+	}
+	return nil
+}
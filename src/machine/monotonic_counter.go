@@ -0,0 +1,83 @@
+package machine
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// MonotonicCounter is a counter in flash that can only go up, for anti-rollback
+// checks: an OTA update raises the counter to the security version of the new
+// firmware once it boots, and the bootloader or the update code refuses images
+// with a lower version.
+//
+//	counter := machine.MonotonicCounter{Device: flash}
+//	counter.Configure()
+//	if imageVersion < counter.Value() {
+//		return errRollback
+//	}
+//	counter.Raise(imageVersion)
+//
+// The counter is stored as an EventLog of values on the whole device, which
+// needs at least two erase blocks, so that the last value survives a power loss
+// while the counter is written and the flash wears out evenly. It only stops
+// code that goes through the counter: code that can erase the device can reset
+// it.
+type MonotonicCounter struct {
+	Device BlockDevice
+
+	log   EventLog
+	value uint32
+	buf   [4]byte
+}
+
+var (
+	errCounterDecrease = errors.New("counter: value lower than the current value")
+	errCounterOverflow = errors.New("counter: value at its maximum")
+)
+
+// Configure reads the counter from the device. It must be called before the
+// other methods. An erased device holds a counter of zero.
+func (c *MonotonicCounter) Configure() error {
+	c.log = EventLog{Device: c.Device, RecordSize: len(c.buf)}
+	if err := c.log.Configure(); err != nil {
+		return err
+	}
+	c.value = 0
+	return c.log.Iterate(func(seq uint32, record []byte) bool {
+		if v := binary.LittleEndian.Uint32(record); v > c.value {
+			c.value = v
+		}
+		return true
+	})
+}
+
+// Value returns the current value of the counter.
+func (c *MonotonicCounter) Value() uint32 {
+	return c.value
+}
+
+// Increment adds one to the counter, and returns the new value.
+func (c *MonotonicCounter) Increment() (uint32, error) {
+	if c.value == 0xffffffff {
+		return c.value, errCounterOverflow
+	}
+	err := c.Raise(c.value + 1)
+	return c.value, err
+}
+
+// Raise sets the counter to value, which must not be lower than the current
+// value. Raising the counter to its current value doesn't write to the device.
+func (c *MonotonicCounter) Raise(value uint32) error {
+	if value < c.value {
+		return errCounterDecrease
+	}
+	if value == c.value {
+		return nil
+	}
+	binary.LittleEndian.PutUint32(c.buf[:], value)
+	if err := c.log.Append(c.buf[:]); err != nil {
+		return err
+	}
+	c.value = value
+	return nil
+}
@@ -0,0 +1,199 @@
+//go:build !gameboyadvance
+// +build !gameboyadvance
+
+package machine
+
+import "errors"
+
+// MotorPWM is the part of a PWM peripheral that a MotorDriver uses. The PWM
+// types of most chips implement it.
+type MotorPWM interface {
+	Configure(config PWMConfig) error
+	Channel(pin Pin) (uint8, error)
+	Top() uint32
+	Set(channel uint8, value uint32)
+	SetInverting(channel uint8, inverting bool)
+}
+
+// motorDeadTimePWM is the part of a PWM peripheral with complementary outputs
+// and a dead time generator that a MotorDriver uses for the low sides of a
+// bridge, like the advanced timers of the STM32.
+type motorDeadTimePWM interface {
+	ComplementaryChannel(pin Pin) (uint8, error)
+	SetDeadTime(deadTime uint32) error
+}
+
+// MotorMode is the way a MotorDriver drives an H-bridge.
+type MotorMode uint8
+
+const (
+	// MotorPhaseEnable drives the PWM signal on A, and the direction on B,
+	// for drivers with an enable (or PWM) and a phase (or direction) input,
+	// like the DRV8838 or the L298 with IN1 and IN2 wired through an
+	// inverter.
+	MotorPhaseEnable MotorMode = iota
+
+	// MotorInIn drives a PWM signal on A to go forward and on B to go
+	// backward, for drivers with two inputs like the DRV8833 and DRV8871.
+	// A and B must be channels of the same PWM peripheral.
+	MotorInIn
+
+	// MotorLockedAntiphase drives opposite PWM signals on A and B, where a 50%
+	// duty cycle stops the motor and higher or lower duty cycles turn it one
+	// way or the other. The motor is driven all the time, which gives good
+	// control at low speed. A and B must be channels of the same PWM
+	// peripheral.
+	MotorLockedAntiphase
+)
+
+// MotorMaxSpeed is the speed of a motor at full power. Speeds range from
+// -MotorMaxSpeed (full power backward) to MotorMaxSpeed.
+const MotorMaxSpeed = 1000
+
+var errMotorSpeed = errors.New("motor: speed out of range")
+
+// MotorDriver controls a DC motor with an H-bridge driver chip.
+//
+//	motor := machine.MotorDriver{PWM: machine.PWM1, Mode: machine.MotorInIn, A: machine.GPIO2, B: machine.GPIO3}
+//	motor.Configure()
+//	motor.SetSpeed(500) // half power forward
+//
+// The PWM frequency is 20kHz, above the range of hearing.
+//
+// A bridge built from half-bridges with separate high and low side inputs
+// needs the low sides driven by the complementary outputs of the PWM, with
+// a dead time between the two sides so that they are never on together. Set
+// DeadTime and the complementary pins ALow and BLow for that:
+//
+//	motor := machine.MotorDriver{
+//		PWM: &machine.TIM1, Mode: machine.MotorLockedAntiphase,
+//		A: machine.PA8, ALow: machine.PA7,
+//		B: machine.PA9, BLow: machine.PB0,
+//		DeadTime: 500,
+//	}
+type MotorDriver struct {
+	PWM  MotorPWM
+	Mode MotorMode
+	A, B Pin
+
+	// DeadTime is the time, in nanoseconds, between switching off one side
+	// of a half-bridge and switching on the other. If it is nonzero, ALow
+	// (and BLow, except in MotorPhaseEnable mode) must be the complementary
+	// outputs of the channels of A and B, on a PWM with a dead time
+	// generator; Configure returns ErrPWMNoDeadTime for other PWMs.
+	DeadTime   uint32
+	ALow, BLow Pin
+
+	chA, chB uint8
+}
+
+// Configure configures the PWM peripheral and the pins, and stops the motor.
+func (m *MotorDriver) Configure() error {
+	err := m.PWM.Configure(PWMConfig{Period: 1e9 / 20000})
+	if err != nil {
+		return err
+	}
+	m.chA, err = m.PWM.Channel(m.A)
+	if err != nil {
+		return err
+	}
+	switch m.Mode {
+	case MotorPhaseEnable:
+		m.B.Configure(PinConfig{Mode: PinOutput})
+	default:
+		m.chB, err = m.PWM.Channel(m.B)
+		if err != nil {
+			return err
+		}
+		m.PWM.SetInverting(m.chB, m.Mode == MotorLockedAntiphase)
+	}
+	if m.DeadTime != 0 {
+		if err := m.configureLowSides(); err != nil {
+			return err
+		}
+	}
+	m.Coast()
+	return nil
+}
+
+// configureLowSides sets the dead time and the complementary outputs of the
+// channels of A and B.
+func (m *MotorDriver) configureLowSides() error {
+	pwm, ok := m.PWM.(motorDeadTimePWM)
+	if !ok {
+		return ErrPWMNoDeadTime
+	}
+	if err := pwm.SetDeadTime(m.DeadTime); err != nil {
+		return err
+	}
+	ch, err := pwm.ComplementaryChannel(m.ALow)
+	if err != nil {
+		return err
+	}
+	if ch != m.chA {
+		return ErrInvalidOutputPin
+	}
+	if m.Mode == MotorPhaseEnable {
+		return nil
+	}
+	ch, err = pwm.ComplementaryChannel(m.BLow)
+	if err != nil {
+		return err
+	}
+	if ch != m.chB {
+		return ErrInvalidOutputPin
+	}
+	return nil
+}
+
+// SetSpeed drives the motor from -MotorMaxSpeed (full power backward) to
+// MotorMaxSpeed (full power forward).
+func (m *MotorDriver) SetSpeed(speed int16) error {
+	if speed < -MotorMaxSpeed || speed > MotorMaxSpeed {
+		return errMotorSpeed
+	}
+	top := m.PWM.Top()
+	magnitude := int32(speed)
+	if magnitude < 0 {
+		magnitude = -magnitude
+	}
+	duty := uint32(uint64(top) * uint64(magnitude) / MotorMaxSpeed)
+	switch m.Mode {
+	case MotorPhaseEnable:
+		m.B.Set(speed < 0)
+		m.PWM.Set(m.chA, duty)
+	case MotorInIn:
+		if speed >= 0 {
+			m.PWM.Set(m.chB, 0)
+			m.PWM.Set(m.chA, duty)
+		} else {
+			m.PWM.Set(m.chA, 0)
+			m.PWM.Set(m.chB, duty)
+		}
+	case MotorLockedAntiphase:
+		// B is inverted, so the same duty cycle on both channels makes
+		// opposite signals.
+		duty = uint32(int64(top)/2 + int64(top)*int64(speed)/(2*MotorMaxSpeed))
+		m.PWM.Set(m.chA, duty)
+		m.PWM.Set(m.chB, duty)
+	}
+	return nil
+}
+
+// Coast lets the motor spin freely. In MotorLockedAntiphase mode, which always
+// drives the motor, it brakes instead.
+func (m *MotorDriver) Coast() {
+	m.SetSpeed(0)
+}
+
+// Brake shorts the motor, which stops it quickly. In MotorPhaseEnable mode,
+// where the driver decides what happens with the enable input low, it is the
+// same as Coast.
+func (m *MotorDriver) Brake() {
+	if m.Mode == MotorInIn {
+		m.PWM.Set(m.chA, m.PWM.Top())
+		m.PWM.Set(m.chB, m.PWM.Top())
+		return
+	}
+	m.SetSpeed(0)
+}
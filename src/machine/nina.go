@@ -0,0 +1,129 @@
+//go:build sam || nrf || rp2040
+// +build sam nrf rp2040
+
+package machine
+
+import (
+	"errors"
+	"runtime/volatile"
+)
+
+var (
+	ErrNINATimeout = errors.New("machine: timeout waiting for WiFi co-processor")
+)
+
+// NINA is the SPI transport to a WiFi co-processor running the NINA firmware,
+// like the u-blox NINA-W102 on Arduino boards or the ESP32 on Adafruit AirLift
+// boards. It implements the reset sequence and the handshake on the ACK (also
+// called BUSY or READY) pin, on top of which the WiFi command protocol runs.
+//
+// If possible, the ACK pin is watched with a pin interrupt so that waiting
+// for the co-processor lets other goroutines run instead of polling the pin.
+type NINA struct {
+	Bus interface {
+		Tx(w, r []byte) error
+	}
+	CS     Pin
+	ACK    Pin
+	GPIO0  Pin
+	RESETN Pin
+
+	ready        volatile.Register8
+	useInterrupt bool
+}
+
+// Timing of the handshake, in microseconds.
+const (
+	ninaResetLow       = 10_000
+	ninaStartup        = 750_000
+	ninaSelectTimeout  = 5_000
+	ninaDefaultTimeout = 10_000_000
+)
+
+// Configure sets up the pins and resets the co-processor. The SPI bus must be
+// configured separately, usually at 8MHz in mode 0.
+func (n *NINA) Configure() {
+	n.CS.Configure(PinConfig{Mode: PinOutput})
+	n.CS.High()
+	n.RESETN.Configure(PinConfig{Mode: PinOutput})
+	n.ACK.Configure(PinConfig{Mode: PinInput})
+
+	n.useInterrupt = n.ACK.SetInterrupt(PinFalling, func(Pin) {
+		n.ready.Set(1)
+	}) == nil
+
+	n.Reset()
+}
+
+// Reset resets the co-processor into its normal (SPI) mode. GPIO0 must be
+// high during reset, otherwise the co-processor starts its bootloader.
+func (n *NINA) Reset() {
+	if n.GPIO0 != NoPin {
+		n.GPIO0.Configure(PinConfig{Mode: PinOutput})
+		n.GPIO0.High()
+	}
+	n.CS.High()
+	n.RESETN.Low()
+	sleepMicroseconds(ninaResetLow)
+	n.RESETN.High()
+	sleepMicroseconds(ninaStartup)
+	if n.GPIO0 != NoPin {
+		n.GPIO0.Configure(PinConfig{Mode: PinInput})
+	}
+}
+
+// Ready returns whether the co-processor is ready to accept a command.
+func (n *NINA) Ready() bool {
+	return !n.ACK.Get()
+}
+
+// WaitReady waits until the co-processor is ready to accept a command, for at
+// most timeout microseconds.
+func (n *NINA) WaitReady(timeout int64) error {
+	n.ready.Set(0)
+	if n.Ready() {
+		return nil
+	}
+	deadline := nanotime() + timeout*1000
+	for {
+		if n.useInterrupt {
+			if n.ready.Get() != 0 {
+				return nil
+			}
+		} else if n.Ready() {
+			return nil
+		}
+		if nanotime() > deadline {
+			return ErrNINATimeout
+		}
+		gosched()
+	}
+}
+
+// Select waits for the co-processor to be ready and starts a transfer by
+// asserting chip select. The co-processor acknowledges by driving ACK high.
+func (n *NINA) Select() error {
+	if err := n.WaitReady(ninaDefaultTimeout); err != nil {
+		return err
+	}
+	n.CS.Low()
+	deadline := nanotime() + ninaSelectTimeout*1000
+	for !n.ACK.Get() {
+		if nanotime() > deadline {
+			n.CS.High()
+			return ErrNINATimeout
+		}
+	}
+	return nil
+}
+
+// Deselect ends a transfer by releasing chip select.
+func (n *NINA) Deselect() {
+	n.CS.High()
+}
+
+// Tx transfers data to and from the co-processor. It must be called between
+// Select and Deselect.
+func (n *NINA) Tx(w, r []byte) error {
+	return n.Bus.Tx(w, r)
+}
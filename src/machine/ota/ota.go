@@ -0,0 +1,102 @@
+// Package ota verifies firmware images that were downloaded for an
+// over-the-air update, before they are handed to the bootloader or the swap
+// code that installs them. An image is read from flash in small chunks, so it
+// doesn't need to fit in RAM:
+//
+//	image := io.NewSectionReader(flash, stagingOffset, imageSize)
+//	if err := ota.VerifyECDSA(image, imageSize, &publicKey, signature); err != nil {
+//		// Discard the update.
+//	}
+//
+// The signatures are over the SHA-256 digest of the image rather than over the
+// image itself, so that the digest can be computed while streaming the image
+// (for Ed25519 this means that the 32 byte digest is the signed message). The
+// digest is computed through machine/hwcrypto, by the CryptoCell 310 on the
+// nRF52840 and in software elsewhere, and ECDSA signatures over the P-256
+// curve are checked through it too.
+package ota
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"machine/hwcrypto"
+	"math/big"
+)
+
+var (
+	ErrDigestMismatch   = errors.New("ota: image digest mismatch")
+	ErrInvalidSignature = errors.New("ota: invalid image signature")
+)
+
+// chunkSize is the number of bytes read from the image at a time.
+const chunkSize = 256
+
+// Digest returns the SHA-256 digest of the first size bytes of image.
+func Digest(image io.ReaderAt, size int64) (sum [sha256.Size]byte, err error) {
+	h := hwcrypto.NewSHA256()
+	var buf [chunkSize]byte
+	for off := int64(0); off < size; {
+		n := int64(len(buf))
+		if size-off < n {
+			n = size - off
+		}
+		// ReadAt may return io.EOF along with the last bytes of the image.
+		if m, err := image.ReadAt(buf[:n], off); err != nil && (err != io.EOF || int64(m) != n) {
+			return sum, err
+		}
+		h.Write(buf[:n])
+		off += n
+	}
+	h.Sum(sum[:0])
+	return sum, nil
+}
+
+// VerifyDigest checks that the SHA-256 digest of the image is want, for
+// images that are authenticated by other means, such as a digest received over
+// a secure channel.
+func VerifyDigest(image io.ReaderAt, size int64, want [sha256.Size]byte) error {
+	sum, err := Digest(image, size)
+	if err != nil {
+		return err
+	}
+	if sum != want {
+		return ErrDigestMismatch
+	}
+	return nil
+}
+
+// VerifyEd25519 checks sig, an Ed25519 signature of the SHA-256 digest of the
+// image.
+func VerifyEd25519(image io.ReaderAt, size int64, pub ed25519.PublicKey, sig []byte) error {
+	sum, err := Digest(image, size)
+	if err != nil {
+		return err
+	}
+	if len(pub) != ed25519.PublicKeySize || !ed25519.Verify(pub, sum[:], sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyECDSA checks sig, an ECDSA signature over the P-256 curve of the
+// SHA-256 digest of the image. The signature is the 32 byte big endian r
+// followed by the 32 byte big endian s, as stored by most signing tools for
+// embedded devices.
+func VerifyECDSA(image io.ReaderAt, size int64, pub *ecdsa.PublicKey, sig []byte) error {
+	if len(sig) != 64 {
+		return ErrInvalidSignature
+	}
+	sum, err := Digest(image, size)
+	if err != nil {
+		return err
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if !hwcrypto.VerifyP256(pub, sum[:], r, s) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
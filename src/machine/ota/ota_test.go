@@ -0,0 +1,74 @@
+package ota
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+)
+
+// testImage returns an image that isn't a multiple of the chunk size.
+func testImage() []byte {
+	image := make([]byte, 3*chunkSize+17)
+	for i := range image {
+		image[i] = byte(i * 7)
+	}
+	return image
+}
+
+func TestDigest(t *testing.T) {
+	image := testImage()
+	sum, err := Digest(bytes.NewReader(image), int64(len(image)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != sha256.Sum256(image) {
+		t.Error("digest differs from sha256.Sum256")
+	}
+	if err := VerifyDigest(bytes.NewReader(image), int64(len(image)-1), sum); err != ErrDigestMismatch {
+		t.Errorf("VerifyDigest of a truncated image: got %v, want ErrDigestMismatch", err)
+	}
+}
+
+func TestVerifyEd25519(t *testing.T) {
+	image := testImage()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(image)
+	sig := ed25519.Sign(priv, sum[:])
+	if err := VerifyEd25519(bytes.NewReader(image), int64(len(image)), pub, sig); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	image[100] ^= 1
+	if err := VerifyEd25519(bytes.NewReader(image), int64(len(image)), pub, sig); err != ErrInvalidSignature {
+		t.Errorf("modified image: got %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestVerifyECDSA(t *testing.T) {
+	image := testImage()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(image)
+	r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	if err := VerifyECDSA(bytes.NewReader(image), int64(len(image)), &key.PublicKey, sig); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	image[0] ^= 1
+	if err := VerifyECDSA(bytes.NewReader(image), int64(len(image)), &key.PublicKey, sig); err != ErrInvalidSignature {
+		t.Errorf("modified image: got %v, want ErrInvalidSignature", err)
+	}
+}
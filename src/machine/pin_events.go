@@ -0,0 +1,100 @@
+//go:build nrf || sam || stm32 || rp2040 || k210 || esp32c3 || mimxrt1062
+// +build nrf sam stm32 rp2040 k210 esp32c3 mimxrt1062
+
+package machine
+
+import "runtime/interrupt"
+
+// PinEvent is a change of a pin, seen by its interrupt.
+type PinEvent struct {
+	Pin   Pin
+	High  bool   // level of the pin just after the change
+	Time  int64  // time of the interrupt, in nanoseconds since the chip started
+	Count uint32 // number of the event, counting dropped events
+}
+
+// pinEventQueue carries the events of a pin from its interrupt to the
+// goroutine that sends them on the channel, as sending on a channel isn't
+// allowed in an interrupt.
+type pinEventQueue struct {
+	events [16]PinEvent
+	head   uint8
+	tail   uint8
+	count  uint32 // events seen, including dropped ones
+	c      chan<- PinEvent
+	stop   bool
+	ready  Event
+}
+
+// pinEventQueues are the queues of the pins that have events enabled.
+var pinEventQueues map[Pin]*pinEventQueue
+
+// SetEvents sends a PinEvent on c for every change of the pin, instead of
+// calling a callback in the interrupt like SetInterrupt does:
+//
+//	events := make(chan machine.PinEvent, 8)
+//	button.SetEvents(machine.PinFalling, events)
+//	for {
+//		select {
+//		case e := <-events:
+//			println("pressed at", e.Time)
+//		case <-timeout:
+//			// ...
+//		}
+//	}
+//
+// The events are queued in the interrupt, and a goroutine moves them to c; it
+// needs the tasks scheduler. When the queue is full because c isn't read fast
+// enough, events are dropped, which shows as a jump in their Count.
+//
+// Passing a nil channel disables the events.
+func (p Pin) SetEvents(change PinChange, c chan<- PinEvent) error {
+	if q := pinEventQueues[p]; q != nil {
+		q.stop = true
+		q.ready.Signal()
+		delete(pinEventQueues, p)
+		p.SetInterrupt(change, nil)
+	}
+	if c == nil {
+		return nil
+	}
+	q := &pinEventQueue{c: c}
+	err := p.SetInterrupt(change, func(pin Pin) {
+		q.count++
+		if q.head-q.tail == uint8(len(q.events)) {
+			return
+		}
+		q.events[q.head%uint8(len(q.events))] = PinEvent{Pin: pin, High: pin.Get(), Time: nanotime(), Count: q.count}
+		q.head++
+		q.ready.Signal()
+	})
+	if err != nil {
+		return err
+	}
+	if pinEventQueues == nil {
+		pinEventQueues = make(map[Pin]*pinEventQueue)
+	}
+	pinEventQueues[p] = q
+	go q.forward()
+	return nil
+}
+
+// forward sends the queued events on the channel, until the events are
+// disabled.
+func (q *pinEventQueue) forward() {
+	for {
+		mask := interrupt.Disable()
+		if q.head == q.tail {
+			interrupt.Restore(mask)
+			if q.stop {
+				return
+			}
+			q.ready.Wait()
+			continue
+		}
+		event := q.events[q.tail%uint8(len(q.events))]
+		q.tail++
+		interrupt.Restore(mask)
+		q.c <- event
+	}
+}
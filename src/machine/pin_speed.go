@@ -0,0 +1,16 @@
+//go:build rp2040 || (stm32 && !stm32f103)
+// +build rp2040 stm32,!stm32f103
+
+package machine
+
+// PinSpeed is the slew rate of an output pin, as set by Pin.SetSlewRate. Fast
+// edges are needed by fast buses, and slow edges cause less electromagnetic
+// interference. Chips that have fewer speeds round to the nearest one.
+type PinSpeed uint8
+
+const (
+	PinSpeedLow PinSpeed = iota
+	PinSpeedMedium
+	PinSpeedHigh
+	PinSpeedVeryHigh
+)
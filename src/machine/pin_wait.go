@@ -0,0 +1,55 @@
+//go:build (nrf || sam || stm32 || rp2040 || k210 || esp32c3 || mimxrt1062) && !scheduler.none
+// +build nrf sam stm32 rp2040 k210 esp32c3 mimxrt1062
+// +build !scheduler.none
+
+package machine
+
+import "errors"
+
+var errPinWaitTimeout = errors.New("machine: timeout waiting for pin level")
+
+// WaitForPin waits until the pin is at the given level, for example until an
+// e-paper display releases its BUSY pin at the end of a refresh:
+//
+//	err := machine.WaitForPin(busy, false, 5e9)
+//
+// Unlike a loop that polls the pin, WaitForPin lets the chip sleep until the
+// pin changes, which it notices with a pin interrupt. The interrupt of the pin
+// must not be in use. The timeout is in nanoseconds, zero to wait forever.
+//
+// The pin must already be configured as an input. WaitForPin needs a
+// scheduler, as it pauses the calling goroutine.
+func WaitForPin(pin Pin, high bool, timeout int64) error {
+	if pin.Get() == high {
+		return nil
+	}
+	w := &pinWait{}
+	if err := pin.SetInterrupt(PinToggle, func(Pin) { w.event.Signal() }); err != nil {
+		return err
+	}
+	if timeout != 0 {
+		go w.expire(timeout)
+	}
+	deadline := nanotime() + timeout
+	var err error
+	for pin.Get() != high {
+		if timeout != 0 && nanotime() >= deadline {
+			err = errPinWaitTimeout
+			break
+		}
+		w.event.Wait()
+	}
+	pin.SetInterrupt(PinToggle, nil)
+	return err
+}
+
+type pinWait struct {
+	event Event
+}
+
+// expire wakes up WaitForPin once the timeout has passed. When the pin changed
+// before that, the signal goes to an event nobody waits for anymore.
+func (w *pinWait) expire(timeout int64) {
+	sleep(timeout)
+	w.event.Signal()
+}
@@ -0,0 +1,54 @@
+//go:build !gameboyadvance
+// +build !gameboyadvance
+
+package machine
+
+// PowerRails are the switched supplies of the board, for the boards that list
+// them. The board enables the rails that its peripherals need, such as the
+// supply of the onboard sensors and of the I2C pull-up resistors, before the
+// program starts; programs may disable them to save power while the
+// peripherals aren't used, and enable them again before using them.
+var PowerRails []*PowerRail
+
+// PowerRail is a supply on the board that a pin switches on and off, through
+// a load switch or the enable input of a regulator.
+type PowerRail struct {
+	Pin       Pin
+	ActiveLow bool // the rail is on while the pin is low
+
+	// StartupTime is how long the peripherals on the rail need after it is
+	// switched on before they work, in microseconds.
+	StartupTime uint32
+
+	configured, enabled bool
+}
+
+// Enable switches the rail on, and waits for the startup time of its
+// peripherals if it was off.
+func (r *PowerRail) Enable() {
+	if r.enabled && r.configured {
+		return
+	}
+	r.set(true)
+	sleepMicroseconds(int64(r.StartupTime))
+}
+
+// Disable switches the rail off. The peripherals on it lose their state, and
+// those on a bus, like I2C, may hold its lines low while they are unpowered.
+func (r *PowerRail) Disable() {
+	r.set(false)
+}
+
+// Enabled returns whether the rail was switched on by Enable.
+func (r *PowerRail) Enabled() bool {
+	return r.enabled && r.configured
+}
+
+func (r *PowerRail) set(on bool) {
+	r.Pin.Set(on != r.ActiveLow)
+	if !r.configured {
+		r.Pin.Configure(PinConfig{Mode: PinOutput})
+		r.configured = true
+	}
+	r.enabled = on
+}
@@ -0,0 +1,55 @@
+//go:build nrf || sam || stm32 || rp2040 || k210 || esp32c3 || mimxrt1062
+// +build nrf sam stm32 rp2040 k210 esp32c3 mimxrt1062
+
+package machine
+
+// ppmMaxChannels is the number of channels PPMReceiver decodes at most.
+const ppmMaxChannels = 16
+
+// PPMReceiver decodes a PPM sum signal (also called CPPM) of an RC receiver:
+// a pulse train with the channels one after the other, each 1000 to 2000µs
+// from one rising edge to the next, and a gap of more than 3ms between frames.
+// The times between the edges are measured in a pin interrupt.
+type PPMReceiver struct {
+	Pin Pin
+
+	// Handler is called with the channel values in microseconds after every
+	// frame. It runs in the pin interrupt, so it must be short, and the slice
+	// is only valid until it returns.
+	Handler func(channels []uint16)
+
+	last     int64
+	channels [ppmMaxChannels]uint16
+	count    int
+	synced   bool
+}
+
+// Configure configures the pin and starts receiving.
+func (r *PPMReceiver) Configure() error {
+	r.Pin.Configure(PinConfig{Mode: PinInput})
+	r.last = nanotime()
+	return r.Pin.SetInterrupt(PinRising, r.edge)
+}
+
+// edge is called on every rising edge of the signal.
+func (r *PPMReceiver) edge(pin Pin) {
+	now := nanotime()
+	us := (now - r.last) / 1000
+	r.last = now
+	switch {
+	case us > 3000:
+		// The gap between frames: the channels before it are complete.
+		if r.synced && r.count > 0 && r.Handler != nil {
+			r.Handler(r.channels[:r.count])
+		}
+		r.synced = true
+		r.count = 0
+	case !r.synced:
+	case us < 500 || r.count == ppmMaxChannels:
+		// Not a PPM signal, or a glitch.
+		r.synced = false
+	default:
+		r.channels[r.count] = uint16(us)
+		r.count++
+	}
+}
@@ -3,7 +3,9 @@ package machine
 import "errors"
 
 var (
-	ErrPWMPeriodTooLong = errors.New("pwm: period too long")
+	ErrPWMPeriodTooLong   = errors.New("pwm: period too long")
+	ErrPWMDeadTimeTooLong = errors.New("pwm: dead time too long")
+	ErrPWMNoDeadTime      = errors.New("pwm: no dead time generator")
 )
 
 // PWMConfig allows setting some configuration while configuring a PWM
@@ -18,4 +20,9 @@ type PWMConfig struct {
 	//     period = 1e9 / frequency
 	//
 	Period uint64
+
+	// Priority of the interrupts of the timer, for the chips where the timer
+	// has interrupt callbacks. Zero keeps the default of the driver, like in
+	// UARTConfig.
+	Priority uint8
 }
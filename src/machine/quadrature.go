@@ -0,0 +1,56 @@
+//go:build nrf || sam || stm32 || rp2040 || k210 || esp32c3 || mimxrt1062
+// +build nrf sam stm32 rp2040 k210 esp32c3 mimxrt1062
+
+package machine
+
+// QuadratureEncoder counts the steps of a quadrature encoder, such as the
+// encoder on the shaft of a gear motor, in the interrupts of its two pins. It
+// counts every edge, four steps per encoder cycle.
+type QuadratureEncoder struct {
+	A, B Pin
+
+	// Mode of the pins, PinInputPullup for encoders with open collector
+	// outputs.
+	Mode PinMode
+
+	position int32
+	state    uint8
+}
+
+// Configure configures the pins and starts counting.
+func (q *QuadratureEncoder) Configure() error {
+	q.A.Configure(PinConfig{Mode: q.Mode})
+	q.B.Configure(PinConfig{Mode: q.Mode})
+	q.state = q.read()
+	if err := q.A.SetInterrupt(PinToggle, q.edge); err != nil {
+		return err
+	}
+	return q.B.SetInterrupt(PinToggle, q.edge)
+}
+
+// Position returns the number of steps counted, positive when A leads B.
+func (q *QuadratureEncoder) Position() int32 {
+	return q.position
+}
+
+// SetPosition sets the step count, for example to 0 after homing.
+func (q *QuadratureEncoder) SetPosition(position int32) {
+	q.position = position
+}
+
+func (q *QuadratureEncoder) read() uint8 {
+	var state uint8
+	if q.A.Get() {
+		state |= 2
+	}
+	if q.B.Get() {
+		state |= 1
+	}
+	return state
+}
+
+func (q *QuadratureEncoder) edge(Pin) {
+	state := q.read()
+	q.position += int32(quadratureStep(q.state, state))
+	q.state = state
+}
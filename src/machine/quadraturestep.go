@@ -0,0 +1,13 @@
+package machine
+
+// quadratureSteps is the direction of travel for a change of the pin states,
+// indexed by the old and new state of A and B, with A in bit 1. When A leads
+// B, the states go 00, 10, 11, 01, which counts up. Invalid changes, where
+// both pins changed, are ignored.
+var quadratureSteps = [16]int8{0, -1, 1, 0, 1, 0, 0, -1, -1, 0, 0, 1, 0, 1, -1, 0}
+
+// quadratureStep returns the step of the encoder for a change of the pin
+// states from state to next.
+func quadratureStep(state, next uint8) int8 {
+	return quadratureSteps[state<<2|next]
+}
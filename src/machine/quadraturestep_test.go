@@ -0,0 +1,24 @@
+package machine
+
+import "testing"
+
+func TestQuadratureStep(t *testing.T) {
+	// One encoder cycle with A leading B, as the states of A and B.
+	cycle := []uint8{0b00, 0b10, 0b11, 0b01}
+	var forward, backward int
+	for i := range cycle {
+		forward += int(quadratureStep(cycle[i], cycle[(i+1)%len(cycle)]))
+		backward += int(quadratureStep(cycle[(i+1)%len(cycle)], cycle[i]))
+	}
+	if forward != 4 || backward != -4 {
+		t.Errorf("got %d steps forward and %d backward, want 4 and -4", forward, backward)
+	}
+	for state := uint8(0); state < 4; state++ {
+		if step := quadratureStep(state, state); step != 0 {
+			t.Errorf("no change from %02b: got %d steps", state, step)
+		}
+		if step := quadratureStep(state, state^0b11); step != 0 {
+			t.Errorf("invalid change from %02b: got %d steps", state, step)
+		}
+	}
+}
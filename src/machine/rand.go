@@ -0,0 +1,63 @@
+//go:build baremetal
+// +build baremetal
+
+package machine
+
+import _ "unsafe" // for go:linkname
+
+// The runtime uses a small pseudo random number generator for hash seeds and
+// select. Without a seed it produces the same numbers after every boot, so the
+// machine package seeds it at startup with entropy from the hardware random
+// number generator, or from clock jitter on chips without one. The global
+// source of math/rand is only seeded when building with the
+// machine.seedmathrand tag, as importing math/rand costs about 5kB of RAM even
+// in programs that don't use it.
+
+//go:linkname seedRand runtime.seedRand
+func seedRand(seed uint32)
+
+func init() {
+	seed := RandomSeed()
+	seedRand(uint32(seed) ^ uint32(seed>>32))
+}
+
+// RandomSeed returns a seed that differs between boots, from the hardware
+// random number generator where available. Without the machine.seedmathrand
+// tag, the global source of math/rand always starts with the same seed, so
+// seed it with this instead:
+//
+//	rand.Seed(machine.RandomSeed())
+//
+// The seed is not suitable for cryptographic use on chips without a hardware
+// random number generator.
+func RandomSeed() int64 {
+	seed, ok := hardwareEntropy()
+	if !ok {
+		seed = jitterEntropy()
+	}
+	return int64(seed)
+}
+
+// jitterEntropy collects entropy from the phase between the CPU clock and the
+// clock of the system timer, which often run from different oscillators. It
+// counts how many loop iterations fit between timer ticks, and hashes the
+// counts together with the current time.
+func jitterEntropy() uint64 {
+	const (
+		fnvOffset = 0xcbf29ce484222325
+		fnvPrime  = 0x100000001b3
+		rounds    = 16
+		maxSpins  = 10000 // in case the timer isn't running
+	)
+	h := uint64(fnvOffset)
+	for i := 0; i < rounds; i++ {
+		start := nanotime()
+		spins := 0
+		for nanotime() == start && spins < maxSpins {
+			spins++
+		}
+		h = (h ^ uint64(spins)) * fnvPrime
+		h = (h ^ uint64(start)) * fnvPrime
+	}
+	return h
+}
@@ -0,0 +1,17 @@
+//go:build rp2040 || nrf || (stm32 && !stm32f103 && !stm32l0x1) || (sam && atsamd51) || (sam && atsame5x)
+// +build rp2040 nrf stm32,!stm32f103,!stm32l0x1 sam,atsamd51 sam,atsame5x
+
+package machine
+
+// hardwareEntropy returns 64 bits from the hardware random number generator.
+func hardwareEntropy() (uint64, bool) {
+	high, err := GetRNG()
+	if err != nil {
+		return 0, false
+	}
+	low, err := GetRNG()
+	if err != nil {
+		return 0, false
+	}
+	return uint64(high)<<32 | uint64(low), true
+}
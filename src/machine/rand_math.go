@@ -0,0 +1,12 @@
+//go:build baremetal && machine.seedmathrand
+// +build baremetal,machine.seedmathrand
+
+package machine
+
+import "math/rand"
+
+// Seed the global source of math/rand at startup, for programs built with the
+// machine.seedmathrand tag.
+func init() {
+	rand.Seed(RandomSeed())
+}
@@ -0,0 +1,14 @@
+//go:build baremetal && !rp2040 && !nrf && !(stm32 && !stm32f103 && !stm32l0x1) && !(sam && atsamd51) && !(sam && atsame5x)
+// +build baremetal
+// +build !rp2040
+// +build !nrf
+// +build !stm32 stm32f103 stm32l0x1
+// +build !sam !atsamd51
+// +build !sam !atsame5x
+
+package machine
+
+// hardwareEntropy reports that there is no hardware random number generator.
+func hardwareEntropy() (uint64, bool) {
+	return 0, false
+}
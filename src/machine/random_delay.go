@@ -0,0 +1,43 @@
+//go:build rp2040 || nrf || (stm32 && !stm32f103 && !stm32l0x1) || (sam && atsamd51) || (sam && atsame5x)
+// +build rp2040 nrf stm32,!stm32f103,!stm32l0x1 sam,atsamd51 sam,atsame5x
+
+package machine
+
+import "runtime/volatile"
+
+// Random delays are a countermeasure against timing and power analysis: an
+// attacker who triggers a cryptographic operation and measures the chip can't
+// line up the measurements of many runs when each run is shifted by a random
+// delay. The delays here are busy loops rather than sleeps, as a sleep lets
+// the scheduler and interrupts add jitter of their own that has nothing to do
+// with the random value, and not every chip has a cycle counter (the Cortex-M0
+// and M0+ don't).
+
+// delaySink is written by the delay loop, so that the compiler can't remove
+// the loop.
+var delaySink uint32
+
+// DelayLoops busy-waits for n iterations of a loop. The time it takes doesn't
+// depend on anything else than n: there are no branches on data and no memory
+// accesses that could miss a cache.
+//
+//go:noinline
+func DelayLoops(n uint32) {
+	for i := uint32(0); i != n; i++ {
+		volatile.StoreUint32(&delaySink, i)
+	}
+}
+
+// RandomDelay busy-waits for a random number of loop iterations, from 0 to
+// max-1, taken from the hardware random number generator. Call it before and
+// after the sensitive operations of a cryptographic algorithm.
+func RandomDelay(max uint32) error {
+	r, err := GetRNG()
+	if err != nil {
+		return err
+	}
+	// Scale the random value to the range without a division or a modulo,
+	// which take a data dependent time on some cores.
+	DelayLoops(uint32(uint64(r) * uint64(max) >> 32))
+	return nil
+}
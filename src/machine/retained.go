@@ -0,0 +1,142 @@
+//go:build cortexm
+// +build cortexm
+
+package machine
+
+import (
+	"runtime/interrupt"
+	_ "unsafe" // for go:section
+)
+
+// Retained memory
+//
+// Global variables in the .noinit section are not cleared at startup, so they
+// keep their value across soft resets such as a watchdog reset, a call to
+// arm.SystemReset or a press of the reset button. After power-on they contain
+// garbage. They are declared with the //go:section pragma, in a file that
+// imports unsafe:
+//
+//	//go:section .noinit.crashdump
+//	var crashDump [64]uint32
+//
+// Such variables are not scanned by the garbage collector and so must not
+// hold pointers to the heap.
+//
+// For the common case of a few counters or flags, the machine package keeps
+// NumRetainedWords words of validated retained memory: they read as zero
+// after power-on instead of as garbage.
+
+// NumRetainedWords is the number of words available through RetainedWord and
+// SetRetainedWord.
+const NumRetainedWords = 8
+
+const retainedMagic = 0x7e7a1ed0
+
+// Retained words used by the machine package itself.
+const (
+	retainedDoubleTap = iota
+	retainedBootCount
+	retainedUptimeLow
+	retainedUptimeHigh
+	numRetainedInternal
+)
+
+type retainedMemory struct {
+	magic    uint32
+	checksum uint32
+	words    [NumRetainedWords]uint32
+	internal [numRetainedInternal]uint32
+}
+
+//go:section .noinit.machine
+var retained retainedMemory
+
+// sum returns the checksum over the retained words.
+func (r *retainedMemory) sum() uint32 {
+	sum := uint32(retainedMagic)
+	for _, w := range r.words {
+		sum = sum<<5 + sum>>27 ^ w
+	}
+	for _, w := range r.internal {
+		sum = sum<<5 + sum>>27 ^ w
+	}
+	return sum
+}
+
+// retainedState records whether the retained memory was valid at startup. It
+// lives in .bss, so it is cleared on every reset.
+var retainedState uint8
+
+const (
+	retainedUnchecked = iota
+	retainedWasValid
+	retainedWasInvalid
+)
+
+// retainedInit clears the retained memory the first time it is accessed after
+// a reset, if it does not hold valid data as after power-on. It must be called
+// with interrupts disabled.
+func retainedInit() {
+	if retainedState != retainedUnchecked {
+		return
+	}
+	r := &retained
+	if r.magic == retainedMagic && r.checksum == r.sum() {
+		retainedState = retainedWasValid
+		return
+	}
+	r.words = [NumRetainedWords]uint32{}
+	r.internal = [numRetainedInternal]uint32{}
+	r.magic = retainedMagic
+	r.checksum = r.sum()
+	retainedState = retainedWasInvalid
+}
+
+// RetainedValid returns whether the retained memory survived the last reset.
+// It returns false after power-on, or if the memory got corrupted, in which
+// case all retained words read as zero.
+func RetainedValid() bool {
+	mask := interrupt.Disable()
+	retainedInit()
+	interrupt.Restore(mask)
+	return retainedState == retainedWasValid
+}
+
+// RetainedWord returns the retained word at index i.
+func RetainedWord(i int) uint32 {
+	mask := interrupt.Disable()
+	retainedInit()
+	w := retained.words[i]
+	interrupt.Restore(mask)
+	return w
+}
+
+// SetRetainedWord stores a value in the retained word at index i, where it
+// stays across soft resets.
+func SetRetainedWord(i int, value uint32) {
+	mask := interrupt.Disable()
+	retainedInit()
+	retained.words[i] = value
+	retained.checksum = retained.sum()
+	interrupt.Restore(mask)
+}
+
+// retainedInternal returns the retained word of the machine package at index
+// i.
+func retainedInternal(i int) uint32 {
+	mask := interrupt.Disable()
+	retainedInit()
+	w := retained.internal[i]
+	interrupt.Restore(mask)
+	return w
+}
+
+// setRetainedInternal stores a value in the retained word of the machine
+// package at index i.
+func setRetainedInternal(i int, value uint32) {
+	mask := interrupt.Disable()
+	retainedInit()
+	retained.internal[i] = value
+	retained.checksum = retained.sum()
+	interrupt.Restore(mask)
+}
@@ -0,0 +1,149 @@
+//go:build cortexm
+// +build cortexm
+
+package machine
+
+import (
+	"errors"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// RTT is channel 0 of SEGGER Real Time Transfer, a console that a debug probe
+// reads and writes in RAM while the program runs, without using any pins. The
+// J-Link RTT Viewer, OpenOCD (with its rtt commands) and probe-rs find it by
+// its control block. It can be the Serial of the program, or a sink of a
+// Console:
+//
+//	machine.Serial = &machine.Console{
+//		Sinks: []machine.ConsoleSink{{Writer: machine.USBCDC}, {Writer: machine.RTT}},
+//	}
+//
+// Write never waits for the probe: the output that doesn't fit in the buffer,
+// which is all of it while no probe reads, is dropped.
+var RTT = &RTTConsole{}
+
+// RTTConsole is the type of RTT.
+type RTTConsole struct{}
+
+const (
+	rttUpSize   = 1024 // bytes of output
+	rttDownSize = 16   // bytes of input
+)
+
+// rttBuffer is a ring buffer of the control block, with the layout of the
+// SEGGER_RTT_BUFFER_UP and _DOWN structs. The probe writes rdOff of the up
+// buffer and wrOff of the down buffer.
+type rttBuffer struct {
+	name   uintptr
+	buffer uintptr
+	size   uint32
+	wrOff  uint32
+	rdOff  uint32
+	flags  uint32
+}
+
+// rttControlBlock is what the probe looks for in RAM, by its id.
+type rttControlBlock struct {
+	id             [16]byte
+	maxUpBuffers   int32
+	maxDownBuffers int32
+	up             rttBuffer
+	down           rttBuffer
+}
+
+var (
+	rttCB   rttControlBlock
+	rttUp   [rttUpSize]byte
+	rttDown [rttDownSize]byte
+	rttName = [...]byte{'T', 'e', 'r', 'm', 'i', 'n', 'a', 'l', 0}
+)
+
+var errRTTFull = errors.New("rtt: buffer full, output dropped")
+
+// rttInit sets up the control block, the first time it is called.
+func rttInit() {
+	if rttCB.maxUpBuffers != 0 {
+		return
+	}
+	rttCB.maxUpBuffers = 1
+	rttCB.maxDownBuffers = 1
+	name := uintptr(unsafe.Pointer(&rttName[0]))
+	rttCB.up = rttBuffer{name: name, buffer: uintptr(unsafe.Pointer(&rttUp[0])), size: rttUpSize}
+	rttCB.down = rttBuffer{name: name, buffer: uintptr(unsafe.Pointer(&rttDown[0])), size: rttDownSize}
+
+	// The id comes last, so that the probe doesn't find the control block
+	// before it is set up.
+	const id = "SEGGER RTT"
+	for i := 0; i < len(id); i++ {
+		volatile.StoreUint8(&rttCB.id[i], id[i])
+	}
+}
+
+// Configure sets up the control block. It is also set up by the first call to
+// another method.
+func (r *RTTConsole) Configure(config UARTConfig) error {
+	mask := interrupt.Disable()
+	rttInit()
+	interrupt.Restore(mask)
+	return nil
+}
+
+// Write copies p to the up buffer, from where the probe reads it. It returns
+// errRTTFull with the number of bytes copied if not all of p fit.
+func (r *RTTConsole) Write(p []byte) (n int, err error) {
+	mask := interrupt.Disable()
+	rttInit()
+	wr := rttCB.up.wrOff
+	rd := volatile.LoadUint32(&rttCB.up.rdOff)
+	for ; n < len(p); n++ {
+		next := (wr + 1) % rttUpSize
+		if next == rd {
+			err = errRTTFull
+			break
+		}
+		volatile.StoreUint8(&rttUp[wr], p[n])
+		wr = next
+	}
+	volatile.StoreUint32(&rttCB.up.wrOff, wr)
+	interrupt.Restore(mask)
+	return n, err
+}
+
+// WriteByte writes a byte to the up buffer.
+func (r *RTTConsole) WriteByte(c byte) error {
+	buf := [1]byte{c}
+	_, err := r.Write(buf[:])
+	return err
+}
+
+// ReadByte reads a byte that the probe wrote to the down buffer.
+func (r *RTTConsole) ReadByte() (byte, error) {
+	mask := interrupt.Disable()
+	defer interrupt.Restore(mask)
+	rttInit()
+	rd := rttCB.down.rdOff
+	if rd == volatile.LoadUint32(&rttCB.down.wrOff) {
+		return 0, errNoByte
+	}
+	c := volatile.LoadUint8(&rttDown[rd])
+	volatile.StoreUint32(&rttCB.down.rdOff, (rd+1)%rttDownSize)
+	return c, nil
+}
+
+// Buffered returns the number of bytes in the down buffer.
+func (r *RTTConsole) Buffered() int {
+	wr := volatile.LoadUint32(&rttCB.down.wrOff)
+	return int((wr + rttDownSize - rttCB.down.rdOff) % rttDownSize)
+}
+
+// DTR always returns true, as there is no way to tell whether a probe reads.
+func (r *RTTConsole) DTR() bool {
+	return true
+}
+
+// RTS always returns true, as there is no way to tell whether a probe reads.
+func (r *RTTConsole) RTS() bool {
+	return true
+}
@@ -0,0 +1,107 @@
+package machine
+
+// SBUS is the serial protocol of Futaba and FrSky RC receivers: a 25-byte
+// frame every 7 or 14ms, at 100000 baud, 8E2, with an inverted signal. Most
+// UARTs can't invert their input and need an inverter (a transistor) on the
+// RX pin. ConfigureSBUS of the UART configures it for SBUS on the RP2040, whose
+// pins can invert their input, and on the STM32F7, L0, L4, L5 and WL, whose
+// USARTs can. It returns an error on the other chips.
+
+const (
+	sbusFrameSize = 25
+	sbusHeader    = 0x0f
+	sbusFooter    = 0x00
+)
+
+// SBUSFrame is a decoded SBUS frame.
+type SBUSFrame struct {
+	// Channels are the 16 proportional channels, from 0 to 2047. Most
+	// transmitters send 172 to 1811, with 992 in the center.
+	Channels [16]uint16
+
+	// Channel17 and Channel18 are the two digital channels.
+	Channel17, Channel18 bool
+
+	// FrameLost is set when the receiver lost a frame from the transmitter,
+	// and Failsafe when it lost the connection and sends failsafe values.
+	FrameLost, Failsafe bool
+}
+
+// SBUSDecoder decodes an SBUS byte stream into frames.
+//
+//	decoder := machine.SBUSDecoder{Handler: func(frame *machine.SBUSFrame) {
+//		throttle = frame.Channels[2]
+//	}}
+//	for {
+//		n, _ := uart.Read(buf)
+//		decoder.Write(buf[:n])
+//	}
+type SBUSDecoder struct {
+	// Handler is called for every complete frame.
+	Handler func(frame *SBUSFrame)
+
+	buf   [sbusFrameSize]byte
+	n     int
+	frame SBUSFrame
+}
+
+// Write decodes the bytes received from the UART, calling Handler for every
+// complete frame. It never fails.
+func (d *SBUSDecoder) Write(data []byte) (int, error) {
+	for _, b := range data {
+		if d.n == 0 && b != sbusHeader {
+			continue // wait for the start of a frame
+		}
+		d.buf[d.n] = b
+		d.n++
+		if d.n < sbusFrameSize {
+			continue
+		}
+		d.n = 0
+		if b != sbusFooter {
+			// Out of sync: resynchronize on the next header byte, which
+			// may be in this frame.
+			d.resync()
+			continue
+		}
+		d.decode()
+		if d.Handler != nil {
+			d.Handler(&d.frame)
+		}
+	}
+	return len(data), nil
+}
+
+// resync keeps the bytes of the buffer from the first header byte after the
+// first byte on.
+func (d *SBUSDecoder) resync() {
+	for i := 1; i < sbusFrameSize; i++ {
+		if d.buf[i] == sbusHeader {
+			d.n = copy(d.buf[:], d.buf[i:])
+			return
+		}
+	}
+}
+
+func (d *SBUSDecoder) decode() {
+	// The channels are packed as 11-bit values, least significant bit first.
+	data := d.buf[1:23]
+	var bits uint32
+	var count uint
+	ch := 0
+	for _, b := range data {
+		bits |= uint32(b) << count
+		count += 8
+		if count >= 11 {
+			d.frame.Channels[ch] = uint16(bits & 0x7ff)
+			ch++
+			bits >>= 11
+			count -= 11
+		}
+	}
+	flags := d.buf[23]
+	d.frame.Channel17 = flags&(1<<0) != 0
+	d.frame.Channel18 = flags&(1<<1) != 0
+	d.frame.FrameLost = flags&(1<<2) != 0
+	d.frame.Failsafe = flags&(1<<3) != 0
+}
@@ -0,0 +1,16 @@
+//go:build atmega || esp || nrf || sam || sifive || (stm32 && !stm32f7 && !stm32l0 && !stm32l4 && !stm32l5 && !stm32wlx) || k210 || nxp
+// +build atmega esp nrf sam sifive stm32,!stm32f7,!stm32l0,!stm32l4,!stm32l5,!stm32wlx k210 nxp
+
+package machine
+
+import "errors"
+
+var errSBUSInversion = errors.New("machine: the UART can't invert its input for SBUS")
+
+// ConfigureSBUS returns an error, as the UARTs of this chip can't invert their
+// input. Put an inverter (a transistor) on the RX pin, and configure the UART
+// with Configure at 100000 baud, and with SetFormat(8, 2, ParityEven) where
+// the UART has it.
+func (uart *UART) ConfigureSBUS(config UARTConfig) error {
+	return errSBUSInversion
+}
@@ -1,6 +1,9 @@
 package machine
 
-import "errors"
+import (
+	"errors"
+	stdio "io"
+)
 
 var errNoByte = errors.New("machine: no byte read")
 
@@ -11,6 +14,24 @@ type UARTConfig struct {
 	BaudRate uint32
 	TX       Pin
 	RX       Pin
+
+	// Priority of the interrupt of the UART (or USB device), as passed to
+	// interrupt.Interrupt.SetPriority: lower values are more urgent. Zero
+	// keeps the default of the driver. Chips only implement the top bits, so
+	// 1 is the most urgent level on Cortex-M. On the nRF with a SoftDevice,
+	// the levels reserved by the SoftDevice are rejected. The ESP32-C3
+	// returns an error for a nonzero Priority, and the AVR, ESP32 and ESP8266
+	// UARTs, which have no interrupt priorities, ignore it.
+	Priority uint8
+}
+
+// SerialPort is a serial port that the protocols of this package run over:
+// ATServer, Terminal, SLIP and ModbusRTU. A UART, the USB CDC serial port and
+// NullSerial implement it.
+type SerialPort interface {
+	stdio.Writer
+	ReadByte() (byte, error)
+	Buffered() int
 }
 
 // NullSerial is a serial version of /dev/null (or null router): it drops
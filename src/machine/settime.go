@@ -0,0 +1,52 @@
+//go:build baremetal
+// +build baremetal
+
+package machine
+
+import (
+	"runtime/interrupt"
+	_ "unsafe" // for go:linkname
+)
+
+//go:linkname runtimeSetTime runtime.setTime
+func runtimeSetTime(unixNano, drift int64) int64
+
+//go:linkname wallTime runtime.wallTime
+func wallTime(mono int64) int64
+
+var timeSync struct {
+	synced bool
+	mono   int64 // monotonic time of the last SetTime
+	drift  int64 // current rate correction in parts per billion
+}
+
+// SetTime sets the wall clock used by time.Now to the given Unix time in
+// nanoseconds, as returned by time.Time.UnixNano (the machine package cannot
+// import the time package). It also sets the hardware real-time clock on chips
+// that have one separate from the system timer. The monotonic clock, used for
+// time.Since and time.Sleep, is not affected.
+//
+// With correctDrift set, SetTime also estimates how fast or slow the clock of
+// the chip runs from the error since the previous call, and corrects the rate
+// of the wall clock accordingly. This keeps the time accurate between
+// synchronizations with an NTP server or host, which should then be at least a
+// minute apart.
+func SetTime(unixNano int64, correctDrift bool) {
+	mask := interrupt.Disable()
+	mono := nanotime()
+	drift := int64(0)
+	if correctDrift {
+		drift = timeSync.drift
+		if timeSync.synced {
+			clockError := unixNano - wallTime(mono) // positive if the clock is slow
+			drift = correctedDrift(drift, clockError, mono-timeSync.mono)
+		}
+	}
+	runtimeSetTime(unixNano, drift)
+	timeSync.synced = true
+	timeSync.mono = mono
+	timeSync.drift = drift
+	interrupt.Restore(mask)
+
+	rtcSetTime(unixNano)
+}
@@ -0,0 +1,7 @@
+//go:build baremetal && !rp2040
+// +build baremetal,!rp2040
+
+package machine
+
+// rtcSetTime does nothing: the runtime clock is the only clock of the chip.
+func rtcSetTime(unixNano int64) {}
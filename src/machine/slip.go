@@ -0,0 +1,113 @@
+package machine
+
+import "errors"
+
+// SLIP sends and receives packets over a serial port with SLIP framing (RFC
+// 1055), which is the simplest way to exchange IP packets with a Linux host:
+//
+//	slattach -s 115200 -p slip /dev/ttyACM0 &
+//	ip addr add 192.168.7.1 peer 192.168.7.2 dev sl0
+//	ip link set sl0 up
+//
+// Every Read returns one packet and every Write sends one, so a SLIP can be
+// handed to a network stack as its link layer.
+type SLIP struct {
+	Port SerialPort
+
+	n       int  // bytes of the packet being received
+	escaped bool // the last byte received was an escape
+	dropped bool // the packet being received didn't fit, and is dropped
+	buf     [64]byte
+}
+
+// Special bytes of SLIP framing.
+const (
+	slipEnd    = 0xc0
+	slipEsc    = 0xdb
+	slipEscEnd = 0xdc
+	slipEscEsc = 0xdd
+)
+
+var errSLIPPacketTooLong = errors.New("slip: packet too long for buffer")
+
+// Read waits for a packet and stores it in p, and returns its length. A
+// packet that doesn't fit in p is dropped, and Read returns an error. A packet
+// that is being received when Read returns an error is kept, so that the next
+// Read continues with it.
+func (s *SLIP) Read(p []byte) (int, error) {
+	for {
+		if s.Port.Buffered() == 0 {
+			gosched()
+			continue
+		}
+		c, err := s.Port.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case c == slipEnd:
+			n, dropped := s.n, s.dropped
+			s.n, s.escaped, s.dropped = 0, false, false
+			if dropped {
+				return 0, errSLIPPacketTooLong
+			}
+			if n != 0 {
+				// An empty packet only flushes line noise: ignore it.
+				return n, nil
+			}
+			continue
+		case c == slipEsc:
+			s.escaped = true
+			continue
+		case s.escaped && c == slipEscEnd:
+			c = slipEnd
+		case s.escaped && c == slipEscEsc:
+			c = slipEsc
+		}
+		s.escaped = false
+		if s.n == len(p) {
+			s.dropped = true
+			continue
+		}
+		p[s.n] = c
+		s.n++
+	}
+}
+
+// Write sends p as a single packet. It starts with an END byte as well, which
+// flushes any line noise received by the other side before the packet.
+func (s *SLIP) Write(p []byte) (int, error) {
+	s.buf[0] = slipEnd
+	n := 1
+	for _, c := range p {
+		// Make room for an escaped byte.
+		if n >= len(s.buf)-1 {
+			if _, err := s.Port.Write(s.buf[:n]); err != nil {
+				return 0, err
+			}
+			n = 0
+		}
+		switch c {
+		case slipEnd:
+			s.buf[n], s.buf[n+1] = slipEsc, slipEscEnd
+			n += 2
+		case slipEsc:
+			s.buf[n], s.buf[n+1] = slipEsc, slipEscEsc
+			n += 2
+		default:
+			s.buf[n] = c
+			n++
+		}
+	}
+	if n >= len(s.buf) {
+		if _, err := s.Port.Write(s.buf[:n]); err != nil {
+			return 0, err
+		}
+		n = 0
+	}
+	s.buf[n] = slipEnd
+	if _, err := s.Port.Write(s.buf[:n+1]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -17,3 +17,14 @@ var (
 	ErrTxInvalidSliceSize      = errors.New("SPI write and read slices must be same size")
 	errSPIInvalidMachineConfig = errors.New("SPI port was not configured properly by the machine")
 )
+
+// The FE310 and the i.MX RT1062, whose SPI peripherals drive the CS pin, have
+// CSSetup, CSHold and WordDelay in their SPIConfig, set in hardware, and the
+// RP2040 has WordDelay. SPIDevice adds these delays in software on every chip,
+// for a device whose CS pin is driven as a GPIO.
+
+// spiDelayCycles converts a delay in nanoseconds to cycles of a clock of the
+// given frequency, rounding up, for the delay settings of SPI peripherals.
+func spiDelayCycles(ns, hz uint32) uint32 {
+	return uint32((uint64(ns)*uint64(hz) + 1e9 - 1) / 1e9)
+}
@@ -0,0 +1,92 @@
+//go:build !baremetal || atmega || esp32 || fe310 || k210 || nrf || (nxp && !mk66f18) || rp2040 || sam || (stm32 && !stm32f7x2 && !stm32l5x2)
+// +build !baremetal atmega esp32 fe310 k210 nrf nxp,!mk66f18 rp2040 sam stm32,!stm32f7x2,!stm32l5x2
+
+package machine
+
+// SPIDevice is a device on an SPI bus whose CS pin is driven as a GPIO. It
+// adds the delays that slow devices, such as some thermocouple ADCs and e-paper
+// controllers, need around CS and between words, which only the FE310 and the
+// i.MX RT1062 can generate in hardware. The delays are busy waits, in
+// nanoseconds:
+//
+//	dev := machine.SPIDevice{Bus: machine.SPI0, CS: machine.D10, CSSetup: 1000, CSHold: 500}
+//	dev.Configure()
+//	err := dev.Tx(cmd, resp)
+type SPIDevice struct {
+	Bus interface {
+		Tx(w, r []byte) error
+		Transfer(b byte) (byte, error)
+	}
+	CS Pin
+
+	// CSSetup is the delay between CS going low and the first clock edge.
+	CSSetup uint32
+	// CSHold is the delay between the last clock edge and CS going high.
+	CSHold uint32
+	// WordDelay is the delay between two words, which are then sent one by
+	// one with Transfer.
+	WordDelay uint32
+}
+
+// Configure makes CS an output, at the inactive high level.
+func (d *SPIDevice) Configure() {
+	d.CS.High()
+	d.CS.Configure(PinConfig{Mode: PinOutput})
+}
+
+// Tx selects the device, does a transfer like the Tx of the bus and deselects
+// the device again.
+func (d *SPIDevice) Tx(w, r []byte) error {
+	d.CS.Low()
+	spiWait(d.CSSetup)
+	var err error
+	if d.WordDelay != 0 {
+		err = spiTxDelayed(d.Bus, w, r, d.WordDelay)
+	} else {
+		err = d.Bus.Tx(w, r)
+	}
+	spiWait(d.CSHold)
+	d.CS.High()
+	return err
+}
+
+// spiTxDelayed sends the words of a transfer with Transfer, waiting delay
+// nanoseconds between two words. It takes the arguments of Tx.
+func spiTxDelayed(bus interface{ Transfer(byte) (byte, error) }, w, r []byte, delay uint32) error {
+	n := len(w)
+	if len(r) > n {
+		n = len(r)
+	}
+	if w != nil && r != nil && len(w) != len(r) && len(w) != 1 {
+		return ErrTxInvalidSliceSize
+	}
+	for i := 0; i < n; i++ {
+		if i != 0 {
+			spiWait(delay)
+		}
+		b := byte(0)
+		if len(w) == 1 && len(r) > 1 {
+			b = w[0]
+		} else if i < len(w) {
+			b = w[i]
+		}
+		c, err := bus.Transfer(b)
+		if err != nil {
+			return err
+		}
+		if i < len(r) {
+			r[i] = c
+		}
+	}
+	return nil
+}
+
+// spiWait busy waits for the given number of nanoseconds, as the SPI delays are
+// too short to let other goroutines run.
+func spiWait(ns uint32) {
+	if ns == 0 {
+		return
+	}
+	for deadline := nanotime() + int64(ns); nanotime() < deadline; {
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !baremetal || atmega || esp32 || fe310 || k210 || nrf || (nxp && !mk66f18) || sam || (stm32 && !stm32f7x2 && !stm32l5x2)
+// +build !baremetal atmega esp32 fe310 k210 nrf nxp,!mk66f18 sam stm32,!stm32f7x2,!stm32l5x2
+
+package machine
+
+// TxBuffers writes the buffers one after the other, ignoring Rx, as if they
+// were a single buffer: a driver can send a command header and its payload
+// without copying them together. Chip select, if any, is left to the caller
+// and stays as it is across the buffers.
+func (spi SPI) TxBuffers(buffers [][]byte) error {
+	for _, buf := range buffers {
+		if len(buf) == 0 {
+			continue
+		}
+		if err := spi.Tx(buf, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
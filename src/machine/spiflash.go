@@ -0,0 +1,356 @@
+//go:build !baremetal || atmega || esp32 || fe310 || k210 || nrf || (nxp && !mk66f18) || rp2040 || sam || (stm32 && !stm32f7x2 && !stm32l5x2)
+// +build !baremetal atmega esp32 fe310 k210 nrf nxp,!mk66f18 rp2040 sam stm32,!stm32f7x2,!stm32l5x2
+
+package machine
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// SPIFlash is a driver for external SPI NOR flash chips, like the W25Q, GD25Q
+// and MX25 series. Instead of a table of known chips, it reads the Serial Flash
+// Discoverable Parameters (SFDP, JESD216) that such chips store to find their
+// size, erase commands and capabilities. Chips without SFDP fall back to the
+// capacity in their JEDEC ID and the common 4kB sector erase.
+//
+//	flash := &machine.SPIFlash{Bus: machine.SPI0, CS: machine.D10}
+//	err := flash.Configure()
+//
+// The SPI bus must be configured before, in mode 0 or 3.
+type SPIFlash struct {
+	Bus interface {
+		Tx(w, r []byte) error
+	}
+	CS Pin
+
+	info SPIFlashInfo
+	buf  [8]byte
+	lock sync.Mutex
+}
+
+// SPIFlashInfo describes a flash chip, as discovered by SPIFlash.Configure.
+type SPIFlashInfo struct {
+	JEDECID        [3]byte // manufacturer, memory type, capacity
+	Size           int64
+	EraseBlockSize int64
+	PageSize       int64
+	AddressBytes   int // 3, or 4 for chips over 16MB
+
+	// Fast read modes the chip supports besides single line fast read, for
+	// buses that can use more than one data line.
+	DualOutput, DualIO bool // 1-1-2, 1-2-2
+	QuadOutput, QuadIO bool // 1-1-4, 1-4-4
+
+	eraseCmd uint8
+}
+
+var (
+	errSPIFlashNotFound = errors.New("SPI flash: no chip found")
+	errSPIFlashTimeout  = errors.New("SPI flash: timeout waiting for chip")
+	errSPIFlashRange    = errors.New("SPI flash: access outside of the chip")
+)
+
+const (
+	spiFlashCmdReadSFDP      = 0x5a
+	spiFlashCmdReadJEDECID   = 0x9f
+	spiFlashCmdReleasePD     = 0xab
+	spiFlashCmdEnter4Byte    = 0xb7
+	spiFlashCmdFastRead      = 0x0b
+	spiFlashCmdWriteEnable   = 0x06
+	spiFlashCmdReadStatus    = 0x05
+	spiFlashCmdPageProgram   = 0x02
+	spiFlashCmdSectorErase   = 0x20
+	spiFlashStatusBusy       = 1 << 0
+	spiFlashSFDPSignature    = 0x50444653 // "SFDP"
+	spiFlashDefaultPageSize  = 256
+	spiFlashDefaultEraseSize = 4096
+
+	// spiFlashTimeout is the longest time an erase or program command may
+	// take, in microseconds. 64kB block erases take up to 2s on some chips.
+	spiFlashTimeout = 3000_000
+)
+
+// Configure identifies the flash chip. It must be called before any other
+// method.
+func (f *SPIFlash) Configure() error {
+	f.CS.Configure(PinConfig{Mode: PinOutput})
+	f.CS.High()
+
+	// The chip may be in deep power-down mode, in which it ignores all other
+	// commands.
+	f.command(spiFlashCmdReleasePD)
+	sleepMicroseconds(50)
+
+	f.CS.Low()
+	f.buf[0] = spiFlashCmdReadJEDECID
+	err := f.Bus.Tx(f.buf[:1], nil)
+	if err == nil {
+		err = f.Bus.Tx(nil, f.info.JEDECID[:])
+	}
+	f.CS.High()
+	if err != nil {
+		return err
+	}
+	id := f.info.JEDECID
+	if id == [3]byte{0, 0, 0} || id == [3]byte{0xff, 0xff, 0xff} {
+		return errSPIFlashNotFound
+	}
+
+	f.info.PageSize = spiFlashDefaultPageSize
+	f.info.EraseBlockSize = spiFlashDefaultEraseSize
+	f.info.eraseCmd = spiFlashCmdSectorErase
+	if !f.readSFDP() {
+		// The capacity byte of most chips is the log2 of the size in bytes.
+		f.info.Size = 1 << (id[2] & 0x3f)
+	}
+	f.info.AddressBytes = 3
+	if f.info.Size > 1<<24 {
+		f.info.AddressBytes = 4
+		f.command(spiFlashCmdEnter4Byte)
+	}
+	return nil
+}
+
+// readSFDP reads the basic flash parameter table, and returns whether the chip
+// has one.
+func (f *SPIFlash) readSFDP() bool {
+	var header [16]byte
+	if f.readSFDPData(0, header[:]) != nil {
+		return false
+	}
+	if binary.LittleEndian.Uint32(header[0:]) != spiFlashSFDPSignature {
+		return false
+	}
+	// The first parameter header always points to the basic flash parameter
+	// table.
+	length := int(header[11])
+	if length > 16 {
+		length = 16
+	}
+	if length < 2 {
+		return false
+	}
+	pointer := uint32(header[12]) | uint32(header[13])<<8 | uint32(header[14])<<16
+	var table [16 * 4]byte
+	if f.readSFDPData(pointer, table[:length*4]) != nil {
+		return false
+	}
+	dword := func(n int) uint32 {
+		if n > length {
+			return 0
+		}
+		return binary.LittleEndian.Uint32(table[(n-1)*4:])
+	}
+
+	// DWORD 1: 4kB erase and fast read modes.
+	d1 := dword(1)
+	f.info.DualOutput = d1&(1<<16) != 0
+	f.info.DualIO = d1&(1<<20) != 0
+	f.info.QuadIO = d1&(1<<21) != 0
+	f.info.QuadOutput = d1&(1<<22) != 0
+
+	// DWORD 2: density in bits.
+	d2 := dword(2)
+	if d2&(1<<31) == 0 {
+		f.info.Size = (int64(d2) + 1) / 8
+	} else {
+		f.info.Size = (1 << (d2 & 0x7fffffff)) / 8
+	}
+
+	if d1&0x3 == 0x1 {
+		f.info.eraseCmd = uint8(d1 >> 8)
+	} else {
+		// No 4kB erase, use the smallest of the erase types in DWORD 8 and
+		// 9. Each has a size (log2) and a command.
+		f.info.EraseBlockSize = 0
+		for _, d := range [...]uint32{dword(8), dword(9)} {
+			for ; d != 0; d >>= 16 {
+				size, cmd := int64(1)<<uint8(d), uint8(d>>8)
+				if uint8(d) != 0 && (f.info.EraseBlockSize == 0 || size < f.info.EraseBlockSize) {
+					f.info.EraseBlockSize = size
+					f.info.eraseCmd = cmd
+				}
+			}
+		}
+		if f.info.EraseBlockSize == 0 {
+			f.info.EraseBlockSize = spiFlashDefaultEraseSize
+			f.info.eraseCmd = spiFlashCmdSectorErase
+		}
+	}
+
+	// DWORD 11: page size.
+	if d11 := dword(11); d11 != 0 {
+		f.info.PageSize = 1 << ((d11 >> 4) & 0xf)
+	}
+	return true
+}
+
+func (f *SPIFlash) readSFDPData(addr uint32, data []byte) error {
+	f.CS.Low()
+	f.buf[0] = spiFlashCmdReadSFDP
+	f.buf[1] = byte(addr >> 16)
+	f.buf[2] = byte(addr >> 8)
+	f.buf[3] = byte(addr)
+	f.buf[4] = 0 // dummy byte
+	err := f.Bus.Tx(f.buf[:5], nil)
+	if err == nil {
+		err = f.Bus.Tx(nil, data)
+	}
+	f.CS.High()
+	return err
+}
+
+// Info returns information about the flash chip.
+func (f *SPIFlash) Info() SPIFlashInfo {
+	return f.info
+}
+
+// Lock locks the chip for the calling goroutine. Goroutines that share the
+// chip, such as a filesystem and a firmware updater, must hold the lock while
+// they access it: erases and writes give up the CPU while waiting.
+func (f *SPIFlash) Lock() {
+	f.lock.Lock()
+}
+
+// Unlock unlocks the chip.
+func (f *SPIFlash) Unlock() {
+	f.lock.Unlock()
+}
+
+// Size returns the size of the flash chip in bytes.
+func (f *SPIFlash) Size() int64 {
+	return f.info.Size
+}
+
+// WriteBlockSize returns 1: NOR flash can be programmed byte by byte.
+func (f *SPIFlash) WriteBlockSize() int64 {
+	return 1
+}
+
+// EraseBlockSize returns the size of the smallest region that can be erased.
+func (f *SPIFlash) EraseBlockSize() int64 {
+	return f.info.EraseBlockSize
+}
+
+// ReadAt reads len(p) bytes at offset off, using the fast read command.
+func (f *SPIFlash) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > f.info.Size {
+		return 0, errSPIFlashRange
+	}
+	n := f.header(spiFlashCmdFastRead, uint32(off))
+	f.buf[n] = 0 // dummy byte
+	f.CS.Low()
+	err := f.Bus.Tx(f.buf[:n+1], nil)
+	if err == nil {
+		err = f.Bus.Tx(nil, p)
+	}
+	f.CS.High()
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteAt programs len(p) bytes at offset off. The region must have been
+// erased.
+func (f *SPIFlash) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > f.info.Size {
+		return 0, errSPIFlashRange
+	}
+	written := 0
+	for len(p) > 0 {
+		// A program command wraps around at the end of a page.
+		chunk := int(f.info.PageSize - off%f.info.PageSize)
+		if chunk > len(p) {
+			chunk = len(p)
+		}
+		f.command(spiFlashCmdWriteEnable)
+		n := f.header(spiFlashCmdPageProgram, uint32(off))
+		f.CS.Low()
+		err := f.Bus.Tx(f.buf[:n], nil)
+		if err == nil {
+			err = f.Bus.Tx(p[:chunk], nil)
+		}
+		f.CS.High()
+		if err == nil {
+			err = f.waitReady()
+		}
+		if err != nil {
+			return written, err
+		}
+		written += chunk
+		off += int64(chunk)
+		p = p[chunk:]
+	}
+	return written, nil
+}
+
+// EraseBlocks erases count blocks of EraseBlockSize bytes, starting at block
+// start.
+func (f *SPIFlash) EraseBlocks(start, count int64) error {
+	if start < 0 || count < 0 || (start+count)*f.info.EraseBlockSize > f.info.Size {
+		return errSPIFlashRange
+	}
+	for block := start; block < start+count; block++ {
+		f.command(spiFlashCmdWriteEnable)
+		n := f.header(f.info.eraseCmd, uint32(block*f.info.EraseBlockSize))
+		f.CS.Low()
+		err := f.Bus.Tx(f.buf[:n], nil)
+		f.CS.High()
+		if err == nil {
+			err = f.waitReady()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// header puts a command and address into f.buf, and returns its length.
+func (f *SPIFlash) header(cmd uint8, addr uint32) int {
+	f.buf[0] = cmd
+	if f.info.AddressBytes == 4 {
+		binary.BigEndian.PutUint32(f.buf[1:], addr)
+		return 5
+	}
+	f.buf[1] = byte(addr >> 16)
+	f.buf[2] = byte(addr >> 8)
+	f.buf[3] = byte(addr)
+	return 4
+}
+
+// command sends a command without arguments.
+func (f *SPIFlash) command(cmd uint8) {
+	f.CS.Low()
+	f.buf[0] = cmd
+	f.Bus.Tx(f.buf[:1], nil)
+	f.CS.High()
+}
+
+// waitReady waits until the chip has finished an erase or program command.
+func (f *SPIFlash) waitReady() error {
+	deadline := nanotime() + spiFlashTimeout*1000
+	for {
+		f.CS.Low()
+		f.buf[0] = spiFlashCmdReadStatus
+		err := f.Bus.Tx(f.buf[:1], nil)
+		if err == nil {
+			err = f.Bus.Tx(nil, f.buf[1:2])
+		}
+		f.CS.High()
+		if err != nil {
+			return err
+		}
+		if f.buf[1]&spiFlashStatusBusy == 0 {
+			return nil
+		}
+		if nanotime() > deadline {
+			return errSPIFlashTimeout
+		}
+		feedWatchdog()
+		gosched()
+	}
+}
@@ -0,0 +1,44 @@
+package machine
+
+import (
+	"internal/task"
+	_ "unsafe" // for go:linkname
+)
+
+// StackUsage returns the most stack space that the calling goroutine used so
+// far, its high water mark, and the size of its stack, in bytes. It can only
+// measure the goroutine that calls it, so a goroutine whose stack size matters
+// has to call it itself. Together with PeakHeapUsage, it shows whether the
+// stack and buffer sizes of a program can be tuned:
+//
+//	var stats runtime.MemStats
+//	runtime.ReadMemStats(&stats)
+//	used, size := machine.StackUsage()
+//	println("heap:", machine.PeakHeapUsage(), "of", stats.HeapSys)
+//	println("stack:", used, "of", size)
+//
+// Filling a stack with a known pattern when its goroutine starts makes the
+// start slower, so it is only done when the program is built with
+// -tags=task.stackusage; the used space is then the part of the pattern that
+// was overwritten. StackUsage returns 0, 0 without that tag and with schedulers
+// other than tasks. Called from an interrupt, it reports the goroutine that
+// was interrupted. The size of a stack is computed at compile time when
+// possible, and is otherwise set by the -stack-size flag or the
+// default-stack-size of the target.
+func StackUsage() (used, size uintptr) {
+	t := task.Current()
+	if t == nil {
+		return 0, 0
+	}
+	return t.StackUsage()
+}
+
+//go:linkname peakHeapInuse runtime.peakHeapInuse
+func peakHeapInuse() uintptr
+
+// PeakHeapUsage returns the most heap space in use since the program started,
+// its high water mark, in bytes. runtime.MemStats only has the current heap
+// usage, in HeapInuse.
+func PeakHeapUsage() uintptr {
+	return peakHeapInuse()
+}
@@ -0,0 +1,211 @@
+//go:build !gameboyadvance
+// +build !gameboyadvance
+
+package machine
+
+import "image/color"
+
+// StatusLEDPWM is the part of a PWM peripheral that a StatusLED uses. The PWM
+// types of most chips implement it.
+type StatusLEDPWM interface {
+	Configure(config PWMConfig) error
+	Channel(pin Pin) (uint8, error)
+	Top() uint32
+	Set(channel uint8, value uint32)
+}
+
+// StatusLEDStep is one step of a StatusLED pattern: a color shown for a
+// number of milliseconds.
+type StatusLEDStep struct {
+	Color    color.RGBA
+	Duration uint32 // milliseconds
+}
+
+type statusLEDMode uint8
+
+const (
+	statusLEDSolid statusLEDMode = iota
+	statusLEDBlink
+	statusLEDBreathe
+	statusLEDPattern
+)
+
+// StatusLED shows the state of a device on an RGB LED: a color, a blinking or
+// breathing color, or a sequence of colors. Channels with a PWM peripheral are
+// dimmed, the others are switched on for color values of 128 and above.
+//
+// Blinking, breathing and patterns are computed from the current time by
+// Update, which a goroutine calls every 10ms for smooth breathing, until the
+// LED shows a solid color again. Without a scheduler (-scheduler=none) there
+// are no goroutines, and Update must be called regularly from the main loop.
+type StatusLED struct {
+	// LEDs are the red, green and blue LED.
+	LEDs [3]BoardLED
+
+	// PWM is the PWM peripheral of each LED, or nil for an LED that can only be
+	// switched on and off.
+	PWM [3]StatusLEDPWM
+
+	channels [3]uint8
+	mode     statusLEDMode
+	color    color.RGBA
+	on, off  uint32 // blink times, or the breathing period in on
+	pattern  []StatusLEDStep
+	repeat   bool
+	start    int64
+	shown    color.RGBA
+	valid    bool // shown is what the LED shows
+	running  bool // the goroutine that calls Update is running
+}
+
+// statusLEDInterval is the time between calls to Update by the goroutine, in
+// nanoseconds.
+const statusLEDInterval = 10e6
+
+// Configure configures the pins and PWM peripherals, and turns the LED off.
+func (s *StatusLED) Configure() error {
+	for i, led := range s.LEDs {
+		pwm := s.PWM[i]
+		if pwm == nil {
+			led.Configure()
+			continue
+		}
+		// LEDs may share a PWM peripheral, configure it once.
+		configured := false
+		for j := 0; j < i; j++ {
+			configured = configured || s.PWM[j] == pwm
+		}
+		if !configured {
+			if err := pwm.Configure(PWMConfig{}); err != nil {
+				return err
+			}
+		}
+		ch, err := pwm.Channel(led.Pin)
+		if err != nil {
+			return err
+		}
+		s.channels[i] = ch
+	}
+	s.valid = false
+	s.SetColor(color.RGBA{})
+	return nil
+}
+
+// SetColor shows a color until the next call.
+func (s *StatusLED) SetColor(c color.RGBA) {
+	s.mode = statusLEDSolid
+	s.color = c
+	s.show(c)
+}
+
+// Blink alternates between a color for on milliseconds and off for off
+// milliseconds.
+func (s *StatusLED) Blink(c color.RGBA, on, off uint32) {
+	s.set(statusLEDBlink, c, on, off)
+}
+
+// Breathe fades a color in and out, once per period milliseconds.
+func (s *StatusLED) Breathe(c color.RGBA, period uint32) {
+	s.set(statusLEDBreathe, c, period, 0)
+}
+
+// SetPattern shows the colors of a pattern one after the other, and starts
+// over at the end if repeat is set. Otherwise the last color stays on.
+func (s *StatusLED) SetPattern(steps []StatusLEDStep, repeat bool) {
+	s.pattern = steps
+	s.repeat = repeat
+	s.set(statusLEDPattern, color.RGBA{}, 0, 0)
+}
+
+func (s *StatusLED) set(mode statusLEDMode, c color.RGBA, on, off uint32) {
+	s.mode = mode
+	s.color = c
+	s.on, s.off = on, off
+	s.start = nanotime()
+	s.Update()
+	if !s.running && s.mode != statusLEDSolid {
+		s.running = goBackground(s.run)
+	}
+}
+
+// run calls Update until the LED shows a solid color.
+func (s *StatusLED) run() {
+	for s.mode != statusLEDSolid {
+		sleep(statusLEDInterval)
+		s.Update()
+	}
+	s.running = false
+}
+
+// Update brings the LED up to date with a blinking or breathing color or a
+// pattern. It does nothing for a solid color. A pattern that doesn't repeat
+// becomes the solid color of its last step when it ends.
+func (s *StatusLED) Update() {
+	ms := uint32((nanotime() - s.start) / 1e6)
+	switch s.mode {
+	case statusLEDBlink:
+		if s.on+s.off == 0 || ms%(s.on+s.off) < s.on {
+			s.show(s.color)
+		} else {
+			s.show(color.RGBA{})
+		}
+	case statusLEDBreathe:
+		if s.on == 0 {
+			s.show(s.color)
+			return
+		}
+		// Triangle wave, squared because the eye sees brightness roughly
+		// logarithmically.
+		phase := ms % s.on * 512 / s.on
+		if phase >= 256 {
+			phase = 511 - phase
+		}
+		level := phase * phase / 255
+		s.show(color.RGBA{
+			R: uint8(uint32(s.color.R) * level / 255),
+			G: uint8(uint32(s.color.G) * level / 255),
+			B: uint8(uint32(s.color.B) * level / 255),
+		})
+	case statusLEDPattern:
+		var total uint32
+		for _, step := range s.pattern {
+			total += step.Duration
+		}
+		if len(s.pattern) == 0 {
+			s.SetColor(color.RGBA{})
+			return
+		}
+		if total == 0 || (!s.repeat && ms >= total) {
+			s.SetColor(s.pattern[len(s.pattern)-1].Color)
+			return
+		}
+		ms %= total
+		for _, step := range s.pattern {
+			if ms < step.Duration {
+				s.show(step.Color)
+				return
+			}
+			ms -= step.Duration
+		}
+	}
+}
+
+// show sets the LEDs to a color, if they don't show it already.
+func (s *StatusLED) show(c color.RGBA) {
+	if s.valid && c == s.shown {
+		return
+	}
+	s.shown, s.valid = c, true
+	for i, value := range [3]uint8{c.R, c.G, c.B} {
+		led, pwm := s.LEDs[i], s.PWM[i]
+		if pwm == nil {
+			led.Set(value >= 128)
+			continue
+		}
+		duty := uint32(uint64(pwm.Top()) * uint64(value) / 255)
+		if led.ActiveLow {
+			duty = pwm.Top() - duty
+		}
+		pwm.Set(s.channels[i], duty)
+	}
+}
@@ -0,0 +1,520 @@
+//go:build !gameboyadvance
+// +build !gameboyadvance
+
+package machine
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// SWD is a Serial Wire Debug host on two GPIO pins, to program or recover
+// another Cortex-M chip from a TinyGo board, for example in a production test
+// fixture. It gives access to the debug port (DP) and access ports (AP) of the
+// target, to its memory through the first memory access port (MEM-AP), and to
+// its core, to run a flash algorithm on it:
+//
+//	swd := machine.SWD{SWCLK: machine.GPIO2, SWDIO: machine.GPIO3}
+//	swd.Configure()
+//	idcode, err := swd.Connect()
+//	// Load a flash algorithm into the RAM of the target, and call it.
+//	swd.Halt()
+//	swd.WriteMemory(0x20000000, algorithm)
+//	status, err := swd.Call(0x20000001+initOffset, 0x20001000, breakpoint, flashBase, freq, 1)
+//
+// SWDIO needs a pull-up, which most targets have internally.
+type SWD struct {
+	SWCLK Pin
+	SWDIO Pin
+
+	// Delay is half the period of SWCLK in nanoseconds. Zero toggles SWCLK
+	// as fast as the pins allow, which targets accept when the wires are
+	// short.
+	Delay uint32
+
+	// Turnaround is the number of clock cycles, 1 to 4, between the driving
+	// of SWDIO by the host and by the target. Zero is one cycle, the value
+	// targets use after a reset.
+	Turnaround uint8
+
+	// DataPhase makes Transfer clock a data phase after WAIT and FAULT
+	// answers too, for targets whose ORUNDETECT bit is set.
+	DataPhase bool
+
+	// IdleCycles is the number of idle cycles Transfer sends after a
+	// successful transfer.
+	IdleCycles uint8
+
+	// selected is the value of the SELECT register of the DP, to avoid
+	// writing it again.
+	selected uint32
+	selectOK bool
+}
+
+// Registers of the debug port and of a MEM-AP.
+const (
+	swdDPIDR    = 0x00 // read
+	swdABORT    = 0x00 // write
+	swdCTRLSTAT = 0x04
+	swdSELECT   = 0x08
+	swdRDBUFF   = 0x0c
+
+	swdAPCSW = 0x00
+	swdAPTAR = 0x04
+	swdAPDRW = 0x0c
+
+	// CSW for 32-bit accesses that increment TAR.
+	swdCSWWord = 0x23000052
+
+	swdAckOK    = 1
+	swdAckWait  = 2
+	swdAckFault = 4
+
+	// Bits of the request of Transfer.
+	swdRequestAP   = 1 << 0
+	swdRequestRead = 1 << 1
+
+	swdWaitRetries = 100
+)
+
+// Debug registers of the core of the target.
+const (
+	swdDHCSR = 0xe000edf0
+	swdDCRSR = 0xe000edf4
+	swdDCRDR = 0xe000edf8
+
+	swdDHCSRKey        = 0xa05f << 16
+	swdDHCSRDebugEn    = 1 << 0
+	swdDHCSRHalt       = 1 << 1
+	swdDHCSRRegReady   = 1 << 16
+	swdDHCSRHalted     = 1 << 17
+	swdDCRSRWrite      = 1 << 16
+	swdCoreRegSP       = 13
+	swdCoreRegLR       = 14
+	swdCoreRegPC       = 15
+	swdCoreRegXPSR     = 16
+	swdXPSRThumb       = 1 << 24
+	swdCallTimeout     = 5e9 // nanoseconds
+	swdRegReadyTimeout = 1e6 // nanoseconds
+)
+
+var (
+	errSWDNoAck   = errors.New("swd: no response from target")
+	errSWDWait    = errors.New("swd: target kept answering WAIT")
+	errSWDFault   = errors.New("swd: target answered FAULT")
+	errSWDParity  = errors.New("swd: parity error")
+	errSWDTimeout = errors.New("swd: timeout waiting for the core")
+)
+
+// Configure configures the pins.
+func (s *SWD) Configure() {
+	s.SWCLK.Configure(PinConfig{Mode: PinOutput})
+	s.SWCLK.Low()
+	s.SWDIO.Configure(PinConfig{Mode: PinOutput})
+	s.SWDIO.High()
+	s.selectOK = false
+}
+
+// Connect switches the target from JTAG to SWD, resets the line, and powers up
+// its debug domain. It returns the IDCODE of the debug port.
+func (s *SWD) Connect() (uint32, error) {
+	s.LineReset()
+	// The JTAG to SWD sequence, followed by another line reset.
+	s.writeBits(0xe79e, 16)
+	s.LineReset()
+	s.selectOK = false
+	idcode, err := s.ReadDP(swdDPIDR)
+	if err != nil {
+		return 0, err
+	}
+	// Clear the sticky errors, and request the debug and system power up.
+	if err := s.WriteDP(swdABORT, 0x1e); err != nil {
+		return 0, err
+	}
+	if err := s.WriteDP(swdCTRLSTAT, 0x50000000); err != nil {
+		return 0, err
+	}
+	for i := 0; ; i++ {
+		stat, err := s.ReadDP(swdCTRLSTAT)
+		if err != nil {
+			return 0, err
+		}
+		if stat&0xa0000000 == 0xa0000000 {
+			break
+		}
+		if i == swdWaitRetries {
+			return 0, errSWDTimeout
+		}
+	}
+	return idcode, nil
+}
+
+// LineReset holds SWDIO high for 56 clock cycles, followed by idle cycles.
+func (s *SWD) LineReset() {
+	s.writeBits(0xffffffff, 32)
+	s.writeBits(0xffffff, 24)
+	s.writeBits(0, 8)
+}
+
+// ReadDP reads a register of the debug port.
+func (s *SWD) ReadDP(addr uint8) (uint32, error) {
+	return s.read(false, addr)
+}
+
+// WriteDP writes a register of the debug port.
+func (s *SWD) WriteDP(addr uint8, value uint32) error {
+	return s.write(false, addr, value)
+}
+
+// ReadAP reads register addr of access port ap.
+func (s *SWD) ReadAP(ap, addr uint8) (uint32, error) {
+	if err := s.selectAP(ap, addr); err != nil {
+		return 0, err
+	}
+	// AP reads are posted: the value comes with the next read.
+	if _, err := s.read(true, addr); err != nil {
+		return 0, err
+	}
+	return s.ReadDP(swdRDBUFF)
+}
+
+// WriteAP writes register addr of access port ap.
+func (s *SWD) WriteAP(ap, addr uint8, value uint32) error {
+	if err := s.selectAP(ap, addr); err != nil {
+		return err
+	}
+	return s.write(true, addr, value)
+}
+
+func (s *SWD) selectAP(ap, addr uint8) error {
+	sel := uint32(ap)<<24 | uint32(addr&0xf0)
+	if s.selectOK && s.selected == sel {
+		return nil
+	}
+	if err := s.WriteDP(swdSELECT, sel); err != nil {
+		return err
+	}
+	s.selected, s.selectOK = sel, true
+	return nil
+}
+
+// ReadMemory reads words from the memory of the target, at a word aligned
+// address, through the first MEM-AP.
+func (s *SWD) ReadMemory(addr uint32, data []uint32) error {
+	if err := s.WriteAP(0, swdAPCSW, swdCSWWord); err != nil {
+		return err
+	}
+	for i := range data {
+		// TAR only increments within a 1kB block.
+		if i == 0 || (addr+uint32(i)*4)&0x3ff == 0 {
+			if err := s.WriteAP(0, swdAPTAR, addr+uint32(i)*4); err != nil {
+				return err
+			}
+		}
+		v, err := s.ReadAP(0, swdAPDRW)
+		if err != nil {
+			return err
+		}
+		data[i] = v
+	}
+	return nil
+}
+
+// WriteMemory writes words to the memory of the target, at a word aligned
+// address, through the first MEM-AP.
+func (s *SWD) WriteMemory(addr uint32, data []uint32) error {
+	if err := s.WriteAP(0, swdAPCSW, swdCSWWord); err != nil {
+		return err
+	}
+	for i, v := range data {
+		if i == 0 || (addr+uint32(i)*4)&0x3ff == 0 {
+			if err := s.WriteAP(0, swdAPTAR, addr+uint32(i)*4); err != nil {
+				return err
+			}
+		}
+		if err := s.WriteAP(0, swdAPDRW, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Halt stops the core of the target.
+func (s *SWD) Halt() error {
+	if err := s.writeWord(swdDHCSR, swdDHCSRKey|swdDHCSRDebugEn|swdDHCSRHalt); err != nil {
+		return err
+	}
+	return s.waitDHCSR(swdDHCSRHalted, swdRegReadyTimeout)
+}
+
+// Resume lets the halted core of the target run again.
+func (s *SWD) Resume() error {
+	return s.writeWord(swdDHCSR, swdDHCSRKey|swdDHCSRDebugEn)
+}
+
+// Call runs a function on the halted core of the target, such as a function
+// of a CMSIS flash algorithm, and returns the value it returns in r0. The
+// function gets up to four arguments in r0 to r3, the stack pointer sp, and
+// returns to breakpoint, the address of a BKPT instruction that halts the core
+// again. Call waits up to 5 seconds for the function, which covers the erase
+// of a sector on most chips.
+func (s *SWD) Call(fn, sp, breakpoint uint32, args ...uint32) (uint32, error) {
+	for i, arg := range args {
+		if err := s.writeCoreReg(uint32(i), arg); err != nil {
+			return 0, err
+		}
+	}
+	for _, r := range [...]struct{ reg, value uint32 }{
+		{swdCoreRegSP, sp},
+		{swdCoreRegLR, breakpoint | 1},
+		{swdCoreRegPC, fn &^ 1},
+		{swdCoreRegXPSR, swdXPSRThumb},
+	} {
+		if err := s.writeCoreReg(r.reg, r.value); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.Resume(); err != nil {
+		return 0, err
+	}
+	if err := s.waitDHCSR(swdDHCSRHalted, swdCallTimeout); err != nil {
+		return 0, err
+	}
+	// Read r0.
+	if err := s.writeWord(swdDCRSR, 0); err != nil {
+		return 0, err
+	}
+	if err := s.waitDHCSR(swdDHCSRRegReady, swdRegReadyTimeout); err != nil {
+		return 0, err
+	}
+	return s.readWord(swdDCRDR)
+}
+
+func (s *SWD) writeCoreReg(reg, value uint32) error {
+	if err := s.writeWord(swdDCRDR, value); err != nil {
+		return err
+	}
+	if err := s.writeWord(swdDCRSR, swdDCRSRWrite|reg); err != nil {
+		return err
+	}
+	return s.waitDHCSR(swdDHCSRRegReady, swdRegReadyTimeout)
+}
+
+func (s *SWD) waitDHCSR(mask uint32, timeout int64) error {
+	deadline := nanotime() + timeout
+	for {
+		v, err := s.readWord(swdDHCSR)
+		if err != nil {
+			return err
+		}
+		if v&mask != 0 {
+			return nil
+		}
+		if nanotime() > deadline {
+			return errSWDTimeout
+		}
+	}
+}
+
+func (s *SWD) readWord(addr uint32) (uint32, error) {
+	var v [1]uint32
+	err := s.ReadMemory(addr, v[:])
+	return v[0], err
+}
+
+func (s *SWD) writeWord(addr, value uint32) error {
+	v := [1]uint32{value}
+	return s.WriteMemory(addr, v[:])
+}
+
+// read sends a read request, retrying while the target answers WAIT.
+func (s *SWD) read(ap bool, addr uint8) (uint32, error) {
+	request := swdRequest(ap, true, addr)
+	for i := 0; i < swdWaitRetries; i++ {
+		var value uint32
+		ack, err := s.Transfer(request, &value)
+		if ack == swdAckOK {
+			return value, err
+		}
+		if err := swdAckError(ack); err != errSWDWait {
+			return 0, err
+		}
+	}
+	return 0, errSWDWait
+}
+
+// write sends a write request, retrying while the target answers WAIT.
+func (s *SWD) write(ap bool, addr uint8, value uint32) error {
+	request := swdRequest(ap, false, addr)
+	for i := 0; i < swdWaitRetries; i++ {
+		ack, _ := s.Transfer(request, &value)
+		if ack == swdAckOK {
+			s.writeBits(0, 8) // idle cycles, in which the target does the write
+			return nil
+		}
+		if err := swdAckError(ack); err != errSWDWait {
+			return err
+		}
+	}
+	return errSWDWait
+}
+
+func swdRequest(ap, read bool, addr uint8) uint8 {
+	request := addr & 0xc
+	if ap {
+		request |= swdRequestAP
+	}
+	if read {
+		request |= swdRequestRead
+	}
+	return request
+}
+
+// Transfer sends a single request to the target, and returns the ACK of the
+// target: 1 for OK, 2 for WAIT, 4 for FAULT, and any other value when there
+// was no answer. The bits of request are those of the CMSIS-DAP DAP_Transfer
+// command: APnDP, RnW and A[3:2]. A read stores the value in data, and also
+// returns an error when its parity is wrong; a write sends the value in data.
+//
+// ReadDP, WriteDP, ReadAP and WriteAP are built on Transfer. It is exported
+// for debug probes, such as the one of the machine/usb/dap package, which
+// leave the retries to the debugger on the host.
+func (s *SWD) Transfer(request uint8, data *uint32) (uint8, error) {
+	header := uint32(1) // start
+	header |= uint32(request&0xf) << 1
+	header |= uint32(bits.OnesCount8(request&0xf)&1) << 5
+	header |= 1 << 7 // park; the stop bit is zero
+	s.writeBits(header, 8)
+	s.turnaround(false)
+	ack := uint8(s.readBits(3))
+
+	var err error
+	read := request&swdRequestRead != 0
+	switch ack {
+	case swdAckOK:
+		if read {
+			value := s.readBits(32)
+			if uint32(bits.OnesCount32(value)&1) != s.readBits(1) {
+				err = errSWDParity
+			}
+			*data = value
+			s.turnaround(true)
+		} else {
+			s.turnaround(true)
+			s.writeBits(*data, 32)
+			s.writeBits(uint32(bits.OnesCount32(*data)&1), 1)
+		}
+		s.writeBits(0, int(s.IdleCycles))
+	case swdAckWait, swdAckFault:
+		if s.DataPhase && read {
+			s.readBits(32)
+			s.readBits(1)
+		}
+		s.turnaround(true)
+		if s.DataPhase && !read {
+			s.writeBits(0, 32)
+			s.writeBits(0, 1)
+		}
+	default:
+		// The target didn't answer; clock a whole data phase to get back in
+		// sync with it.
+		s.readBits(32)
+		s.readBits(1)
+		s.turnaround(true)
+	}
+	s.SWDIO.High()
+	return ack, err
+}
+
+// Sequence sends the first n bits of data on SWDIO, least significant bit of
+// each byte first, such as the JTAG to SWD sequence of a debugger.
+func (s *SWD) Sequence(n int, data []byte) {
+	for i := 0; i < n; i += 8 {
+		count := n - i
+		if count > 8 {
+			count = 8
+		}
+		s.writeBits(uint32(data[i/8]), count)
+	}
+}
+
+// ReadSequence reads n bits from SWDIO into data, least significant bit of
+// each byte first. SWDIO is only an input while it does.
+func (s *SWD) ReadSequence(n int, data []byte) {
+	s.SWDIO.Configure(PinConfig{Mode: PinInput})
+	for i := 0; i < n; i += 8 {
+		count := n - i
+		if count > 8 {
+			count = 8
+		}
+		data[i/8] = byte(s.readBits(count))
+	}
+	s.SWDIO.Configure(PinConfig{Mode: PinOutput})
+}
+
+func swdAckError(ack uint8) error {
+	switch ack {
+	case swdAckWait:
+		return errSWDWait
+	case swdAckFault:
+		return errSWDFault
+	default:
+		return errSWDNoAck
+	}
+}
+
+// turnaround lets the turnaround cycles pass while the direction of SWDIO
+// changes, to an output when toOutput is set.
+func (s *SWD) turnaround(toOutput bool) {
+	if !toOutput {
+		s.SWDIO.Configure(PinConfig{Mode: PinInput})
+	}
+	for i := uint8(0); i == 0 || i < s.Turnaround; i++ {
+		s.clock()
+	}
+	if toOutput {
+		s.SWDIO.Configure(PinConfig{Mode: PinOutput})
+	}
+}
+
+// writeBits writes n bits of value, least significant first. The target
+// samples SWDIO on the rising edges of SWCLK.
+func (s *SWD) writeBits(value uint32, n int) {
+	for i := 0; i < n; i++ {
+		s.SWDIO.Set(value&1 != 0)
+		value >>= 1
+		s.clock()
+	}
+}
+
+// readBits reads n bits, least significant first. The target changes SWDIO
+// after the rising edges of SWCLK, so it is sampled while SWCLK is low.
+func (s *SWD) readBits(n int) uint32 {
+	var value uint32
+	for i := 0; i < n; i++ {
+		s.wait()
+		if s.SWDIO.Get() {
+			value |= 1 << i
+		}
+		s.SWCLK.High()
+		s.wait()
+		s.SWCLK.Low()
+	}
+	return value
+}
+
+// clock sends a clock cycle.
+func (s *SWD) clock() {
+	s.wait()
+	s.SWCLK.High()
+	s.wait()
+	s.SWCLK.Low()
+}
+
+func (s *SWD) wait() {
+	if s.Delay == 0 {
+		return
+	}
+	for deadline := nanotime() + int64(s.Delay); nanotime() < deadline; {
+	}
+}
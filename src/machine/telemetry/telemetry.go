@@ -0,0 +1,196 @@
+// Package telemetry sends and receives small binary messages over a serial
+// link (a UART or USB CDC), as a fast alternative to formatting text with
+// fmt: a sample of a few sensor values takes a few bytes and a few
+// microseconds to send, and the host can decode it without parsing text.
+//
+// # Wire format
+//
+// Every message is a frame that ends with a zero byte. The frame before it is
+// the COBS (Consistent Overhead Byte Stuffing) encoding of a packet, which
+// contains no zero bytes, so a receiver finds the start of the next message
+// after any zero byte, also when it starts listening in the middle of a
+// message or loses bytes. A packet is:
+//
+//	channel   1 byte, which the application uses to tell messages apart
+//	data      0 to MaxDataSize bytes
+//	crc       2 bytes, little endian: CRC-16/CCITT-FALSE (polynomial 0x1021,
+//	          initial value 0xffff, no reflection, no final xor) of the
+//	          channel and data
+//
+// The layout of the data is up to the application. Multi-byte values are
+// little endian by convention, as written by encoding/binary.LittleEndian.
+// Data that needs to describe itself, with optional or added fields, can be
+// CBOR encoded with package machine/cbor, which doesn't allocate either.
+//
+// # Decoding on the host
+//
+// Read the serial port, and split the bytes at every zero byte. For each
+// non-empty frame:
+//
+//  1. COBS decode it: the first byte n is followed by n-1 data bytes, which
+//     are followed by a zero byte unless n is 0xff or the frame ends there.
+//     Repeat with the byte after the data bytes until the end of the frame.
+//  2. Drop the packet if it is shorter than 3 bytes, or if the CRC of all but
+//     its last 2 bytes doesn't match these 2 bytes.
+//  3. Dispatch the data between the first byte and the CRC on the channel in
+//     the first byte.
+//
+// This package doesn't depend on the machine package, so host programs in Go
+// can use its Decoder directly.
+package telemetry
+
+import (
+	"errors"
+	"io"
+)
+
+// MaxDataSize is the largest amount of data in a message.
+const MaxDataSize = 252
+
+const (
+	// maxPacketSize is the size of a packet with MaxDataSize bytes of data:
+	// the channel, the data and the CRC.
+	maxPacketSize = 1 + MaxDataSize + 2
+
+	// maxFrameSize is the size of the COBS encoding of the largest packet,
+	// which adds a code byte for every 254 bytes.
+	maxFrameSize = maxPacketSize + maxPacketSize/254 + 1
+)
+
+var errDataSize = errors.New("telemetry: data too large")
+
+// Encoder writes messages to a serial link.
+//
+//	enc := telemetry.NewEncoder(machine.Serial)
+//	var sample [4]byte
+//	binary.LittleEndian.PutUint16(sample[0:], temperature)
+//	binary.LittleEndian.PutUint16(sample[2:], pressure)
+//	enc.WriteMessage(1, sample[:])
+type Encoder struct {
+	w   io.Writer
+	buf [maxFrameSize + 1]byte
+}
+
+// NewEncoder returns an Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// WriteMessage sends a message with up to MaxDataSize bytes of data, in a
+// single write to the serial link.
+func (e *Encoder) WriteMessage(channel uint8, data []byte) error {
+	if len(data) > MaxDataSize {
+		return errDataSize
+	}
+	crc := crc16(crc16Byte(0xffff, channel), data)
+	trailer := [2]byte{byte(crc), byte(crc >> 8)}
+
+	// COBS encode the channel, data and CRC into e.buf. Each block starts with
+	// a code byte: the distance to the next zero byte, which is left out.
+	code, out := 0, 1
+	put := func(b byte) {
+		if b != 0 {
+			e.buf[out] = b
+			out++
+		}
+		if b == 0 || out-code == 0xff {
+			e.buf[code] = byte(out - code)
+			code = out
+			out++
+		}
+	}
+	put(channel)
+	for _, b := range data {
+		put(b)
+	}
+	put(trailer[0])
+	put(trailer[1])
+	e.buf[code] = byte(out - code)
+	e.buf[out] = 0
+	_, err := e.w.Write(e.buf[:out+1])
+	return err
+}
+
+// Decoder reassembles messages from the bytes received on a serial link, and
+// drops the ones that were damaged on the way.
+type Decoder struct {
+	// Handler is called for every message received. The data slice is only
+	// valid during the call.
+	Handler func(channel uint8, data []byte)
+
+	frame [maxFrameSize]byte
+	n     int
+	lost  bool // the current frame is too long, and is dropped
+}
+
+// Write decodes the received bytes, calling Handler for every message they
+// complete. It always returns len(p), nil, so that a Decoder can be used as an
+// io.Writer.
+func (d *Decoder) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b != 0 {
+			if d.n == len(d.frame) {
+				d.lost = true
+			} else {
+				d.frame[d.n] = b
+				d.n++
+			}
+			continue
+		}
+		if !d.lost {
+			d.decode()
+		}
+		d.n = 0
+		d.lost = false
+	}
+	return len(p), nil
+}
+
+// decode decodes the frame in d.frame, in place, and passes on the message.
+func (d *Decoder) decode() {
+	frame := d.frame[:d.n]
+	n := 0
+	for i := 0; i < len(frame); {
+		code := int(frame[i])
+		if i+code > len(frame) {
+			return
+		}
+		n += copy(frame[n:], frame[i+1:i+code])
+		i += code
+		if code != 0xff && i < len(frame) {
+			frame[n] = 0
+			n++
+		}
+	}
+	if n < 3 {
+		return
+	}
+	packet := frame[:n]
+	crc := uint16(packet[n-2]) | uint16(packet[n-1])<<8
+	if crc16(0xffff, packet[:n-2]) != crc {
+		return
+	}
+	if d.Handler != nil {
+		d.Handler(packet[0], packet[1:n-2])
+	}
+}
+
+// crc16 updates a CRC-16/CCITT-FALSE with data.
+func crc16(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		crc = crc16Byte(crc, b)
+	}
+	return crc
+}
+
+func crc16Byte(crc uint16, b byte) uint16 {
+	crc ^= uint16(b) << 8
+	for i := 0; i < 8; i++ {
+		if crc&0x8000 != 0 {
+			crc = crc<<1 ^ 0x1021
+		} else {
+			crc <<= 1
+		}
+	}
+	return crc
+}
@@ -0,0 +1,103 @@
+package telemetry
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCRC16(t *testing.T) {
+	// Check value of CRC-16/CCITT-FALSE.
+	if got := crc16(0xffff, []byte("123456789")); got != 0x29b1 {
+		t.Errorf("crc16 = %#x, want 0x29b1", got)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.WriteMessage(0x11, []byte{0x22, 0x00, 0x33}); err != nil {
+		t.Fatal(err)
+	}
+	crc := crc16(0xffff, []byte{0x11, 0x22, 0x00, 0x33})
+	want := []byte{0x03, 0x11, 0x22, 0x04, 0x33, byte(crc), byte(crc >> 8), 0x00}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("encoded % x, want % x", buf.Bytes(), want)
+	}
+	if bytes.IndexByte(buf.Bytes()[:buf.Len()-1], 0) >= 0 {
+		t.Error("zero byte inside frame")
+	}
+}
+
+type message struct {
+	channel uint8
+	data    []byte
+}
+
+func TestRoundTrip(t *testing.T) {
+	long := make([]byte, MaxDataSize)
+	for i := range long {
+		long[i] = byte(i%255 + 1)
+	}
+	messages := []message{
+		{0, nil},
+		{1, []byte{0}},
+		{2, []byte{0, 0, 0}},
+		{3, []byte("hello")},
+		{4, long},
+		{5, long[:253-2]},
+		{0xff, make([]byte, MaxDataSize)},
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, m := range messages {
+		if err := enc.WriteMessage(m.channel, m.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteMessage(0, make([]byte, MaxDataSize+1)); err == nil {
+		t.Error("no error for too much data")
+	}
+
+	var got []message
+	dec := Decoder{Handler: func(channel uint8, data []byte) {
+		got = append(got, message{channel, append([]byte(nil), data...)})
+	}}
+	// Feed the bytes one at a time, after some garbage to resync on.
+	dec.Write([]byte{0x42, 0x01, 0x13})
+	dec.Write([]byte{0})
+	for _, b := range buf.Bytes() {
+		dec.Write([]byte{b})
+	}
+	if len(got) != len(messages) {
+		t.Fatalf("decoded %d messages, want %d", len(got), len(messages))
+	}
+	for i, m := range messages {
+		if got[i].channel != m.channel || !bytes.Equal(got[i].data, m.data) {
+			t.Errorf("message %d: got %d % x, want %d % x", i, got[i].channel, got[i].data, m.channel, m.data)
+		}
+	}
+}
+
+func TestCorrupted(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.WriteMessage(7, []byte("temperature"))
+	frame := buf.Bytes()
+	count := 0
+	dec := Decoder{Handler: func(channel uint8, data []byte) { count++ }}
+	for i := 0; i < len(frame)-1; i++ {
+		damaged := append([]byte(nil), frame...)
+		damaged[i] ^= 0x40
+		if damaged[i] == 0 {
+			continue
+		}
+		dec.Write(damaged)
+	}
+	if count != 0 {
+		t.Errorf("%d damaged messages decoded", count)
+	}
+	dec.Write(frame)
+	if count != 1 {
+		t.Errorf("intact message not decoded")
+	}
+}
@@ -0,0 +1,276 @@
+package machine
+
+import (
+	"errors"
+	stdio "io"
+)
+
+// Terminal reads lines typed in a VT100 compatible terminal emulator, such as
+// screen, minicom or PuTTY, with the editing keys they send: backspace and
+// delete, the left and right arrows, home and end, and the up and down arrows
+// to go through the lines typed before. It is a building block for the
+// configuration shells of devices:
+//
+//	term := machine.Terminal{Port: machine.Serial, Prompt: "> "}
+//	for {
+//		line, err := term.ReadLine()
+//		if err != nil {
+//			continue
+//		}
+//		handle(line)
+//	}
+//
+// Ctrl-C discards the line, Ctrl-U clears it, and Ctrl-D on an empty line
+// makes ReadLine return io.EOF.
+type Terminal struct {
+	Port SerialPort
+
+	// Prompt is written before every line.
+	Prompt string
+
+	// NoEcho stops the characters typed from being echoed, to read a
+	// password. Lines read without echo are kept out of the history.
+	NoEcho bool
+
+	// HistorySize is the number of lines kept for the up arrow. Zero keeps
+	// 8 lines.
+	HistorySize int
+
+	line    [128]byte
+	n       int // length of the line
+	cursor  int // position of the cursor in the line
+	history []string
+	recall  int  // lines back in the history, while going through it
+	esc     byte // escape sequence state: 0, escape seen, or '[' or 'O' seen
+	param   byte // parameter of a CSI sequence, such as the 3 of delete
+	cr      bool // the last byte was a carriage return
+	buf     [32]byte
+}
+
+var ErrTerminalInterrupt = errors.New("machine: terminal line interrupted")
+
+const terminalHistorySize = 8
+
+// ReadLine writes the prompt and waits for a line, which it returns without
+// the line ending.
+func (t *Terminal) ReadLine() (string, error) {
+	t.n, t.cursor, t.recall = 0, 0, 0
+	t.write(t.Prompt)
+	for {
+		if t.Port.Buffered() == 0 {
+			gosched()
+			continue
+		}
+		c, err := t.Port.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		cr := t.cr
+		t.cr = c == '\r'
+		if t.esc != 0 {
+			t.escape(c)
+			continue
+		}
+		switch c {
+		case '\n':
+			if cr {
+				// The second half of a CRLF line ending.
+				continue
+			}
+			fallthrough
+		case '\r':
+			t.write("\r\n")
+			line := string(t.line[:t.n])
+			if !t.NoEcho {
+				t.remember(line)
+			}
+			return line, nil
+		case 0x03: // Ctrl-C
+			t.write("^C\r\n")
+			return "", ErrTerminalInterrupt
+		case 0x04: // Ctrl-D
+			if t.n == 0 {
+				t.write("\r\n")
+				return "", stdio.EOF
+			}
+		case 0x7f, 0x08: // backspace
+			if t.cursor > 0 {
+				t.cursor--
+				t.delete()
+			}
+		case 0x15: // Ctrl-U
+			t.n, t.cursor = 0, 0
+			t.redraw()
+		case 0x01: // Ctrl-A
+			t.cursor = 0
+			t.redraw()
+		case 0x05: // Ctrl-E
+			t.cursor = t.n
+			t.redraw()
+		case 0x1b:
+			t.esc, t.param = 1, 0
+		default:
+			if c >= ' ' {
+				t.insert(c)
+			}
+		}
+	}
+}
+
+// escape handles a byte of an escape sequence.
+func (t *Terminal) escape(c byte) {
+	if t.esc == 1 {
+		if c == '[' || c == 'O' {
+			t.esc = c
+		} else {
+			t.esc = 0
+		}
+		return
+	}
+	if c >= '0' && c <= '9' {
+		t.param = t.param*10 + c - '0'
+		return
+	}
+	t.esc = 0
+	switch c {
+	case 'A': // up
+		t.recallLine(t.recall + 1)
+	case 'B': // down
+		t.recallLine(t.recall - 1)
+	case 'C': // right
+		if t.cursor < t.n {
+			t.cursor++
+			t.redraw()
+		}
+	case 'D': // left
+		if t.cursor > 0 {
+			t.cursor--
+			t.redraw()
+		}
+	case 'H': // home
+		t.cursor = 0
+		t.redraw()
+	case 'F': // end
+		t.cursor = t.n
+		t.redraw()
+	case '~':
+		switch t.param {
+		case 1, 7: // home
+			t.cursor = 0
+			t.redraw()
+		case 3: // delete
+			if t.cursor < t.n {
+				t.delete()
+			}
+		case 4, 8: // end
+			t.cursor = t.n
+			t.redraw()
+		}
+	}
+}
+
+// insert inserts c at the cursor, unless the line is full.
+func (t *Terminal) insert(c byte) {
+	if t.n == len(t.line) {
+		return
+	}
+	copy(t.line[t.cursor+1:t.n+1], t.line[t.cursor:t.n])
+	t.line[t.cursor] = c
+	t.n++
+	t.cursor++
+	if t.cursor == t.n {
+		// Typing at the end of the line, the usual case, needs no redraw.
+		if !t.NoEcho {
+			t.buf[0] = c
+			t.Port.Write(t.buf[:1])
+		}
+		return
+	}
+	t.redraw()
+}
+
+// delete deletes the character at the cursor.
+func (t *Terminal) delete() {
+	copy(t.line[t.cursor:], t.line[t.cursor+1:t.n])
+	t.n--
+	t.redraw()
+}
+
+// recallLine replaces the line with the line that is back lines back in the
+// history, or with an empty line for zero.
+func (t *Terminal) recallLine(back int) {
+	if back < 0 || back > len(t.history) || t.NoEcho {
+		return
+	}
+	t.recall = back
+	t.n = 0
+	if back > 0 {
+		t.n = copy(t.line[:], t.history[len(t.history)-back])
+	}
+	t.cursor = t.n
+	t.redraw()
+}
+
+// remember adds a line to the history.
+func (t *Terminal) remember(line string) {
+	if line == "" || (len(t.history) > 0 && t.history[len(t.history)-1] == line) {
+		return
+	}
+	size := t.HistorySize
+	if size == 0 {
+		size = terminalHistorySize
+	}
+	if len(t.history) >= size {
+		copy(t.history, t.history[len(t.history)-size+1:])
+		t.history = t.history[:size-1]
+	}
+	t.history = append(t.history, line)
+}
+
+// redraw writes the prompt and the line again, and puts the cursor back.
+func (t *Terminal) redraw() {
+	if t.NoEcho {
+		return
+	}
+	t.write("\r")
+	t.write(t.Prompt)
+	t.Port.Write(t.line[:t.n])
+	t.write("\x1b[K") // clear the rest of the screen line
+	if back := t.n - t.cursor; back > 0 {
+		// Move the cursor left.
+		b := append(t.buf[:0], 0x1b, '[')
+		b = appendDecimal(b, back)
+		b = append(b, 'D')
+		t.Port.Write(b)
+	}
+}
+
+func (t *Terminal) write(s string) {
+	for len(s) > 0 {
+		n := copy(t.buf[:], s)
+		t.Port.Write(t.buf[:n])
+		s = s[n:]
+	}
+}
+
+// appendDecimal appends v in decimal to b.
+func appendDecimal(b []byte, v int) []byte {
+	var digits [20]byte
+	i := len(digits)
+	u := uint64(v)
+	if v < 0 {
+		u = -u
+	}
+	for {
+		i--
+		digits[i] = byte('0' + u%10)
+		u /= 10
+		if u == 0 {
+			break
+		}
+	}
+	if v < 0 {
+		b = append(b, '-')
+	}
+	return append(b, digits[i:]...)
+}
@@ -0,0 +1,17 @@
+//go:build baremetal
+// +build baremetal
+
+package machine
+
+// ticksOffset is added to the runtime clock by Ticks, for the time the chip
+// spent in a sleep mode the runtime clock doesn't count in, such as a deep
+// sleep that ends with a reset.
+var ticksOffset int64
+
+// Ticks returns a monotonic clock in microseconds. Unlike the runtime clock
+// behind time.Now and time.Since, it keeps counting across low-power modes
+// that stop or reset the system timer, on chips where the machine package
+// implements those modes. It starts at zero on power-on.
+func Ticks() uint64 {
+	return uint64(ticksOffset + nanotime()/1000)
+}
@@ -0,0 +1,33 @@
+package machine
+
+import _ "unsafe" // for go:linkname
+
+// The machine package cannot import the time package, as that would result in
+// an import cycle through the runtime. These functions provide the little bit
+// of timekeeping that drivers need.
+
+//go:linkname nanotime runtime.nanotime
+func nanotime() int64
+
+//go:linkname gosched runtime.Gosched
+func gosched()
+
+// sleep is time.Sleep, which puts the goroutine to sleep for the given number
+// of nanoseconds.
+//
+//go:linkname sleep time.Sleep
+func sleep(duration int64)
+
+// sleepMicroseconds waits for at least the given number of microseconds,
+// letting other goroutines run in the meantime.
+func sleepMicroseconds(us int64) {
+	sleepUntil(nanotime() + us*1000)
+}
+
+// sleepUntil waits until nanotime reaches deadline, letting other goroutines
+// run in the meantime.
+func sleepUntil(deadline int64) {
+	for nanotime() < deadline {
+		gosched()
+	}
+}
@@ -0,0 +1,32 @@
+package machine
+
+const (
+	// maxTimeDrift is the largest rate correction of the clock, in parts per
+	// billion (0.1%, far more than any crystal is off).
+	maxTimeDrift = 1000_000
+
+	// minDriftInterval is the shortest time between two calls to SetTime,
+	// in nanoseconds, for which the drift is estimated. Over shorter
+	// intervals the network latency dominates.
+	minDriftInterval = 60 * 1000_000_000
+
+	// maxDriftError is the largest clock error, in nanoseconds, that is
+	// treated as drift. Larger errors are corrected with a jump only.
+	maxDriftError = 1000_000_000
+)
+
+// correctedDrift returns the rate correction of the wall clock, in parts per
+// billion, after the clock was off by clockError nanoseconds (positive if it
+// is slow) over elapsed nanoseconds with the given correction.
+func correctedDrift(drift, clockError, elapsed int64) int64 {
+	if elapsed < minDriftInterval || clockError <= -maxDriftError || clockError >= maxDriftError {
+		return drift
+	}
+	drift += clockError * 1000_000 / (elapsed / 1000)
+	if drift > maxTimeDrift {
+		drift = maxTimeDrift
+	} else if drift < -maxTimeDrift {
+		drift = -maxTimeDrift
+	}
+	return drift
+}
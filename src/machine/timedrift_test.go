@@ -0,0 +1,24 @@
+package machine
+
+import "testing"
+
+func TestCorrectedDrift(t *testing.T) {
+	const minute = 60 * 1000_000_000
+	for _, tc := range []struct {
+		name                     string
+		drift, clockError, since int64
+		want                     int64
+	}{
+		// A clock that is 20ppm slow loses 1.2ms per minute.
+		{"slow", 0, 1200_000, minute, 20_000},
+		{"fast", 0, -1200_000, minute, -20_000},
+		{"corrected", 20_000, 60_000, 10 * minute, 20_100},
+		{"too soon", 5, 1200_000, minute / 2, 5},
+		{"jump", 5, 2000_000_000, minute, 5},
+		{"limit", 0, 900_000_000, minute, maxTimeDrift},
+	} {
+		if got := correctedDrift(tc.drift, tc.clockError, tc.since); got != tc.want {
+			t.Errorf("%s: drift %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
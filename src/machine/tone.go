@@ -0,0 +1,175 @@
+//go:build !gameboyadvance
+// +build !gameboyadvance
+
+package machine
+
+import "errors"
+
+// MaxToneFrequency is the highest frequency that Tone plays, in Hz, far above
+// the range of hearing.
+const MaxToneFrequency = 100_000
+
+var errToneFrequency = errors.New("tone: frequency out of range")
+
+// Tone plays a square wave of the given frequency in Hz on a pin, for example
+// to drive a piezo buzzer, and returns after duration milliseconds. A
+// frequency of 0 keeps the pin low. It toggles the pin in software, so it
+// works on every pin, and lets other goroutines run between the edges, which
+// makes the tone less accurate when they run for long and at high
+// frequencies. Use a Buzzer to play tones in the background with a PWM
+// peripheral.
+func Tone(pin Pin, frequency, duration uint32) error {
+	if frequency > MaxToneFrequency {
+		return errToneFrequency
+	}
+	pin.Configure(PinConfig{Mode: PinOutput})
+	end := nanotime() + int64(duration)*1e6
+	if frequency == 0 {
+		pin.Low()
+		sleepUntil(end)
+		return nil
+	}
+	halfPeriod := int64(500_000_000 / frequency)
+	high := false
+	for next := nanotime(); next < end; next += halfPeriod {
+		high = !high
+		pin.Set(high)
+		sleepUntil(next + halfPeriod)
+	}
+	pin.Low()
+	return nil
+}
+
+// BuzzerPWM is the part of a PWM peripheral that a Buzzer uses. The PWM types
+// of most chips implement it.
+type BuzzerPWM interface {
+	Configure(config PWMConfig) error
+	Channel(pin Pin) (uint8, error)
+	SetPeriod(period uint64) error
+	Top() uint32
+	Set(channel uint8, value uint32)
+}
+
+// Note is a tone of a melody. A frequency of 0 is a rest.
+type Note struct {
+	Frequency uint32 // Hz
+	Duration  uint32 // milliseconds
+}
+
+// buzzerQueueSize is the number of notes a Buzzer can queue.
+const buzzerQueueSize = 32
+
+// Buzzer plays tones on a piezo buzzer with a PWM peripheral, in the
+// background: the PWM keeps generating the tone while the program does other
+// things, and a goroutine that sleeps until the end of each note stops it and
+// starts the next queued one. The buzzer changes the period of the PWM
+// peripheral, so its other channels can't be used for anything else.
+//
+//	buzzer := &machine.Buzzer{PWM: machine.PWM0, Pin: machine.D2}
+//	buzzer.Configure()
+//	buzzer.Queue(melody...)
+//
+// Without a scheduler (-scheduler=none) there are no goroutines: Update must
+// then be called regularly, for example from the main loop, and the length of
+// a note is only as accurate as the interval between calls.
+type Buzzer struct {
+	PWM BuzzerPWM
+	Pin Pin
+
+	channel uint8
+	queue   [buzzerQueueSize]Note
+	head    uint8 // index of the next queued note
+	count   uint8 // number of queued notes
+	playing bool
+	end     int64 // end of the current note in nanoseconds
+	running bool  // the goroutine that plays the queue is running
+}
+
+// Configure configures the PWM peripheral and the pin.
+func (b *Buzzer) Configure() error {
+	err := b.PWM.Configure(PWMConfig{})
+	if err == nil {
+		b.channel, err = b.PWM.Channel(b.Pin)
+	}
+	return err
+}
+
+// Tone stops what is playing, and plays a tone of the given frequency in Hz
+// for duration milliseconds.
+func (b *Buzzer) Tone(frequency, duration uint32) {
+	b.Stop()
+	b.Queue(Note{Frequency: frequency, Duration: duration})
+}
+
+// Queue adds notes to play after those already queued. It returns how many
+// notes it added, which is less than len(notes) if the queue is full.
+func (b *Buzzer) Queue(notes ...Note) int {
+	n := 0
+	for _, note := range notes {
+		if b.count == buzzerQueueSize {
+			break
+		}
+		b.queue[(b.head+b.count)%buzzerQueueSize] = note
+		b.count++
+		n++
+	}
+	b.Update()
+	if !b.running && b.Playing() {
+		b.running = goBackground(b.play)
+	}
+	return n
+}
+
+// play calls Update at the end of each note until the queue is empty.
+func (b *Buzzer) play() {
+	for b.Playing() {
+		if wait := b.end - nanotime(); wait > 0 {
+			sleep(wait)
+		}
+		b.Update()
+	}
+	b.running = false
+}
+
+// Playing returns whether a note is playing or queued.
+func (b *Buzzer) Playing() bool {
+	return b.playing || b.count != 0
+}
+
+// Stop silences the buzzer and clears the queue.
+func (b *Buzzer) Stop() {
+	b.count = 0
+	b.playing = false
+	b.PWM.Set(b.channel, 0)
+}
+
+// Update stops the current note once its time is up and starts the next one.
+// It only needs to be called without a scheduler.
+func (b *Buzzer) Update() {
+	now := nanotime()
+	if b.playing && now < b.end {
+		return
+	}
+	if b.count == 0 {
+		if b.playing {
+			b.playing = false
+			b.PWM.Set(b.channel, 0)
+		}
+		return
+	}
+	note := b.queue[b.head]
+	b.head = (b.head + 1) % buzzerQueueSize
+	b.count--
+	start := now
+	if b.playing {
+		// Keep the tempo when Update is called late.
+		start = b.end
+	}
+	b.playing = true
+	b.end = start + int64(note.Duration)*1e6
+	if note.Frequency == 0 || note.Frequency > 1e9 || b.PWM.SetPeriod(1e9/uint64(note.Frequency)) != nil {
+		b.PWM.Set(b.channel, 0)
+		return
+	}
+	b.PWM.Set(b.channel, b.PWM.Top()/2)
+}
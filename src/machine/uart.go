@@ -3,7 +3,10 @@
 
 package machine
 
-import "errors"
+import (
+	"errors"
+	"runtime/interrupt"
+)
 
 var errUARTBufferEmpty = errors.New("UART buffer empty")
 
@@ -84,6 +87,40 @@ func (uart *UART) Buffered() int {
 	return int(uart.Buffer.Used())
 }
 
+// SetFrameGap makes the UART note which bytes it receives after the line was
+// idle for at least gap nanoseconds, for protocols that delimit frames by idle
+// time like Modbus RTU. The bytes are timed by the receive interrupt, so
+// ReadFrameByte tells the frames apart however late the program reads them. A
+// gap of zero stops it.
+func (uart *UART) SetFrameGap(gap int64) {
+	mask := interrupt.Disable()
+	uart.Buffer.setFrameGap(gap)
+	interrupt.Restore(mask)
+}
+
+// ReadFrameByte is ReadByte, and also returns whether the byte starts a frame:
+// whether the line was idle for the gap set with SetFrameGap before it.
+func (uart *UART) ReadFrameByte() (c byte, start bool, err error) {
+	mask := interrupt.Disable()
+	c, start, ok := uart.Buffer.getFrameByte()
+	interrupt.Restore(mask)
+	if !ok {
+		return 0, false, errUARTBufferEmpty
+	}
+	return c, start, nil
+}
+
+// LineIdle returns whether the line has been idle for the gap set with
+// SetFrameGap since the last byte was received, which ends the frame of that
+// byte.
+func (uart *UART) LineIdle() bool {
+	mask := interrupt.Disable()
+	g := uart.Buffer.gaps
+	idle := g == nil || nanotime()-g.last >= g.gap
+	interrupt.Restore(mask)
+	return idle
+}
+
 // Receive handles adding data to the UART's data buffer.
 // Usually called by the IRQ handler for a machine.
 func (uart *UART) Receive(data byte) {
@@ -6,10 +6,12 @@ package machine
 import (
 	"errors"
 	"machine/usb"
+	"runtime/interrupt"
 )
 
 type USBDevice struct {
 	initcomplete bool
+	attached     bool // whether the device should be visible to the host
 }
 
 var (
@@ -27,9 +29,7 @@ type Serialer interface {
 	RTS() bool
 }
 
-var usbDescriptor = usb.DescriptorCDC
-
-var usbDescriptorConfig uint8 = usb.DescriptorConfigCDC
+var usbDescriptor = &usb.DescriptorComposite
 
 // strToUTF16LEDescriptor converts a utf8 string into a string descriptor
 // note: the following code only converts ascii characters to UTF16LE. In order
@@ -63,20 +63,102 @@ var (
 	usbSetInterface  uint8
 )
 
+// USBStateChange is signaled when the host configures the USB device, and when
+// the bus is reset, for example when the cable is plugged in.
+var USBStateChange Event
+
+// usbAttach is set to "manual" at build time to keep the USB device detached
+// from the bus when the runtime configures it at startup:
+//
+//	tinygo flash -ldflags="-X machine.usbAttach=manual" ...
+//
+// The host then doesn't see the device until the program calls USBDev.Attach,
+// so that the program can choose the USB classes and descriptors first, for
+// example depending on a button held at boot.
+var usbAttach string
+
+// attachOnConfigure returns whether Configure should attach the device.
+func (dev *USBDevice) attachOnConfigure() bool {
+	return usbAttach != "manual"
+}
+
+// usbReenumerateTime is how long Reenumerate keeps the device detached, in
+// microseconds. Hosts only notice a disconnection after a few milliseconds.
+const usbReenumerateTime = 100e3
+
+// Reenumerate detaches the device from the bus and attaches it again after a
+// while, so that the host enumerates it again. This is needed after changing
+// the USB classes or descriptors at runtime, for example to show or hide a
+// mass storage device, and recovers from a host that stopped talking to the
+// device.
+func (dev *USBDevice) Reenumerate() {
+	dev.Detach()
+	sleepMicroseconds(usbReenumerateTime)
+	dev.Attach()
+}
+
+// usbDetached resets the state that the host set up, once the device is
+// detached.
+func usbDetached() {
+	usbConfiguration = 0
+	usbSetInterface = 0
+	isEndpointHalt = false
+	isRemoteWakeUpEnabled = false
+	usbRxHeld = 0
+	USBStateChange.Signal()
+}
+
+// usbSuspended is whether the host has stopped sending start of frame packets.
+var usbSuspended bool
+
+// USBSuspended returns whether the host stopped talking to the device: a host
+// sends a start of frame packet every millisecond, and the device sees that
+// the bus is suspended when they stop for 3ms. This happens when the host goes
+// to sleep, and when a self-powered device is unplugged. USBStateChange is
+// signaled when this changes, so that a device that streams data can stop and
+// save power in the meantime.
+func USBSuspended() bool {
+	return usbSuspended
+}
+
+// usbSetSuspended is called by the interrupt of the USB device when the bus is
+// suspended, and when it sees traffic again.
+func usbSetSuspended(suspended bool) {
+	if usbSuspended != suspended {
+		usbSuspended = suspended
+		USBStateChange.Signal()
+	}
+}
+
+// USBConfigured returns whether the host has configured the USB device, which
+// it does once it has enumerated it.
+func USBConfigured() bool {
+	return usbConfiguration != 0
+}
+
 //go:align 4
 var udd_ep_control_cache_buffer [256]uint8
 
 //go:align 4
-var udd_ep_in_cache_buffer [7][64]uint8
+var udd_ep_in_cache_buffer [usbEndpointBuffers][usb.EndpointPacketSize]uint8
 
 //go:align 4
-var udd_ep_out_cache_buffer [7][64]uint8
+var udd_ep_out_cache_buffer [usbEndpointBuffers][usb.EndpointPacketSize]uint8
 
 var (
 	usbTxHandler    [usb.NumberOfEndpoints]func()
 	usbRxHandler    [usb.NumberOfEndpoints]func([]byte)
 	usbSetupHandler [usb.NumberOfInterfaces]func(usb.Setup) bool
 
+	// usbAltSettingHandler is called with the alternate setting that the host
+	// selects for an interface that has several, such as one that streams
+	// audio.
+	usbAltSettingHandler [usb.NumberOfInterfaces]func(alt uint8)
+
+	// usbEndpointInterface is the interface that owns each endpoint, which
+	// handles the class requests addressed to the endpoint.
+	usbEndpointInterface [usb.NumberOfEndpoints]uint8
+
 	endPoints = []uint32{
 		usb.CONTROL_ENDPOINT:  usb.ENDPOINT_TYPE_CONTROL,
 		usb.CDC_ENDPOINT_ACM:  (usb.ENDPOINT_TYPE_INTERRUPT | usb.EndpointIn),
@@ -89,26 +171,18 @@ var (
 )
 
 // sendDescriptor creates and sends the various USB descriptor types that
-// can be requested by the host.
-func sendDescriptor(setup usb.Setup) {
+// can be requested by the host. It returns false when the requested descriptor
+// is not supported, in which case the caller should stall the control
+// endpoint to signal a request error.
+func sendDescriptor(setup usb.Setup) bool {
 	switch setup.WValueH {
 	case usb.CONFIGURATION_DESCRIPTOR_TYPE:
 		sendUSBPacket(0, usbDescriptor.Configuration, setup.WLength)
-		return
+		return true
 	case usb.DEVICE_DESCRIPTOR_TYPE:
-		// composite descriptor
-		switch {
-		case (usbDescriptorConfig & usb.DescriptorConfigHID) > 0:
-			usbDescriptor = usb.DescriptorCDCHID
-		case (usbDescriptorConfig & usb.DescriptorConfigMIDI) > 0:
-			usbDescriptor = usb.DescriptorCDCMIDI
-		default:
-			usbDescriptor = usb.DescriptorCDC
-		}
-
 		usbDescriptor.Configure(usb_VID, usb_PID)
 		sendUSBPacket(0, usbDescriptor.Device, setup.WLength)
-		return
+		return true
 
 	case usb.STRING_DESCRIPTOR_TYPE:
 		switch setup.WValueL {
@@ -127,23 +201,101 @@ func sendDescriptor(setup usb.Setup) {
 			sendUSBPacket(0, b, setup.WLength)
 
 		case usb.ISERIAL:
-			// TODO: allow returning a product serial number
-			SendZlp()
+			id := usbSerialNumber()
+			if len(id) == 0 {
+				SendZlp()
+				break
+			}
+			// The serial number is the ID in hex, in UTF-16.
+			const digits = "0123456789ABCDEF"
+			b := make([]byte, len(id)*4+2)
+			b[0], b[1] = byte(len(b)), usb.STRING_DESCRIPTOR_TYPE
+			for i, c := range id {
+				b[2+i*4] = digits[c>>4]
+				b[4+i*4] = digits[c&0xf]
+			}
+			sendUSBPacket(0, b, setup.WLength)
+
+		default:
+			str, ok := usbDescriptor.Strings[setup.WValueL]
+			if !ok {
+				return false
+			}
+			b := make([]byte, (len(str)<<1)+2)
+			strToUTF16LEDescriptor(str, b)
+			sendUSBPacket(0, b, setup.WLength)
+		}
+		return true
+	case usb.BOS_DESCRIPTOR_TYPE:
+		if usbDescriptor.BOS != nil {
+			sendUSBPacket(0, usbDescriptor.BOS, setup.WLength)
+			return true
 		}
-		return
 	case usb.HID_REPORT_TYPE:
 		if h, ok := usbDescriptor.HID[setup.WIndex]; ok {
 			sendUSBPacket(0, h, setup.WLength)
-			return
+			return true
 		}
-	case usb.DEVICE_QUALIFIER:
-		// skip
-	default:
+	case usb.DEVICE_QUALIFIER, usb.OTHER_SPEED_CONFIGURATION:
+		// All supported USB peripherals are full-speed only. The USB 2.0
+		// specification (section 9.6.2) requires such devices to respond to
+		// these requests with a request error, which means stalling the
+		// control endpoint.
+		return false
 	}
 
-	// do not know how to handle this message, so return zero
-	SendZlp()
-	return
+	// do not know how to handle this message, so signal a request error
+	return false
+}
+
+// handleSetup handles a setup packet received on the control endpoint. It
+// returns false if the request is not supported, in which case the control
+// endpoint should be stalled.
+func handleSetup(setup usb.Setup) bool {
+	if (setup.BmRequestType & usb.REQUEST_TYPE) == usb.REQUEST_STANDARD {
+		// Standard Requests
+		return handleStandardSetup(setup)
+	}
+
+	if (setup.BmRequestType&usb.REQUEST_TYPE) == usb.REQUEST_VENDOR &&
+		(setup.BmRequestType&usb.REQUEST_RECIPIENT) == usb.REQUEST_DEVICE {
+		// Vendor Device Requests
+		return handleVendorSetup(setup)
+	}
+
+	// Class Interface Requests
+	iface := setup.WIndex
+	if (setup.BmRequestType & usb.REQUEST_RECIPIENT) == usb.REQUEST_ENDPOINT {
+		// The index is the endpoint address: pass the request to the
+		// interface that owns the endpoint.
+		ep := setup.WIndex & 0x7f
+		if ep == 0 || ep >= usb.NumberOfEndpoints || endPoints[ep] == usb.ENDPOINT_TYPE_DISABLE {
+			return false
+		}
+		iface = uint16(usbEndpointInterface[ep])
+	}
+	if iface < uint16(len(usbSetupHandler)) && usbSetupHandler[iface] != nil {
+		return usbSetupHandler[iface](setup)
+	}
+	return false
+}
+
+// handleVendorSetup handles vendor requests addressed to the device. The
+// supported requests are the one for the Microsoft OS 2.0 descriptor set, which
+// lets Windows bind the WinUSB driver without an INF file, and the WebUSB one
+// for the URL of the landing page.
+func handleVendorSetup(setup usb.Setup) bool {
+	if usbDescriptor.MSOS20 != nil && setup.BRequest == usb.MS_VENDOR_CODE &&
+		setup.WIndex == usb.MS_OS_20_DESCRIPTOR_INDEX {
+		sendUSBPacket(0, usbDescriptor.MSOS20, setup.WLength)
+		return true
+	}
+	if usbDescriptor.WebUSBURL != nil && setup.BRequest == usb.WEBUSB_VENDOR_CODE &&
+		setup.WIndex == usb.WEBUSB_REQUEST_GET_URL && setup.WValueL == usb.WEBUSB_LANDING_PAGE {
+		sendUSBPacket(0, usbDescriptor.WebUSBURL, setup.WLength)
+		return true
+	}
+	return false
 }
 
 func handleStandardSetup(setup usb.Setup) bool {
@@ -165,6 +317,7 @@ func handleStandardSetup(setup usb.Setup) bool {
 			isRemoteWakeUpEnabled = false
 		} else if setup.WValueL == 0 { // ENDPOINTHALT
 			isEndpointHalt = false
+			setFeatureHalt(setup, false)
 		}
 		SendZlp()
 		return true
@@ -174,6 +327,7 @@ func handleStandardSetup(setup usb.Setup) bool {
 			isRemoteWakeUpEnabled = true
 		} else if setup.WValueL == 0 { // ENDPOINTHALT
 			isEndpointHalt = true
+			setFeatureHalt(setup, true)
 		}
 		SendZlp()
 		return true
@@ -182,8 +336,7 @@ func handleStandardSetup(setup usb.Setup) bool {
 		return handleUSBSetAddress(setup)
 
 	case usb.GET_DESCRIPTOR:
-		sendDescriptor(setup)
-		return true
+		return sendDescriptor(setup)
 
 	case usb.SET_DESCRIPTOR:
 		return false
@@ -200,6 +353,7 @@ func handleStandardSetup(setup usb.Setup) bool {
 			}
 
 			usbConfiguration = setup.WValueL
+			USBStateChange.Signal()
 
 			SendZlp()
 			return true
@@ -214,6 +368,9 @@ func handleStandardSetup(setup usb.Setup) bool {
 
 	case usb.SET_INTERFACE:
 		usbSetInterface = setup.WValueL
+		if setup.WIndex < usb.NumberOfInterfaces && usbAltSettingHandler[setup.WIndex] != nil {
+			usbAltSettingHandler[setup.WIndex](setup.WValueL)
+		}
 
 		SendZlp()
 		return true
@@ -223,8 +380,57 @@ func handleStandardSetup(setup usb.Setup) bool {
 	}
 }
 
+// setFeatureHalt halts or resumes the endpoint that an ENDPOINT_HALT request
+// of the host is addressed to.
+func setFeatureHalt(setup usb.Setup, halt bool) {
+	ep := uint32(setup.WIndex & 0x7f)
+	if setup.BmRequestType&usb.REQUEST_RECIPIENT == usb.REQUEST_ENDPOINT && ep != 0 && ep < uint32(len(endPoints)) {
+		setEndpointStall(ep, setup.WIndex&usb.EndpointIn != 0, halt)
+	}
+}
+
+// SetUSBEndpointHalt halts the bulk or interrupt endpoint ep, an endpoint
+// number that includes usb.EndpointIn for an IN endpoint, which then answers
+// the host with STALL, or resumes it. The host resumes a halted endpoint itself
+// with a CLEAR_FEATURE request, which also resets its data toggle; the Bulk-Only
+// Transport of mass storage halts its endpoints this way on an invalid command.
+func SetUSBEndpointHalt(ep uint32, halt bool) {
+	mask := interrupt.Disable()
+	setEndpointStall(ep&0x7f, ep&usb.EndpointIn != 0, halt)
+	interrupt.Restore(mask)
+}
+
+// usbRxHeld has a bit set for the OUT endpoints that HoldUSBRx holds.
+var usbRxHeld uint32
+
+// HoldUSBRx makes the host wait before it sends the next packet to the OUT
+// endpoint ep, instead of sending a packet that the receive handler of the
+// endpoint has no room for, until ReleaseUSBRx is called. It must be called
+// from the receive handler.
+func HoldUSBRx(ep uint32) {
+	usbRxHeld |= 1 << ep
+}
+
+// ReleaseUSBRx lets the host send the next packet to the OUT endpoint ep, which
+// HoldUSBRx held.
+func ReleaseUSBRx(ep uint32) {
+	mask := interrupt.Disable()
+	if usbRxHeld&(1<<ep) != 0 {
+		usbRxHeld &^= 1 << ep
+		handleEndpointRxComplete(ep)
+	}
+	interrupt.Restore(mask)
+}
+
+// usbRxComplete readies the OUT endpoint ep for the next packet, once its
+// receive handler has run, unless the handler held it.
+func usbRxComplete(ep uint32) {
+	if usbRxHeld&(1<<ep) == 0 {
+		handleEndpointRxComplete(ep)
+	}
+}
+
 func EnableCDC(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) {
-	usbDescriptorConfig |= usb.DescriptorConfigCDC
 	endPoints[usb.CDC_ENDPOINT_ACM] = (usb.ENDPOINT_TYPE_INTERRUPT | usb.EndpointIn)
 	endPoints[usb.CDC_ENDPOINT_OUT] = (usb.ENDPOINT_TYPE_BULK | usb.EndpointOut)
 	endPoints[usb.CDC_ENDPOINT_IN] = (usb.ENDPOINT_TYPE_BULK | usb.EndpointIn)
@@ -232,21 +438,14 @@ func EnableCDC(txHandler func(), rxHandler func([]byte), setupHandler func(usb.S
 	usbTxHandler[usb.CDC_ENDPOINT_IN] = txHandler
 	usbSetupHandler[usb.CDC_ACM_INTERFACE] = setupHandler // 0x02 (Communications and CDC Control)
 	usbSetupHandler[usb.CDC_DATA_INTERFACE] = nil         // 0x0A (CDC-Data)
+	usbEndpointInterface[usb.CDC_ENDPOINT_ACM] = usb.CDC_ACM_INTERFACE
+	usbEndpointInterface[usb.CDC_ENDPOINT_OUT] = usb.CDC_DATA_INTERFACE
+	usbEndpointInterface[usb.CDC_ENDPOINT_IN] = usb.CDC_DATA_INTERFACE
 }
 
-// EnableHID enables HID. This function must be executed from the init().
-func EnableHID(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) {
-	usbDescriptorConfig |= usb.DescriptorConfigHID
-	endPoints[usb.HID_ENDPOINT_IN] = (usb.ENDPOINT_TYPE_INTERRUPT | usb.EndpointIn)
-	usbTxHandler[usb.HID_ENDPOINT_IN] = txHandler
-	usbSetupHandler[usb.HID_INTERFACE] = setupHandler // 0x03 (HID - Human Interface Device)
-}
-
-// EnableMIDI enables MIDI. This function must be executed from the init().
-func EnableMIDI(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) {
-	usbDescriptorConfig |= usb.DescriptorConfigMIDI
-	endPoints[usb.MIDI_ENDPOINT_OUT] = (usb.ENDPOINT_TYPE_BULK | usb.EndpointOut)
-	endPoints[usb.MIDI_ENDPOINT_IN] = (usb.ENDPOINT_TYPE_BULK | usb.EndpointIn)
-	usbRxHandler[usb.MIDI_ENDPOINT_OUT] = rxHandler
-	usbTxHandler[usb.MIDI_ENDPOINT_IN] = txHandler
+// EnableDFURuntime adds the DFU runtime interface, whose class requests are
+// passed to setupHandler. This function must be executed from the init().
+func EnableDFURuntime(setupHandler func(usb.Setup) bool) {
+	iface := usb.AddFunction(usb.DFURuntimeFunction)
+	usbSetupHandler[iface] = setupHandler
 }
@@ -0,0 +1,205 @@
+//go:build sam
+// +build sam
+
+// Package audio implements a USB Audio Class 1 microphone, which streams mono
+// 16-bit samples to the host over an isochronous endpoint. The host sees a
+// sound card input that needs no driver.
+//
+// The samples come from the program, with Write, or from an I2S or PDM
+// microphone through a Bridge:
+//
+//	var mic *audio.Microphone
+//
+//	func init() {
+//		mic, _ = audio.Configure(audio.Config{SampleRate: 16000})
+//	}
+//
+//	func main() {
+//		machine.I2S0.Configure(machine.I2SConfig{
+//			Mode:           machine.I2SModeReceiver,
+//			AudioFrequency: 16000,
+//			DataFormat:     machine.I2SDataFormat32bit,
+//		})
+//		bridge := audio.Bridge{I2S: machine.I2S0}
+//		bridge.Run(mic)
+//	}
+//
+// The interface shares its endpoint with USB MIDI and the vendor-specific
+// interface, so it cannot be combined with them. Only the USB drivers of the
+// SAMD21 and SAMD51 have the isochronous endpoint it needs, so the package
+// doesn't build for the other chips.
+package audio
+
+import (
+	"errors"
+	"machine"
+	"machine/usb"
+	"runtime/volatile"
+)
+
+var errSampleRate = errors.New("USB audio: sample rate doesn't fit in a USB packet")
+
+// bufferSize is the number of samples that the microphone buffers, a power of
+// two. The streaming starts with the buffer half full, which is the latency of
+// the microphone: 16ms at 16kHz.
+const bufferSize = 512
+
+// Config describes the microphone as reported to the host.
+type Config struct {
+	// SampleRate is the number of samples per second, 16000 if it is zero.
+	// A packet of a millisecond of samples, and one more, must fit in
+	// usb.EndpointPacketSize, which allows up to 30kHz.
+	SampleRate uint32
+}
+
+var Mic *Microphone
+
+// Microphone is a USB audio input with one channel of 16-bit samples.
+type Microphone struct {
+	rate uint32
+
+	buffer [bufferSize]int16
+	head   volatile.Register16
+	tail   volatile.Register16
+
+	streaming bool
+	frac      uint32 // samples per frame beyond rate/1000, in thousandths
+	packet    [usb.EndpointPacketSize]byte
+}
+
+// Configure enables the microphone. This function must be executed from the
+// init(), before the host enumerates the device.
+func Configure(config Config) (*Microphone, error) {
+	if Mic != nil {
+		return Mic, nil
+	}
+	if config.SampleRate == 0 {
+		config.SampleRate = 16000
+	}
+	maxPacketSize := ((config.SampleRate+999)/1000 + 1) * 2
+	if maxPacketSize > usb.EndpointPacketSize {
+		return nil, errSampleRate
+	}
+	Mic = &Microphone{rate: config.SampleRate}
+	machine.EnableAudio(microphoneFunction(config.SampleRate, uint16(maxPacketSize)), Mic.handler, Mic.setAltSetting)
+	return Mic, nil
+}
+
+// microphoneFunction returns the USB function of the microphone: an audio
+// control interface with a microphone terminal, and a streaming interface
+// whose alternate setting 1 has the isochronous endpoint.
+func microphoneFunction(rate uint32, maxPacketSize uint16) usb.Function {
+	return usb.Function{
+		Interfaces: 2,
+		Descriptor: func(b []byte, first uint8) []byte {
+			b = usb.AppendInterfaceAssociation(b, first, 2, 0x01, 0x01, 0x00)
+			b = usb.AppendInterface(b, first, 0, 0x01, 0x01, 0x00, 0) // audio control
+			b = append(b,
+				0x09, 0x24, 0x01, 0x00, 0x01, 0x1e, 0x00, 0x01, first+1, // header
+				0x0c, 0x24, 0x02, 0x01, 0x01, 0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, // microphone input terminal 1
+				0x09, 0x24, 0x03, 0x02, 0x01, 0x01, 0x00, 0x01, 0x00, // USB streaming output terminal 2
+			)
+			b = usb.AppendInterface(b, first+1, 0, 0x01, 0x02, 0x00, 0) // audio streaming, no bandwidth
+			b = append(b,
+				0x09, usb.INTERFACE_DESCRIPTOR_TYPE, first+1, 0x01, 0x01, 0x01, 0x02, 0x00, 0x00, // alternate setting 1
+				0x07, 0x24, 0x01, 0x02, 0x01, 0x01, 0x00, // general, PCM from terminal 2
+				0x0b, 0x24, 0x02, 0x01, 0x01, 0x02, 0x10, 0x01, byte(rate), byte(rate>>8), byte(rate>>16), // mono, 16 bits
+				// Audio class endpoints have two more bytes.
+				0x09, 0x05, usb.AUDIO_ENDPOINT_IN|usb.EndpointIn, usb.ENDPOINT_TYPE_ISOCHRONOUS|0x04, byte(maxPacketSize), byte(maxPacketSize>>8), 0x01, 0x00, 0x00, // asynchronous
+				0x07, 0x25, 0x01, 0x00, 0x00, 0x00, 0x00,
+			)
+			return b
+		},
+	}
+}
+
+// SampleRate returns the number of samples per second.
+func (m *Microphone) SampleRate() uint32 {
+	return m.rate
+}
+
+// Streaming returns whether the host is reading the microphone. Samples that
+// are written in the meantime fill the buffer, and are dropped when the
+// streaming starts, apart from the latest ones.
+func (m *Microphone) Streaming() bool {
+	return m.streaming
+}
+
+// Buffered returns the number of samples in the buffer.
+func (m *Microphone) Buffered() int {
+	return int(m.head.Get() - m.tail.Get())
+}
+
+// Write stores samples in the buffer, for the host to read, and returns how
+// many fit. The host reads them at the sample rate, so that the program must
+// write them at the same rate: the microphone makes up for a small difference
+// by sending a sample more or less in a packet.
+func (m *Microphone) Write(samples []int16) int {
+	n := 0
+	for _, s := range samples {
+		head := m.head.Get()
+		if head-m.tail.Get() == bufferSize {
+			break
+		}
+		m.buffer[head%bufferSize] = s
+		m.head.Set(head + 1)
+		n++
+	}
+	return n
+}
+
+// setAltSetting is called from the USB interrupt when the host starts or stops
+// the stream.
+func (m *Microphone) setAltSetting(alt uint8) {
+	m.streaming = alt == 1
+	if !m.streaming {
+		return
+	}
+	// Drop the oldest samples, so that the stream starts with half a buffer
+	// to make up for the difference between the sample rate of the program
+	// and that of the host.
+	if used := m.head.Get() - m.tail.Get(); used > bufferSize/2 {
+		m.tail.Set(m.tail.Get() + used - bufferSize/2)
+	}
+	m.frac = 0
+	m.sendNext()
+}
+
+// handler is called from the USB interrupt when the host has received a
+// packet.
+func (m *Microphone) handler() {
+	if m.streaming {
+		m.sendNext()
+	}
+}
+
+// sendNext sends the samples of the next frame. The host reads a packet each
+// millisecond, so that a packet has rate/1000 samples nominally, and one more
+// or less when the buffer drifts away from being half full. A packet with the
+// samples there are is sent when the program doesn't keep up.
+func (m *Microphone) sendNext() {
+	m.frac += m.rate % 1000
+	n := m.rate / 1000
+	if m.frac >= 1000 {
+		m.frac -= 1000
+		n++
+	}
+	used := uint32(m.head.Get() - m.tail.Get())
+	switch {
+	case used > bufferSize/2+n:
+		n++
+	case used+n < bufferSize/2 && n > 0:
+		n--
+	}
+	if n > used {
+		n = used
+	}
+	tail := m.tail.Get()
+	for i := uint32(0); i < n; i++ {
+		s := m.buffer[(tail+uint16(i))%bufferSize]
+		m.packet[i*2] = byte(s)
+		m.packet[i*2+1] = byte(s >> 8)
+	}
+	m.tail.Set(tail + uint16(n))
+	machine.SendUSBInPacket(usb.AUDIO_ENDPOINT_IN, m.packet[:n*2])
+}
@@ -0,0 +1,116 @@
+//go:build sam
+// +build sam
+
+package audio
+
+// I2S is the part of an I2S bus that Bridge reads the samples from, which
+// machine.I2S implements.
+type I2S interface {
+	Read(p []uint32) (n int, err error)
+}
+
+// Bridge passes the samples of an I2S or PDM microphone to the USB microphone,
+// which makes the board a USB microphone:
+//
+//	bridge := audio.Bridge{I2S: machine.I2S0}
+//	bridge.Run(mic)
+//
+// The I2S bus must be configured to receive at the sample rate of the USB
+// microphone, or, for a PDM microphone, with a clock of 64 times the sample
+// rate. The USB microphone makes up for the small difference between the
+// clock of the I2S bus and that of the host, which comes from the dividers of
+// the I2S clock.
+type Bridge struct {
+	I2S I2S
+
+	// Bits is the size of the data words that the I2S bus is configured
+	// with, 32 if it is zero. The most significant 16 bits of each word are
+	// the sample.
+	Bits uint8
+
+	// Stereo skips every second word, the right channel, for an I2S bus
+	// configured in stereo.
+	Stereo bool
+
+	// PDM takes the words as the bit stream of a PDM microphone, 32 bits
+	// each, most significant bit first, and decimates 64 bits into a sample.
+	PDM bool
+
+	words   [64]uint32
+	samples [64]int16
+	cic     cic
+}
+
+// Run reads the samples from the I2S bus and writes them to the USB
+// microphone, and never returns. The samples are dropped while the host
+// doesn't read the microphone. It keeps the CPU busy, as the I2S bus is read
+// without interrupts.
+func (b *Bridge) Run(mic *Microphone) {
+	for {
+		n, _ := b.I2S.Read(b.words[:])
+		mic.Write(b.samples[:b.convert(b.words[:n])])
+	}
+}
+
+// convert converts the words read from the I2S bus into samples, and returns
+// the number of samples.
+func (b *Bridge) convert(words []uint32) int {
+	if b.PDM {
+		n := 0
+		for _, w := range words {
+			if s, ok := b.cic.decimate(w); ok {
+				b.samples[n] = s
+				n++
+			}
+		}
+		return n
+	}
+	shift := uint8(16)
+	if b.Bits != 0 {
+		shift = b.Bits - 16
+	}
+	step := 1
+	if b.Stereo {
+		step = 2
+	}
+	n := 0
+	for i := 0; i < len(words); i += step {
+		b.samples[n] = int16(words[i] >> shift)
+		n++
+	}
+	return n
+}
+
+// cic is a third order CIC filter, which decimates a PDM bit stream by 64. Its
+// registers wrap around, which the CIC filter allows.
+type cic struct {
+	integrators [3]uint32
+	combs       [3]uint32
+	half        bool // whether the first 32 bits of a sample went in
+}
+
+// decimate filters the 32 bits of w, and returns a sample every second word.
+func (f *cic) decimate(w uint32) (int16, bool) {
+	i0, i1, i2 := f.integrators[0], f.integrators[1], f.integrators[2]
+	for bit := 31; bit >= 0; bit-- {
+		i0 += w >> uint(bit) & 1
+		i1 += i0
+		i2 += i1
+	}
+	f.integrators = [3]uint32{i0, i1, i2}
+	f.half = !f.half
+	if f.half {
+		return 0, false
+	}
+	v := i2
+	for i := range f.combs {
+		v, f.combs[i] = v-f.combs[i], v
+	}
+	// The gain is 64^3, so v goes from 0 to 1<<18, with silence in the
+	// middle.
+	s := (int32(v) - 1<<17) >> 2
+	if s > 32767 {
+		s = 32767
+	}
+	return int16(s), true
+}
@@ -0,0 +1,117 @@
+// Package bulkserial implements a serial port over a pair of vendor-specific
+// USB bulk endpoints. It is an alternative to USB CDC for hosts where the CDC
+// driver is missing or problematic. On Windows the WinUSB driver is bound
+// automatically, on other systems the port can be opened with libusb.
+//
+// The port implements machine.Serialer, so it can be used as the console when
+// building with -serial=usb:
+//
+//	func init() {
+//		machine.Serial = bulkserial.Configure()
+//	}
+package bulkserial
+
+import (
+	"errors"
+	"machine"
+	"machine/usb/vendor"
+	"runtime/volatile"
+)
+
+var (
+	ErrBufferEmpty = errors.New("USB bulk serial buffer empty")
+)
+
+const rxBufferSize = 128
+
+var Port *Serial
+
+// Serial is a serial port over vendor-specific USB bulk endpoints.
+type Serial struct {
+	port   *vendor.Vendor
+	buffer [rxBufferSize]volatile.Register8
+	head   volatile.Register8
+	tail   volatile.Register8
+}
+
+// Configure enables the bulk serial port. This function must be executed from
+// the init(), before the host enumerates the device.
+func Configure() *Serial {
+	if Port != nil {
+		return Port
+	}
+	Port = &Serial{}
+	Port.port = vendor.Configure(vendor.Config{
+		Name:   "TinyGo Serial",
+		Stream: true,
+		WinUSB: true,
+	})
+	Port.port.SetHandler(Port.receive)
+	return Port
+}
+
+// Configure is here for compatibility with the UART interface.
+func (s *Serial) Configure(config machine.UARTConfig) error {
+	return nil
+}
+
+// Write data to the host. Data that does not fit in the transmit buffer is
+// dropped.
+func (s *Serial) Write(data []byte) (n int, err error) {
+	return s.port.Write(data)
+}
+
+// WriteByte writes a single byte to the host.
+func (s *Serial) WriteByte(c byte) error {
+	_, err := s.port.Write([]byte{c})
+	return err
+}
+
+// Read from the RX buffer.
+func (s *Serial) Read(data []byte) (n int, err error) {
+	for n < len(data) {
+		v, err := s.ReadByte()
+		if err != nil {
+			break
+		}
+		data[n] = v
+		n++
+	}
+	return n, nil
+}
+
+// ReadByte reads a single byte from the RX buffer.
+// If there is no data in the buffer, returns an error.
+func (s *Serial) ReadByte() (byte, error) {
+	if s.Buffered() == 0 {
+		return 0, ErrBufferEmpty
+	}
+	s.tail.Set(s.tail.Get() + 1)
+	return s.buffer[s.tail.Get()%rxBufferSize].Get(), nil
+}
+
+// Buffered returns the number of bytes currently stored in the RX buffer.
+func (s *Serial) Buffered() int {
+	return int(uint8(s.head.Get() - s.tail.Get()))
+}
+
+// DTR always returns true: there are no control lines.
+func (s *Serial) DTR() bool {
+	return true
+}
+
+// RTS always returns true: there are no control lines.
+func (s *Serial) RTS() bool {
+	return true
+}
+
+// receive is called from the USB interrupt with every received packet.
+func (s *Serial) receive(b []byte) {
+	for _, c := range b {
+		if s.Buffered() == rxBufferSize {
+			return
+		}
+		s.head.Set(s.head.Get() + 1)
+		s.buffer[s.head.Get()%rxBufferSize].Set(c)
+	}
+}
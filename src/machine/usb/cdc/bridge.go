@@ -0,0 +1,178 @@
+package cdc
+
+import (
+	"io"
+	"machine"
+	"machine/usb"
+	"runtime"
+	"runtime/interrupt"
+	"time"
+)
+
+// BridgeUART is the part of a UART that Bridge uses, which *machine.UART
+// implements. The format of the line (data bits, stop bits and parity) and
+// break conditions are passed on too if the UART has the SetFormat and
+// SetBreak methods.
+type BridgeUART interface {
+	io.ReadWriter
+	Buffered() int
+	SetBaudRate(br uint32)
+}
+
+type uartFormatter interface {
+	SetFormat(databits, stopbits uint8, parity machine.UARTParity) error
+}
+
+type uartBreaker interface {
+	SetBreak(on bool)
+}
+
+// Bridge passes the data between the USB CDC serial port and a UART, which
+// makes the board a USB to serial adapter:
+//
+//	bridge := cdc.Bridge{UART: machine.UART0}
+//	bridge.SetSignalPins(machine.D2, machine.NoPin)
+//	bridge.Run()
+//
+// The UART follows the baud rate and format that the program on the host
+// opens the port with, and sends a break condition when the host asks for one.
+// Both directions have flow control: the host waits while the UART is sending
+// the data it got before, and the UART data waits in the USB buffer while the
+// host doesn't read it. The UART can use hardware flow control too, see
+// EnableFlowControl of the UART. Without it, data from the UART is lost when
+// the small receive buffer of the UART fills up while the host doesn't read,
+// and while no program on the host has the port open.
+//
+// Like any USB CDC port, the board resets into its bootloader when the host
+// opens the port at 1200 baud and closes it.
+type Bridge struct {
+	UART BridgeUART
+
+	dtrPin, rtsPin machine.Pin
+	signalPins     bool // set by SetSignalPins, as Pin(0) is a real pin
+
+	// Requests of the host, set by the USB interrupt.
+	coding         LineCoding
+	codingChanged  bool
+	dtr, rts       bool
+	signalsChanged bool
+	breakDuration  uint16
+	breakRequested bool
+
+	breakEnd time.Time // end of a break with a duration
+
+	buf [usb.EndpointPacketSize]byte
+}
+
+// SetSignalPins sets the outputs that follow the DTR and RTS signals of the
+// host, active low like on most USB to serial adapters, for example to reset
+// the board on the other side of the UART as the Arduino IDE does. NoPin leaves
+// a signal out.
+func (b *Bridge) SetSignalPins(dtr, rts machine.Pin) {
+	b.dtrPin, b.rtsPin = dtr, rts
+	b.signalPins = true
+	for _, pin := range [...]machine.Pin{dtr, rts} {
+		if pin != machine.NoPin {
+			pin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+			pin.High()
+		}
+	}
+}
+
+// Run passes the data between the USB CDC serial port and the UART, and never
+// returns. The USB CDC serial port must be enabled, which is the default.
+func (b *Bridge) Run() {
+	usbcdc := New()
+	usbcdc.SetLineCodingHandler(func(coding LineCoding) {
+		b.coding = coding
+		b.codingChanged = true
+	})
+	usbcdc.SetLineStateHandler(func(dtr, rts bool) {
+		b.dtr, b.rts = dtr, rts
+		b.signalsChanged = true
+	})
+	usbcdc.SetBreakHandler(func(duration uint16) {
+		b.breakDuration = duration
+		b.breakRequested = true
+	})
+	b.coding = usbcdc.LineCoding()
+	b.codingChanged = true
+	for {
+		b.handleRequests()
+		idle := true
+		if b.UART.Buffered() > 0 {
+			n, _ := b.UART.Read(b.buf[:])
+			usbcdc.Write(b.buf[:n])
+			idle = false
+		}
+		if usbcdc.Buffered() > 0 {
+			n, _ := usbcdc.Read(b.buf[:])
+			b.UART.Write(b.buf[:n])
+			idle = false
+		}
+		if idle {
+			runtime.Gosched()
+		}
+	}
+}
+
+// handleRequests passes the requests of the host on to the UART and the pins.
+func (b *Bridge) handleRequests() {
+	mask := interrupt.Disable()
+	coding, codingChanged := b.coding, b.codingChanged
+	dtr, rts, signalsChanged := b.dtr, b.rts, b.signalsChanged
+	duration, breakRequested := b.breakDuration, b.breakRequested
+	b.codingChanged, b.signalsChanged, b.breakRequested = false, false, false
+	interrupt.Restore(mask)
+
+	if codingChanged {
+		b.setLineCoding(coding)
+	}
+	if signalsChanged && b.signalPins {
+		if b.dtrPin != machine.NoPin {
+			b.dtrPin.Set(!dtr)
+		}
+		if b.rtsPin != machine.NoPin {
+			b.rtsPin.Set(!rts)
+		}
+	}
+	breaker, ok := b.UART.(uartBreaker)
+	if !ok {
+		return
+	}
+	if breakRequested {
+		breaker.SetBreak(duration != 0)
+		b.breakEnd = time.Time{}
+		if duration != 0 && duration != 0xffff {
+			b.breakEnd = time.Now().Add(time.Duration(duration) * time.Millisecond)
+		}
+	}
+	if !b.breakEnd.IsZero() && time.Now().After(b.breakEnd) {
+		breaker.SetBreak(false)
+		b.breakEnd = time.Time{}
+	}
+}
+
+// setLineCoding sets the baud rate and format of the UART. Formats that the
+// UART doesn't have, like mark and space parity, are left out.
+func (b *Bridge) setLineCoding(coding LineCoding) {
+	if coding.BaudRate != 0 {
+		b.UART.SetBaudRate(coding.BaudRate)
+	}
+	formatter, ok := b.UART.(uartFormatter)
+	if !ok || coding.DataBits < 5 || coding.DataBits > 8 {
+		return
+	}
+	stopBits := uint8(1)
+	if coding.StopBits != 0 {
+		stopBits = 2 // 1.5 stop bits are sent as 2
+	}
+	parity := machine.ParityNone
+	switch coding.Parity {
+	case 1:
+		parity = machine.ParityOdd
+	case 2:
+		parity = machine.ParityEven
+	}
+	formatter.SetFormat(coding.DataBits, stopBits, parity)
+}
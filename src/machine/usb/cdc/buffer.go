@@ -1,6 +1,7 @@
 package cdc
 
 import (
+	"machine/usb"
 	"runtime/volatile"
 )
 
@@ -57,7 +58,7 @@ const txRingBufferSize = 8
 // https://www.embeddedrelated.com/showthread/comp.arch.embedded/77084-1.php
 type txRingBuffer struct {
 	buffer [txRingBufferSize]struct {
-		buf  [64]byte
+		buf  [usb.EndpointPacketSize]byte
 		size int
 	}
 	head volatile.Register8
@@ -74,31 +75,26 @@ func (rb *txRingBuffer) Used() uint8 {
 	return uint8(rb.head.Get() - rb.tail.Get())
 }
 
-// Put stores a byte in the buffer. If the buffer is already
-// full, the method will return false.
-func (rb *txRingBuffer) Put(val []byte) bool {
-	if rb.Used() == txRingBufferSize {
-		return false
-	}
-
-	if rb.Used() == 0 {
-		rb.head.Set(rb.head.Get() + 1)
-		rb.buffer[rb.head.Get()%txRingBufferSize].size = 0
-	}
-	buf := &rb.buffer[rb.head.Get()%txRingBufferSize]
-
-	for i := 0; i < len(val); i++ {
-		if buf.size == 64 {
-			// next
-			// TODO: Make sure that data is not corrupted even when the buffer is full
+// Put appends val to the packets in the buffer, filling the last packet
+// before it starts a new one, and returns how many bytes of val fit.
+func (rb *txRingBuffer) Put(val []byte) int {
+	n := 0
+	for n < len(val) {
+		// The last packet was taken by Get when the buffer is empty, so a
+		// new one is started.
+		if rb.Used() == 0 || rb.buffer[rb.head.Get()%txRingBufferSize].size == len(rb.buffer[0].buf) {
+			if rb.Used() == txRingBufferSize {
+				break
+			}
 			rb.head.Set(rb.head.Get() + 1)
-			buf = &rb.buffer[rb.head.Get()%txRingBufferSize]
 			rb.buffer[rb.head.Get()%txRingBufferSize].size = 0
 		}
-		buf.buf[buf.size] = val[i]
-		buf.size++
+		buf := &rb.buffer[rb.head.Get()%txRingBufferSize]
+		copied := copy(buf.buf[buf.size:], val[n:])
+		buf.size += copied
+		n += copied
 	}
-	return true
+	return n
 }
 
 // Get returns a byte from the buffer. If the buffer is empty,
@@ -4,11 +4,15 @@ import (
 	"errors"
 	"machine"
 	"machine/usb"
+	"runtime"
 	"runtime/interrupt"
 )
 
 var (
-	ErrBufferEmpty = errors.New("USB-CDC buffer empty")
+	ErrBufferEmpty      = errors.New("USB-CDC buffer empty")
+	ErrUSBNotConfigured = errors.New("USB-CDC: the host hasn't configured the device")
+	ErrPortClosed       = errors.New("USB-CDC: the host hasn't opened the port")
+	ErrBufferFull       = errors.New("USB-CDC: TX buffer full")
 )
 
 const cdcLineInfoSize = 7
@@ -21,26 +25,35 @@ type cdcLineInfo struct {
 	lineState   uint8
 }
 
-// Read from the RX buffer.
+// Read reads the data in the RX buffer into data, and returns how many bytes
+// it read. Like an io.Reader, it waits for at least a byte, letting other
+// goroutines run, unless the host hasn't configured the device, which makes it
+// return ErrUSBNotConfigured. Use Buffered to check whether Read would wait.
+// In an interrupt handler or with interrupts disabled, it returns
+// ErrBufferEmpty instead of waiting.
 func (usbcdc *USBCDC) Read(data []byte) (n int, err error) {
-	// check if RX buffer is empty
-	size := usbcdc.Buffered()
-	if size == 0 {
+	if len(data) == 0 {
 		return 0, nil
 	}
-
-	// Make sure we do not read more from buffer than the data slice can hold.
-	if len(data) < size {
-		size = len(data)
+	for usbcdc.Buffered() == 0 {
+		if !machine.USBConfigured() {
+			return 0, ErrUSBNotConfigured
+		}
+		if !canWait() {
+			return 0, ErrBufferEmpty
+		}
+		runtime.Gosched()
 	}
-
-	// only read number of bytes used from buffer
-	for i := 0; i < size; i++ {
-		v, _ := usbcdc.ReadByte()
-		data[i] = v
+	for n < len(data) {
+		v, ok := usbcdc.rxBuffer.Get()
+		if !ok {
+			break
+		}
+		data[n] = v
+		n++
 	}
-
-	return size, nil
+	usbcdc.releaseRx()
+	return n, nil
 }
 
 // ReadByte reads a single byte from the RX buffer.
@@ -51,6 +64,7 @@ func (usbcdc *USBCDC) ReadByte() (byte, error) {
 	if !ok {
 		return 0, ErrBufferEmpty
 	}
+	usbcdc.releaseRx()
 	return buf, nil
 }
 
@@ -65,13 +79,46 @@ func (usbcdc *USBCDC) Receive(data byte) {
 	usbcdc.rxBuffer.Put(data)
 }
 
+// releaseRx lets the host send the next packet once the RX buffer has room for
+// it, if cdcCallbackRx held the OUT endpoint.
+func (usbcdc *USBCDC) releaseRx() {
+	mask := interrupt.Disable()
+	if usbcdc.rxHeld && rxRingBufferSize-int(usbcdc.rxBuffer.Used()) >= usb.EndpointPacketSize {
+		usbcdc.rxHeld = false
+		machine.ReleaseUSBRx(cdcEndpointOut)
+	}
+	interrupt.Restore(mask)
+}
+
 // USBCDC is the USB CDC aka serial over USB interface.
 type USBCDC struct {
+	// TxPolicy says what Write does while no host has the port open.
+	TxPolicy TxPolicy
+
 	rxBuffer *rxRingBuffer
 	txBuffer *txRingBuffer
 	waitTxc  bool
+	rxHeld   bool
+
+	lineCodingHandler func(LineCoding)
+	lineStateHandler  func(dtr, rts bool)
+	breakHandler      func(duration uint16)
 }
 
+// TxPolicy is what Write does with the data written while the host hasn't
+// asserted DTR, which it does when a program opens the port.
+type TxPolicy uint8
+
+const (
+	// TxDrop drops the data, so that a program that prints its logs keeps
+	// running without a host. This is the default.
+	TxDrop TxPolicy = iota
+
+	// TxBlock makes Write wait for the host to open the port, so that no data
+	// is lost.
+	TxBlock
+)
+
 var (
 	// USB is a USB CDC interface.
 	USB *USBCDC
@@ -84,35 +131,130 @@ func (usbcdc *USBCDC) Configure(config machine.UARTConfig) error {
 	return nil
 }
 
-// Flush flushes buffered data.
+// Flush waits until the data in the buffer has been sent to the host, or until
+// the host closes the port. It returns right away in an interrupt handler or
+// with interrupts disabled, as the USB interrupt can't send the data then.
 func (usbcdc *USBCDC) Flush() {
-	mask := interrupt.Disable()
+	if !canWait() {
+		return
+	}
+	for usbcdc.DTR() {
+		mask := interrupt.Disable()
+		sending := usbcdc.waitTxc
+		interrupt.Restore(mask)
+		if !sending {
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+// sendNext sends the next packet in the buffer. It is called by the USB
+// interrupt when the host has received a packet, and by Write to send the
+// first one, with interrupts disabled.
+func (usbcdc *USBCDC) sendNext() {
 	if b, ok := usbcdc.txBuffer.Get(); ok {
 		machine.SendUSBInPacket(cdcEndpointIn, b)
 	} else {
 		usbcdc.waitTxc = false
 	}
-	interrupt.Restore(mask)
 }
 
-// Write data to the USBCDC.
+// Write data to the USBCDC. The data is copied into a buffer of packets,
+// which are sent one after the other by the USB interrupt, so Write returns
+// as soon as the data fits in the buffer, and lets other goroutines run while
+// it waits for the packets ahead of it to be sent otherwise. While the host
+// hasn't opened the port, the data is dropped or Write waits, depending on
+// TxPolicy. Write returns how many bytes it buffered, with ErrUSBNotConfigured
+// or ErrPortClosed when it dropped the rest.
+//
+// In an interrupt handler or with interrupts disabled, such as with a println
+// from an interrupt, Write never waits, as the USB interrupt that empties the
+// buffer can't run: it buffers what fits and drops the rest, with
+// ErrBufferFull.
 func (usbcdc *USBCDC) Write(data []byte) (n int, err error) {
-	if usbLineInfo.lineState > 0 {
+	wait := canWait()
+	for n < len(data) {
+		if !usbcdc.DTR() {
+			if usbcdc.TxPolicy == TxDrop || !wait {
+				if !machine.USBConfigured() {
+					return n, ErrUSBNotConfigured
+				}
+				return n, ErrPortClosed
+			}
+			runtime.Gosched()
+			continue
+		}
 		mask := interrupt.Disable()
-		usbcdc.txBuffer.Put(data)
+		n += usbcdc.txBuffer.Put(data[n:])
 		if !usbcdc.waitTxc {
 			usbcdc.waitTxc = true
-			usbcdc.Flush()
+			usbcdc.sendNext()
 		}
 		interrupt.Restore(mask)
+		if n < len(data) {
+			if !wait {
+				return n, ErrBufferFull
+			}
+			runtime.Gosched()
+		}
 	}
-	return len(data), nil
+	return n, nil
+}
+
+// canWait returns whether Write and Flush may wait for the USB interrupt to
+// send the buffer, which is not the case in an interrupt handler or with
+// interrupts disabled.
+func canWait() bool {
+	return !interrupt.In() && !interrupt.Disabled()
 }
 
 // WriteByte writes a byte of data to the USB CDC interface.
 func (usbcdc *USBCDC) WriteByte(c byte) error {
-	usbcdc.Write([]byte{c})
-	return nil
+	_, err := usbcdc.Write([]byte{c})
+	return err
+}
+
+// LineCoding is the format of the serial port that the host asked for. It
+// only matters to a device that passes the data on to a UART.
+type LineCoding struct {
+	BaudRate uint32
+	StopBits uint8 // 0 for 1 stop bit, 1 for 1.5 and 2 for 2
+	Parity   uint8 // 0 for none, 1 for odd, 2 for even, 3 for mark and 4 for space
+	DataBits uint8
+}
+
+// LineCoding returns the format of the serial port that the host asked for
+// last.
+func (usbcdc *USBCDC) LineCoding() LineCoding {
+	mask := interrupt.Disable()
+	coding := LineCoding{
+		BaudRate: usbLineInfo.dwDTERate,
+		StopBits: usbLineInfo.bCharFormat,
+		Parity:   usbLineInfo.bParityType,
+		DataBits: usbLineInfo.bDataBits,
+	}
+	interrupt.Restore(mask)
+	return coding
+}
+
+// SetLineCodingHandler sets a callback that is called from the USB interrupt
+// when the host changes the format of the serial port.
+func (usbcdc *USBCDC) SetLineCodingHandler(handler func(LineCoding)) {
+	usbcdc.lineCodingHandler = handler
+}
+
+// SetLineStateHandler sets a callback that is called from the USB interrupt
+// when the host changes the DTR or RTS signal.
+func (usbcdc *USBCDC) SetLineStateHandler(handler func(dtr, rts bool)) {
+	usbcdc.lineStateHandler = handler
+}
+
+// SetBreakHandler sets a callback that is called from the USB interrupt when
+// the host asks for a break condition. The duration is in milliseconds: 0
+// ends the break, and 0xffff makes it last until it is ended.
+func (usbcdc *USBCDC) SetBreakHandler(handler func(duration uint16)) {
+	usbcdc.breakHandler = handler
 }
 
 func (usbcdc *USBCDC) DTR() bool {
@@ -123,10 +265,17 @@ func (usbcdc *USBCDC) RTS() bool {
 	return (usbLineInfo.lineState & usb_CDC_LINESTATE_RTS) > 0
 }
 
+// cdcCallbackRx stores a packet from the host, and holds the OUT endpoint when
+// the RX buffer has no room for the next one, so that the host waits instead of
+// sending data that would be dropped.
 func cdcCallbackRx(b []byte) {
 	for i := range b {
 		USB.Receive(b[i])
 	}
+	if rxRingBufferSize-int(USB.rxBuffer.Used()) < usb.EndpointPacketSize {
+		USB.rxHeld = true
+		machine.HoldUSBRx(cdcEndpointOut)
+	}
 }
 
 func cdcSetup(setup usb.Setup) bool {
@@ -157,10 +306,16 @@ func cdcSetup(setup usb.Setup) bool {
 			usbLineInfo.bCharFormat = b[4]
 			usbLineInfo.bParityType = b[5]
 			usbLineInfo.bDataBits = b[6]
+			if USB.lineCodingHandler != nil {
+				USB.lineCodingHandler(USB.LineCoding())
+			}
 		}
 
 		if setup.BRequest == usb_CDC_SET_CONTROL_LINE_STATE {
 			usbLineInfo.lineState = setup.WValueL
+			if USB.lineStateHandler != nil {
+				USB.lineStateHandler(USB.DTR(), USB.RTS())
+			}
 		}
 
 		if setup.BRequest == usb_CDC_SET_LINE_CODING || setup.BRequest == usb_CDC_SET_CONTROL_LINE_STATE {
@@ -174,9 +329,9 @@ func cdcSetup(setup usb.Setup) bool {
 		}
 
 		if setup.BRequest == usb_CDC_SEND_BREAK {
-			// TODO: something with this value?
-			// breakValue = ((uint16_t)setup.wValueH << 8) | setup.wValueL;
-			// return false;
+			if USB.breakHandler != nil {
+				USB.breakHandler(uint16(setup.WValueH)<<8 | uint16(setup.WValueL))
+			}
 			machine.SendZlp()
 		}
 		return true
@@ -186,5 +341,5 @@ func cdcSetup(setup usb.Setup) bool {
 
 func EnableUSBCDC() {
 	machine.USBCDC = New()
-	machine.EnableCDC(USB.Flush, cdcCallbackRx, cdcSetup)
+	machine.EnableCDC(USB.sendNext, cdcCallbackRx, cdcSetup)
 }
@@ -0,0 +1,148 @@
+package usb
+
+// Function is a function of the device, such as the CDC serial port or a HID
+// keyboard: one or more interfaces, with their endpoints.
+type Function struct {
+	// Interfaces is the number of interfaces of the function.
+	Interfaces uint8
+
+	// Descriptor appends the descriptors of the function to b: its interface
+	// descriptors, numbered from first, and their class and endpoint
+	// descriptors.
+	Descriptor func(b []byte, first uint8) []byte
+}
+
+// AddFunction adds a function at the end of the configuration descriptor of
+// DescriptorComposite, and returns the number of its first interface. Class
+// requests to its interfaces are passed to the setup handler for that number.
+// It must be called from an init(), before the host enumerates the device.
+//
+// The functions of a device must use distinct endpoints: the vendor-specific
+// interface uses the endpoints of MIDI, so they can't be added together, but
+// CDC, HID and a vendor-specific interface such as mass storage can.
+func AddFunction(f Function) uint8 {
+	c := DescriptorComposite.Configuration
+	first := c[4]
+	c = f.Descriptor(c, first)
+	c[2] = byte(len(c))
+	c[3] = byte(len(c) >> 8)
+	c[4] = first + f.Interfaces
+	DescriptorComposite.Configuration = c
+	return first
+}
+
+const hidDescriptorType = 0x21
+
+// hidInterface is the number of the HID interface, once hidAdded.
+var (
+	hidInterface uint8
+	hidAdded     bool
+)
+
+// AddHID adds a HID interface with the given report descriptor, and returns
+// its number.
+func AddHID(report []byte) uint8 {
+	iface := AddFunction(Function{
+		Interfaces: 1,
+		Descriptor: func(b []byte, first uint8) []byte {
+			b = AppendInterface(b, first, 1, DEVICE_CLASS_HUMAN_INTERFACE, 0, 0, 0)
+			b = append(b, 0x09, hidDescriptorType, 0x01, 0x01, 0x00, 0x01, HID_REPORT_TYPE, byte(len(report)), byte(len(report)>>8))
+			return AppendEndpoint(b, HID_ENDPOINT_IN|EndpointIn, ENDPOINT_TYPE_INTERRUPT, EndpointPacketSize, 1)
+		},
+	})
+	DescriptorComposite.HID[uint16(iface)] = report
+	hidInterface, hidAdded = iface, true
+	return iface
+}
+
+// AppendHIDReport adds the reports in report, one or more top-level
+// collections, to the report descriptor of the HID interface, whether AddHID
+// has added it yet or not. It must be called from an init(), before the host
+// enumerates the device.
+func AppendHIDReport(report []byte) {
+	if !hidAdded {
+		HIDReportDescriptor = append(HIDReportDescriptor[:len(HIDReportDescriptor):len(HIDReportDescriptor)], report...)
+		return
+	}
+	r := DescriptorComposite.HID[uint16(hidInterface)]
+	r = append(r[:len(r):len(r)], report...)
+	DescriptorComposite.HID[uint16(hidInterface)] = r
+
+	// Update the length of the report descriptor in the HID descriptor that
+	// follows the interface descriptor.
+	c := DescriptorComposite.Configuration
+	found := false
+	for i := int(c[0]); i+1 < len(c) && c[i] != 0; i += int(c[i]) {
+		switch c[i+1] {
+		case INTERFACE_DESCRIPTOR_TYPE:
+			found = c[i+2] == hidInterface
+		case hidDescriptorType:
+			if found {
+				c[i+7], c[i+8] = byte(len(r)), byte(len(r)>>8)
+				return
+			}
+		}
+	}
+}
+
+// CDCFunction is the CDC ACM serial port, which is always the first function
+// of the device.
+var CDCFunction = Function{
+	Interfaces: 2,
+	Descriptor: func(b []byte, first uint8) []byte {
+		b = AppendInterfaceAssociation(b, first, 2, DEVICE_CLASS_COMMUNICATIONS, 0x02, 0x00)
+		b = AppendInterface(b, first, 1, DEVICE_CLASS_COMMUNICATIONS, 0x02, 0x00, 0)
+		b = append(b,
+			0x05, 0x24, 0x00, 0x10, 0x01, // header
+			0x04, 0x24, 0x02, 0x06, // abstract control management
+			0x05, 0x24, 0x06, first, first+1, // union
+			0x05, 0x24, 0x01, 0x01, first+1, // call management
+		)
+		b = AppendEndpoint(b, CDC_ENDPOINT_ACM|EndpointIn, ENDPOINT_TYPE_INTERRUPT, 0x10, 0x10)
+		b = AppendInterface(b, first+1, 2, 0x0a, 0x00, 0x00, 0) // CDC data
+		b = AppendEndpoint(b, CDC_ENDPOINT_OUT|EndpointOut, ENDPOINT_TYPE_BULK, EndpointPacketSize, 0)
+		return AppendEndpoint(b, CDC_ENDPOINT_IN|EndpointIn, ENDPOINT_TYPE_BULK, EndpointPacketSize, 0)
+	},
+}
+
+// MIDIFunction is a USB MIDI device with one input and one output port.
+var MIDIFunction = Function{
+	Interfaces: 2,
+	Descriptor: func(b []byte, first uint8) []byte {
+		b = AppendInterfaceAssociation(b, first, 2, 0x01, 0x01, 0x00)
+		b = AppendInterface(b, first, 0, 0x01, 0x01, 0x00, 0) // audio control
+		b = append(b, 0x09, 0x24, 0x01, 0x00, 0x01, 0x09, 0x00, 0x01, first+1)
+		b = AppendInterface(b, first+1, 2, 0x01, 0x03, 0x00, 0) // MIDI streaming
+		b = append(b,
+			0x07, 0x24, 0x01, 0x00, 0x01, 0x41, 0x00, // header
+			0x06, 0x24, 0x02, 0x01, 0x01, 0x00, // embedded IN jack 1
+			0x06, 0x24, 0x02, 0x02, 0x02, 0x00, // external IN jack 2
+			0x09, 0x24, 0x03, 0x01, 0x03, 0x01, 0x02, 0x01, 0x00, // embedded OUT jack 3
+			0x09, 0x24, 0x03, 0x02, 0x04, 0x01, 0x01, 0x01, 0x00, // external OUT jack 4
+			// Audio class endpoints have two more bytes.
+			0x09, 0x05, MIDI_ENDPOINT_OUT|EndpointOut, ENDPOINT_TYPE_BULK, EndpointPacketSize, 0x00, 0x00, 0x00, 0x00,
+			0x05, 0x25, 0x01, 0x01, 0x01,
+			0x09, 0x05, MIDI_ENDPOINT_IN|EndpointIn, ENDPOINT_TYPE_BULK, EndpointPacketSize, 0x00, 0x00, 0x00, 0x00,
+			0x05, 0x25, 0x01, 0x01, 0x03,
+		)
+		return b
+	},
+}
+
+// AppendInterfaceAssociation appends an interface association descriptor,
+// which groups the interfaces of a function that has several.
+func AppendInterfaceAssociation(b []byte, first, count, class, subClass, protocol uint8) []byte {
+	return append(b, 0x08, 0x0b, first, count, class, subClass, protocol, 0x00)
+}
+
+// AppendInterface appends an interface descriptor. The name is the index of
+// a string descriptor, or zero.
+func AppendInterface(b []byte, number, endpoints, class, subClass, protocol, name uint8) []byte {
+	return append(b, 0x09, INTERFACE_DESCRIPTOR_TYPE, number, 0x00, endpoints, class, subClass, protocol, name)
+}
+
+// AppendEndpoint appends an endpoint descriptor. The address includes the
+// direction (EndpointIn or EndpointOut), and attributes is the transfer type.
+func AppendEndpoint(b []byte, address, attributes uint8, maxPacketSize uint16, interval uint8) []byte {
+	return append(b, 0x07, ENDPOINT_DESCRIPTOR_TYPE, address, attributes, byte(maxPacketSize), byte(maxPacketSize>>8), interval)
+}
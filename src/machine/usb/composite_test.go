@@ -0,0 +1,152 @@
+package usb
+
+import (
+	"bytes"
+	"testing"
+)
+
+// resetComposite starts a new configuration with only the CDC serial port.
+func resetComposite() {
+	DescriptorComposite.Configuration = []byte{0x09, 0x02, 0x09, 0x00, 0x00, 0x01, 0x00, 0xa0, 0x32}
+	DescriptorComposite.HID = map[uint16][]byte{}
+	DescriptorComposite.Strings = map[uint8]string{}
+	hidAdded = false
+	AddFunction(CDCFunction)
+}
+
+// The configurations that were fixed before they were built from functions.
+var (
+	configurationCDC = []byte{
+		0x09, 0x02, 0x4b, 0x00, 0x02, 0x01, 0x00, 0xa0, 0x32,
+		0x08, 0x0b, 0x00, 0x02, 0x02, 0x02, 0x00, 0x00,
+		0x09, 0x04, 0x00, 0x00, 0x01, 0x02, 0x02, 0x00, 0x00,
+		0x05, 0x24, 0x00, 0x10, 0x01,
+		0x04, 0x24, 0x02, 0x06,
+		0x05, 0x24, 0x06, 0x00, 0x01,
+		0x05, 0x24, 0x01, 0x01, 0x01,
+		0x07, 0x05, 0x81, 0x03, 0x10, 0x00, 0x10,
+		0x09, 0x04, 0x01, 0x00, 0x02, 0x0a, 0x00, 0x00, 0x00,
+		0x07, 0x05, 0x02, 0x02, 0x40, 0x00, 0x00,
+		0x07, 0x05, 0x83, 0x02, 0x40, 0x00, 0x00,
+	}
+	configurationHID = []byte{
+		0x09, 0x04, 0x02, 0x00, 0x01, 0x03, 0x00, 0x00, 0x00,
+		0x09, 0x21, 0x01, 0x01, 0x00, 0x01, 0x22, 0x65, 0x00,
+		0x07, 0x05, 0x84, 0x03, 0x40, 0x00, 0x01,
+	}
+	configurationMIDI = []byte{
+		0x08, 0x0b, 0x02, 0x02, 0x01, 0x01, 0x00, 0x00,
+		0x09, 0x04, 0x02, 0x00, 0x00, 0x01, 0x01, 0x00, 0x00,
+		0x09, 0x24, 0x01, 0x00, 0x01, 0x09, 0x00, 0x01, 0x03,
+		0x09, 0x04, 0x03, 0x00, 0x02, 0x01, 0x03, 0x00, 0x00,
+		0x07, 0x24, 0x01, 0x00, 0x01, 0x41, 0x00,
+		0x06, 0x24, 0x02, 0x01, 0x01, 0x00,
+		0x06, 0x24, 0x02, 0x02, 0x02, 0x00,
+		0x09, 0x24, 0x03, 0x01, 0x03, 0x01, 0x02, 0x01, 0x00,
+		0x09, 0x24, 0x03, 0x02, 0x04, 0x01, 0x01, 0x01, 0x00,
+		0x09, 0x05, 0x05, 0x02, 0x40, 0x00, 0x00, 0x00, 0x00,
+		0x05, 0x25, 0x01, 0x01, 0x01,
+		0x09, 0x05, 0x86, 0x02, 0x40, 0x00, 0x00, 0x00, 0x00,
+		0x05, 0x25, 0x01, 0x01, 0x03,
+	}
+	configurationVendor = []byte{
+		0x09, 0x04, 0x02, 0x00, 0x02, 0xff, 0x00, 0x00, 0x00,
+		0x07, 0x05, 0x05, 0x02, 0x40, 0x00, 0x00,
+		0x07, 0x05, 0x86, 0x02, 0x40, 0x00, 0x00,
+	}
+)
+
+// configurationDFU is the DFU runtime interface and its functional descriptor.
+var configurationDFU = []byte{
+	0x09, 0x04, 0x02, 0x00, 0x00, 0xfe, 0x01, 0x01, 0x00,
+	0x09, 0x21, 0x08, 0xe8, 0x03, 0x40, 0x00, 0x10, 0x01,
+}
+
+// withHeader returns the CDC configuration followed by the descriptors of a
+// function, with the header updated.
+func withHeader(function []byte, interfaces uint8) []byte {
+	c := append(append([]byte{}, configurationCDC...), function...)
+	c[2], c[3] = byte(len(c)), byte(len(c)>>8)
+	c[4] = interfaces
+	return c
+}
+
+func TestCompositeFunctions(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		add  func()
+		want []byte
+	}{
+		{"CDC", func() {}, configurationCDC},
+		{"HID", func() { AddHID(HIDReportDescriptor) }, withHeader(configurationHID, 3)},
+		{"MIDI", func() { AddFunction(MIDIFunction) }, withHeader(configurationMIDI, 4)},
+		{"Vendor", func() { ConfigureVendor(DEVICE_CLASS_VENDOR_SPECIFIC, 0, 0, ENDPOINT_TYPE_BULK, "") }, withHeader(configurationVendor, 3)},
+		{"DFU", func() { AddFunction(DFURuntimeFunction) }, withHeader(configurationDFU, 3)},
+	} {
+		resetComposite()
+		tc.add()
+		if got := DescriptorComposite.Configuration; !bytes.Equal(got, tc.want) {
+			t.Errorf("%s configuration:\ngot  % x\nwant % x", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCompositeNumbering(t *testing.T) {
+	resetComposite()
+	hid := AddHID(HIDReportDescriptor)
+	ConfigureVendor(DEVICE_CLASS_STORAGE, 0x06, 0x50, ENDPOINT_TYPE_BULK, "Drive")
+	if hid != 2 || VendorInterface != 3 {
+		t.Errorf("interfaces: HID %d, vendor %d; want 2 and 3", hid, VendorInterface)
+	}
+	c := DescriptorComposite.Configuration
+	if int(c[2])|int(c[3])<<8 != len(c) || c[4] != 4 {
+		t.Errorf("header % x for a configuration of %d bytes", c[:9], len(c))
+	}
+	if _, ok := DescriptorComposite.HID[2]; !ok {
+		t.Error("HID report descriptor not registered for interface 2")
+	}
+	vendor := c[len(c)-23:]
+	if vendor[2] != 3 || vendor[5] != DEVICE_CLASS_STORAGE || vendor[8] != IVENDOR {
+		t.Errorf("vendor interface descriptor % x", vendor[:9])
+	}
+	if DescriptorComposite.Strings[IVENDOR] != "Drive" {
+		t.Error("vendor interface name not registered")
+	}
+}
+
+func TestAppendHIDReport(t *testing.T) {
+	report := []byte{0x05, 0x01, 0x09, 0x05, 0xa1, 0x01, 0xc0}
+	defaultReport := HIDReportDescriptor
+	defer func() { HIDReportDescriptor = defaultReport }()
+
+	// Before the HID interface is added.
+	resetComposite()
+	AppendHIDReport(report)
+	AddHID(HIDReportDescriptor)
+	before := DescriptorComposite.Configuration
+
+	// After it is added, behind the DFU runtime interface which has a class
+	// descriptor of the same type.
+	HIDReportDescriptor = defaultReport
+	resetComposite()
+	AddFunction(DFURuntimeFunction)
+	AddHID(HIDReportDescriptor)
+	AppendHIDReport(report)
+	if got := len(DescriptorComposite.HID[3]); got != len(defaultReport)+len(report) {
+		t.Errorf("report descriptor of %d bytes, want %d", got, len(defaultReport)+len(report))
+	}
+	if len(HIDReportDescriptor) != len(defaultReport) {
+		t.Error("default report descriptor changed")
+	}
+	c := DescriptorComposite.Configuration
+	hid := c[len(c)-25:]
+	if d := hid[9:]; d[1] != 0x21 || int(d[7])|int(d[8])<<8 != len(defaultReport)+len(report) {
+		t.Errorf("HID descriptor % x", d[:9])
+	}
+	if dfu := c[len(configurationCDC)+9:][:9]; !bytes.Equal(dfu, configurationDFU[9:]) {
+		t.Errorf("DFU functional descriptor changed: % x", dfu)
+	}
+	if !bytes.Equal(before[len(before)-22:], hid[3:]) {
+		t.Errorf("HID interface appended before and after AddHID:\n% x\n% x", before[len(before)-25:], hid)
+	}
+}
@@ -0,0 +1,542 @@
+// Package dap implements a CMSIS-DAP v2 debug probe, so that a board with USB
+// can be used to program and debug other chips over SWD.
+//
+// The probe is exposed as a vendor-specific USB interface named "CMSIS-DAP"
+// with bulk endpoints, which is detected by tools like OpenOCD and pyOCD.
+// Only the SWD protocol is implemented.
+//
+// Example:
+//
+//	var probe = dap.New(&dap.SWD{
+//		SWCLK:  machine.D2,
+//		SWDIO:  machine.D3,
+//		NRESET: machine.D4,
+//	})
+//
+//	func main() {
+//		probe.Serve()
+//	}
+package dap
+
+import (
+	"machine/usb"
+	"machine/usb/vendor"
+	"time"
+)
+
+// DAP commands.
+const (
+	cmdInfo              = 0x00
+	cmdHostStatus        = 0x01
+	cmdConnect           = 0x02
+	cmdDisconnect        = 0x03
+	cmdTransferConfigure = 0x04
+	cmdTransfer          = 0x05
+	cmdTransferBlock     = 0x06
+	cmdTransferAbort     = 0x07
+	cmdWriteABORT        = 0x08
+	cmdDelay             = 0x09
+	cmdResetTarget       = 0x0A
+	cmdSWJPins           = 0x10
+	cmdSWJClock          = 0x11
+	cmdSWJSequence       = 0x12
+	cmdSWDConfigure      = 0x13
+	cmdSWDSequence       = 0x1D
+	cmdQueueCommands     = 0x7E
+	cmdExecuteCommands   = 0x7F
+	cmdInvalid           = 0xFF
+)
+
+// DAP_Info IDs.
+const (
+	infoVendor          = 0x01
+	infoProduct         = 0x02
+	infoSerialNumber    = 0x03
+	infoProtocolVersion = 0x04
+	infoFirmwareVersion = 0x09
+	infoCapabilities    = 0xF0
+	infoPacketCount     = 0xFE
+	infoPacketSize      = 0xFF
+)
+
+// Bits of a transfer request.
+const (
+	transferAPnDP      = 1 << 0
+	transferRnW        = 1 << 1
+	transferValueMatch = 1 << 4
+	transferMatchMask  = 1 << 5
+	transferTimestamp  = 1 << 7
+
+	transferMismatch = 1 << 4
+)
+
+const (
+	dapOK    = 0x00
+	dapError = 0xFF
+
+	portDefault = 0
+	portSWD     = 1
+
+	// Address of the RDBUFF register on the debug port, which holds the
+	// result of the previous (posted) AP read.
+	dpRDBUFF = 0x0C
+
+	packetSize = usb.EndpointPacketSize
+)
+
+// Strings reported by DAP_Info.
+var (
+	Vendor          = "TinyGo"
+	Product         = "TinyGo CMSIS-DAP"
+	FirmwareVersion = "1.0"
+)
+
+// DAP is a CMSIS-DAP command processor.
+type DAP struct {
+	swd       Transport
+	port      *vendor.Vendor
+	connected bool
+	aborted   bool
+
+	waitRetry  int
+	matchRetry int
+	matchMask  uint32
+
+	request  [packetSize]byte
+	response [packetSize]byte
+}
+
+// New returns a new CMSIS-DAP probe using the given SWD transport and
+// enables the USB interface for it. It must be called before the host
+// enumerates the device, for example from a package-level variable
+// initializer or from init().
+func New(swd Transport) *DAP {
+	return &DAP{
+		swd: swd,
+		port: vendor.Configure(vendor.Config{
+			Name: "CMSIS-DAP",
+		}),
+		waitRetry: 100,
+	}
+}
+
+// Serve processes DAP commands received over USB. It never returns.
+func (d *DAP) Serve() {
+	for {
+		n, _ := d.port.Read(d.request[:])
+		if n == 0 {
+			time.Sleep(100 * time.Microsecond)
+			continue
+		}
+		m := d.Process(d.request[:n], d.response[:])
+		d.port.Write(d.response[:m])
+	}
+}
+
+// Process executes a single DAP command from req and stores the response in
+// resp. It returns the length of the response. A command that is shorter than
+// its parameters, or whose response doesn't fit in resp, is answered with
+// DAP_ERROR without being executed, and ends the packet.
+func (d *DAP) Process(req, resp []byte) int {
+	if len(req) == 0 || len(resp) < 2 {
+		return 0
+	}
+	if req[0] == cmdExecuteCommands || req[0] == cmdQueueCommands {
+		// Multiple commands in a single packet.
+		resp[0] = req[0]
+		if len(req) < 2 {
+			resp[1] = dapError
+			return 2
+		}
+		reqOffset, respOffset := 2, 2
+		done := 0
+		for ; done < int(req[1]) && reqOffset < len(req); done++ {
+			n, m := d.process(req[reqOffset:], resp[respOffset:])
+			reqOffset += n
+			respOffset += m
+		}
+		resp[1] = byte(done)
+		return respOffset
+	}
+	_, m := d.process(req, resp)
+	return m
+}
+
+// requestSizes are the sizes of the commands with fixed parameters, and the
+// sizes of the fixed part of the others.
+var requestSizes = [...]uint8{
+	cmdInfo:              2,
+	cmdHostStatus:        3,
+	cmdConnect:           2,
+	cmdDisconnect:        1,
+	cmdTransferConfigure: 6,
+	cmdTransfer:          3,
+	cmdTransferBlock:     5,
+	cmdTransferAbort:     1,
+	cmdWriteABORT:        6,
+	cmdDelay:             3,
+	cmdResetTarget:       1,
+	cmdSWJPins:           7,
+	cmdSWJClock:          5,
+	cmdSWJSequence:       2,
+	cmdSWDConfigure:      2,
+	cmdSWDSequence:       2,
+}
+
+// process executes a single command and returns the number of request bytes
+// consumed and the length of the response.
+func (d *DAP) process(req, resp []byte) (n, m int) {
+	if len(resp) < 3 {
+		// There is no room left for the response.
+		return len(req), 0
+	}
+	resp[0] = req[0]
+	if int(req[0]) < len(requestSizes) && len(req) < int(requestSizes[req[0]]) {
+		return malformed(req, resp)
+	}
+	switch req[0] {
+	case cmdInfo:
+		return 2, 1 + d.info(req[1], resp[1:])
+	case cmdHostStatus:
+		resp[1] = dapOK
+		return 3, 2
+	case cmdConnect:
+		resp[1] = 0
+		if req[1] == portDefault || req[1] == portSWD {
+			d.swd.Configure()
+			d.connected = true
+			resp[1] = portSWD
+		}
+		return 2, 2
+	case cmdDisconnect:
+		d.swd.Release()
+		d.connected = false
+		resp[1] = dapOK
+		return 1, 2
+	case cmdTransferConfigure:
+		d.swd.SetIdleCycles(int(req[1]))
+		d.waitRetry = int(le16(req[2:]))
+		d.matchRetry = int(le16(req[4:]))
+		resp[1] = dapOK
+		return 6, 2
+	case cmdTransfer:
+		return d.transfer(req, resp)
+	case cmdTransferBlock:
+		return d.transferBlock(req, resp)
+	case cmdTransferAbort:
+		d.aborted = true
+		// This command has no response.
+		return 1, 0
+	case cmdWriteABORT:
+		value := le32(req[2:])
+		if d.swd.Transfer(0x00, &value) == ackOK {
+			resp[1] = dapOK
+		} else {
+			resp[1] = dapError
+		}
+		return 6, 2
+	case cmdDelay:
+		time.Sleep(time.Duration(le16(req[1:])) * time.Microsecond)
+		resp[1] = dapOK
+		return 3, 2
+	case cmdResetTarget:
+		// There is no device specific reset sequence.
+		resp[1] = dapOK
+		resp[2] = 0
+		return 1, 3
+	case cmdSWJPins:
+		return d.swjPins(req, resp)
+	case cmdSWJClock:
+		// The bit-banged transport always runs at the maximum speed.
+		resp[1] = dapOK
+		return 5, 2
+	case cmdSWJSequence:
+		count := int(req[1])
+		if count == 0 {
+			count = 256
+		}
+		if len(req) < 2+(count+7)/8 {
+			return malformed(req, resp)
+		}
+		d.swd.Sequence(count, req[2:])
+		resp[1] = dapOK
+		return 2 + (count+7)/8, 2
+	case cmdSWDConfigure:
+		d.swd.SetTurnaround(int(req[1]&0x3)+1, req[1]&0x4 != 0)
+		resp[1] = dapOK
+		return 2, 2
+	case cmdSWDSequence:
+		return d.swdSequence(req, resp)
+	default:
+		resp[0] = cmdInvalid
+		return len(req), 1
+	}
+}
+
+// malformed answers a command that is too short, or whose response doesn't
+// fit, with DAP_ERROR, and consumes the rest of the packet.
+func malformed(req, resp []byte) (n, m int) {
+	resp[1] = dapError
+	return len(req), 2
+}
+
+// info stores the requested information in resp, prefixed with its length.
+func (d *DAP) info(id byte, resp []byte) int {
+	var str string
+	switch id {
+	case infoVendor:
+		str = Vendor
+	case infoProduct:
+		str = Product
+	case infoProtocolVersion:
+		str = "2.1.0"
+	case infoFirmwareVersion:
+		str = FirmwareVersion
+	case infoCapabilities:
+		resp[0] = 1
+		resp[1] = 0x01 // SWD
+		return 2
+	case infoPacketCount:
+		resp[0] = 1
+		resp[1] = 1
+		return 2
+	case infoPacketSize:
+		if len(resp) < 3 {
+			resp[0] = 0
+			return 1
+		}
+		resp[0] = 2
+		resp[1] = byte(packetSize)
+		resp[2] = byte(packetSize >> 8)
+		return 3
+	default:
+		// Not available.
+		resp[0] = 0
+		return 1
+	}
+	if len(str)+2 > len(resp) {
+		str = str[:len(resp)-2]
+	}
+	resp[0] = byte(len(str) + 1)
+	copy(resp[1:], str)
+	resp[1+len(str)] = 0
+	return len(str) + 2
+}
+
+// transferOne executes a single transfer, retrying while the target returns
+// WAIT. Reads from an AP are posted, so they are followed by a read of
+// RDBUFF to obtain the value.
+func (d *DAP) transferOne(request uint8, data *uint32) uint8 {
+	ack := d.retry(request, data)
+	if ack == ackOK && request&(transferAPnDP|transferRnW) == transferAPnDP|transferRnW {
+		ack = d.retry(transferRnW|dpRDBUFF, data)
+	}
+	return ack
+}
+
+func (d *DAP) retry(request uint8, data *uint32) uint8 {
+	ack := d.swd.Transfer(request, data)
+	for i := 0; ack == ackWait && i < d.waitRetry && !d.aborted; i++ {
+		ack = d.swd.Transfer(request, data)
+	}
+	return ack
+}
+
+// transferSize returns the size of a DAP_Transfer request and of its response
+// if all transfers succeed, or false if the request is cut short.
+func transferSize(req []byte) (n, m int, ok bool) {
+	n, m = 3, 3
+	for i := 0; i < int(req[2]); i++ {
+		if n >= len(req) {
+			return n, m, false
+		}
+		request := req[n]
+		n++
+		switch {
+		case request&transferRnW == 0, request&transferValueMatch != 0:
+			n += 4
+		case request&transferTimestamp != 0:
+			m += 8
+		default:
+			m += 4
+		}
+	}
+	return n, m, n <= len(req)
+}
+
+func (d *DAP) transfer(req, resp []byte) (n, m int) {
+	if _, respSize, ok := transferSize(req); !ok || respSize > len(resp) {
+		return malformed(req, resp)
+	}
+	d.aborted = false
+	count := int(req[2])
+	n, m = 3, 3
+	done := 0
+	ack := uint8(ackOK)
+	for i := 0; i < count; i++ {
+		request := req[n]
+		n++
+		if ack != ackOK || d.aborted {
+			// Skip the remaining requests in the packet.
+			if request&transferRnW == 0 || request&transferValueMatch != 0 {
+				n += 4
+			}
+			continue
+		}
+		var data uint32
+		switch {
+		case request&transferRnW == 0:
+			// Write, possibly of the match mask.
+			data = le32(req[n:])
+			n += 4
+			if request&transferMatchMask != 0 {
+				d.matchMask = data
+				ack = ackOK
+				break
+			}
+			ack = d.transferOne(request&0x0F, &data)
+		case request&transferValueMatch != 0:
+			// Read until the value matches.
+			match := le32(req[n:])
+			n += 4
+			ack = d.transferOne(request&0x0F, &data)
+			for i := 0; ack == ackOK && data&d.matchMask != match; i++ {
+				if i >= d.matchRetry {
+					ack |= transferMismatch
+					break
+				}
+				ack = d.transferOne(request&0x0F, &data)
+			}
+		default:
+			ack = d.transferOne(request&0x0F, &data)
+			if ack == ackOK {
+				if request&transferTimestamp != 0 {
+					putLE32(resp[m:], uint32(time.Now().UnixNano()/1000))
+					m += 4
+				}
+				putLE32(resp[m:], data)
+				m += 4
+			}
+		}
+		if ack == ackOK {
+			done++
+		}
+	}
+	resp[1] = byte(done)
+	resp[2] = ack
+	return n, m
+}
+
+func (d *DAP) transferBlock(req, resp []byte) (n, m int) {
+	count := int(le16(req[2:]))
+	request := req[4] & 0x0F
+	if request&transferRnW == 0 && len(req) < 5+count*4 || len(resp) < 4 {
+		return malformed(req, resp)
+	}
+	d.aborted = false
+	n, m = 5, 4
+	done := 0
+	ack := uint8(ackOK)
+	for ; done < count && !d.aborted; done++ {
+		var data uint32
+		if request&transferRnW == 0 {
+			data = le32(req[n:])
+			n += 4
+			ack = d.transferOne(request, &data)
+		} else {
+			if m+4 > len(resp) {
+				break
+			}
+			ack = d.transferOne(request, &data)
+			if ack == ackOK {
+				putLE32(resp[m:], data)
+				m += 4
+			}
+		}
+		if ack != ackOK {
+			break
+		}
+	}
+	if request&transferRnW == 0 {
+		n = 5 + count*4
+	}
+	resp[1] = byte(done)
+	resp[2] = byte(done >> 8)
+	resp[3] = ack
+	return n, m
+}
+
+func (d *DAP) swjPins(req, resp []byte) (n, m int) {
+	const pinNRESET = 1 << 7
+	output, selected := req[1], req[2]
+	if selected&pinNRESET != 0 {
+		d.swd.SetReset(output&pinNRESET == 0)
+	}
+	// Only nRESET can be controlled. Pin levels cannot be read back, so
+	// report the requested output levels.
+	resp[1] = output & selected
+	return 7, 2
+}
+
+// swdSequenceSize returns the size of a DAP_SWD_Sequence request and of its
+// response, or false if the request is cut short.
+func swdSequenceSize(req []byte) (n, m int, ok bool) {
+	n, m = 2, 2
+	for i := 0; i < int(req[1]); i++ {
+		if n >= len(req) {
+			return n, m, false
+		}
+		info := req[n]
+		n++
+		cycles := int(info & 0x3F)
+		if cycles == 0 {
+			cycles = 64
+		}
+		if info&0x80 != 0 {
+			m += (cycles + 7) / 8
+		} else {
+			n += (cycles + 7) / 8
+		}
+	}
+	return n, m, n <= len(req)
+}
+
+func (d *DAP) swdSequence(req, resp []byte) (n, m int) {
+	if _, respSize, ok := swdSequenceSize(req); !ok || respSize > len(resp) {
+		return malformed(req, resp)
+	}
+	count := int(req[1])
+	n, m = 2, 2
+	for i := 0; i < count; i++ {
+		info := req[n]
+		n++
+		cycles := int(info & 0x3F)
+		if cycles == 0 {
+			cycles = 64
+		}
+		if info&0x80 != 0 {
+			d.swd.ReadSequence(cycles, resp[m:])
+			m += (cycles + 7) / 8
+		} else {
+			d.swd.Sequence(cycles, req[n:])
+			n += (cycles + 7) / 8
+		}
+	}
+	resp[1] = dapOK
+	return n, m
+}
+
+func le16(b []byte) uint16 {
+	return uint16(b[0]) | uint16(b[1])<<8
+}
+
+func le32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+func putLE32(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+}
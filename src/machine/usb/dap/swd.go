@@ -0,0 +1,130 @@
+package dap
+
+import (
+	"machine"
+)
+
+// SWD acknowledge values.
+const (
+	ackOK    = 0x1
+	ackWait  = 0x2
+	ackFault = 0x4
+	ackNone  = 0x7
+
+	// Set in the transfer response when the parity of read data is wrong.
+	protocolError = 0x8
+)
+
+// Transport is a Serial Wire Debug (SWD) master. It is implemented by SWD,
+// but may also be implemented by transports that use a hardware peripheral
+// (like SPI) to shift out the bits.
+type Transport interface {
+	// Configure sets up the pins for SWD.
+	Configure()
+
+	// Release puts all pins into a high-impedance state.
+	Release()
+
+	// Sequence clocks out count bits of data, LSB first.
+	Sequence(count int, data []byte)
+
+	// ReadSequence clocks in count bits into data, LSB first.
+	ReadSequence(count int, data []byte)
+
+	// Transfer executes a single SWD transfer. The request contains the
+	// APnDP, RnW and A[3:2] bits as used by DAP_Transfer. It returns the ACK
+	// received from the target, possibly combined with protocolError.
+	Transfer(request uint8, data *uint32) uint8
+
+	// SetTurnaround sets the number of turnaround cycles (1-4) and whether a
+	// data phase is generated on WAIT and FAULT responses.
+	SetTurnaround(cycles int, dataPhase bool)
+
+	// SetIdleCycles sets the number of idle cycles after each transfer.
+	SetIdleCycles(cycles int)
+
+	// SetReset drives the reset pin of the target low (asserted) or
+	// releases it.
+	SetReset(asserted bool)
+}
+
+// SWD is a bit-banged SWD master using regular GPIO pins, built on
+// machine.SWD.
+type SWD struct {
+	SWCLK machine.Pin
+	SWDIO machine.Pin
+
+	// NRESET is the optional (open drain) reset pin of the target. Set it to
+	// machine.NoPin if it is not connected.
+	NRESET machine.Pin
+
+	// Delay is half the period of SWCLK in nanoseconds, see machine.SWD.
+	Delay uint32
+
+	bus machine.SWD
+}
+
+// Configure sets up the pins for SWD. Both SWCLK and SWDIO are driven.
+func (s *SWD) Configure() {
+	s.bus.SWCLK = s.SWCLK
+	s.bus.SWDIO = s.SWDIO
+	s.bus.Delay = s.Delay
+	s.bus.Configure()
+	if s.NRESET != machine.NoPin {
+		s.NRESET.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	}
+}
+
+// Release puts all pins into a high-impedance state.
+func (s *SWD) Release() {
+	s.SWCLK.Configure(machine.PinConfig{Mode: machine.PinInput})
+	s.SWDIO.Configure(machine.PinConfig{Mode: machine.PinInput})
+	if s.NRESET != machine.NoPin {
+		s.NRESET.Configure(machine.PinConfig{Mode: machine.PinInput})
+	}
+}
+
+// SetTurnaround sets the number of turnaround cycles and whether a data
+// phase is generated on WAIT and FAULT responses.
+func (s *SWD) SetTurnaround(cycles int, dataPhase bool) {
+	s.bus.Turnaround = uint8(cycles)
+	s.bus.DataPhase = dataPhase
+}
+
+// SetIdleCycles sets the number of idle cycles after each transfer.
+func (s *SWD) SetIdleCycles(cycles int) {
+	s.bus.IdleCycles = uint8(cycles)
+}
+
+// SetReset drives the reset pin of the target low (asserted) or releases it.
+func (s *SWD) SetReset(asserted bool) {
+	if s.NRESET == machine.NoPin {
+		return
+	}
+	if asserted {
+		s.NRESET.Low()
+		s.NRESET.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	} else {
+		s.NRESET.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	}
+}
+
+// Sequence clocks out count bits of data, LSB first.
+func (s *SWD) Sequence(count int, data []byte) {
+	s.bus.Sequence(count, data)
+}
+
+// ReadSequence clocks in count bits into data, LSB first.
+func (s *SWD) ReadSequence(count int, data []byte) {
+	s.bus.ReadSequence(count, data)
+}
+
+// Transfer executes a single SWD transfer and returns the ACK received from
+// the target.
+func (s *SWD) Transfer(request uint8, data *uint32) uint8 {
+	ack, err := s.bus.Transfer(request, data)
+	if err != nil {
+		ack |= protocolError
+	}
+	return ack
+}
@@ -19,6 +19,14 @@ type Descriptor struct {
 	Device        []byte
 	Configuration []byte
 	HID           map[uint16][]byte
+	Strings       map[uint8]string
+
+	// BOS and MSOS20 are the Binary device Object Store descriptor and the
+	// Microsoft OS 2.0 descriptor set, and WebUSBURL is the URL descriptor of
+	// the WebUSB landing page. They are only sent if present.
+	BOS       []byte
+	MSOS20    []byte
+	WebUSBURL []byte
 }
 
 func (d *Descriptor) Configure(idVendor, idProduct uint16) {
@@ -31,87 +39,76 @@ func (d *Descriptor) Configure(idVendor, idProduct uint16) {
 	d.Configuration[3] = byte(len(d.Configuration) >> 8)
 }
 
-var DescriptorCDC = Descriptor{
+// DescriptorComposite is the descriptor of the device. Its configuration
+// descriptor starts with the CDC serial port, and grows with the functions that
+// the class packages add with AddFunction (directly, or through machine).
+var DescriptorComposite = Descriptor{
 	Device: []byte{
-		0x12, 0x01, 0x00, 0x02, 0xef, 0x02, 0x01, 0x40, 0x86, 0x28, 0x2d, 0x80, 0x00, 0x01, 0x01, 0x02, 0x03, 0x01,
+		0x12, 0x01, 0x00, 0x02, 0xef, 0x02, 0x01, EndpointPacketSize, 0x86, 0x28, 0x2d, 0x80, 0x00, 0x01, 0x01, 0x02, 0x03, 0x01,
 	},
 	Configuration: []byte{
-		0x09, 0x02, 0x4b, 0x00, 0x02, 0x01, 0x00, 0xa0, 0x32,
-		0x08, 0x0b, 0x00, 0x02, 0x02, 0x02, 0x00, 0x00,
-		0x09, 0x04, 0x00, 0x00, 0x01, 0x02, 0x02, 0x00, 0x00,
-		0x05, 0x24, 0x00, 0x10, 0x01,
-		0x04, 0x24, 0x02, 0x06,
-		0x05, 0x24, 0x06, 0x00, 0x01,
-		0x05, 0x24, 0x01, 0x01, 0x01,
-		0x07, 0x05, 0x81, 0x03, 0x10, 0x00, 0x10,
-		0x09, 0x04, 0x01, 0x00, 0x02, 0x0a, 0x00, 0x00, 0x00,
-		0x07, 0x05, 0x02, 0x02, 0x40, 0x00, 0x00,
-		0x07, 0x05, 0x83, 0x02, 0x40, 0x00, 0x00,
+		0x09, 0x02, 0x09, 0x00, 0x00, 0x01, 0x00, 0xa0, 0x32,
 	},
+	HID:     map[uint16][]byte{},
+	Strings: map[uint8]string{},
 }
 
-var DescriptorCDCHID = Descriptor{
-	Device: []byte{
-		0x12, 0x01, 0x00, 0x02, 0xef, 0x02, 0x01, 0x40, 0x86, 0x28, 0x2d, 0x80, 0x00, 0x01, 0x01, 0x02, 0x03, 0x01,
-	},
-	Configuration: []byte{
-		0x09, 0x02, 0x64, 0x00, 0x03, 0x01, 0x00, 0xa0, 0x32,
-		0x08, 0x0b, 0x00, 0x02, 0x02, 0x02, 0x00, 0x00,
-		0x09, 0x04, 0x00, 0x00, 0x01, 0x02, 0x02, 0x00, 0x00,
-		0x05, 0x24, 0x00, 0x10, 0x01,
-		0x04, 0x24, 0x02, 0x06,
-		0x05, 0x24, 0x06, 0x00, 0x01,
-		0x05, 0x24, 0x01, 0x01, 0x01,
-		0x07, 0x05, 0x81, 0x03, 0x10, 0x00, 0x10,
-		0x09, 0x04, 0x01, 0x00, 0x02, 0x0a, 0x00, 0x00, 0x00,
-		0x07, 0x05, 0x02, 0x02, 0x40, 0x00, 0x00,
-		0x07, 0x05, 0x83, 0x02, 0x40, 0x00, 0x00,
-		0x09, 0x04, 0x02, 0x00, 0x01, 0x03, 0x00, 0x00, 0x00,
-		0x09, 0x21, 0x01, 0x01, 0x00, 0x01, 0x22, 0x65, 0x00,
-		0x07, 0x05, 0x84, 0x03, 0x40, 0x00, 0x01,
-	},
-	HID: map[uint16][]byte{
-		2: []byte{
-			// keyboard and mouse
-			0x05, 0x01, 0x09, 0x06, 0xa1, 0x01, 0x85, 0x02, 0x05, 0x07, 0x19, 0xe0, 0x29, 0xe7, 0x15, 0x00,
-			0x25, 0x01, 0x75, 0x01, 0x95, 0x08, 0x81, 0x02, 0x95, 0x01, 0x75, 0x08, 0x81, 0x03, 0x95, 0x06,
-			0x75, 0x08, 0x15, 0x00, 0x25, 0x73, 0x05, 0x07, 0x19, 0x00, 0x29, 0x73, 0x81, 0x00, 0xc0, 0x05,
-			0x01, 0x09, 0x02, 0xa1, 0x01, 0x09, 0x01, 0xa1, 0x00, 0x85, 0x01, 0x05, 0x09, 0x19, 0x01, 0x29,
-			0x03, 0x15, 0x00, 0x25, 0x01, 0x95, 0x03, 0x75, 0x01, 0x81, 0x02, 0x95, 0x01, 0x75, 0x05, 0x81,
-			0x03, 0x05, 0x01, 0x09, 0x30, 0x09, 0x31, 0x09, 0x38, 0x15, 0x81, 0x25, 0x7f, 0x75, 0x08, 0x95,
-			0x03, 0x81, 0x06, 0xc0, 0xc0,
-		},
-	},
+func init() {
+	AddFunction(CDCFunction)
 }
 
-var DescriptorCDCMIDI = Descriptor{
-	Device: []byte{
-		0x12, 0x01, 0x00, 0x02, 0xef, 0x02, 0x01, 0x40, 0x86, 0x28, 0x2d, 0x80, 0x00, 0x01, 0x01, 0x02, 0x03, 0x01,
-	},
-	Configuration: []byte{
-		0x09, 0x02, 0xaf, 0x00, 0x04, 0x01, 0x00, 0xa0, 0x32,
-		0x08, 0x0b, 0x00, 0x02, 0x02, 0x02, 0x00, 0x00,
-		0x09, 0x04, 0x00, 0x00, 0x01, 0x02, 0x02, 0x00, 0x00,
-		0x05, 0x24, 0x00, 0x10, 0x01,
-		0x04, 0x24, 0x02, 0x06,
-		0x05, 0x24, 0x06, 0x00, 0x01,
-		0x05, 0x24, 0x01, 0x01, 0x01,
-		0x07, 0x05, 0x81, 0x03, 0x10, 0x00, 0x10,
-		0x09, 0x04, 0x01, 0x00, 0x02, 0x0a, 0x00, 0x00, 0x00,
-		0x07, 0x05, 0x02, 0x02, 0x40, 0x00, 0x00,
-		0x07, 0x05, 0x83, 0x02, 0x40, 0x00, 0x00,
-		0x08, 0x0b, 0x02, 0x02, 0x01, 0x01, 0x00, 0x00,
-		0x09, 0x04, 0x02, 0x00, 0x00, 0x01, 0x01, 0x00, 0x00,
-		0x09, 0x24, 0x01, 0x00, 0x01, 0x09, 0x00, 0x01, 0x03,
-		0x09, 0x04, 0x03, 0x00, 0x02, 0x01, 0x03, 0x00, 0x00,
-		0x07, 0x24, 0x01, 0x00, 0x01, 0x41, 0x00,
-		0x06, 0x24, 0x02, 0x01, 0x01, 0x00,
-		0x06, 0x24, 0x02, 0x02, 0x02, 0x00,
-		0x09, 0x24, 0x03, 0x01, 0x03, 0x01, 0x02, 0x01, 0x00,
-		0x09, 0x24, 0x03, 0x02, 0x04, 0x01, 0x01, 0x01, 0x00,
-		0x09, 0x05, 0x05, 0x02, 0x40, 0x00, 0x00, 0x00, 0x00,
-		0x05, 0x25, 0x01, 0x01, 0x01,
-		0x09, 0x05, 0x86, 0x02, 0x40, 0x00, 0x00, 0x00, 0x00,
-		0x05, 0x25, 0x01, 0x01, 0x03,
-	},
+// The fixed descriptors of the CDC serial port alone and combined with a HID,
+// MIDI or vendor-specific interface were replaced by DescriptorComposite. The
+// old names refer to it, so that code which configures them still configures
+// the descriptor of the device.
+//
+// Deprecated: use DescriptorComposite.
+var (
+	DescriptorCDC       = &DescriptorComposite
+	DescriptorCDCHID    = &DescriptorComposite
+	DescriptorCDCMIDI   = &DescriptorComposite
+	DescriptorCDCVendor = &DescriptorComposite
+)
+
+// HIDReportDescriptor describes the reports of the HID interface: a keyboard
+// (report 2) and a mouse (report 1).
+var HIDReportDescriptor = []byte{
+	0x05, 0x01, 0x09, 0x06, 0xa1, 0x01, 0x85, 0x02, 0x05, 0x07, 0x19, 0xe0, 0x29, 0xe7, 0x15, 0x00,
+	0x25, 0x01, 0x75, 0x01, 0x95, 0x08, 0x81, 0x02, 0x95, 0x01, 0x75, 0x08, 0x81, 0x03, 0x95, 0x06,
+	0x75, 0x08, 0x15, 0x00, 0x25, 0x73, 0x05, 0x07, 0x19, 0x00, 0x29, 0x73, 0x81, 0x00, 0xc0, 0x05,
+	0x01, 0x09, 0x02, 0xa1, 0x01, 0x09, 0x01, 0xa1, 0x00, 0x85, 0x01, 0x05, 0x09, 0x19, 0x01, 0x29,
+	0x03, 0x15, 0x00, 0x25, 0x01, 0x95, 0x03, 0x75, 0x01, 0x81, 0x02, 0x95, 0x01, 0x75, 0x05, 0x81,
+	0x03, 0x05, 0x01, 0x09, 0x30, 0x09, 0x31, 0x09, 0x38, 0x15, 0x81, 0x25, 0x7f, 0x75, 0x08, 0x95,
+	0x03, 0x81, 0x06, 0xc0, 0xc0,
+}
+
+// VendorInterface is the number of the vendor-specific interface, once
+// ConfigureVendor has added it.
+var VendorInterface uint8
+
+// ConfigureVendor adds the vendor-specific interface to DescriptorComposite,
+// with the class codes and name reported for it and the transfer type
+// (ENDPOINT_TYPE_BULK or ENDPOINT_TYPE_INTERRUPT) of its endpoints. An empty
+// name leaves the interface unnamed.
+func ConfigureVendor(class, subClass, protocol, epType uint8, name string) {
+	iName := uint8(0)
+	if name != "" {
+		iName = IVENDOR
+		DescriptorComposite.Strings[IVENDOR] = name
+	}
+
+	// Interrupt endpoints are polled every frame, bulk endpoints ignore
+	// bInterval.
+	interval := uint8(0)
+	if epType == ENDPOINT_TYPE_INTERRUPT {
+		interval = 1
+	}
+	VendorInterface = AddFunction(Function{
+		Interfaces: 1,
+		Descriptor: func(b []byte, first uint8) []byte {
+			b = AppendInterface(b, first, 2, class, subClass, protocol, iName)
+			b = AppendEndpoint(b, VENDOR_ENDPOINT_OUT|EndpointOut, epType, EndpointPacketSize, interval)
+			return AppendEndpoint(b, VENDOR_ENDPOINT_IN|EndpointIn, epType, EndpointPacketSize, interval)
+		},
+	})
 }
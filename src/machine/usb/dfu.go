@@ -0,0 +1,32 @@
+package usb
+
+const (
+	// Class requests of the DFU (Device Firmware Upgrade) runtime interface.
+	DFU_DETACH    = 0x00
+	DFU_GETSTATUS = 0x03
+	DFU_GETSTATE  = 0x05
+
+	// DFU_STATE_APP_IDLE is the state of a device that runs its application.
+	DFU_STATE_APP_IDLE = 0x00
+
+	dfuFunctionalDescriptorType = 0x21
+	dfuSubClass                 = 0x01
+	dfuProtocolRuntime          = 0x01
+	dfuWillDetach               = 0x08 // the device detaches itself on DFU_DETACH
+)
+
+// DFURuntimeFunction is the runtime interface of USB DFU, without endpoints,
+// which lets a host tell the device to reset into its bootloader with a
+// DFU_DETACH request.
+var DFURuntimeFunction = Function{
+	Interfaces: 1,
+	Descriptor: func(b []byte, first uint8) []byte {
+		b = AppendInterface(b, first, 0, DEVICE_CLASS_APPLICATION, dfuSubClass, dfuProtocolRuntime, 0)
+		return append(b,
+			0x09, dfuFunctionalDescriptorType, dfuWillDetach,
+			0xe8, 0x03, // wDetachTimeOut: 1000ms
+			EndpointPacketSize, 0x00, // wTransferSize
+			0x10, 0x01, // DFU 1.1
+		)
+	},
+}
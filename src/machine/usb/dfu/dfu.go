@@ -0,0 +1,45 @@
+// Package dfu adds the runtime interface of USB DFU (Device Firmware Upgrade)
+// to the device, so that dfu-util can reset it into its bootloader without the
+// 1200 baud touch of the CDC serial port:
+//
+//	func init() {
+//		dfu.Enable()
+//	}
+//
+// and on the host:
+//
+//	dfu-util --detach
+//
+// The bootloaders of the supported chips (UF2, BOSSA or the boot ROM) don't
+// implement DFU themselves, so the firmware is then flashed as usual.
+package dfu
+
+import (
+	"machine"
+	"machine/usb"
+)
+
+// Enable adds the DFU runtime interface. This function must be executed from
+// the init(), before the host enumerates the device.
+func Enable() {
+	machine.EnableDFURuntime(setupHandler)
+}
+
+func setupHandler(setup usb.Setup) bool {
+	switch setup.BRequest {
+	case usb.DFU_DETACH:
+		// Acknowledge the request first: the reset then detaches the device,
+		// as the interface descriptor announces.
+		machine.SendZlp()
+		machine.EnterBootloader()
+		return true
+	case usb.DFU_GETSTATUS:
+		// Status OK, no poll timeout, no status string.
+		machine.SendUSBInPacket(0, []byte{0x00, 0x00, 0x00, 0x00, usb.DFU_STATE_APP_IDLE, 0x00})
+		return true
+	case usb.DFU_GETSTATE:
+		machine.SendUSBInPacket(0, []byte{usb.DFU_STATE_APP_IDLE})
+		return true
+	}
+	return false
+}
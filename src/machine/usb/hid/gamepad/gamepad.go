@@ -0,0 +1,269 @@
+// Package gamepad makes the board a HID game controller with buttons, axes
+// and hat switches, next to the keyboard and mouse:
+//
+//	func init() {
+//		gamepad.Configure(gamepad.Config{Buttons: 8, Axes: 2})
+//	}
+//
+//	func main() {
+//		gp := gamepad.New()
+//		gp.SetButton(0, true)
+//		gp.SetAxis(gamepad.X, -32767)
+//		gp.SendReport()
+//	}
+package gamepad
+
+import (
+	"machine"
+	"machine/usb"
+	"machine/usb/hid"
+	"runtime/interrupt"
+)
+
+// The largest gamepad, with a report of 1+4+2+16 bytes.
+const (
+	MaxButtons = 32
+	MaxHats    = 4
+	MaxAxes    = 8
+
+	reportID      = 0x03 // after the mouse (1) and the keyboard (2)
+	maxReportSize = 1 + MaxButtons/8 + MaxHats/2 + MaxAxes*2
+)
+
+// Axis is an axis of the gamepad, in the order in which they are added to the
+// report: a gamepad with 2 axes has X and Y.
+type Axis uint8
+
+const (
+	X Axis = iota
+	Y
+	Z
+	Rx
+	Ry
+	Rz
+	Slider
+	Dial
+)
+
+// Hat is the direction of a hat switch, clockwise from Up.
+type Hat uint8
+
+const (
+	Up Hat = iota
+	UpRight
+	Right
+	DownRight
+	Down
+	DownLeft
+	Left
+	UpLeft
+	Centered // out of the range of the report: no direction
+)
+
+// Config is the layout of the report of the gamepad. The zero value is a
+// gamepad with 16 buttons, 6 axes and a hat switch. Larger numbers are limited
+// to MaxButtons, MaxAxes and MaxHats.
+type Config struct {
+	Buttons uint8
+	Axes    uint8
+	Hats    uint8
+}
+
+// Gamepad is the HID gamepad. Its state is sent to the host by SendReport.
+type Gamepad struct {
+	config Config
+
+	// The report of the state, and the last one that SendReport queued.
+	report [maxReportSize]byte
+	queued [maxReportSize]byte
+	size   int
+
+	hats, axes int // offsets in the report
+	pending    bool
+	waitTxc    bool
+}
+
+var gamepad *Gamepad
+
+// Configure adds the gamepad to the report descriptor of the HID interface
+// and returns it. It must be called from an init(), as the host reads the
+// report descriptor when it enumerates the device. Later calls return the
+// gamepad of the first one.
+func Configure(config Config) *Gamepad {
+	if gamepad != nil {
+		return gamepad
+	}
+	if config == (Config{}) {
+		config = Config{Buttons: 16, Axes: 6, Hats: 1}
+	}
+	if config.Buttons > MaxButtons {
+		config.Buttons = MaxButtons
+	}
+	if config.Axes > MaxAxes {
+		config.Axes = MaxAxes
+	}
+	if config.Hats > MaxHats {
+		config.Hats = MaxHats
+	}
+	g := &Gamepad{config: config}
+	g.hats = 1 + (int(config.Buttons)+7)/8
+	g.axes = g.hats + (int(config.Hats)+1)/2
+	g.size = g.axes + 2*int(config.Axes)
+	g.report[0] = reportID
+	for i := 0; i < int(config.Hats); i++ {
+		g.SetHat(i, Centered)
+	}
+	gamepad = g
+
+	usb.AppendHIDReport(reportDescriptor(config))
+	hid.SetHandler(g)
+	return g
+}
+
+// New returns the gamepad that Configure added, or nil if there is none.
+func New() *Gamepad {
+	return gamepad
+}
+
+// reportDescriptor returns the top-level collection of a gamepad with the
+// layout of config: the buttons, the hat switches of 4 bits, and the axes of
+// 16 bits, each padded to a byte.
+func reportDescriptor(config Config) []byte {
+	b := []byte{
+		0x05, 0x01, // usage page (generic desktop)
+		0x09, 0x05, // usage (game pad)
+		0xa1, 0x01, // collection (application)
+		0x85, reportID,
+	}
+	if n := config.Buttons; n > 0 {
+		b = append(b,
+			0x05, 0x09, // usage page (button)
+			0x19, 0x01, // usage minimum (1)
+			0x29, n, // usage maximum (n)
+			0x15, 0x00, // logical minimum (0)
+			0x25, 0x01, // logical maximum (1)
+			0x75, 0x01, // report size (1)
+			0x95, n, // report count (n)
+			0x81, 0x02, // input (data, variable, absolute)
+		)
+		if n%8 != 0 {
+			b = append(b, 0x75, 0x01, 0x95, 8-n%8, 0x81, 0x03) // padding
+		}
+	}
+	if n := config.Hats; n > 0 {
+		b = append(b, 0x05, 0x01) // usage page (generic desktop)
+		for i := uint8(0); i < n; i++ {
+			b = append(b, 0x09, 0x39) // usage (hat switch)
+		}
+		b = append(b,
+			0x15, 0x00, // logical minimum (0)
+			0x25, 0x07, // logical maximum (7)
+			0x35, 0x00, // physical minimum (0)
+			0x46, 0x3b, 0x01, // physical maximum (315)
+			0x65, 0x14, // unit (degrees)
+			0x75, 0x04, // report size (4)
+			0x95, n, // report count (n)
+			0x81, 0x42, // input (data, variable, absolute, null state)
+			0x65, 0x00, // unit (none)
+			0x45, 0x00, // physical maximum (0)
+		)
+		if n%2 != 0 {
+			b = append(b, 0x75, 0x04, 0x95, 0x01, 0x81, 0x03) // padding
+		}
+	}
+	if n := config.Axes; n > 0 {
+		b = append(b, 0x05, 0x01) // usage page (generic desktop)
+		for i := uint8(0); i < n; i++ {
+			b = append(b, 0x09, 0x30+i) // usage (X, Y, Z, Rx, Ry, Rz, slider, dial)
+		}
+		b = append(b,
+			0x16, 0x01, 0x80, // logical minimum (-32767)
+			0x26, 0xff, 0x7f, // logical maximum (32767)
+			0x75, 0x10, // report size (16)
+			0x95, n, // report count (n)
+			0x81, 0x02, // input (data, variable, absolute)
+		)
+	}
+	return append(b, 0xc0) // end collection
+}
+
+// Handler sends the report that SendReport queued while the previous one was
+// being sent.
+func (g *Gamepad) Handler() bool {
+	g.waitTxc = false
+	if g.pending {
+		g.pending = false
+		g.waitTxc = true
+		hid.SendUSBPacket(g.queued[:g.size])
+		return true
+	}
+	return false
+}
+
+// SetButton presses or releases a button. Button 0 is the one that the host
+// calls button 1.
+func (g *Gamepad) SetButton(button int, pressed bool) {
+	if button < 0 || button >= int(g.config.Buttons) {
+		return
+	}
+	mask := byte(1) << (button % 8)
+	if pressed {
+		g.report[1+button/8] |= mask
+	} else {
+		g.report[1+button/8] &^= mask
+	}
+}
+
+// Button returns whether a button is pressed in the state of the gamepad.
+func (g *Gamepad) Button(button int) bool {
+	if button < 0 || button >= int(g.config.Buttons) {
+		return false
+	}
+	return g.report[1+button/8]&(1<<(button%8)) != 0
+}
+
+// SetAxis sets the position of an axis, from -32767 to 32767 with 0 in the
+// center.
+func (g *Gamepad) SetAxis(axis Axis, value int16) {
+	if int(axis) >= int(g.config.Axes) {
+		return
+	}
+	if value < -32767 {
+		value = -32767
+	}
+	i := g.axes + 2*int(axis)
+	g.report[i] = byte(value)
+	g.report[i+1] = byte(uint16(value) >> 8)
+}
+
+// SetHat sets the direction of a hat switch, or Centered.
+func (g *Gamepad) SetHat(hat int, direction Hat) {
+	if hat < 0 || hat >= int(g.config.Hats) {
+		return
+	}
+	if direction > Centered {
+		direction = Centered
+	}
+	i := g.hats + hat/2
+	shift := 4 * (hat % 2)
+	g.report[i] = g.report[i]&^(0x0f<<shift) | byte(direction)<<shift
+}
+
+// SendReport sends the state of the gamepad to the host. A report that can't
+// be sent yet, because the previous one is still on its way, replaces the one
+// that is waiting, as the host only needs the last state.
+func (g *Gamepad) SendReport() error {
+	if !machine.USBConfigured() {
+		return hid.ErrHIDReportTransfer
+	}
+	mask := interrupt.Disable()
+	g.queued = g.report
+	if g.waitTxc {
+		g.pending = true
+	} else {
+		g.waitTxc = true
+		hid.SendUSBPacket(g.queued[:g.size])
+	}
+	interrupt.Restore(mask)
+	return nil
+}
@@ -1,3 +1,16 @@
+// Package hid implements the USB HID interface that the keyboard, mouse and
+// gamepad packages share. It is enabled by importing the keyboard or mouse, or
+// by configuring the gamepad, which adds the HID interface and its interrupt
+// IN endpoint to the USB descriptor, next to the CDC serial port:
+//
+//	import "machine/usb/hid/keyboard"
+//
+//	kb := keyboard.New()
+//	kb.Write([]byte("hello"))
+//
+// This works on every chip with a USB device driver in machine: the nRF52840,
+// the SAMD21 and SAMD51, and the RP2040. It lives outside of machine because
+// it is built on top of machine.EnableHID.
 package hid
 
 import (
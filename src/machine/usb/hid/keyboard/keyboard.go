@@ -1,3 +1,5 @@
+// Package keyboard sends key presses to the USB host as a HID keyboard, with
+// the keyboard layouts of several countries and the media and system keys.
 package keyboard
 
 import (
@@ -1,3 +1,5 @@
+// Package mouse moves the cursor of the USB host and clicks its buttons as a
+// HID mouse.
 package mouse
 
 import (
@@ -17,3 +17,43 @@ func (m *midi) SendCC(cable, channel, control, value uint8) {
 	m.msg[0], m.msg[1], m.msg[2], m.msg[3] = (cable&0xf<<4)|0xB, 0xB0|(channel&0xf), control&0x7f, value&0x7f
 	m.Write(m.msg[:])
 }
+
+// ProgramChange sends a program change message.
+func (m *midi) ProgramChange(cable, channel, program uint8) {
+	m.WritePacket(NewPacket(cable, 0xC0|(channel&0xf), program, 0))
+}
+
+// PitchBend sends a pitch bend message, with bend from -8192 to 8191 and 0 in
+// the middle.
+func (m *midi) PitchBend(cable, channel uint8, bend int16) {
+	v := uint16(bend+8192) & 0x3fff
+	m.WritePacket(NewPacket(cable, 0xE0|(channel&0xf), uint8(v), uint8(v>>7)))
+}
+
+// SendSysEx sends a system exclusive message, whose data goes between the
+// 0xF0 and 0xF7 bytes that SendSysEx adds.
+func (m *midi) SendSysEx(cable uint8, data []byte) {
+	cable = cable & 0xf << 4
+	p := Packet{1: 0xF0}
+	n := 2
+	for _, b := range data {
+		if n == 4 {
+			p[0] = cable | CodeSysExStart
+			m.WritePacket(p)
+			n = 1
+		}
+		p[n] = b & 0x7f
+		n++
+	}
+	if n == 4 {
+		p[0] = cable | CodeSysExStart
+		m.WritePacket(p)
+		n = 1
+	}
+	p[n] = 0xF7
+	for i := n + 1; i < 4; i++ {
+		p[i] = 0
+	}
+	p[0] = cable | (CodeSysExEnd1 + uint8(n-1))
+	m.WritePacket(p)
+}
@@ -1,7 +1,21 @@
+// Package midi is a USB MIDI device with one input and one output port, such
+// as a MIDI controller. It sends and receives the 4 byte USB MIDI event
+// packets, each with a cable number and up to three bytes of a MIDI message:
+//
+//	m := midi.New()
+//	m.NoteOn(0, 0, midi.C4, 100)
+//	for {
+//		if p, ok := m.ReadPacket(); ok && p.CodeIndex() == midi.CodeControlChange {
+//			msg := p.Message()
+//			println("control", msg[1], "=", msg[2])
+//		}
+//		time.Sleep(time.Millisecond)
+//	}
 package midi
 
 import (
 	"machine"
+	"runtime/interrupt"
 )
 
 const (
@@ -14,6 +28,7 @@ var Midi *midi
 type midi struct {
 	msg       [4]byte
 	buf       *RingBuffer
+	rx        *RingBuffer
 	rxHandler func([]byte)
 	waitTxc   bool
 }
@@ -24,7 +39,7 @@ func init() {
 	}
 }
 
-// New returns hid-mouse.
+// New returns the USB MIDI device.
 func New() *midi {
 	return Midi
 }
@@ -32,23 +47,46 @@ func New() *midi {
 func newMidi() *midi {
 	m := &midi{
 		buf: NewRingBuffer(),
+		rx:  NewRingBuffer(),
 	}
 	machine.EnableMIDI(m.Handler, m.RxHandler, nil)
 	return m
 }
 
+// SetHandler sets a function that is called in the USB interrupt with the
+// event packets received from the host, instead of queueing them for
+// ReadPacket. The slice holds one or more packets of 4 bytes.
 func (m *midi) SetHandler(rxHandler func([]byte)) {
 	m.rxHandler = rxHandler
 }
 
+// Write sends b, which holds whole event packets of 4 bytes. A trailing
+// partial packet isn't sent.
 func (m *midi) Write(b []byte) (n int, err error) {
 	i := 0
-	for i = 0; i < len(b); i += 4 {
+	for i = 0; i+4 <= len(b); i += 4 {
 		m.tx(b[i : i+4])
 	}
 	return i, nil
 }
 
+// WritePacket sends an event packet.
+func (m *midi) WritePacket(p Packet) {
+	m.tx(p[:])
+}
+
+// ReadPacket returns the next event packet received from the host, if there
+// is one. Packets are dropped when more than 128 are waiting.
+func (m *midi) ReadPacket() (p Packet, ok bool) {
+	mask := interrupt.Disable()
+	b, ok := m.rx.Get()
+	if ok {
+		copy(p[:], b)
+	}
+	interrupt.Restore(mask)
+	return p, ok
+}
+
 // sendUSBPacket sends a MIDIPacket.
 func (m *midi) sendUSBPacket(b []byte) {
 	machine.SendUSBInPacket(midiEndpointIn, b)
@@ -64,17 +102,27 @@ func (m *midi) Handler() {
 }
 
 func (m *midi) tx(b []byte) {
+	// The transfer complete interrupt changes waitTxc and reads the buffer.
+	mask := interrupt.Disable()
 	if m.waitTxc {
 		m.buf.Put(b)
 	} else {
 		m.waitTxc = true
 		m.sendUSBPacket(b)
 	}
+	interrupt.Restore(mask)
 }
 
 // from BulkOut
 func (m *midi) RxHandler(b []byte) {
 	if m.rxHandler != nil {
 		m.rxHandler(b)
+		return
+	}
+	for i := 0; i+4 <= len(b); i += 4 {
+		// Empty packets pad a transfer.
+		if b[i] != 0 {
+			m.rx.Put(b[i : i+4])
+		}
 	}
 }
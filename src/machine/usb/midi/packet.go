@@ -0,0 +1,53 @@
+package midi
+
+// Packet is a USB MIDI event packet: a header byte with the cable number and
+// the code index, which tells what kind of message follows, and up to three
+// bytes of a MIDI message.
+type Packet [4]byte
+
+// Code index numbers of USB MIDI event packets.
+const (
+	CodeSysExStart      = 0x4 // SysEx starts or continues, with 3 bytes
+	CodeSysExEnd1       = 0x5 // SysEx ends with 1 byte, or a 1 byte system common message
+	CodeSysExEnd2       = 0x6 // SysEx ends with 2 bytes
+	CodeSysExEnd3       = 0x7 // SysEx ends with 3 bytes
+	CodeNoteOff         = 0x8
+	CodeNoteOn          = 0x9
+	CodePolyPressure    = 0xA
+	CodeControlChange   = 0xB
+	CodeProgramChange   = 0xC
+	CodeChannelPressure = 0xD
+	CodePitchBend       = 0xE
+	CodeSingleByte      = 0xF
+)
+
+// NewPacket returns the packet of a channel voice message, such as a note on
+// with status 0x90, for cable.
+func NewPacket(cable, status, data1, data2 uint8) Packet {
+	return Packet{cable&0xf<<4 | status>>4, status, data1 & 0x7f, data2 & 0x7f}
+}
+
+// Cable returns the number of the virtual cable, or port, of the packet.
+func (p *Packet) Cable() uint8 {
+	return p[0] >> 4
+}
+
+// CodeIndex returns the code index number of the packet.
+func (p *Packet) CodeIndex() uint8 {
+	return p[0] & 0xf
+}
+
+// Message returns the bytes of the MIDI message in the packet, whose number
+// depends on the code index.
+func (p *Packet) Message() []byte {
+	switch p.CodeIndex() {
+	case CodeSysExEnd1, CodeSingleByte:
+		return p[1:2]
+	case 0x2, CodeSysExEnd2, CodeProgramChange, CodeChannelPressure:
+		return p[1:3]
+	case 0x0, 0x1:
+		// Reserved for future extensions.
+		return p[1:1]
+	}
+	return p[1:4]
+}
@@ -0,0 +1,549 @@
+//go:build !scheduler.none
+// +build !scheduler.none
+
+// Package msc implements the USB Mass Storage Class, so that a block device,
+// such as the QSPI flash of a board or an SD card, shows up as a drive on the
+// host:
+//
+//	msc.Configure(flash, msc.Config{Vendor: "TinyGo", Product: "Flash"})
+//
+// The drive uses the Bulk-Only Transport and the SCSI commands that hosts send
+// to a USB stick. The host formats the drive, so the program should not write
+// to the device while the host has it mounted.
+//
+// Commands and the data of writes are handled by a goroutine, as erasing and
+// writing flash takes far too long for the USB interrupt. The OUT endpoint
+// answers the host with NAK until the goroutine is done with a packet, which
+// holds off the host while the device is busy. The data of reads is read from
+// the block device in the USB interrupt when the previous packet was sent.
+// Devices that need to be erased before they are written, such as flash, are
+// erased an erase block at a time, with the erase block read back first.
+//
+// An invalid command halts both endpoints until the host resets the drive, as
+// the Bulk-Only Transport requires. The package needs a scheduler for its
+// goroutine, so it doesn't build with -scheduler=none.
+//
+// The drive uses the vendor-specific interface of the machine/usb/vendor
+// package, so it can't be used together with it or with USB MIDI. It can be
+// used with USB HID, for a composite device with a serial port, a keyboard and
+// a drive.
+package msc
+
+import (
+	"machine"
+	"machine/usb"
+	"runtime/interrupt"
+)
+
+// Config describes the drive to the host.
+type Config struct {
+	// Vendor and Product are the names in the SCSI INQUIRY response, of up
+	// to 8 and 16 characters.
+	Vendor  string
+	Product string
+
+	// ReadOnly makes the drive write protected.
+	ReadOnly bool
+}
+
+const (
+	mscClass    = 0x08
+	mscSubClass = 0x06 // SCSI transparent command set
+	mscProtocol = 0x50 // Bulk-Only Transport
+
+	sectorSize = 512
+
+	cbwSignature = 0x43425355
+	cswSignature = 0x53425355
+	cbwSize      = 31
+	cswSize      = 13
+
+	// Class requests
+	requestReset     = 0xff
+	requestGetMaxLUN = 0xfe
+
+	// SCSI commands
+	scsiTestUnitReady      = 0x00
+	scsiRequestSense       = 0x03
+	scsiInquiry            = 0x12
+	scsiModeSense6         = 0x1a
+	scsiStartStopUnit      = 0x1b
+	scsiPreventAllow       = 0x1e
+	scsiReadFormatCapacity = 0x23
+	scsiReadCapacity10     = 0x25
+	scsiRead10             = 0x28
+	scsiWrite10            = 0x2a
+	scsiVerify10           = 0x2f
+	scsiSyncCache10        = 0x35
+	scsiModeSense10        = 0x5a
+
+	// Status of a command in the CSW
+	statusPassed     = 0
+	statusFailed     = 1
+	statusPhaseError = 2
+)
+
+// Sense keys with their additional sense codes.
+var (
+	senseInvalidCommand = [3]byte{0x05, 0x20, 0x00}
+	senseOutOfRange     = [3]byte{0x05, 0x21, 0x00}
+	senseWriteProtected = [3]byte{0x07, 0x27, 0x00}
+	senseWriteError     = [3]byte{0x03, 0x0c, 0x00}
+	senseReadError      = [3]byte{0x03, 0x11, 0x00}
+)
+
+// States of the Bulk-Only Transport.
+const (
+	stateCommand = iota // waiting for a CBW
+	stateDataIn         // sending data
+	stateDataOut        // receiving data
+	stateStatus         // sending the CSW
+	stateHalted         // endpoints halted after an invalid CBW, until a reset
+)
+
+var Port *MSC
+
+// MSC is a USB mass storage drive.
+type MSC struct {
+	dev     machine.BlockDevice
+	config  Config
+	sectors uint32
+
+	// Packet received in the USB interrupt, for the goroutine. The OUT
+	// endpoint is held while received is set.
+	work     machine.Event
+	rx       [usb.EndpointPacketSize]byte
+	rxLen    int
+	received bool
+	reset    bool // flush the erase block after a class reset
+
+	state   uint8
+	tag     uint32
+	residue uint32 // bytes of the data phase that are left
+	status  uint8
+	sense   [3]byte
+
+	// Current READ(10) or WRITE(10).
+	lba     uint32
+	count   uint32 // sectors left
+	discard bool   // drop the data received, after an error
+	pending []byte // data to send
+	padding uint32 // zeros to send after pending
+	offset  int    // bytes of sector received
+
+	sector [sectorSize]byte
+	resp   [36]byte
+	zeros  [usb.EndpointPacketSize]byte
+
+	// Erase block being written, for devices that must be erased first.
+	block   []byte
+	cached  int64
+	dirty   bool
+	erasure int64 // bytes erased at once
+}
+
+// Configure enables the drive, backed by dev. This function must be executed
+// from the init(), before the host enumerates the device.
+func Configure(dev machine.BlockDevice, config Config) *MSC {
+	if Port != nil {
+		return Port
+	}
+	m := &MSC{
+		dev:     dev,
+		config:  config,
+		sectors: uint32(dev.Size() / sectorSize),
+		cached:  -1,
+		erasure: dev.EraseBlockSize(),
+	}
+	size := m.erasure
+	if size < sectorSize {
+		size = sectorSize
+	}
+	m.block = make([]byte, size)
+	Port = m
+	go m.run()
+	usb.ConfigureVendor(mscClass, mscSubClass, mscProtocol, usb.ENDPOINT_TYPE_BULK, "")
+	machine.EnableVendor(usb.ENDPOINT_TYPE_BULK, m.txHandler, m.rxHandler, m.setupHandler)
+	return m
+}
+
+// rxHandler is called from the USB interrupt for every bulk OUT packet. It
+// passes the packet to run, and holds the endpoint until run is done with it.
+func (m *MSC) rxHandler(b []byte) {
+	if m.state == stateHalted || m.state == stateDataIn {
+		return
+	}
+	m.rxLen = copy(m.rx[:], b)
+	m.received = true
+	machine.HoldUSBRx(usb.VENDOR_ENDPOINT_OUT)
+	m.work.Signal()
+}
+
+// run handles the packets received by rxHandler, and the flush after a class
+// reset, outside of the USB interrupt.
+func (m *MSC) run() {
+	for {
+		m.work.Wait()
+		mask := interrupt.Disable()
+		received, reset := m.received, m.reset
+		m.reset = false
+		interrupt.Restore(mask)
+
+		if reset {
+			m.flush()
+		}
+		if !received {
+			continue
+		}
+		// A CBW can arrive before the interrupt for the CSW of the previous
+		// command is handled, which it has been by the time run gets here.
+		switch m.state {
+		case stateCommand, stateStatus:
+			m.command(m.rx[:m.rxLen])
+		case stateDataOut:
+			m.dataOut(m.rx[:m.rxLen])
+		}
+		m.received = false
+		machine.ReleaseUSBRx(usb.VENDOR_ENDPOINT_OUT)
+	}
+}
+
+// txHandler is called from the USB interrupt when a bulk IN packet was sent.
+func (m *MSC) txHandler() {
+	switch m.state {
+	case stateDataIn:
+		m.sendNext()
+	case stateStatus:
+		m.state = stateCommand
+	}
+}
+
+func (m *MSC) setupHandler(setup usb.Setup) bool {
+	if setup.BmRequestType&usb.REQUEST_TYPE != usb.REQUEST_CLASS {
+		return false
+	}
+	switch setup.BRequest {
+	case requestGetMaxLUN:
+		machine.SendUSBInPacket(0, []byte{0})
+		return true
+	case requestReset:
+		// The data of an interrupted write is written by run. The host
+		// resumes the halted endpoints with CLEAR_FEATURE after the reset.
+		m.state = stateCommand
+		m.reset = true
+		m.work.Signal()
+		machine.SendZlp()
+		return true
+	}
+	return false
+}
+
+// command handles a CBW.
+func (m *MSC) command(b []byte) {
+	if len(b) != cbwSize || le32(b) != cbwSignature {
+		m.state = stateHalted
+		machine.SetUSBEndpointHalt(usb.VENDOR_ENDPOINT_IN|usb.EndpointIn, true)
+		machine.SetUSBEndpointHalt(usb.VENDOR_ENDPOINT_OUT, true)
+		return
+	}
+	m.tag = le32(b[4:])
+	m.residue = le32(b[8:])
+	in := b[12]&0x80 != 0
+	cb := b[15:]
+	m.status = statusPassed
+	m.pending = nil
+	m.padding = 0
+	m.count = 0
+
+	switch cb[0] {
+	case scsiTestUnitReady, scsiStartStopUnit, scsiPreventAllow, scsiVerify10:
+		m.sendStatus()
+	case scsiSyncCache10:
+		if m.flush() != nil {
+			m.fail(senseWriteError)
+		}
+		m.sendStatus()
+	case scsiRequestSense:
+		r := m.resp[:18]
+		zero(r)
+		r[0] = 0x70 // current error
+		r[2] = m.sense[0]
+		r[7] = 10 // additional length
+		r[12] = m.sense[1]
+		r[13] = m.sense[2]
+		m.sense = [3]byte{}
+		m.sendData(r)
+	case scsiInquiry:
+		r := m.resp[:36]
+		zero(r)
+		r[1] = 0x80 // removable
+		r[2] = 0x04 // SPC-2
+		r[3] = 0x02 // response data format
+		r[4] = byte(len(r) - 5)
+		pad(r[8:16], m.config.Vendor)
+		pad(r[16:32], m.config.Product)
+		pad(r[32:36], "1.0")
+		m.sendData(r)
+	case scsiModeSense6, scsiModeSense10:
+		wp := byte(0)
+		if m.config.ReadOnly {
+			wp = 0x80
+		}
+		if cb[0] == scsiModeSense6 {
+			m.resp[0], m.resp[1], m.resp[2], m.resp[3] = 3, 0, wp, 0
+			m.sendData(m.resp[:4])
+		} else {
+			r := m.resp[:8]
+			zero(r)
+			r[1], r[3] = 6, wp
+			m.sendData(r)
+		}
+	case scsiReadFormatCapacity:
+		r := m.resp[:12]
+		zero(r)
+		r[3] = 8 // capacity list length
+		putBE32(r[4:], m.sectors)
+		putBE32(r[8:], 0x02000000|sectorSize) // formatted media
+		m.sendData(r)
+	case scsiReadCapacity10:
+		putBE32(m.resp[0:], m.sectors-1)
+		putBE32(m.resp[4:], sectorSize)
+		m.sendData(m.resp[:8])
+	case scsiRead10:
+		m.lba = be32(cb[2:])
+		m.count = uint32(cb[7])<<8 | uint32(cb[8])
+		if uint64(m.lba)+uint64(m.count) > uint64(m.sectors) {
+			m.count = 0
+			m.fail(senseOutOfRange)
+			m.padding = m.residue
+		}
+		m.state = stateDataIn
+		m.sendNext()
+	case scsiWrite10:
+		m.lba = be32(cb[2:])
+		m.count = uint32(cb[7])<<8 | uint32(cb[8])
+		m.offset = 0
+		m.discard = false
+		if m.config.ReadOnly {
+			m.fail(senseWriteProtected)
+			m.discard = true
+		} else if uint64(m.lba)+uint64(m.count) > uint64(m.sectors) {
+			m.fail(senseOutOfRange)
+			m.discard = true
+		}
+		m.receive()
+	default:
+		// The data phase still has to happen: send zeros or drop the data.
+		m.fail(senseInvalidCommand)
+		if in {
+			m.padding = m.residue
+			m.state = stateDataIn
+			m.sendNext()
+		} else {
+			m.discard = true
+			m.receive()
+		}
+	}
+}
+
+// fail sets the sense data of a failed command.
+func (m *MSC) fail(sense [3]byte) {
+	m.status = statusFailed
+	m.sense = sense
+}
+
+// sendData sends a response, cut to the length the host asked for.
+func (m *MSC) sendData(b []byte) {
+	if uint32(len(b)) > m.residue {
+		b = b[:m.residue]
+	}
+	m.pending = b
+	m.state = stateDataIn
+	m.sendNext()
+}
+
+// sendNext sends the next packet of the data phase, or the CSW at its end.
+// The state is updated before the packet is sent, as the USB interrupt calls
+// sendNext again once it was sent.
+func (m *MSC) sendNext() {
+	if len(m.pending) == 0 && m.count > 0 {
+		if _, err := m.dev.ReadAt(m.sector[:], int64(m.lba)*sectorSize); err != nil {
+			m.count = 0
+			m.fail(senseReadError)
+			m.padding = m.residue
+		} else {
+			m.pending = m.sector[:]
+			m.lba++
+			m.count--
+		}
+	}
+	if len(m.pending) > 0 && m.residue == 0 {
+		// The host expects less data than the command reads.
+		m.pending = nil
+		m.count = 0
+		m.status = statusPhaseError
+	}
+	if len(m.pending) > 0 {
+		n := uint32(len(m.pending))
+		if n > usb.EndpointPacketSize {
+			n = usb.EndpointPacketSize
+		}
+		if n > m.residue {
+			n = m.residue
+		}
+		b := m.pending[:n]
+		m.pending = m.pending[n:]
+		m.residue -= n
+		machine.SendUSBInPacket(usb.VENDOR_ENDPOINT_IN, b)
+		return
+	}
+	if m.padding > 0 {
+		n := m.padding
+		if n > usb.EndpointPacketSize {
+			n = usb.EndpointPacketSize
+		}
+		if n > m.residue {
+			n = m.residue
+		}
+		m.padding -= n
+		m.residue -= n
+		machine.SendUSBInPacket(usb.VENDOR_ENDPOINT_IN, m.zeros[:n])
+		return
+	}
+	m.sendStatus()
+}
+
+// receive starts the data phase of a command that receives data.
+func (m *MSC) receive() {
+	if m.residue == 0 {
+		m.sendStatus()
+		return
+	}
+	m.state = stateDataOut
+}
+
+// dataOut handles a data packet of a write.
+func (m *MSC) dataOut(b []byte) {
+	if uint32(len(b)) > m.residue {
+		b = b[:m.residue]
+	}
+	m.residue -= uint32(len(b))
+	if !m.discard {
+		for len(b) > 0 && m.count > 0 {
+			n := copy(m.sector[m.offset:], b)
+			b = b[n:]
+			m.offset += n
+			if m.offset < sectorSize {
+				break
+			}
+			m.offset = 0
+			if m.write(m.lba) != nil {
+				m.fail(senseWriteError)
+				m.discard = true
+				break
+			}
+			m.lba++
+			m.count--
+		}
+	}
+	if m.residue == 0 || (!m.discard && m.count == 0) {
+		if m.flush() != nil {
+			m.fail(senseWriteError)
+		}
+		if m.residue != 0 {
+			// More data than sectors: drop the rest.
+			m.discard = true
+			return
+		}
+		if !m.discard && m.count > 0 {
+			// The host sends less data than the command writes.
+			m.status = statusPhaseError
+		}
+		m.sendStatus()
+	}
+}
+
+// write stores the sector buffer at lba, in the cached erase block.
+func (m *MSC) write(lba uint32) error {
+	offset := int64(lba) * sectorSize
+	size := int64(len(m.block))
+	block := offset / size
+	if block != m.cached {
+		if err := m.flush(); err != nil {
+			return err
+		}
+		if _, err := m.dev.ReadAt(m.block, block*size); err != nil {
+			m.cached = -1
+			return err
+		}
+		m.cached = block
+	}
+	copy(m.block[offset-block*size:], m.sector[:])
+	m.dirty = true
+	return nil
+}
+
+// flush erases and writes the cached erase block, if it was changed.
+func (m *MSC) flush() error {
+	if !m.dirty {
+		return nil
+	}
+	m.dirty = false
+	size := int64(len(m.block))
+	if m.erasure > 0 {
+		if err := m.dev.EraseBlocks(m.cached*size/m.erasure, size/m.erasure); err != nil {
+			return err
+		}
+	}
+	if _, err := m.dev.WriteAt(m.block, m.cached*size); err != nil {
+		return err
+	}
+	return machine.SyncBlockDevice(m.dev)
+}
+
+// sendStatus sends the CSW of the command.
+func (m *MSC) sendStatus() {
+	var csw [cswSize]byte
+	putLE32(csw[0:], cswSignature)
+	putLE32(csw[4:], m.tag)
+	putLE32(csw[8:], m.residue)
+	csw[12] = m.status
+	m.state = stateStatus
+	machine.SendUSBInPacket(usb.VENDOR_ENDPOINT_IN, csw[:])
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// pad copies s into b, padded with spaces.
+func pad(b []byte, s string) {
+	n := copy(b, s)
+	for i := n; i < len(b); i++ {
+		b[i] = ' '
+	}
+}
+
+func le32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+func putLE32(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+}
+
+func be32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func putBE32(b []byte, v uint32) {
+	b[0] = byte(v >> 24)
+	b[1] = byte(v >> 16)
+	b[2] = byte(v >> 8)
+	b[3] = byte(v)
+}
@@ -0,0 +1,11 @@
+//go:build !usb.packet16 && !usb.packet32
+// +build !usb.packet16,!usb.packet32
+
+package usb
+
+// EndpointPacketSize is the size of the packets of every endpoint, and of the
+// packet buffer that the machine package reserves in RAM for each endpoint in
+// each direction. It is 64, the largest packet of a Full Speed bulk endpoint,
+// by default. Building with the usb.packet32 or usb.packet16 tag makes the
+// packets smaller, which saves RAM but makes the transfers slower.
+const EndpointPacketSize = 64
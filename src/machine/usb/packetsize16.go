@@ -0,0 +1,8 @@
+//go:build usb.packet16 && !usb.packet32
+// +build usb.packet16,!usb.packet32
+
+package usb
+
+// EndpointPacketSize is the size of the packets of every endpoint. The
+// usb.packet16 tag makes it 16 bytes.
+const EndpointPacketSize = 16
@@ -0,0 +1,8 @@
+//go:build usb.packet32
+// +build usb.packet32
+
+package usb
+
+// EndpointPacketSize is the size of the packets of every endpoint. The
+// usb.packet32 tag makes it 32 bytes.
+const EndpointPacketSize = 32
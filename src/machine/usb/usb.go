@@ -5,16 +5,23 @@ var (
 	STRING_LANGUAGE = [2]uint16{(3 << 8) | (2 + 2), 0x0409} // English
 )
 
+// The configuration descriptor used to be picked from a fixed set with these
+// flags.
+//
+// Deprecated: DescriptorComposite holds the functions that were added with
+// AddFunction, so there is nothing to pick anymore. The flags have no effect.
 const (
 	DescriptorConfigCDC = 1 << iota
 	DescriptorConfigHID
 	DescriptorConfigMIDI
+	DescriptorConfigVendor
 )
 
 const (
 	IMANUFACTURER = 1
 	IPRODUCT      = 2
 	ISERIAL       = 3
+	IVENDOR       = 4
 
 	ENDPOINT_TYPE_DISABLE     = 0xFF
 	ENDPOINT_TYPE_CONTROL     = 0x00
@@ -29,14 +36,14 @@ const (
 	ENDPOINT_DESCRIPTOR_TYPE      = 5
 	DEVICE_QUALIFIER              = 6
 	OTHER_SPEED_CONFIGURATION     = 7
+	BOS_DESCRIPTOR_TYPE           = 15
 	SET_REPORT_TYPE               = 33
 	HID_REPORT_TYPE               = 34
 
 	EndpointOut = 0x00
 	EndpointIn  = 0x80
 
-	NumberOfEndpoints  = 8
-	EndpointPacketSize = 64 // 64 for Full Speed, EPT size max is 1024
+	NumberOfEndpoints = 8
 
 	// standard requests
 	GET_STATUS        = 0
@@ -56,6 +63,7 @@ const (
 	DEVICE_CLASS_COMMUNICATIONS  = 0x02
 	DEVICE_CLASS_HUMAN_INTERFACE = 0x03
 	DEVICE_CLASS_STORAGE         = 0x08
+	DEVICE_CLASS_APPLICATION     = 0xFE
 	DEVICE_CLASS_VENDOR_SPECIFIC = 0xFF
 
 	CONFIG_POWERED_MASK  = 0x40
@@ -63,12 +71,18 @@ const (
 	CONFIG_SELF_POWERED  = 0xC0
 	CONFIG_REMOTE_WAKEUP = 0x20
 
-	// Interface
-	NumberOfInterfaces = 3
+	// Interface. The interfaces after the CDC ones are numbered as the
+	// functions are added with AddFunction.
+	NumberOfInterfaces = 8
 	CDC_ACM_INTERFACE  = 0 // CDC ACM
 	CDC_DATA_INTERFACE = 1 // CDC Data
 	CDC_FIRST_ENDPOINT = 1
-	HID_INTERFACE      = 2 // HID
+
+	// Deprecated: HID_INTERFACE and VENDOR_INTERFACE are only right when the
+	// interface is the first function added after CDC. Use the number that
+	// AddHID returns, or VendorInterface.
+	HID_INTERFACE    = 2 // HID
+	VENDOR_INTERFACE = 2 // Vendor specific
 
 	// Endpoint
 	CONTROL_ENDPOINT  = 0
@@ -79,6 +93,15 @@ const (
 	MIDI_ENDPOINT_OUT = 5
 	MIDI_ENDPOINT_IN  = 6
 
+	// The vendor-specific interface shares its endpoints with MIDI, so they
+	// cannot be enabled at the same time.
+	VENDOR_ENDPOINT_OUT = 5
+	VENDOR_ENDPOINT_IN  = 6
+
+	// The audio interface shares its endpoint with MIDI and the
+	// vendor-specific interface too.
+	AUDIO_ENDPOINT_IN = 6
+
 	// bmRequestType
 	REQUEST_HOSTTODEVICE = 0x00
 	REQUEST_DEVICETOHOST = 0x80
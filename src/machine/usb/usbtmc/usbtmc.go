@@ -0,0 +1,276 @@
+// Package usbtmc implements the USB Test and Measurement Class (USBTMC), so
+// that an instrument can be controlled with VISA and SCPI tools on the host.
+//
+// Commands sent by the host are returned by Read, complete responses are
+// passed to Write:
+//
+//	tmc := usbtmc.Configure()
+//	buf := make([]byte, 64)
+//	for {
+//		n, _ := tmc.Read(buf)
+//		if n > 0 && string(buf[:n]) == "*IDN?\n" {
+//			tmc.Write([]byte("TinyGo,Instrument,0,1.0\n"))
+//		}
+//	}
+//
+// Only the base USBTMC protocol is implemented (without the USB488 subclass,
+// which needs an interrupt endpoint).
+package usbtmc
+
+import (
+	"errors"
+	"machine"
+	"machine/usb"
+	"machine/usb/vendor"
+	"runtime/interrupt"
+)
+
+var (
+	ErrMessageTooLarge = errors.New("USBTMC message too large")
+)
+
+const (
+	usbtmcSubClass = 0x03
+
+	// Bulk message IDs
+	msgDevDepMsgOut       = 1
+	msgRequestDevDepMsgIn = 2
+	msgDevDepMsgIn        = 2
+
+	headerSize = 12
+	bufferSize = 256
+
+	// Class requests
+	requestInitiateAbortBulkOut    = 1
+	requestCheckAbortBulkOutStatus = 2
+	requestInitiateAbortBulkIn     = 3
+	requestCheckAbortBulkInStatus  = 4
+	requestInitiateClear           = 5
+	requestCheckClearStatus        = 6
+	requestGetCapabilities         = 7
+
+	statusSuccess               = 0x01
+	statusTransferNotInProgress = 0x81
+)
+
+var Port *USBTMC
+
+// USBTMC is a USB Test and Measurement Class interface.
+type USBTMC struct {
+	port *vendor.Vendor
+
+	// message received from the host
+	rx        [bufferSize]byte
+	rxLen     int
+	remaining int
+	eom       bool
+	ready     bool
+	lastTag   uint8
+
+	// response to the host
+	tx       [bufferSize]byte
+	txLen    int
+	txOffset int
+
+	// pending REQUEST_DEV_DEP_MSG_IN
+	requested bool
+	reqTag    uint8
+	reqSize   int
+
+	packet [headerSize + bufferSize + 3]byte
+}
+
+// Configure enables the USBTMC interface. This function must be executed
+// from the init(), before the host enumerates the device.
+func Configure() *USBTMC {
+	if Port != nil {
+		return Port
+	}
+	Port = &USBTMC{}
+	Port.port = vendor.Configure(vendor.Config{
+		Class:    usb.DEVICE_CLASS_APPLICATION,
+		SubClass: usbtmcSubClass,
+	})
+	Port.port.SetHandler(Port.rxHandler)
+	Port.port.SetSetupHandler(Port.setupHandler)
+	return Port
+}
+
+// Read copies the last complete command message sent by the host into data
+// and returns its size. It returns 0 if no message is available.
+func (t *USBTMC) Read(data []byte) (n int, err error) {
+	mask := interrupt.Disable()
+	defer interrupt.Restore(mask)
+	if !t.ready {
+		return 0, nil
+	}
+	n = copy(data, t.rx[:t.rxLen])
+	t.ready = false
+	t.rxLen = 0
+	return n, nil
+}
+
+// Write sets the response to the last command. It is sent as soon as the host
+// requests it.
+func (t *USBTMC) Write(data []byte) (n int, err error) {
+	if len(data) > bufferSize {
+		return 0, ErrMessageTooLarge
+	}
+	mask := interrupt.Disable()
+	defer interrupt.Restore(mask)
+	t.txLen = copy(t.tx[:], data)
+	t.txOffset = 0
+	if t.requested {
+		t.sendResponse()
+	}
+	return len(data), nil
+}
+
+// rxHandler is called from the USB interrupt for every bulk OUT packet.
+func (t *USBTMC) rxHandler(b []byte) {
+	if t.remaining > 0 {
+		// Continuation of a DEV_DEP_MSG_OUT transfer.
+		t.appendData(b)
+		return
+	}
+	if len(b) < headerSize || b[1] != ^b[2] {
+		return
+	}
+	t.lastTag = b[1]
+	switch b[0] {
+	case msgDevDepMsgOut:
+		if t.ready {
+			// The previous message was not read yet, replace it.
+			t.ready = false
+			t.rxLen = 0
+		}
+		t.remaining = int(le32(b[4:]))
+		t.eom = b[8]&0x01 != 0
+		t.appendData(b[headerSize:])
+	case msgRequestDevDepMsgIn:
+		t.requested = true
+		t.reqTag = b[1]
+		t.reqSize = int(le32(b[4:]))
+		if t.txLen > 0 {
+			t.sendResponse()
+		}
+	}
+}
+
+func (t *USBTMC) appendData(b []byte) {
+	if len(b) > t.remaining {
+		// strip alignment bytes
+		b = b[:t.remaining]
+	}
+	t.remaining -= len(b)
+	t.rxLen += copy(t.rx[t.rxLen:], b)
+	if t.remaining == 0 && t.eom {
+		t.ready = true
+	}
+}
+
+// sendResponse sends (part of) the response as a DEV_DEP_MSG_IN transfer.
+func (t *USBTMC) sendResponse() {
+	size := t.txLen - t.txOffset
+	attributes := uint8(0x01) // EOM
+	if size > t.reqSize {
+		size = t.reqSize
+		attributes = 0
+	}
+
+	p := t.packet[:]
+	p[0] = msgDevDepMsgIn
+	p[1] = t.reqTag
+	p[2] = ^t.reqTag
+	p[3] = 0
+	putLE32(p[4:], uint32(size))
+	p[8] = attributes
+	p[9], p[10], p[11] = 0, 0, 0
+	copy(p[headerSize:], t.tx[t.txOffset:t.txOffset+size])
+	length := headerSize + size
+	for length%4 != 0 {
+		p[length] = 0
+		length++
+	}
+	t.port.Write(p[:length])
+	if length%usb.EndpointPacketSize == 0 {
+		// end the transfer with a zero-length packet
+		t.port.Write(nil)
+	}
+
+	t.requested = false
+	t.txOffset += size
+	if t.txOffset == t.txLen {
+		t.txLen = 0
+		t.txOffset = 0
+	}
+}
+
+func (t *USBTMC) setupHandler(setup usb.Setup) bool {
+	if setup.BmRequestType&(usb.REQUEST_DIRECTION|usb.REQUEST_TYPE) != usb.REQUEST_DEVICETOHOST|usb.REQUEST_CLASS {
+		return false
+	}
+	switch setup.BRequest {
+	case requestGetCapabilities:
+		var b [0x18]byte
+		b[0] = statusSuccess
+		b[2] = 0x00 // bcdUSBTMC 1.00
+		b[3] = 0x01
+		machine.SendUSBInPacket(0, b[:])
+		return true
+	case requestInitiateAbortBulkOut:
+		status := uint8(statusTransferNotInProgress)
+		if t.remaining > 0 {
+			status = statusSuccess
+			t.remaining = 0
+			t.rxLen = 0
+		}
+		machine.SendUSBInPacket(0, []byte{status, t.lastTag})
+		return true
+	case requestCheckAbortBulkOutStatus:
+		var b [8]byte
+		b[0] = statusSuccess
+		putLE32(b[4:], uint32(t.rxLen))
+		machine.SendUSBInPacket(0, b[:])
+		return true
+	case requestInitiateAbortBulkIn:
+		status := uint8(statusTransferNotInProgress)
+		if t.txLen > 0 {
+			status = statusSuccess
+			t.txLen = 0
+			t.txOffset = 0
+		}
+		t.requested = false
+		machine.SendUSBInPacket(0, []byte{status, t.reqTag})
+		return true
+	case requestCheckAbortBulkInStatus:
+		var b [8]byte
+		b[0] = statusSuccess
+		machine.SendUSBInPacket(0, b[:])
+		return true
+	case requestInitiateClear:
+		t.remaining = 0
+		t.rxLen = 0
+		t.ready = false
+		t.txLen = 0
+		t.txOffset = 0
+		t.requested = false
+		machine.SendUSBInPacket(0, []byte{statusSuccess})
+		return true
+	case requestCheckClearStatus:
+		machine.SendUSBInPacket(0, []byte{statusSuccess, 0})
+		return true
+	}
+	return false
+}
+
+func le32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+func putLE32(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+}
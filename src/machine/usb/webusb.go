@@ -0,0 +1,66 @@
+package usb
+
+import "strings"
+
+const (
+	// WEBUSB_VENDOR_CODE is the vendor request used by browsers for the
+	// WebUSB requests, like the one for the landing page.
+	WEBUSB_VENDOR_CODE = 0x02
+
+	// WEBUSB_REQUEST_GET_URL is the wIndex of the vendor request for a URL
+	// descriptor, with the index of the URL in wValue.
+	WEBUSB_REQUEST_GET_URL = 0x02
+
+	// WEBUSB_LANDING_PAGE is the index of the URL of the landing page.
+	WEBUSB_LANDING_PAGE = 0x01
+
+	webUSBURLDescriptorType = 0x03
+)
+
+var webUSBEnabled bool
+
+// EnableWebUSB adds the descriptors to DescriptorComposite that let web pages
+// access the vendor-specific interface, which ConfigureVendor must have added,
+// with the WebUSB API. On Windows, the browser also needs the WinUSB driver
+// bound to the interface, see EnableWinUSB.
+// Browsers may suggest to open the landing page when the device is plugged
+// in. It is an "https://" or "http://" URL, or an empty string for no landing
+// page.
+func EnableWebUSB(landingPage string) {
+	webUSBEnabled = true
+	DescriptorComposite.WebUSBURL = nil
+	if landingPage != "" {
+		DescriptorComposite.WebUSBURL = webUSBURLDescriptor(landingPage)
+	}
+	updateBOS()
+}
+
+// appendWebUSBCapability appends the WebUSB platform capability descriptor.
+func appendWebUSBCapability(b []byte) []byte {
+	landingPage := uint8(0)
+	if DescriptorComposite.WebUSBURL != nil {
+		landingPage = WEBUSB_LANDING_PAGE
+	}
+	return append(b,
+		0x18, 0x10, 0x05, 0x00,
+		0x38, 0xb6, 0x08, 0x34, 0xa9, 0x09, 0xa0, 0x47, 0x8b, 0xfd, 0xa0, 0x76, 0x88, 0x15, 0xb6, 0x65,
+		0x00, 0x01, // WebUSB 1.0
+		WEBUSB_VENDOR_CODE,
+		landingPage,
+	)
+}
+
+// webUSBURLDescriptor returns the URL descriptor of url, which stores the
+// scheme as a number and the rest of the URL as UTF-8.
+func webUSBURLDescriptor(url string) []byte {
+	scheme := uint8(0xff) // the scheme is part of the URL
+	switch {
+	case strings.HasPrefix(url, "https://"):
+		scheme, url = 0x01, url[len("https://"):]
+	case strings.HasPrefix(url, "http://"):
+		scheme, url = 0x00, url[len("http://"):]
+	}
+	b := make([]byte, 0, 3+len(url))
+	b = append(b, byte(3+len(url)), webUSBURLDescriptorType, scheme)
+	return append(b, url...)
+}
@@ -0,0 +1,66 @@
+package usb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWebUSBDescriptors(t *testing.T) {
+	device := append([]byte(nil), DescriptorComposite.Device...)
+	defer func() {
+		DescriptorComposite.Device = device
+		DescriptorComposite.BOS = nil
+		DescriptorComposite.MSOS20 = nil
+		DescriptorComposite.WebUSBURL = nil
+		webUSBEnabled = false
+	}()
+
+	EnableWebUSB("https://example.com/app")
+	bos := DescriptorComposite.BOS
+	if len(bos) != 5+24 || bos[2] != byte(len(bos)) || bos[4] != 1 {
+		t.Fatalf("BOS with WebUSB: % x", bos)
+	}
+	if bos[5+22] != WEBUSB_VENDOR_CODE || bos[5+23] != WEBUSB_LANDING_PAGE {
+		t.Errorf("WebUSB capability: % x", bos[5:])
+	}
+	if DescriptorComposite.Device[2] != 0x01 || DescriptorComposite.Device[3] != 0x02 {
+		t.Errorf("bcdUSB: % x", DescriptorComposite.Device[2:4])
+	}
+	want := append([]byte{3 + 15, 0x03, 0x01}, "example.com/app"...)
+	if !bytes.Equal(DescriptorComposite.WebUSBURL, want) {
+		t.Errorf("URL descriptor: % x, want % x", DescriptorComposite.WebUSBURL, want)
+	}
+
+	// Both capabilities are in the BOS descriptor, whatever the order in
+	// which they are enabled.
+	EnableWinUSB(DefaultWinUSBInterfaceGUID)
+	bos = DescriptorComposite.BOS
+	if len(bos) != 5+28+24 || bos[2] != byte(len(bos)) || bos[4] != 2 {
+		t.Fatalf("BOS with WinUSB and WebUSB: % x", bos)
+	}
+	if bos[5+26] != MS_VENDOR_CODE || bos[5+28+22] != WEBUSB_VENDOR_CODE {
+		t.Errorf("vendor codes: % x", bos)
+	}
+
+	EnableWebUSB("")
+	if DescriptorComposite.WebUSBURL != nil || DescriptorComposite.BOS[5+28+23] != 0 {
+		t.Errorf("landing page without a URL: % x", DescriptorComposite.BOS)
+	}
+}
+
+func TestWebUSBURLScheme(t *testing.T) {
+	for _, tc := range []struct {
+		url    string
+		scheme byte
+		rest   string
+	}{
+		{"https://example.com", 0x01, "example.com"},
+		{"http://localhost:8080", 0x00, "localhost:8080"},
+		{"chrome://usb-internals", 0xff, "chrome://usb-internals"},
+	} {
+		b := webUSBURLDescriptor(tc.url)
+		if int(b[0]) != len(b) || b[1] != 0x03 || b[2] != tc.scheme || string(b[3:]) != tc.rest {
+			t.Errorf("%s: % x", tc.url, b)
+		}
+	}
+}
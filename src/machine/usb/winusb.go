@@ -0,0 +1,122 @@
+package usb
+
+const (
+	// MS_VENDOR_CODE is the vendor request used by Windows to retrieve the
+	// Microsoft OS 2.0 descriptor set.
+	MS_VENDOR_CODE = 0x01
+
+	// MS_OS_20_DESCRIPTOR_INDEX is the wIndex of the vendor request for the
+	// Microsoft OS 2.0 descriptor set.
+	MS_OS_20_DESCRIPTOR_INDEX = 0x07
+
+	msOS20SetHeaderLength      = 10
+	msOS20SubsetHeaderLength   = 8
+	msOS20CompatibleIDLength   = 20
+	msOS20RegistryHeaderLength = 10
+)
+
+// DefaultWinUSBInterfaceGUID is the device interface GUID registered by
+// Windows for vendor-specific interfaces bound to WinUSB, unless another GUID
+// is given to EnableWinUSB.
+const DefaultWinUSBInterfaceGUID = "{de82a00f-b6b4-4813-80e9-814867506d72}"
+
+// EnableWinUSB adds the descriptors to DescriptorComposite that make Windows
+// (8.1 and newer) bind the WinUSB driver to the vendor-specific interface,
+// which ConfigureVendor must have added.
+// The interface is registered under the given device interface GUID, in the
+// form "{xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx}".
+func EnableWinUSB(guid string) {
+	DescriptorComposite.MSOS20 = msOS20Descriptor(VendorInterface, guid)
+	updateBOS()
+}
+
+// updateBOS builds the BOS descriptor from the platform capabilities that are
+// enabled: Microsoft OS 2.0 and WebUSB.
+func updateBOS() {
+	// BOS descriptors require USB version 2.01.
+	DescriptorComposite.Device[2] = 0x01
+	DescriptorComposite.Device[3] = 0x02
+
+	b := []byte{0x05, BOS_DESCRIPTOR_TYPE, 0x00, 0x00, 0x00}
+	if msos := DescriptorComposite.MSOS20; msos != nil {
+		// Platform capability descriptor for Microsoft OS 2.0
+		b = append(b,
+			0x1c, 0x10, 0x05, 0x00,
+			0xdf, 0x60, 0xdd, 0xd8, 0x89, 0x45, 0xc7, 0x4c, 0x9c, 0xd2, 0x65, 0x9d, 0x9e, 0x64, 0x8a, 0x9f,
+			0x00, 0x00, 0x03, 0x06, // Windows 8.1
+			byte(len(msos)), byte(len(msos)>>8),
+			MS_VENDOR_CODE,
+			0x00,
+		)
+		b[4]++
+	}
+	if webUSBEnabled {
+		b = appendWebUSBCapability(b)
+		b[4]++
+	}
+	b[2], b[3] = byte(len(b)), byte(len(b)>>8)
+	DescriptorComposite.BOS = b
+}
+
+// msOS20Descriptor returns a Microsoft OS 2.0 descriptor set that assigns the
+// WinUSB driver to the given interface of the first configuration.
+func msOS20Descriptor(iface uint8, guid string) []byte {
+	const propertyName = "DeviceInterfaceGUIDs"
+	nameLength := (len(propertyName) + 1) * 2
+	dataLength := (len(guid) + 2) * 2 // REG_MULTI_SZ: double terminated
+	registryLength := msOS20RegistryHeaderLength + nameLength + dataLength
+	functionLength := msOS20SubsetHeaderLength + msOS20CompatibleIDLength + registryLength
+	configLength := msOS20SubsetHeaderLength + functionLength
+	totalLength := msOS20SetHeaderLength + configLength
+
+	b := make([]byte, 0, totalLength)
+
+	// Descriptor set header
+	b = appendLE16(b, msOS20SetHeaderLength)
+	b = appendLE16(b, 0x0000)
+	b = append(b, 0x00, 0x00, 0x03, 0x06) // Windows 8.1
+	b = appendLE16(b, totalLength)
+
+	// Configuration subset header
+	b = appendLE16(b, msOS20SubsetHeaderLength)
+	b = appendLE16(b, 0x0001)
+	b = append(b, 0x00, 0x00)
+	b = appendLE16(b, configLength)
+
+	// Function subset header
+	b = appendLE16(b, msOS20SubsetHeaderLength)
+	b = appendLE16(b, 0x0002)
+	b = append(b, iface, 0x00)
+	b = appendLE16(b, functionLength)
+
+	// Compatible ID descriptor
+	b = appendLE16(b, msOS20CompatibleIDLength)
+	b = appendLE16(b, 0x0003)
+	b = append(b, 'W', 'I', 'N', 'U', 'S', 'B', 0, 0)
+	b = append(b, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	// Registry property descriptor
+	b = appendLE16(b, registryLength)
+	b = appendLE16(b, 0x0004)
+	b = appendLE16(b, 0x0007) // REG_MULTI_SZ
+	b = appendLE16(b, nameLength)
+	b = appendUTF16LE(b, propertyName)
+	b = append(b, 0, 0)
+	b = appendLE16(b, dataLength)
+	b = appendUTF16LE(b, guid)
+	b = append(b, 0, 0, 0, 0)
+
+	return b
+}
+
+func appendLE16(b []byte, v int) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+// appendUTF16LE appends an ASCII string as UTF-16LE.
+func appendUTF16LE(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		b = append(b, s[i], 0)
+	}
+	return b
+}
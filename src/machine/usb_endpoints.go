@@ -0,0 +1,50 @@
+//go:build (sam || nrf52840 || rp2040) && !usb.cdconly
+// +build sam nrf52840 rp2040
+// +build !usb.cdconly
+
+package machine
+
+import "machine/usb"
+
+// usbEndpointBuffers is the number of endpoints that have a packet buffer in
+// RAM, for each direction: the control endpoint and the endpoints of CDC, HID,
+// MIDI and the vendor interface. Building with the usb.cdconly tag reserves
+// only the buffers of the control and CDC endpoints, and leaves out EnableHID,
+// EnableMIDI, EnableVendor and EnableAudio, so that a program that uses them
+// fails to build.
+const usbEndpointBuffers = 7
+
+// EnableHID enables HID. This function must be executed from the init().
+func EnableHID(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) {
+	iface := usb.AddHID(usb.HIDReportDescriptor)
+	endPoints[usb.HID_ENDPOINT_IN] = (usb.ENDPOINT_TYPE_INTERRUPT | usb.EndpointIn)
+	usbTxHandler[usb.HID_ENDPOINT_IN] = txHandler
+	usbSetupHandler[iface] = setupHandler // 0x03 (HID - Human Interface Device)
+	usbEndpointInterface[usb.HID_ENDPOINT_IN] = iface
+}
+
+// EnableMIDI enables MIDI. This function must be executed from the init().
+func EnableMIDI(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) {
+	iface := usb.AddFunction(usb.MIDIFunction)
+	endPoints[usb.MIDI_ENDPOINT_OUT] = (usb.ENDPOINT_TYPE_BULK | usb.EndpointOut)
+	endPoints[usb.MIDI_ENDPOINT_IN] = (usb.ENDPOINT_TYPE_BULK | usb.EndpointIn)
+	usbRxHandler[usb.MIDI_ENDPOINT_OUT] = rxHandler
+	usbTxHandler[usb.MIDI_ENDPOINT_IN] = txHandler
+	usbEndpointInterface[usb.MIDI_ENDPOINT_OUT] = iface + 1 // MIDI streaming
+	usbEndpointInterface[usb.MIDI_ENDPOINT_IN] = iface + 1
+}
+
+// EnableVendor enables a vendor-specific interface with one OUT and one IN
+// endpoint of the given transfer type (usb.ENDPOINT_TYPE_BULK or
+// usb.ENDPOINT_TYPE_INTERRUPT). Control requests addressed to the interface
+// are passed to setupHandler. The interface must have been added with
+// usb.ConfigureVendor first. This function must be executed from the init().
+func EnableVendor(epType uint8, txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) {
+	endPoints[usb.VENDOR_ENDPOINT_OUT] = (uint32(epType) | usb.EndpointOut)
+	endPoints[usb.VENDOR_ENDPOINT_IN] = (uint32(epType) | usb.EndpointIn)
+	usbRxHandler[usb.VENDOR_ENDPOINT_OUT] = rxHandler
+	usbTxHandler[usb.VENDOR_ENDPOINT_IN] = txHandler
+	usbSetupHandler[usb.VendorInterface] = setupHandler
+	usbEndpointInterface[usb.VENDOR_ENDPOINT_OUT] = usb.VendorInterface
+	usbEndpointInterface[usb.VENDOR_ENDPOINT_IN] = usb.VendorInterface
+}
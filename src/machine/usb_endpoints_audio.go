@@ -0,0 +1,23 @@
+//go:build sam && !usb.cdconly
+// +build sam,!usb.cdconly
+
+package machine
+
+import "machine/usb"
+
+// EnableAudio adds the audio function f, whose second interface streams over
+// an isochronous IN endpoint, usb.AUDIO_ENDPOINT_IN. The host selects the
+// alternate setting 1 of the streaming interface to start the stream and 0 to
+// stop it, which is passed to altHandler; txHandler is then called each time
+// the host has received a packet, once per frame. This function must be
+// executed from the init().
+//
+// Only the USB drivers of the SAMD21 and SAMD51 have isochronous endpoints,
+// so EnableAudio doesn't exist on the other chips.
+func EnableAudio(f usb.Function, txHandler func(), altHandler func(alt uint8)) {
+	iface := usb.AddFunction(f)
+	endPoints[usb.AUDIO_ENDPOINT_IN] = (usb.ENDPOINT_TYPE_ISOCHRONOUS | usb.EndpointIn)
+	usbTxHandler[usb.AUDIO_ENDPOINT_IN] = txHandler
+	usbAltSettingHandler[iface+1] = altHandler
+	usbEndpointInterface[usb.AUDIO_ENDPOINT_IN] = iface + 1
+}
@@ -0,0 +1,15 @@
+//go:build (sam || nrf52840 || rp2040) && usb.cdconly
+// +build sam nrf52840 rp2040
+// +build usb.cdconly
+
+package machine
+
+import "machine/usb"
+
+// usbEndpointBuffers is the number of endpoints that have a packet buffer in
+// RAM, for each direction. The usb.cdconly tag reserves only the buffers of
+// the control and CDC endpoints, which saves 384 bytes of RAM on the SAMD
+// and nRF52840 chips when the device is only a serial port. EnableHID,
+// EnableMIDI, EnableVendor and EnableAudio are left out, so that the packages
+// of HID, MIDI, vendor and audio interfaces fail to build.
+const usbEndpointBuffers = usb.CDC_ENDPOINT_IN + 1
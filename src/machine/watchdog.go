@@ -0,0 +1,45 @@
+package machine
+
+// WatchdogConfig holds the configuration of the watchdog timer.
+type WatchdogConfig struct {
+	// TimeoutMillis is the time after which the watchdog resets the chip,
+	// unless it is fed.
+	TimeoutMillis uint32
+}
+
+var (
+	// watchdogFeed feeds the watchdog, once it has been configured.
+	watchdogFeed func()
+
+	// watchdogLongOperations is the number of WithWatchdogFeed calls running.
+	watchdogLongOperations uint8
+)
+
+// WithWatchdogFeed runs fn, a long operation, and lets the drivers feed the
+// watchdog while they wait for the hardware in the meantime. The drivers that
+// do are SPIFlash while it waits for an erase or a write to finish, the
+// sector erase of the FE310 internal flash, and DMATransfer.Wait on the
+// RP2040. The watchdog is fed once before fn too. Only the RP2040 Watchdog is
+// fed: on other chips, WithWatchdogFeed just runs fn.
+//
+// Drivers only feed the watchdog while a WithWatchdogFeed call runs:
+// elsewhere, a driver that keeps waiting for hardware that has stopped
+// responding is exactly what the watchdog is there to catch. In the meantime,
+// the drivers feed it from any goroutine, not only from the one that runs fn.
+// Long waits of fn outside these drivers don't feed the watchdog.
+func WithWatchdogFeed(fn func()) {
+	if watchdogFeed != nil {
+		watchdogFeed()
+	}
+	watchdogLongOperations++
+	fn()
+	watchdogLongOperations--
+}
+
+// feedWatchdog feeds the watchdog while a WithWatchdogFeed call runs. Drivers
+// call it in the loops that wait for slow hardware.
+func feedWatchdog() {
+	if watchdogLongOperations != 0 && watchdogFeed != nil {
+		watchdogFeed()
+	}
+}
@@ -15,6 +15,17 @@ func fastrand() uint32 {
 
 var xorshift32State uint32 = 1
 
+// seedRand mixes entropy into the state of fastrand, so that map iteration
+// order and hash seeds differ between boots. Package machine calls it at
+// startup on targets where it can collect entropy.
+func seedRand(seed uint32) {
+	xorshift32State ^= seed
+	if xorshift32State == 0 {
+		// Xorshift never leaves the zero state.
+		xorshift32State = 1
+	}
+}
+
 func xorshift32(x uint32) uint32 {
 	// Algorithm "xor" from p. 4 of Marsaglia, "Xorshift RNGs".
 	// Improved sequence based on
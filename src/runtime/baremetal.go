@@ -64,14 +64,47 @@ const baremetal = true
 // been set.
 var timeOffset int64
 
+// timeDrift corrects the rate of the wall clock relative to the monotonic
+// clock, in parts per billion, from timeDriftStart on. It is set by package
+// machine when synchronizing the time.
+var timeDrift, timeDriftStart int64
+
 //go:linkname now time.now
 func now() (sec int64, nsec int32, mono int64) {
 	mono = nanotime()
-	sec = (mono + timeOffset) / (1000 * 1000 * 1000)
-	nsec = int32((mono + timeOffset) - sec*(1000*1000*1000))
+	wall := wallTime(mono)
+	sec = wall / (1000 * 1000 * 1000)
+	nsec = int32(wall - sec*(1000*1000*1000))
 	return
 }
 
+// wallTime returns the wall clock time in nanoseconds since the Unix epoch for
+// the given monotonic time.
+func wallTime(mono int64) int64 {
+	wall := mono + timeOffset
+	if timeDrift != 0 {
+		// Correct whole seconds and the nanoseconds left separately, as the
+		// product of nanoseconds and the drift would overflow after a few
+		// months.
+		elapsed := mono - timeDriftStart
+		sec := elapsed / (1000 * 1000 * 1000)
+		wall += sec*timeDrift + (elapsed-sec*(1000*1000*1000))*timeDrift/(1000*1000*1000)
+	}
+	return wall
+}
+
+// setTime sets the wall clock to unixNano and its rate correction to drift
+// parts per billion, and returns the wall clock time just before the change.
+// It is used by package machine.
+func setTime(unixNano, drift int64) (old int64) {
+	mono := nanotime()
+	old = wallTime(mono)
+	timeOffset = unixNano - mono
+	timeDrift = drift
+	timeDriftStart = mono
+	return old
+}
+
 // AdjustTimeOffset adds the given offset to the built-in time offset. A
 // positive value adds to the time (skipping some time), a negative value moves
 // the clock into the past.
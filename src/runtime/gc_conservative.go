@@ -56,6 +56,8 @@ var (
 	gcTotalAlloc  uint64         // total number of bytes allocated
 	gcMallocs     uint64         // total number of allocations
 	gcFrees       uint64         // total number of objects freed
+	gcInuse       uintptr        // bytes in blocks that are in use
+	gcPeakInuse   uintptr        // largest value of gcInuse so far
 )
 
 // zeroSizedAlloc is just a sentinel that gets returned when allocating 0 bytes.
@@ -338,6 +340,10 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 			// Found a big enough range of free blocks!
 			nextAlloc = index
 			thisAlloc := index - gcBlock(neededBlocks)
+			gcInuse += neededBlocks * bytesPerBlock
+			if gcInuse > gcPeakInuse {
+				gcPeakInuse = gcInuse
+			}
 			if gcDebug {
 				println("found memory:", thisAlloc.pointer(), int(size))
 			}
@@ -382,6 +388,12 @@ func free(ptr unsafe.Pointer) {
 	// TODO: free blocks on request, when the compiler knows they're unused.
 }
 
+// peakHeapInuse returns the largest number of heap bytes in use since the
+// program started, for machine.PeakHeapUsage.
+func peakHeapInuse() uintptr {
+	return gcPeakInuse
+}
+
 // GC performs a garbage collection cycle.
 func GC() {
 	runGC()
@@ -433,6 +445,7 @@ func runGC() (freeBytes uintptr) {
 	// Sweep phase: free all non-marked objects and unmark marked objects for
 	// the next collection cycle.
 	freeBytes = sweep()
+	gcInuse = uintptr(endBlock)*bytesPerBlock - freeBytes
 
 	// Show how much has been sweeped, for debugging.
 	if gcDebug {
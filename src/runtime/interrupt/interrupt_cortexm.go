@@ -51,3 +51,17 @@ func Disable() (state State) {
 func Restore(state State) {
 	arm.EnableInterrupts(uintptr(state))
 }
+
+// In returns whether the system is currently in an interrupt.
+func In() bool {
+	// The IPSR register holds the number of the exception being handled, or
+	// zero in thread mode.
+	return arm.AsmFull("mrs {}, IPSR", nil) != 0
+}
+
+// Disabled returns whether interrupts are disabled, as they are between
+// Disable and Restore, so that no interrupt handler can run until they are
+// enabled again.
+func Disabled() bool {
+	return arm.AsmFull("mrs {}, PRIMASK", nil)&1 != 0
+}
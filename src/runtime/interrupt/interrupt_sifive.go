@@ -5,15 +5,46 @@ package interrupt
 
 import "device/sifive"
 
+// The PLIC of the FE310 implements priority levels 1 (lowest) to 7 (highest),
+// where level 0 never interrupts. To make drivers portable, the priority
+// parameters below follow the Cortex-M convention instead and are mapped onto
+// these levels.
+const plicMaxLevel = 7
+
 // Enable enables this interrupt. Right after calling this function, the
 // interrupt may be invoked if it was already pending.
 func (irq Interrupt) Enable() {
 	sifive.PLIC.ENABLE[irq.num/32].SetBits(1 << (uint(irq.num) % 32))
 }
 
-// SetPriority sets the interrupt priority for this interrupt. A higher priority
-// number means a higher priority (unlike Cortex-M). Priority 0 effectively
-// disables the interrupt.
+// Disable disables this interrupt.
+func (irq Interrupt) Disable() {
+	sifive.PLIC.ENABLE[irq.num/32].ClearBits(1 << (uint(irq.num) % 32))
+}
+
+// SetPriority sets the interrupt priority for this interrupt. Like on Cortex-M,
+// a lower number means a higher priority. The PLIC only implements 7 levels,
+// so several priority numbers share a level.
+//
+// Examples: 0xff (lowest priority), 0xc0 (low priority), 0x00 (highest possible
+// priority).
 func (irq Interrupt) SetPriority(priority uint8) {
-	sifive.PLIC.PRIORITY[irq.num].Set(uint32(priority))
+	sifive.PLIC.PRIORITY[irq.num].Set(plicLevel(priority))
+}
+
+// SetPriorityThreshold masks all interrupts with the given priority or a lower
+// priority (a priority number that is equal or higher), similar to BASEPRI on
+// Cortex-M. A threshold of 0 disables masking.
+func SetPriorityThreshold(priority uint8) {
+	if priority == 0 {
+		sifive.PLIC.THRESHOLD.Set(0)
+		return
+	}
+	sifive.PLIC.THRESHOLD.Set(plicLevel(priority))
+}
+
+// plicLevel maps a Cortex-M style priority number onto a PLIC level, from 7
+// for 0x00 to 1 for 0xff.
+func plicLevel(priority uint8) uint32 {
+	return plicMaxLevel - uint32(priority)*plicMaxLevel/256
 }
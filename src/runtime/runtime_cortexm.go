@@ -22,6 +22,15 @@ var _sidata [0]byte
 //go:extern _edata
 var _edata [0]byte
 
+//go:extern _sramfunc
+var _sramfunc [0]byte
+
+//go:extern _siramfunc
+var _siramfunc [0]byte
+
+//go:extern _eramfunc
+var _eramfunc [0]byte
+
 func preinit() {
 	// Initialize .bss: zero-initialized global variables.
 	ptr := unsafe.Pointer(&_sbss)
@@ -38,6 +47,15 @@ func preinit() {
 		dst = unsafe.Pointer(uintptr(dst) + 4)
 		src = unsafe.Pointer(uintptr(src) + 4)
 	}
+
+	// Copy the functions that run from RAM.
+	src = unsafe.Pointer(&_siramfunc)
+	dst = unsafe.Pointer(&_sramfunc)
+	for dst != unsafe.Pointer(&_eramfunc) {
+		*(*uint32)(dst) = *(*uint32)(src)
+		dst = unsafe.Pointer(uintptr(dst) + 4)
+		src = unsafe.Pointer(uintptr(src) + 4)
+	}
 }
 
 // The stack layout at the moment an interrupt occurs.
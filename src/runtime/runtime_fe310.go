@@ -23,6 +23,13 @@ func main() {
 	sifive.PLIC.ENABLE[0].Set(0)
 	sifive.PLIC.ENABLE[1].Set(0)
 
+	// Give all interrupts the highest priority, like on Cortex-M, so that an
+	// interrupt fires when enabled even if its priority was never set. The
+	// priorities are not reset either.
+	for i := range sifive.PLIC.PRIORITY {
+		sifive.PLIC.PRIORITY[i].Set(7)
+	}
+
 	// Zero the threshold value to allow all priorities of interrupts.
 	sifive.PLIC.THRESHOLD.Set(0)
 
@@ -47,13 +54,27 @@ func main() {
 //go:extern handleInterruptASM
 var handleInterruptASM [0]uintptr
 
+// machineSoftwareInterrupt is provided by package machine.
+func machineSoftwareInterrupt()
+
+// handleInterrupt dispatches the traps. It runs from RAM, as fetching it from
+// the SPI flash after an instruction cache miss takes several microseconds,
+// which adds up to the latency of every interrupt. Only the functions marked
+// with //go:section .ramfunc.<name> are in RAM: the interrupt handlers that
+// aren't marked, and the functions that the marked ones call, still run from
+// flash.
+//
 //export handleInterrupt
+//go:section .ramfunc.handleInterrupt
+//go:noinline
 func handleInterrupt() {
 	cause := riscv.MCAUSE.Get()
 	code := uint(cause &^ (1 << 31))
 	if cause&(1<<31) != 0 {
 		// Topmost bit is set, which means that it is an interrupt.
 		switch code {
+		case 3: // Machine software interrupt
+			machineSoftwareInterrupt()
 		case 7: // Machine timer interrupt
 			// Signal timeout.
 			timerWakeup.Set(1)
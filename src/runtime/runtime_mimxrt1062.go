@@ -29,7 +29,7 @@ func main() {
 	// configure core and peripheral clocks/PLLs/PFDs
 	initClocks()
 
-	// copy data/bss sections from flash to RAM
+	// copy data/bss sections and RAM functions from flash to RAM
 	preinit()
 
 	// initialize cache and MPU
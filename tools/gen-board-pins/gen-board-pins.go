@@ -0,0 +1,298 @@
+// gen-board-pins generates the pin constants of a board file from a JSON pin
+// map, and checks the map for common mistakes: duplicate names, references to
+// pins that don't exist, and several board pins wired to the same chip pin
+// without that being marked as intended.
+//
+// Usage:
+//
+//	gen-board-pins targets/pins/hifive1b.json src/machine/board_hifive1b_pins.go [src/machine/machine_fe310_pins.go]
+//
+// The pin map looks like this:
+//
+//	{
+//		"build-tags": "hifive1b",
+//		"chip-pins": "^P[0-9]{2}$",
+//		"groups": [
+//			{
+//				"comment": "LEDs",
+//				"pins": [
+//					{"name": "LED", "pin": "LED_RED"},
+//					{"name": "LED_RED", "pin": "P22"}
+//				]
+//			}
+//		]
+//	}
+//
+// A pin refers to another board pin, to a chip pin matching the chip-pins
+// pattern, or to NoPin. Set "shared" on a pin that deliberately uses the same
+// chip pin as another one.
+//
+// The map may also list which chip pins support a peripheral function, for
+// chips where the functions are fixed to some pins, and the functions that a
+// board pin is meant for. A board pin whose chip pin doesn't support one of
+// its functions is reported as an error, so a wrong pin is caught when the
+// constants are generated rather than when a driver silently doesn't work:
+//
+//	"chip-functions": {
+//		"PWM": ["P19", "P21", "P22"]
+//	},
+//	...
+//		{"name": "LED_RED", "pin": "P22", "functions": ["PWM"]}
+//
+// With a third argument, the chip functions are also written to a file for the
+// chip, so that the chip code checks its pins against the same table. The file
+// has the chip-build-tags, and for each function a bit mask of the pin numbers
+// (the digits at the end of the chip pin names) named after chip-prefix, like
+// fe310PWMPins, as well as the pin itself for a function on a single pin, like
+// fe310SPI1SCK:
+//
+//	"chip-build-tags": "fe310",
+//	"chip-prefix": "fe310",
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type pinMap struct {
+	BuildTags     string              `json:"build-tags"`
+	ChipPins      string              `json:"chip-pins"`
+	ChipFunctions map[string][]string `json:"chip-functions"`
+	ChipBuildTags string              `json:"chip-build-tags"`
+	ChipPrefix    string              `json:"chip-prefix"`
+	Groups        []group             `json:"groups"`
+}
+
+type group struct {
+	Comment string `json:"comment"`
+	Pins    []pin  `json:"pins"`
+}
+
+type pin struct {
+	Name      string   `json:"name"`
+	Pin       string   `json:"pin"`
+	Comment   string   `json:"comment"`
+	Shared    bool     `json:"shared"`
+	Functions []string `json:"functions"`
+}
+
+func main() {
+	if len(os.Args) != 3 && len(os.Args) != 4 {
+		fmt.Fprintln(os.Stderr, "usage: gen-board-pins <pins.json> <board_pins.go> [<chip_pins.go>]")
+		os.Exit(1)
+	}
+	chipPath := ""
+	if len(os.Args) == 4 {
+		chipPath = os.Args[3]
+	}
+	err := generate(os.Args[1], os.Args[2], chipPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func generate(inPath, outPath, chipPath string) error {
+	data, err := os.ReadFile(inPath)
+	if err != nil {
+		return err
+	}
+	var m pinMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("%s: %w", inPath, err)
+	}
+	if err := m.check(); err != nil {
+		return fmt.Errorf("%s: %w", inPath, err)
+	}
+	src, err := m.generate(inPath)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outPath, src, 0666); err != nil {
+		return err
+	}
+	if chipPath == "" {
+		return nil
+	}
+	src, err = m.generateChip(inPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", inPath, err)
+	}
+	return os.WriteFile(chipPath, src, 0666)
+}
+
+// check validates the pin map.
+func (m *pinMap) check() error {
+	if m.BuildTags == "" {
+		return errors.New("build-tags is not set")
+	}
+	chipPin, err := regexp.Compile(m.ChipPins)
+	if err != nil || m.ChipPins == "" {
+		return fmt.Errorf("invalid chip-pins pattern %q", m.ChipPins)
+	}
+
+	// Collect all board pins.
+	pins := make(map[string]pin)
+	for _, g := range m.Groups {
+		for _, p := range g.Pins {
+			if !token.IsIdentifier(p.Name) || !token.IsExported(p.Name) {
+				return fmt.Errorf("%s: not an exported Go identifier", p.Name)
+			}
+			if _, ok := pins[p.Name]; ok {
+				return fmt.Errorf("%s: defined twice", p.Name)
+			}
+			if chipPin.MatchString(p.Name) {
+				return fmt.Errorf("%s: name of a chip pin", p.Name)
+			}
+			pins[p.Name] = p
+		}
+	}
+
+	// Resolve every pin to a chip pin, and check that each chip pin is used
+	// by one pin only, not counting names that refer to other board pins.
+	users := make(map[string]string)
+	for _, g := range m.Groups {
+		for _, p := range g.Pins {
+			target, err := resolve(pins, chipPin, p.Name)
+			if err != nil {
+				return err
+			}
+			if err := m.checkFunctions(p, target); err != nil {
+				return err
+			}
+			if target == "NoPin" || !chipPin.MatchString(p.Pin) {
+				continue
+			}
+			if other, ok := users[target]; ok && !p.Shared && !pins[other].Shared {
+				return fmt.Errorf("%s: uses chip pin %s like %s (set \"shared\" if intended)", p.Name, target, other)
+			}
+			users[target] = p.Name
+		}
+	}
+	return nil
+}
+
+// checkFunctions checks that the chip pin target of p supports the functions
+// of p.
+func (m *pinMap) checkFunctions(p pin, target string) error {
+	for _, f := range p.Functions {
+		chipPins, ok := m.ChipFunctions[f]
+		if !ok {
+			return fmt.Errorf("%s: unknown function %s", p.Name, f)
+		}
+		supported := false
+		for _, c := range chipPins {
+			if c == target {
+				supported = true
+			}
+		}
+		if !supported {
+			return fmt.Errorf("%s: chip pin %s doesn't support %s (only %s do)", p.Name, target, f, strings.Join(chipPins, ", "))
+		}
+	}
+	return nil
+}
+
+// resolve follows references between board pins down to a chip pin or NoPin.
+func resolve(pins map[string]pin, chipPin *regexp.Regexp, name string) (string, error) {
+	seen := make(map[string]bool)
+	for {
+		if seen[name] {
+			return "", fmt.Errorf("%s: circular reference", name)
+		}
+		seen[name] = true
+		p := pins[name]
+		switch {
+		case p.Pin == "NoPin" || chipPin.MatchString(p.Pin):
+			return p.Pin, nil
+		case p.Pin == "":
+			return "", fmt.Errorf("%s: no pin set", name)
+		}
+		if _, ok := pins[p.Pin]; !ok {
+			return "", fmt.Errorf("%s: unknown pin %s", name, p.Pin)
+		}
+		name = p.Pin
+	}
+}
+
+// generate returns the formatted Go source file with the pin constants.
+func (m *pinMap) generate(inPath string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "//go:build %s\n", m.BuildTags)
+	fmt.Fprintf(buf, "// +build %s\n\n", strings.ReplaceAll(strings.ReplaceAll(m.BuildTags, " || ", " "), " && ", ","))
+	fmt.Fprintf(buf, "// Code generated by gen-board-pins from %s. DO NOT EDIT.\n\n", inPath)
+	fmt.Fprintf(buf, "package machine\n")
+	for _, g := range m.Groups {
+		fmt.Fprintf(buf, "\n")
+		if g.Comment != "" {
+			fmt.Fprintf(buf, "// %s\n", g.Comment)
+		}
+		fmt.Fprintf(buf, "const (\n")
+		for _, p := range g.Pins {
+			if p.Comment != "" {
+				fmt.Fprintf(buf, "\t%s = %s // %s\n", p.Name, p.Pin, p.Comment)
+			} else {
+				fmt.Fprintf(buf, "\t%s = %s\n", p.Name, p.Pin)
+			}
+		}
+		fmt.Fprintf(buf, ")\n")
+	}
+	return format.Source(buf.Bytes())
+}
+
+// chipPinNumber matches the number at the end of a chip pin name.
+var chipPinNumber = regexp.MustCompile("[0-9]+$")
+
+// generateChip returns the formatted Go source file with the pins of the chip
+// functions.
+func (m *pinMap) generateChip(inPath string) ([]byte, error) {
+	if m.ChipBuildTags == "" || !token.IsIdentifier(m.ChipPrefix) {
+		return nil, errors.New("chip-build-tags or chip-prefix is not set")
+	}
+	var functions []string
+	for f := range m.ChipFunctions {
+		functions = append(functions, f)
+	}
+	sort.Strings(functions)
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "//go:build %s\n", m.ChipBuildTags)
+	fmt.Fprintf(buf, "// +build %s\n\n", strings.ReplaceAll(strings.ReplaceAll(m.ChipBuildTags, " || ", " "), " && ", ","))
+	fmt.Fprintf(buf, "// Code generated by gen-board-pins from %s. DO NOT EDIT.\n\n", inPath)
+	fmt.Fprintf(buf, "package machine\n\n")
+	fmt.Fprintf(buf, "// The chip pins of each peripheral function, as a bit mask of the pin\n")
+	fmt.Fprintf(buf, "// numbers, and the pin of a function that has a single one.\n")
+	fmt.Fprintf(buf, "const (\n")
+	for _, f := range functions {
+		name := m.ChipPrefix + strings.ReplaceAll(f, "_", "")
+		if !token.IsIdentifier(name) {
+			return nil, fmt.Errorf("%s: function name doesn't make a Go identifier", f)
+		}
+		var mask uint64
+		var number int
+		for _, c := range m.ChipFunctions[f] {
+			var err error
+			number, err = strconv.Atoi(chipPinNumber.FindString(c))
+			if err != nil || number >= 64 {
+				return nil, fmt.Errorf("%s: chip pin %s has no pin number", f, c)
+			}
+			mask |= 1 << number
+		}
+		fmt.Fprintf(buf, "\t%sPins = %#x // %s\n", name, mask, strings.Join(m.ChipFunctions[f], ", "))
+		if len(m.ChipFunctions[f]) == 1 {
+			fmt.Fprintf(buf, "\t%s Pin = %d\n", name, number)
+		}
+	}
+	fmt.Fprintf(buf, ")\n")
+	return format.Source(buf.Bytes())
+}